@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/pghq/go-tea/trail"
+
+	"github.com/pghq/go-store/internal/encode"
+	"github.com/pghq/go-store/provider"
+)
+
+// auditUserKey the context key of the user recorded in the audit log
+type auditUserKey struct{}
+
+// AuditUserKey the context key of the user recorded as changed_by in the audit log (e.g., context.WithValue(ctx, store.AuditUserKey, "user:1234"))
+var AuditUserKey = auditUserKey{}
+
+// auditing checks if the write should be audited within a transaction started for it
+func (s Store) auditing(ctx context.Context) bool {
+	_, ok := ctx.Value(contextKey{}).(Txn)
+	return s.conf.AuditTable != "" && !ok
+}
+
+// audited gets the json of the values matching the spec before they are written, if audited
+func (s Store) audited(ctx context.Context, collection string, spec provider.Spec) ([]string, error) {
+	if s.conf.AuditTable == "" {
+		return nil, nil
+	}
+
+	query := squirrel.Select("to_jsonb(audited)::text").
+		From(fmt.Sprintf("%s AS audited", collection)).
+		Where(spec).
+		Suffix("FOR UPDATE")
+
+	var values []string
+	if err := s.repository(ctx).All(ctx, provider.NewSpec(nil, query), &values); err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	return values, nil
+}
+
+// audit records the change of each value written in the audit log, if audited
+// edited values are recorded as the values before the edit with the edited columns
+func (s Store) audit(ctx context.Context, op WriteOp, collection string, old []string, v interface{}, opts ...provider.WriteOption) error {
+	if s.conf.AuditTable == "" {
+		return nil
+	}
+
+	var data map[string]interface{}
+	if v != nil {
+		var skip []string
+		if op == WriteEdit {
+			skip = append(skip, "readonly")
+		}
+
+		var err error
+		if data, err = encode.Map(v, skip...); err != nil {
+			return trail.Stacktrace(err)
+		}
+
+		conf := provider.WriteConfig{}
+		for _, opt := range opts {
+			opt(&conf)
+		}
+
+		data = conf.Filter(data)
+	}
+
+	var changedBy interface{}
+	if user := ctx.Value(AuditUserKey); user != nil {
+		changedBy = fmt.Sprint(user)
+	}
+
+	entry := func(oldData, newData interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"operation":  string(op),
+			"table_name": collection,
+			"old_data":   oldData,
+			"new_data":   newData,
+			"changed_at": time.Now().UTC(),
+			"changed_by": changedBy,
+		}
+	}
+
+	var entries []map[string]interface{}
+	switch op {
+	case WriteAdd:
+		value, err := json.Marshal(data)
+		if err != nil {
+			return trail.Stacktrace(err)
+		}
+
+		entries = append(entries, entry(nil, string(value)))
+	case WriteEdit:
+		for _, before := range old {
+			after := make(map[string]interface{})
+			if err := json.Unmarshal([]byte(before), &after); err != nil {
+				return trail.Stacktrace(err)
+			}
+
+			for key, value := range data {
+				after[key] = value
+			}
+
+			value, err := json.Marshal(after)
+			if err != nil {
+				return trail.Stacktrace(err)
+			}
+
+			entries = append(entries, entry(before, string(value)))
+		}
+	case WriteRemove:
+		for _, before := range old {
+			entries = append(entries, entry(before, nil))
+		}
+	}
+
+	for _, e := range entries {
+		if err := s.repository(ctx).Add(ctx, s.conf.AuditTable, e); err != nil {
+			return trail.Stacktrace(err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithAuditLog(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	s, err := New(WithDSN(dsn), WithAuditLog("audit_log"))
+	assert.Nil(t, err)
+
+	type entry struct {
+		Operation string  `db:"operation"`
+		OldData   *string `db:"old_data"`
+		NewData   *string `db:"new_data"`
+		ChangedBy *string `db:"changed_by"`
+	}
+
+	entries := func(id string) []entry {
+		var entries []entry
+		assert.Nil(t, store.All(context.TODO(), spec("SELECT operation, old_data::text, new_data::text, changed_by FROM audit_log WHERE table_name = 'tests' AND COALESCE(old_data, new_data)->>'id' = '"+id+"' ORDER BY id"), &entries))
+		return entries
+	}
+
+	t.Run("edit", func(t *testing.T) {
+		ctx := context.WithValue(context.TODO(), AuditUserKey, "user:1234")
+		assert.Nil(t, s.Add(ctx, "tests", map[string]interface{}{"id": "audit:edit", "num": 1}))
+		assert.Nil(t, s.Edit(ctx, "tests", spec("id = 'audit:edit'"), map[string]interface{}{"num": 2}))
+
+		e := entries("audit:edit")
+		assert.Len(t, e, 2)
+		assert.Equal(t, "add", e[0].Operation)
+		assert.Nil(t, e[0].OldData)
+		assert.JSONEq(t, `{"id": "audit:edit", "num": 1}`, *e[0].NewData)
+
+		assert.Equal(t, "edit", e[1].Operation)
+		assert.JSONEq(t, `{"id": "audit:edit", "name": null, "num": 1, "deleted_at": null, "data": null}`, *e[1].OldData)
+		assert.JSONEq(t, `{"id": "audit:edit", "name": null, "num": 2, "deleted_at": null, "data": null}`, *e[1].NewData)
+		assert.Equal(t, "user:1234", *e[1].ChangedBy)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		assert.Nil(t, s.Add(context.TODO(), "tests", map[string]interface{}{"id": "audit:remove"}))
+		assert.Nil(t, s.Remove(context.TODO(), "tests", spec("id = 'audit:remove'")))
+
+		e := entries("audit:remove")
+		assert.Len(t, e, 2)
+		assert.Equal(t, "remove", e[1].Operation)
+		assert.NotNil(t, e[1].OldData)
+		assert.Nil(t, e[1].NewData)
+		assert.Nil(t, e[1].ChangedBy)
+	})
+
+	t.Run("rolled back", func(t *testing.T) {
+		assert.NotNil(t, s.Do(context.TODO(), func(tx Txn) error {
+			if err := tx.Add("tests", map[string]interface{}{"id": "audit:rollback"}); err != nil {
+				return err
+			}
+
+			return trail.NewError("rollback")
+		}))
+		assert.Empty(t, entries("audit:rollback"))
+	})
+}
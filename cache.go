@@ -0,0 +1,77 @@
+package store
+
+import (
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+)
+
+// Cache a store for query results shared across transactions
+// keys are spec ids or names given with WithCacheKey
+type Cache interface {
+	Get(key interface{}) (interface{}, bool)
+	Set(key, v interface{}, ttl time.Duration)
+	Delete(keys ...interface{})
+	Clear()
+}
+
+// memoryCache an in-process cache
+type memoryCache struct {
+	*ristretto.Cache
+}
+
+func (c memoryCache) Set(key, v interface{}, ttl time.Duration) {
+	c.SetWithTTL(key, v, 1, ttl)
+}
+
+func (c memoryCache) Delete(keys ...interface{}) {
+	for _, key := range keys {
+		c.Del(key)
+	}
+}
+
+// newMemoryCache creates a new in-process cache
+func newMemoryCache() memoryCache {
+	cache, _ := ristretto.NewCache(&ristretto.Config{
+		NumCounters: 1e7,
+		MaxCost:     1 << 30,
+		BufferItems: 64,
+	})
+
+	return memoryCache{Cache: cache}
+}
+
+// timedCache expires values for external caches that do not honor ttls
+type timedCache struct {
+	Cache
+}
+
+// timedValue a cached value and its expiry
+type timedValue struct {
+	value   interface{}
+	expires time.Time
+}
+
+func (c timedCache) Get(key interface{}) (interface{}, bool) {
+	v, present := c.Cache.Get(key)
+	if !present {
+		return nil, false
+	}
+
+	tv, ok := v.(timedValue)
+	if !ok || (!tv.expires.IsZero() && time.Now().After(tv.expires)) {
+		c.Cache.Delete(key)
+		return nil, false
+	}
+
+	return tv.value, true
+}
+
+func (c timedCache) Set(key, v interface{}, ttl time.Duration) {
+	tv := timedValue{value: v}
+	if ttl > 0 {
+		tv.expires = time.Now().Add(ttl)
+	}
+
+	c.Cache.Set(key, tv, ttl)
+}
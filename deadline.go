@@ -0,0 +1,218 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/pghq/go-tea/trail"
+
+	"github.com/pghq/go-store/provider"
+)
+
+// deadlineSavepoint the savepoint each operation with a deadline runs under
+const deadlineSavepoint = "deadline"
+
+// deadlineUnitOfWork a unit of work whose repository runs each operation under a savepoint bounded by the context deadline
+type deadlineUnitOfWork struct {
+	provider.UnitOfWork
+	parent context.Context
+}
+
+func (u deadlineUnitOfWork) Repository() provider.Repository {
+	return deadlineRepository{uow: u.UnitOfWork, parent: u.parent}
+}
+
+// deadlineRepository a repository limiting each operation to the context deadline with statement_timeout (pg only)
+// statements run with the transaction context, as pgx closes the connection when a context expires mid-statement,
+// and an operation exceeding the deadline is rolled back to its savepoint, leaving the transaction usable
+type deadlineRepository struct {
+	uow    provider.UnitOfWork
+	parent context.Context
+}
+
+// do runs the function in a savepoint, rolled back if it fails
+func (r deadlineRepository) do(ctx context.Context, fn func(repo provider.Repository) error) error {
+	sp, timeout, err := r.begin(ctx)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	return r.end(ctx, sp, timeout, fn(sp.Repository()))
+}
+
+// begin opens a savepoint with the statement timeout set to the context deadline, returning the previous timeout
+func (r deadlineRepository) begin(ctx context.Context) (provider.UnitOfWork, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", trail.Stacktrace(err)
+	}
+
+	sp, err := r.uow.Savepoint(r.parent, deadlineSavepoint)
+	if err != nil {
+		return nil, "", trail.Stacktrace(err)
+	}
+
+	var timeout string
+	spec := provider.NewSpec(nil, squirrel.Expr("SELECT current_setting('statement_timeout')"))
+	if err := sp.Repository().One(r.parent, spec, &timeout); err != nil {
+		sp.Rollback(r.parent)
+		return nil, "", trail.Stacktrace(err)
+	}
+
+	// statements are ended by postgres just after the deadline, rather than by the context
+	if deadline, ok := ctx.Deadline(); ok {
+		ms := time.Until(deadline).Milliseconds() + 1
+		if ms < 1 {
+			ms = 1
+		}
+
+		if err := setStatementTimeout(r.parent, sp, strconv.FormatInt(ms, 10)); err != nil {
+			sp.Rollback(r.parent)
+			return nil, "", trail.Stacktrace(err)
+		}
+	}
+
+	return sp, timeout, nil
+}
+
+// end releases the savepoint restoring the previous timeout, or rolls back to it if the operation failed
+func (r deadlineRepository) end(ctx context.Context, sp provider.UnitOfWork, timeout string, err error) error {
+	if err != nil {
+		sp.Rollback(r.parent)
+		if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+			return trail.Stacktrace(context.DeadlineExceeded)
+		}
+
+		return err
+	}
+
+	if err := setStatementTimeout(r.parent, sp, timeout); err != nil {
+		sp.Rollback(r.parent)
+		return trail.Stacktrace(err)
+	}
+
+	return trail.Stacktrace(sp.Commit(r.parent))
+}
+
+func (r deadlineRepository) One(ctx context.Context, spec provider.Spec, v interface{}) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		return repo.One(r.parent, spec, v)
+	})
+}
+
+func (r deadlineRepository) All(ctx context.Context, spec provider.Spec, v interface{}) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		return repo.All(r.parent, spec, v)
+	})
+}
+
+// Scan the deadline includes iterating over the rows, and the savepoint is released when they are closed
+func (r deadlineRepository) Scan(ctx context.Context, spec provider.Spec) (provider.Rows, error) {
+	sp, timeout, err := r.begin(ctx)
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	rows, err := sp.Repository().Scan(r.parent, spec)
+	if err != nil {
+		return nil, r.end(ctx, sp, timeout, err)
+	}
+
+	return deadlineRows{Rows: rows, ctx: ctx, repo: r, sp: sp, timeout: timeout}, nil
+}
+
+func (r deadlineRepository) Add(ctx context.Context, collection string, v interface{}, opts ...provider.WriteOption) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		return repo.Add(r.parent, collection, v, opts...)
+	})
+}
+
+func (r deadlineRepository) Edit(ctx context.Context, collection string, spec provider.Spec, v interface{}, opts ...provider.WriteOption) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		return repo.Edit(r.parent, collection, spec, v, opts...)
+	})
+}
+
+func (r deadlineRepository) Upsert(ctx context.Context, collection string, v interface{}, conflict []string) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		return repo.Upsert(r.parent, collection, v, conflict)
+	})
+}
+
+func (r deadlineRepository) Merge(ctx context.Context, collection string, v interface{}, match []string, insertDefaults map[string]interface{}, update []string) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		m, ok := repo.(provider.Merger)
+		if !ok {
+			return trail.NewErrorf("repository %T does not support merge", repo)
+		}
+
+		return m.Merge(r.parent, collection, v, match, insertDefaults, update)
+	})
+}
+
+func (r deadlineRepository) AddBatch(ctx context.Context, collection string, values []interface{}) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		b, ok := repo.(provider.BatchAdder)
+		if !ok {
+			return trail.NewErrorf("repository %T does not support batch adds", repo)
+		}
+
+		return b.AddBatch(r.parent, collection, values)
+	})
+}
+
+func (r deadlineRepository) Remove(ctx context.Context, collection string, spec provider.Spec, opts ...provider.WriteOption) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		return repo.Remove(r.parent, collection, spec, opts...)
+	})
+}
+
+func (r deadlineRepository) BatchQuery(ctx context.Context, query provider.BatchQuery) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		return repo.BatchQuery(r.parent, query)
+	})
+}
+
+func (r deadlineRepository) BatchExec(ctx context.Context, exec provider.BatchExec) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		return repo.BatchExec(r.parent, exec)
+	})
+}
+
+func (r deadlineRepository) CopyFrom(ctx context.Context, collection string, columns []string, rows [][]interface{}) (int64, error) {
+	var n int64
+	err := r.do(ctx, func(repo provider.Repository) error {
+		var err error
+		n, err = repo.CopyFrom(r.parent, collection, columns, rows)
+		return err
+	})
+
+	return n, err
+}
+
+func (r deadlineRepository) ExecRaw(ctx context.Context, stmt string, args ...interface{}) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		return repo.ExecRaw(r.parent, stmt, args...)
+	})
+}
+
+// deadlineRows rows releasing the savepoint when closed
+type deadlineRows struct {
+	provider.Rows
+	ctx     context.Context
+	repo    deadlineRepository
+	sp      provider.UnitOfWork
+	timeout string
+}
+
+func (r deadlineRows) Close() error {
+	return r.repo.end(r.ctx, r.sp, r.timeout, r.Rows.Close())
+}
+
+// setStatementTimeout sets the statement timeout until the unit of work ends
+func setStatementTimeout(ctx context.Context, uow provider.UnitOfWork, timeout string) error {
+	// set_config is used as SET LOCAL does not take parameters
+	err := uow.Repository().ExecRaw(ctx, "SELECT set_config('statement_timeout', $1, true)", timeout)
+	return trail.Stacktrace(err)
+}
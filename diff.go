@@ -0,0 +1,46 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pghq/go-tea/trail"
+
+	"github.com/pghq/go-store/internal/jsonpatch"
+	"github.com/pghq/go-store/provider"
+)
+
+// Diff calls fn and gets the JSON Patch (RFC 6902) of its changes to the row of the collection matching the id column (pg only)
+// the row is locked before fn is called, so the patch only describes the changes of fn (e.g., for human-readable audit logs)
+func (tx Txn) Diff(collection string, id interface{}, fn func(tx Txn) error) (json.RawMessage, error) {
+	span := trail.StartSpan(tx.Context(), "Txn.Diff")
+	defer span.Finish()
+
+	row := func() *provider.Builder {
+		return provider.NewBuilder().
+			Select("to_jsonb(diffed)::text").
+			From(fmt.Sprintf("%s AS diffed", collection)).
+			Where("id = ?", id)
+	}
+
+	var before string
+	if err := tx.OneForUpdate(row(), &before); err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	if err := fn(tx); err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	var after string
+	if err := tx.store.repository(tx.Context()).One(tx.Context(), row(), &after); err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	patch, err := jsonpatch.Diff([]byte(before), []byte(after))
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	return patch, nil
+}
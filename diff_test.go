@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxn_Diff(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	assert.Nil(t, store.Add(context.TODO(), "tests", map[string]interface{}{"id": "diff:1", "name": "foo", "num": 1}))
+	edit := func(tx Txn) error {
+		return tx.Edit("tests", spec("id = 'diff:1'"), map[string]interface{}{"name": "bar", "num": 2})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			_, err := tx.Diff("tests", "diff:missing", edit)
+			return err
+		}))
+	})
+
+	t.Run("bad func", func(t *testing.T) {
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			_, err := tx.Diff("tests", "diff:1", func(tx Txn) error {
+				return trail.NewError("an error has occurred")
+			})
+			return err
+		}))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		var patch json.RawMessage
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			var err error
+			patch, err = tx.Diff("tests", "diff:1", edit)
+			return err
+		}))
+
+		var ops []map[string]interface{}
+		assert.Nil(t, json.Unmarshal(patch, &ops))
+		assert.Equal(t, []map[string]interface{}{
+			{"op": "replace", "path": "/name", "value": "bar"},
+			{"op": "replace", "path": "/num", "value": float64(2)},
+		}, ops)
+	})
+}
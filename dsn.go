@@ -0,0 +1,103 @@
+package store
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/pghq/go-tea/trail"
+)
+
+// DSNConfig a parsed postgres connection string
+type DSNConfig struct {
+	Host     string
+	Port     int
+	Database string
+	User     string
+	Password string
+	Params   url.Values
+}
+
+// ParseDSN parses and validates a postgres connection url (postgres:// or postgresql://)
+func ParseDSN(rawURL string) (*DSNConfig, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, trail.NewErrorf("dsn is not a valid url")
+	}
+
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return nil, trail.NewErrorf("dsn scheme %s is not supported", u.Scheme)
+	}
+
+	conf := DSNConfig{
+		Host:     u.Hostname(),
+		Port:     5432,
+		Database: u.Path,
+		User:     u.User.Username(),
+		Params:   u.Query(),
+	}
+
+	if conf.Host == "" {
+		return nil, trail.NewError("dsn is missing a host")
+	}
+
+	if port := u.Port(); port != "" {
+		conf.Port, err = strconv.Atoi(port)
+		if err != nil || conf.Port < 1 || conf.Port > 65535 {
+			return nil, trail.NewErrorf("dsn port %s is out of range", port)
+		}
+	}
+
+	if len(conf.Database) > 0 && conf.Database[0] == '/' {
+		conf.Database = conf.Database[1:]
+	}
+
+	if conf.Database == "" {
+		return nil, trail.NewError("dsn is missing a database name")
+	}
+
+	if conf.User == "" {
+		return nil, trail.NewError("dsn is missing a user")
+	}
+
+	conf.Password, _ = u.User.Password()
+	return &conf, nil
+}
+
+// String gets the normalized connection url
+func (c DSNConfig) String() string {
+	return c.url(c.Password, c.Params)
+}
+
+// Redacted gets the connection url with passwords masked (e.g., for logging)
+func (c DSNConfig) Redacted() string {
+	params := make(url.Values)
+	for key, values := range c.Params {
+		params[key] = values
+		if key == "password" {
+			params[key] = []string{"***"}
+		}
+	}
+
+	if c.Password == "" {
+		return c.url("", params)
+	}
+
+	return c.url("***", params)
+}
+
+// url gets the connection url with the password and params
+func (c DSNConfig) url(password string, params url.Values) string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.User(c.User),
+		Host:     c.Host + ":" + strconv.Itoa(c.Port),
+		Path:     "/" + c.Database,
+		RawQuery: params.Encode(),
+	}
+
+	if password != "" {
+		u.User = url.UserPassword(c.User, password)
+	}
+
+	return u.String()
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pghq/go-tea/trail"
+
+	"github.com/pghq/go-store/provider/pg"
+)
+
+// ConfigErrors all invalid fields of a configuration (e.g., trail.AsError(err, &ConfigErrors{}))
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// ConfigFromEnv reads a postgres configuration from the environment, returning ConfigErrors for all missing or invalid variables
+// DB_URL is required, while DB_MAX_CONNS, DB_MIN_CONNS, DB_MIGRATION_DIR, DB_SEED_DIR, DB_SSL_MODE,
+// DB_QUERY_TIMEOUT (e.g., 5s) and DB_ENABLE_PREPARED_STMTS (true, 1 or yes, false, 0 or no) are optional
+func ConfigFromEnv() (Config, error) {
+	conf := Config{Dialect: "postgres"}
+	var errs ConfigErrors
+	invalid := func(key, reason string) {
+		errs = append(errs, ConfigError{Field: key, Reason: reason})
+	}
+
+	if conf.DSN = os.Getenv("DB_URL"); conf.DSN == "" {
+		invalid("DB_URL", "is required")
+	}
+
+	for _, key := range []string{"DB_MAX_CONNS", "DB_MIN_CONNS"} {
+		value, present := os.LookupEnv(key)
+		if !present {
+			continue
+		}
+
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || n < 0 {
+			invalid(key, fmt.Sprintf("%s is not a non-negative integer", value))
+			continue
+		}
+
+		if key == "DB_MAX_CONNS" {
+			conf.PgOptions = append(conf.PgOptions, pg.WithMaxConns(int32(n)))
+		} else {
+			conf.PgOptions = append(conf.PgOptions, pg.WithMinConns(int32(n)))
+		}
+	}
+
+	if dir := os.Getenv("DB_MIGRATION_DIR"); dir != "" {
+		conf.Migration = migrationDir{os.DirFS(dir).(fs.ReadDirFS)}
+	}
+
+	conf.SeedDir = os.Getenv("DB_SEED_DIR")
+	if mode := os.Getenv("DB_SSL_MODE"); mode != "" {
+		conf.PgOptions = append(conf.PgOptions, pg.WithSSLMode(mode))
+	}
+
+	if value := os.Getenv("DB_QUERY_TIMEOUT"); value != "" {
+		var err error
+		if conf.QueryTimeout, err = time.ParseDuration(value); err != nil {
+			invalid("DB_QUERY_TIMEOUT", fmt.Sprintf("%s is not a duration", value))
+		}
+	}
+
+	if value := os.Getenv("DB_ENABLE_PREPARED_STMTS"); value != "" {
+		enabled, ok := parseBool(value)
+		if !ok {
+			invalid("DB_ENABLE_PREPARED_STMTS", fmt.Sprintf("%s is not a boolean", value))
+		}
+
+		if ok && !enabled {
+			conf.PgOptions = append(conf.PgOptions, pg.WithSimpleProtocol(true))
+		}
+	}
+
+	if len(errs) > 0 {
+		return conf, trail.ErrorBadRequest(errs)
+	}
+
+	return conf, nil
+}
+
+// parseBool parses true, 1 or yes and false, 0 or no (case insensitive)
+func parseBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "true", "1", "yes":
+		return true, true
+	case "false", "0", "no":
+		return false, true
+	}
+
+	return false, false
+}
+
+// migrationDir a directory of migrations, read as the migrations directory of the fs migrations are expected in
+type migrationDir struct {
+	fs.ReadDirFS
+}
+
+func (d migrationDir) Open(name string) (fs.File, error) {
+	return d.ReadDirFS.Open(d.name(name))
+}
+
+func (d migrationDir) ReadDir(name string) ([]fs.DirEntry, error) {
+	return d.ReadDirFS.ReadDir(d.name(name))
+}
+
+// name gets the name within the directory
+func (d migrationDir) name(name string) string {
+	if name == "migrations" {
+		return "."
+	}
+
+	return strings.TrimPrefix(name, "migrations/")
+}
+
+// Seed loads the fixtures of the seed directory (.json, .yaml or .yml files), see WithSeedDir and LoadFixtures
+func (s Store) Seed(ctx context.Context) error {
+	if s.conf.SeedDir == "" {
+		return trail.NewError("seed directory is not set")
+	}
+
+	entries, err := os.ReadDir(s.conf.SeedDir)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".json", ".yaml", ".yml":
+			paths = append(paths, filepath.Join(s.conf.SeedDir, entry.Name()))
+		}
+	}
+
+	sort.Strings(paths)
+	return LoadFixtures(ctx, &s, paths...)
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+)
+
+// env tests can not run in parallel (t.Setenv)
+func TestConfigFromEnv(t *testing.T) {
+	trail.Testing()
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv("DB_URL", "")
+		t.Setenv("DB_MAX_CONNS", "many")
+		t.Setenv("DB_QUERY_TIMEOUT", "5")
+		t.Setenv("DB_ENABLE_PREPARED_STMTS", "maybe")
+		_, err := ConfigFromEnv()
+		assert.NotNil(t, err)
+		assert.False(t, trail.IsFatal(err))
+
+		var errs ConfigErrors
+		assert.True(t, trail.AsError(err, &errs))
+		assert.Len(t, errs, 4)
+		assert.Equal(t, "DB_URL", errs[0].Field)
+		assert.Equal(t, "DB_ENABLE_PREPARED_STMTS", errs[3].Field)
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "00001_init.sql"), []byte("-- +goose Up"), 0644))
+		t.Setenv("DB_URL", dsn)
+		t.Setenv("DB_MAX_CONNS", "10")
+		t.Setenv("DB_MIN_CONNS", "2")
+		t.Setenv("DB_MIGRATION_DIR", dir)
+		t.Setenv("DB_SEED_DIR", dir)
+		t.Setenv("DB_SSL_MODE", "disable")
+		t.Setenv("DB_QUERY_TIMEOUT", "5s")
+		t.Setenv("DB_ENABLE_PREPARED_STMTS", "no")
+		conf, err := ConfigFromEnv()
+		assert.Nil(t, err)
+		assert.Equal(t, dsn, conf.DSN)
+		assert.Equal(t, dir, conf.SeedDir)
+		assert.Equal(t, 5*time.Second, conf.QueryTimeout)
+		assert.Len(t, conf.PgOptions, 4)
+
+		entries, err := conf.Migration.ReadDir("migrations")
+		assert.Nil(t, err)
+		assert.Len(t, entries, 1)
+		_, err = fs.ReadFile(conf.Migration, "migrations/00001_init.sql")
+		assert.Nil(t, err)
+	})
+}
+
+func TestStore_Seed(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("missing directory", func(t *testing.T) {
+		s, err := New(WithDSN(dsn))
+		assert.Nil(t, err)
+		assert.NotNil(t, s.Seed(context.TODO()))
+	})
+
+	t.Run("empty directory", func(t *testing.T) {
+		s, err := New(WithConfig(Config{Dialect: "postgres", DSN: dsn}), WithSeedDir(t.TempDir()))
+		assert.Nil(t, err)
+		assert.Nil(t, s.Seed(context.TODO()))
+	})
+}
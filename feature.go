@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+
+	"github.com/pghq/go-tea/trail"
+
+	"github.com/pghq/go-store/provider"
+)
+
+// featureFlagTable the default table of feature flags
+const featureFlagTable = "feature_flags"
+
+// FeatureEnabled checks if the feature flag is enabled, flags not in the feature flag table are disabled
+// the table has the columns name (unique) and enabled, see WithFeatureFlagTable
+func (s Store) FeatureEnabled(ctx context.Context, flag string) (bool, error) {
+	span := trail.StartSpan(ctx, "Store.FeatureEnabled")
+	defer span.Finish()
+
+	var enabled bool
+	query := provider.NewBuilder().Select("enabled").From(s.featureFlagTable()).Where("name = ?", flag)
+	if err := s.repository(ctx).One(ctx, query, &enabled); err != nil {
+		if trail.IsNotFound(err) {
+			return false, nil
+		}
+
+		return false, trail.Stacktrace(err)
+	}
+
+	return enabled, nil
+}
+
+// SetFeatureFlag enables or disables the feature flag, adding it to the feature flag table if missing
+func (s Store) SetFeatureFlag(ctx context.Context, flag string, enabled bool) error {
+	span := trail.StartSpan(ctx, "Store.SetFeatureFlag")
+	defer span.Finish()
+
+	v := map[string]interface{}{"name": flag, "enabled": enabled}
+	if err := s.repository(ctx).Upsert(ctx, s.featureFlagTable(), v, []string{"name"}); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	return nil
+}
+
+// featureFlagTable gets the table of feature flags
+func (s Store) featureFlagTable() string {
+	if s.conf.FeatureFlagTable != "" {
+		return s.conf.FeatureFlagTable
+	}
+
+	return featureFlagTable
+}
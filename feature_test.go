@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_FeatureEnabled(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	assert.Nil(t, store.ExecRaw(context.TODO(), "CREATE TABLE flags (name text primary key, enabled boolean not null)"))
+	s, err := New(WithDSN(dsn), WithFeatureFlagTable("flags"))
+	assert.Nil(t, err)
+
+	t.Run("missing table", func(t *testing.T) {
+		_, err := store.FeatureEnabled(context.TODO(), "missing")
+		assert.NotNil(t, err)
+		assert.NotNil(t, store.SetFeatureFlag(context.TODO(), "missing", true))
+	})
+
+	t.Run("missing flag", func(t *testing.T) {
+		enabled, err := s.FeatureEnabled(context.TODO(), "missing")
+		assert.Nil(t, err)
+		assert.False(t, enabled)
+	})
+
+	t.Run("toggle", func(t *testing.T) {
+		assert.Nil(t, s.SetFeatureFlag(context.TODO(), "toggle", true))
+		enabled, err := s.FeatureEnabled(context.TODO(), "toggle")
+		assert.Nil(t, err)
+		assert.True(t, enabled)
+
+		assert.Nil(t, s.SetFeatureFlag(context.TODO(), "toggle", false))
+		enabled, err = s.FeatureEnabled(context.TODO(), "toggle")
+		assert.Nil(t, err)
+		assert.False(t, enabled)
+	})
+
+	t.Run("concurrent reads", func(t *testing.T) {
+		assert.Nil(t, s.SetFeatureFlag(context.TODO(), "concurrent", true))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				enabled, err := s.FeatureEnabled(context.TODO(), "concurrent")
+				assert.Nil(t, err)
+				assert.True(t, enabled)
+			}()
+		}
+
+		wg.Wait()
+	})
+}
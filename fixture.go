@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/pghq/go-tea/trail"
+	"gopkg.in/yaml.v3"
+)
+
+// Fixture seed data for a table
+type Fixture struct {
+	Schema string                   `json:"schema" yaml:"schema"`
+	Table  string                   `json:"table" yaml:"table"`
+	Rows   []map[string]interface{} `json:"rows" yaml:"rows"`
+}
+
+// LoadFixtures adds the rows of each fixture file (.json, .yaml or .yml) in a single transaction
+// tables are seeded after the tables they reference by foreign key, so files can be given in any order
+func LoadFixtures(ctx context.Context, s *Store, paths ...string) error {
+	span := trail.StartSpan(ctx, "Store.LoadFixtures")
+	defer span.Finish()
+
+	var fixtures []Fixture
+	for _, path := range paths {
+		fixture, err := readFixture(path)
+		if err != nil {
+			return trail.Stacktrace(err)
+		}
+
+		fixtures = append(fixtures, fixture)
+	}
+
+	references := make(map[string][]string)
+	for _, fixture := range fixtures {
+		if _, present := references[fixture.Table]; present {
+			continue
+		}
+
+		info, err := s.InspectTable(ctx, fixture.Schema, fixture.Table)
+		if err != nil {
+			return trail.Stacktrace(err)
+		}
+
+		references[fixture.Table] = info.References
+	}
+
+	fixtures, err := sortFixtures(fixtures, references)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	return s.Do(ctx, func(tx Txn) error {
+		for _, fixture := range fixtures {
+			for _, row := range fixture.Rows {
+				if err := tx.Add(fixture.Table, row); err != nil {
+					return trail.Stacktrace(err)
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// readFixture reads a fixture from a json or yaml file
+func readFixture(path string) (Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Fixture{}, trail.Stacktrace(err)
+	}
+
+	var fixture Fixture
+	switch filepath.Ext(path) {
+	case ".json":
+		err = json.Unmarshal(data, &fixture)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &fixture)
+	default:
+		err = trail.NewErrorf("fixture %s is not a json or yaml file", path)
+	}
+
+	if err != nil {
+		return Fixture{}, trail.Stacktrace(err)
+	}
+
+	if fixture.Table == "" {
+		return Fixture{}, trail.NewErrorf("fixture %s is missing a table", path)
+	}
+
+	if fixture.Schema == "" {
+		fixture.Schema = "public"
+	}
+
+	return fixture, nil
+}
+
+// sortFixtures orders fixtures so referenced tables come first, otherwise keeping the given order
+func sortFixtures(fixtures []Fixture, references map[string][]string) ([]Fixture, error) {
+	pending := make(map[string]int)
+	for _, fixture := range fixtures {
+		pending[fixture.Table]++
+	}
+
+	var sorted []Fixture
+	for len(fixtures) > 0 {
+		var remaining []Fixture
+		for _, fixture := range fixtures {
+			ready := true
+			for _, table := range references[fixture.Table] {
+				if table != fixture.Table && pending[table] > 0 {
+					ready = false
+				}
+			}
+
+			if !ready {
+				remaining = append(remaining, fixture)
+				continue
+			}
+
+			sorted = append(sorted, fixture)
+			pending[fixture.Table]--
+		}
+
+		if len(remaining) == len(fixtures) {
+			return nil, trail.NewErrorf("fixtures for table %s have circular references", remaining[0].Table)
+		}
+
+		fixtures = remaining
+	}
+
+	return sorted, nil
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pghq/go-store/provider"
+)
+
+func TestLoadFixtures(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	dir := t.TempDir()
+	fixture := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	books := fixture("books.yaml", "table: books\nrows:\n  - id: fixture:book:1\n    author_id: fixture:author:1\n    title: foo\n  - id: fixture:book:2\n    author_id: fixture:author:1\n    title: bar\n")
+	authors := fixture("authors.json", `{"table": "authors", "rows": [{"id": "fixture:author:1", "name": "baz"}]}`)
+
+	t.Run("missing file", func(t *testing.T) {
+		assert.NotNil(t, LoadFixtures(context.TODO(), store, filepath.Join(dir, "missing.yaml")))
+	})
+
+	t.Run("bad extension", func(t *testing.T) {
+		assert.NotNil(t, LoadFixtures(context.TODO(), store, fixture("bad.txt", "table: tests")))
+	})
+
+	t.Run("bad data", func(t *testing.T) {
+		assert.NotNil(t, LoadFixtures(context.TODO(), store, fixture("bad.json", "{")))
+	})
+
+	t.Run("missing table", func(t *testing.T) {
+		assert.NotNil(t, LoadFixtures(context.TODO(), store, fixture("empty.yaml", "rows: []")))
+	})
+
+	t.Run("unknown table", func(t *testing.T) {
+		assert.NotNil(t, LoadFixtures(context.TODO(), store, fixture("unknown.yaml", "table: unknown")))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, LoadFixtures(context.TODO(), store, books, authors))
+
+		var titles []string
+		query := provider.NewBuilder().
+			Select("books.title").
+			From("books").
+			Join("authors", "authors.id = books.author_id").
+			Where("authors.id = ?", "fixture:author:1").
+			OrderBy("books.title", false)
+		assert.Nil(t, store.All(context.TODO(), query, &titles))
+		assert.Equal(t, []string{"bar", "foo"}, titles)
+	})
+}
+
+func TestSortFixtures(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("circular", func(t *testing.T) {
+		fixtures := []Fixture{{Table: "a"}, {Table: "b"}}
+		_, err := sortFixtures(fixtures, map[string][]string{"a": {"b"}, "b": {"a"}})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		fixtures := []Fixture{{Table: "c"}, {Table: "b"}, {Table: "a"}, {Table: "d"}}
+		sorted, err := sortFixtures(fixtures, map[string][]string{"a": {"a", "external"}, "b": {"a"}, "c": {"b"}})
+		assert.Nil(t, err)
+		assert.Equal(t, []Fixture{{Table: "a"}, {Table: "d"}, {Table: "b"}, {Table: "c"}}, sorted)
+	})
+}
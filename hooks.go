@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pghq/go-tea/trail"
+)
+
+// WriteOp a write operation observed by write hooks
+type WriteOp string
+
+const (
+	WriteAdd    WriteOp = "add"
+	WriteEdit   WriteOp = "edit"
+	WriteUpsert WriteOp = "upsert"
+	WriteRemove WriteOp = "remove"
+)
+
+// BeforeWriteHook is called before each write, aborting it on error
+// v is the value written, or the spec of the values removed
+type BeforeWriteHook func(ctx context.Context, op WriteOp, collection string, v interface{}) error
+
+// AfterWriteHook is called after each successful write
+type AfterWriteHook func(ctx context.Context, op WriteOp, collection string, rowsAffected int64)
+
+// writeHooks the write hooks shared by copies of a store
+type writeHooks struct {
+	mu     sync.RWMutex
+	before []BeforeWriteHook
+	after  []AfterWriteHook
+}
+
+// OnBeforeWrite calls the hook before each add, edit, upsert and remove (e.g., for validation or audit logging)
+// hooks are called in the order they were added, and an error aborts the write (rolling back the transaction if returned from Do)
+func (s Store) OnBeforeWrite(hook BeforeWriteHook) {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+	s.hooks.before = append(s.hooks.before, hook)
+}
+
+// OnAfterWrite calls the hook after each successful add, edit, upsert and remove (e.g., for event publishing)
+// hooks run within the transaction of the write, which may still be rolled back
+// upserts report a single row affected
+func (s Store) OnAfterWrite(hook AfterWriteHook) {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+	s.hooks.after = append(s.hooks.after, hook)
+}
+
+// beforeWrite calls the before write hooks, stopping at the first error
+func (s Store) beforeWrite(ctx context.Context, op WriteOp, collection string, v interface{}) error {
+	s.hooks.mu.RLock()
+	hooks := s.hooks.before
+	s.hooks.mu.RUnlock()
+	for _, hook := range hooks {
+		if err := hook(ctx, op, collection, v); err != nil {
+			return trail.Stacktrace(err)
+		}
+	}
+
+	return nil
+}
+
+// afterWrite calls the after write hooks
+func (s Store) afterWrite(ctx context.Context, op WriteOp, collection string, rowsAffected int64) {
+	s.hooks.mu.RLock()
+	hooks := s.hooks.after
+	s.hooks.mu.RUnlock()
+	for _, hook := range hooks {
+		hook(ctx, op, collection, rowsAffected)
+	}
+}
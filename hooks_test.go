@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_OnWrite(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("order", func(t *testing.T) {
+		s, err := New(WithDSN(dsn))
+		assert.Nil(t, err)
+
+		var mu sync.Mutex
+		var calls []string
+		record := func(call string) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, call)
+		}
+
+		s.OnBeforeWrite(func(_ context.Context, op WriteOp, collection string, _ interface{}) error {
+			record(fmt.Sprintf("before %s %s", op, collection))
+			return nil
+		})
+
+		s.OnBeforeWrite(func(_ context.Context, op WriteOp, _ string, _ interface{}) error {
+			record(fmt.Sprintf("before %s again", op))
+			return nil
+		})
+
+		s.OnAfterWrite(func(_ context.Context, op WriteOp, collection string, rowsAffected int64) {
+			record(fmt.Sprintf("after %s %s %d", op, collection, rowsAffected))
+		})
+
+		assert.Nil(t, s.Do(context.TODO(), func(tx Txn) error {
+			if err := tx.Add("tests", map[string]interface{}{"id": "hooks:1"}); err != nil {
+				return err
+			}
+
+			if err := tx.Add("tests", map[string]interface{}{"id": "hooks:2"}); err != nil {
+				return err
+			}
+
+			if err := tx.Edit("tests", spec("id LIKE 'hooks:%'"), map[string]interface{}{"num": 1}); err != nil {
+				return err
+			}
+
+			if err := tx.Upsert("tests", map[string]interface{}{"id": "hooks:1", "num": 2}, []string{"id"}); err != nil {
+				return err
+			}
+
+			return tx.Remove("tests", spec("id LIKE 'hooks:%'"))
+		}))
+
+		assert.Equal(t, []string{
+			"before add tests",
+			"before add again",
+			"after add tests 1",
+			"before add tests",
+			"before add again",
+			"after add tests 1",
+			"before edit tests",
+			"before edit again",
+			"after edit tests 2",
+			"before upsert tests",
+			"before upsert again",
+			"after upsert tests 1",
+			"before remove tests",
+			"before remove again",
+			"after remove tests 2",
+		}, calls)
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		s, err := New(WithDSN(dsn))
+		assert.Nil(t, err)
+
+		var after int
+		s.OnBeforeWrite(func(_ context.Context, _ WriteOp, _ string, v interface{}) error {
+			if v.(map[string]interface{})["id"] == "hooks:abort:2" {
+				return trail.NewError("abort")
+			}
+
+			return nil
+		})
+
+		s.OnAfterWrite(func(_ context.Context, _ WriteOp, _ string, _ int64) {
+			after++
+		})
+
+		assert.NotNil(t, s.Do(context.TODO(), func(tx Txn) error {
+			if err := tx.Add("tests", map[string]interface{}{"id": "hooks:abort:1"}); err != nil {
+				return err
+			}
+
+			return tx.Add("tests", map[string]interface{}{"id": "hooks:abort:2"})
+		}))
+		assert.Equal(t, 1, after)
+
+		exists, err := store.Exists(context.TODO(), spec("SELECT 1 FROM tests WHERE id LIKE 'hooks:abort:%'"))
+		assert.Nil(t, err)
+		assert.False(t, exists)
+	})
+}
@@ -10,7 +10,8 @@ import (
 // Map Convert an interface to a map using reflection
 // variation of: https://play.golang.org/p/2Qi3thFf--
 // meant to be used for data persistence.
-func Map(v interface{}) (map[string]interface{}, error) {
+// struct fields tagged with any of the skip options (e.g., db:"id,readonly") are excluded.
+func Map(v interface{}, skip ...string) (map[string]interface{}, error) {
 	if m, ok := v.(map[string]interface{}); ok || v == nil {
 		return m, nil
 	}
@@ -37,10 +38,55 @@ func Map(v interface{}) (map[string]interface{}, error) {
 	}
 
 	item := make(map[string]interface{})
+	fields(rv, item, skip)
+	return item, nil
+}
+
+// Tagged gets the fields of the struct tagged with the option (e.g., db:"id,pk")
+func Tagged(v interface{}, option string) (map[string]interface{}, error) {
+	all, err := Map(v)
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	untagged, _ := Map(v, option)
+	tagged := make(map[string]interface{})
+	for key, value := range all {
+		if _, present := untagged[key]; !present {
+			tagged[key] = value
+		}
+	}
+
+	return tagged, nil
+}
+
+// fields adds the persisted fields of the struct to the item
+// untagged embedded structs are flattened into the item
+func fields(rv reflect.Value, item map[string]interface{}, skip []string) {
 	t := rv.Type()
 	for i := 0; i < rv.NumField(); i++ {
 		sf := t.Field(i)
 		key := sf.Tag.Get("db")
+		if sf.Anonymous && key == "" {
+			fv := rv.Field(i)
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+
+				fv = fv.Elem()
+			}
+
+			if fv.Kind() == reflect.Struct {
+				fields(fv, item, skip)
+				continue
+			}
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+
 		if key == "" {
 			key = sf.Name
 		}
@@ -49,8 +95,37 @@ func Map(v interface{}) (map[string]interface{}, error) {
 			continue
 		}
 
-		item[strings.Split(key, ",")[0]] = rv.Field(i).Interface()
+		opts := strings.Split(key, ",")
+		if hasOption(opts[1:], "omitempty") && rv.Field(i).IsZero() {
+			continue
+		}
+
+		if hasAnyOption(opts[1:], skip) {
+			continue
+		}
+
+		item[opts[0]] = rv.Field(i).Interface()
 	}
+}
 
-	return item, nil
+// hasOption checks if a struct tag option is present
+func hasOption(opts []string, name string) bool {
+	for _, opt := range opts {
+		if opt == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasAnyOption checks if any of the struct tag options are present
+func hasAnyOption(opts []string, names []string) bool {
+	for _, name := range names {
+		if hasOption(opts, name) {
+			return true
+		}
+	}
+
+	return false
 }
@@ -49,6 +49,52 @@ func TestMap(t *testing.T) {
 		assert.Equal(t, map[string]interface{}{"field1": 1, "field2": 2, "Field4": 4}, m)
 	})
 
+	t.Run("struct omitempty", func(t *testing.T) {
+		type value struct {
+			Field1 int    `db:"field1,omitempty"`
+			Field2 string `db:"field2,omitempty"`
+			Field3 int    `db:"field3"`
+		}
+
+		m, _ := Map(value{Field1: 1})
+		assert.Equal(t, map[string]interface{}{"field1": 1, "field3": 0}, m)
+	})
+
+	t.Run("struct embedded", func(t *testing.T) {
+		type base struct {
+			Id string `db:"id"`
+		}
+
+		type meta struct {
+			Tags string `db:"tags"`
+		}
+
+		type value struct {
+			base
+			*meta
+			Name  *string `db:"name"`
+			Num   *int    `db:"num,omitempty"`
+			value string
+		}
+
+		name := "foo"
+		m, _ := Map(value{base: base{Id: "1234"}, Name: &name, value: "bar"})
+		assert.Equal(t, map[string]interface{}{"id": "1234", "name": &name}, m)
+
+		m, _ = Map(value{meta: &meta{Tags: "baz"}})
+		assert.Equal(t, map[string]interface{}{"id": "", "tags": "baz", "name": (*string)(nil)}, m)
+	})
+
+	t.Run("struct skip", func(t *testing.T) {
+		type value struct {
+			Field1 int `db:"field1,readonly"`
+			Field2 int `db:"field2"`
+		}
+
+		m, _ := Map(value{Field1: 1, Field2: 2}, "readonly")
+		assert.Equal(t, map[string]interface{}{"field2": 2}, m)
+	})
+
 	t.Run("struct slice", func(t *testing.T) {
 		type value struct {
 			Field1 int `db:"field1"`
@@ -68,3 +114,30 @@ func TestMap(t *testing.T) {
 		assert.Equal(t, map[string]interface{}{"field1": 0, "field2": 0, "Field4": 0}, m)
 	})
 }
+
+func TestTagged(t *testing.T) {
+	t.Parallel()
+
+	t.Run("unrecognized type", func(t *testing.T) {
+		_, err := Tagged(func() {}, "pk")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		m, err := Tagged(map[string]interface{}{"id": "foo"}, "pk")
+		assert.Nil(t, err)
+		assert.Empty(t, m)
+	})
+
+	t.Run("struct", func(t *testing.T) {
+		type value struct {
+			Id      string `db:"id,pk"`
+			Version int    `db:"version,readonly,pk"`
+			Name    string `db:"name"`
+		}
+
+		m, err := Tagged(&value{Id: "foo", Version: 2, Name: "bar"}, "pk")
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]interface{}{"id": "foo", "version": 2}, m)
+	})
+}
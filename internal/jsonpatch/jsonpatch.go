@@ -0,0 +1,98 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pghq/go-tea/trail"
+)
+
+// Operation a JSON Patch (RFC 6902) operation
+type Operation struct {
+	Op    string
+	Path  string
+	Value interface{}
+}
+
+// MarshalJSON encodes the operation, with a value (possibly null) for all but remove operations
+func (o Operation) MarshalJSON() ([]byte, error) {
+	if o.Op == "remove" {
+		return json.Marshal(map[string]interface{}{"op": o.Op, "path": o.Path})
+	}
+
+	return json.Marshal(map[string]interface{}{"op": o.Op, "path": o.Path, "value": o.Value})
+}
+
+// Diff creates the JSON Patch transforming the before document into the after document
+// objects are compared member by member, all other values (including arrays) are replaced as a whole
+func Diff(before, after []byte) (json.RawMessage, error) {
+	var b, a interface{}
+	if err := json.Unmarshal(before, &b); err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	if err := json.Unmarshal(after, &a); err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	ops := diff("", b, a, []Operation{})
+	data, err := json.Marshal(ops)
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	return data, nil
+}
+
+// diff appends the operations transforming the before value at the path into the after value
+func diff(path string, before, after interface{}, ops []Operation) []Operation {
+	b, bok := before.(map[string]interface{})
+	a, aok := after.(map[string]interface{})
+	if !bok || !aok {
+		if !reflect.DeepEqual(before, after) {
+			ops = append(ops, Operation{Op: "replace", Path: path, Value: after})
+		}
+
+		return ops
+	}
+
+	for _, key := range keys(b, a) {
+		bv, inBefore := b[key]
+		av, inAfter := a[key]
+		member := path + "/" + escape(key)
+		switch {
+		case !inAfter:
+			ops = append(ops, Operation{Op: "remove", Path: member})
+		case !inBefore:
+			ops = append(ops, Operation{Op: "add", Path: member, Value: av})
+		default:
+			ops = diff(member, bv, av, ops)
+		}
+	}
+
+	return ops
+}
+
+// keys gets the sorted keys of the objects
+func keys(objects ...map[string]interface{}) []string {
+	seen := make(map[string]struct{})
+	var keys []string
+	for _, object := range objects {
+		for key := range object {
+			if _, present := seen[key]; !present {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// escape escapes the key as a JSON Pointer (RFC 6901) reference token
+func escape(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
@@ -0,0 +1,46 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	t.Run("bad json", func(t *testing.T) {
+		_, err := Diff([]byte("{"), []byte("{}"))
+		assert.NotNil(t, err)
+
+		_, err = Diff([]byte("{}"), []byte("{"))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		patch, err := Diff([]byte(`{"id": "1", "tags": ["a"]}`), []byte(`{"tags": ["a"], "id": "1"}`))
+		assert.Nil(t, err)
+		assert.JSONEq(t, `[]`, string(patch))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		before := `{"id": "1", "name": "foo", "num": 1, "gone": true, "data": {"a/b": 1, "c~": [1]}, "note": "x"}`
+		after := `{"id": "1", "name": "bar", "num": 1, "added": "new", "data": {"a/b": 2, "c~": [1, 2]}, "note": null}`
+		patch, err := Diff([]byte(before), []byte(after))
+		assert.Nil(t, err)
+		assert.JSONEq(t, `[
+			{"op": "add", "path": "/added", "value": "new"},
+			{"op": "replace", "path": "/data/a~1b", "value": 2},
+			{"op": "replace", "path": "/data/c~0", "value": [1, 2]},
+			{"op": "remove", "path": "/gone"},
+			{"op": "replace", "path": "/name", "value": "bar"},
+			{"op": "replace", "path": "/note", "value": null}
+		]`, string(patch))
+	})
+
+	t.Run("document", func(t *testing.T) {
+		patch, err := Diff([]byte(`{"id": "1"}`), []byte(`null`))
+		assert.Nil(t, err)
+		assert.JSONEq(t, `[{"op": "replace", "path": "", "value": null}]`, string(patch))
+	})
+}
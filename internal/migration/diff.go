@@ -0,0 +1,198 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pghq/go-tea/trail"
+)
+
+var (
+	// createTable matches statements creating a table, capturing its name
+	createTable = regexp.MustCompile("(?is)^CREATE\\s+(?:UNLOGGED\\s+)?TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?([\\w.\"`]+)")
+
+	// addColumn matches statements adding a column to a table, capturing the table and column names
+	addColumn = regexp.MustCompile("(?is)^ALTER\\s+TABLE\\s+(?:IF\\s+EXISTS\\s+)?(?:ONLY\\s+)?([\\w.\"`]+)\\s+ADD\\s+(?:COLUMN\\s+)?(?:IF\\s+NOT\\s+EXISTS\\s+)?([\\w\"`]+)\\s")
+
+	// add matches each ADD of an ALTER TABLE statement
+	add = regexp.MustCompile(`(?i)\bADD\b`)
+)
+
+// Diff gets the statements of migrations not yet applied which the live schema does not already reflect
+// statements creating tables or adding columns are checked against the schema, all others are assumed to be missing
+func (m Migrator) Diff(ctx context.Context) ([]string, error) {
+	if m.fs == nil {
+		return nil, nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err := m.setup(); err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	migrations, err := m.pending()
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	var diff []string
+	for _, migration := range migrations {
+		if filepath.Ext(migration.Source) != ".sql" {
+			continue
+		}
+
+		data, err := fs.ReadFile(m.fs, migration.Source)
+		if err != nil {
+			return nil, trail.Stacktrace(err)
+		}
+
+		for _, stmt := range upStatements(string(data)) {
+			applied, err := m.applied(ctx, stmt)
+			if err != nil {
+				return nil, trail.Stacktrace(err)
+			}
+
+			if !applied {
+				diff = append(diff, stmt)
+			}
+		}
+	}
+
+	return diff, nil
+}
+
+// applied checks if the schema already reflects the statement
+func (m Migrator) applied(ctx context.Context, stmt string) (bool, error) {
+	if match := createTable.FindStringSubmatch(stmt); match != nil {
+		return m.exists(ctx, match[1], "")
+	}
+
+	if match := addColumn.FindStringSubmatch(stmt); match != nil && len(add.FindAllString(stmt, -1)) == 1 {
+		switch strings.ToUpper(match[2]) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "FOREIGN", "CHECK", "INDEX", "KEY":
+			return false, nil
+		}
+
+		return m.exists(ctx, match[1], match[2])
+	}
+
+	return false, nil
+}
+
+// exists checks if the table, or the column of the table if any, exists
+func (m Migrator) exists(ctx context.Context, table, column string) (bool, error) {
+	param := func(i int) string {
+		if m.dialect == "pgx" {
+			return fmt.Sprintf("$%d", i)
+		}
+
+		return "?"
+	}
+
+	schema, name := "", m.identifier(table)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		schema, name = name[:i], name[i+1:]
+	}
+
+	args := []interface{}{name}
+	var query string
+	switch {
+	case m.dialect == "sqlite3" && column == "":
+		query = "SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+	case m.dialect == "sqlite3":
+		query = "SELECT count(*) FROM pragma_table_info(?) WHERE name = ?"
+		args = append(args, m.identifier(column))
+	default:
+		query = fmt.Sprintf("SELECT count(*) FROM information_schema.tables WHERE table_name = %s", param(1))
+		if column != "" {
+			query = fmt.Sprintf("SELECT count(*) FROM information_schema.columns WHERE table_name = %s AND column_name = %s", param(1), param(2))
+			args = append(args, m.identifier(column))
+		}
+
+		current := "current_schema()"
+		if m.dialect == "mysql" {
+			current = "database()"
+		}
+
+		if schema != "" {
+			current = param(len(args) + 1)
+			args = append(args, schema)
+		}
+
+		query = fmt.Sprintf("%s AND table_schema = %s", query, current)
+	}
+
+	var n int
+	if err := m.db.QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+		return false, trail.Stacktrace(err)
+	}
+
+	return n > 0, nil
+}
+
+// identifier gets the name of a possibly quoted and qualified identifier as stored in the schema
+// unquoted pg identifiers are folded to lower case
+func (m Migrator) identifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if unquoted := strings.Trim(part, "\"`"); unquoted != part {
+			parts[i] = unquoted
+			continue
+		}
+
+		if m.dialect == "pgx" {
+			parts[i] = strings.ToLower(part)
+		}
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// upStatements splits the up section of a sql migration into statements as goose does
+func upStatements(data string) []string {
+	var stmts, stmt []string
+	up, explicit := false, false
+	flush := func() {
+		if s := strings.TrimSpace(strings.Join(stmt, "\n")); s != "" && up {
+			stmts = append(stmts, s)
+		}
+
+		stmt = nil
+	}
+
+	for _, line := range strings.Split(annotate(data), "\n") {
+		if strings.HasPrefix(line, "--") {
+			switch strings.TrimSpace(strings.TrimPrefix(line, "-- +goose")) {
+			case "Up":
+				up = true
+			case "Down":
+				flush()
+				up = false
+			case "StatementBegin":
+				explicit = true
+			case "StatementEnd":
+				flush()
+				explicit = false
+			}
+
+			continue
+		}
+
+		if !up || strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		stmt = append(stmt, line)
+		if !explicit && endsStatement(line) {
+			flush()
+		}
+	}
+
+	flush()
+	return stmts
+}
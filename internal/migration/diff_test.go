@@ -0,0 +1,111 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pghq/go-store/provider/pg/pgtest"
+)
+
+func TestMigrator_Diff(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("no migrations", func(t *testing.T) {
+		diff, err := New(nil, "pgx", nil, nil).Diff(context.TODO())
+		assert.Nil(t, err)
+		assert.Empty(t, diff)
+	})
+
+	t.Run("bad dialect", func(t *testing.T) {
+		_, err := New(nil, "", fstest.MapFS{}, nil).Diff(context.TODO())
+		assert.NotNil(t, err)
+	})
+
+	dsn, cleanup, err := pgtest.Start()
+	if err != nil {
+		panic(err)
+	}
+
+	defer cleanup()
+
+	db, _ := sql.Open("pgx", dsn)
+	m := New(db, "pgx", fstest.MapFS{
+		"migrations/00001_first.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nCREATE TABLE first (id text primary key);\n-- +goose Down\nDROP TABLE first;"),
+		},
+		"migrations/00002_second.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nCREATE TABLE second (id text primary key);\nALTER TABLE first ADD COLUMN name text;\n-- +goose Down\nDROP TABLE second;"),
+		},
+		"migrations/00003_third.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nCREATE TABLE IF NOT EXISTS public.third (id text primary key);\nALTER TABLE second ADD COLUMN IF NOT EXISTS num int;\nCREATE INDEX idx_first_name ON first (name);\n-- +goose Down\nDROP TABLE third;"),
+		},
+		"migrations/00004_fourth.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nALTER TABLE first ADD CONSTRAINT first_name_unique UNIQUE (name);\n-- +goose Down\nALTER TABLE first DROP CONSTRAINT first_name_unique;"),
+		},
+	}, nil)
+
+	t.Run("pending", func(t *testing.T) {
+		assert.Nil(t, m.MigrateTo(context.TODO(), 2, false))
+
+		diff, err := m.Diff(context.TODO())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"CREATE TABLE IF NOT EXISTS public.third (id text primary key);",
+			"ALTER TABLE second ADD COLUMN IF NOT EXISTS num int;",
+			"CREATE INDEX idx_first_name ON first (name);",
+			"ALTER TABLE first ADD CONSTRAINT first_name_unique UNIQUE (name);",
+		}, diff)
+	})
+
+	t.Run("drift", func(t *testing.T) {
+		_, err := db.Exec("CREATE TABLE third (id text primary key); ALTER TABLE second ADD COLUMN num int;")
+		assert.Nil(t, err)
+
+		diff, err := m.Diff(context.TODO())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"CREATE INDEX idx_first_name ON first (name);",
+			"ALTER TABLE first ADD CONSTRAINT first_name_unique UNIQUE (name);",
+		}, diff)
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		assert.Nil(t, m.Apply(context.TODO()))
+
+		diff, err := m.Diff(context.TODO())
+		assert.Nil(t, err)
+		assert.Empty(t, diff)
+	})
+}
+
+func TestUpStatements(t *testing.T) {
+	t.Parallel()
+
+	stmts := upStatements(`-- +goose Up
+-- a comment
+CREATE TABLE tests (id text primary key,
+  name text);
+
+-- +goose StatementBegin
+INSERT INTO tests VALUES ('a', 'b'); INSERT INTO tests VALUES ('c', 'd');
+-- +goose StatementEnd
+DO $$
+BEGIN
+  PERFORM 1;
+END;
+$$;
+-- +goose Down
+DROP TABLE tests;`)
+
+	assert.Equal(t, []string{
+		"CREATE TABLE tests (id text primary key,\n  name text);",
+		"INSERT INTO tests VALUES ('a', 'b'); INSERT INTO tests VALUES ('c', 'd');",
+		"DO $$\nBEGIN\n  PERFORM 1;\nEND;\n$$;",
+	}, stmts)
+}
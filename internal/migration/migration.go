@@ -0,0 +1,626 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/pressly/goose/v3"
+
+	"github.com/pghq/go-store/provider"
+)
+
+// dir the directory of the migrations within the fs
+const dir = "migrations"
+
+var (
+	// mu guards goose, which keeps its configuration in globals
+	// it is held only around goose calls, never while waiting for the migration lock
+	mu sync.Mutex
+
+	// defaultTable the migration table of migrators without one, mu must be held
+	defaultTable = "goose_db_version"
+
+	// dollarQuote matches the delimiters of dollar quoted strings (e.g., $$ or $body$)
+	dollarQuote = regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+)
+
+// SetDefaultTable sets the migration table used by migrators without one
+func SetDefaultTable(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultTable = name
+}
+
+// Migrator applies and inspects the migrations of a database
+type Migrator struct {
+	db           *sql.DB
+	dialect      string
+	fs           fs.FS
+	hook         provider.MigrationHook
+	metrics      provider.MigrationMetrics
+	table        string
+	lockInterval time.Duration
+	lockTimeout  time.Duration
+}
+
+// WithTable records applied migrations in the table instead of the default
+// migrators with different tables track their migrations independently (e.g., for services sharing a database)
+func (m Migrator) WithTable(name string) Migrator {
+	m.table = name
+	return m
+}
+
+// WithMetrics records the duration of each migration applied and the schema version
+func (m Migrator) WithMetrics(metrics provider.MigrationMetrics) Migrator {
+	m.metrics = metrics
+	return m
+}
+
+// WithLock polls for the migration lock at the interval until the timeout
+// the lock prevents instances sharing the database (e.g., during a rolling deploy) from migrating concurrently
+func (m Migrator) WithLock(interval, timeout time.Duration) Migrator {
+	m.lockInterval = interval
+	m.lockTimeout = timeout
+	return m
+}
+
+// Apply pending migrations, one version at a time
+func (m Migrator) Apply(ctx context.Context) error {
+	if m.fs == nil {
+		return nil
+	}
+
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	defer unlock()
+	return m.up(ctx, goose.MaxVersion)
+}
+
+// MigrateTo migrates up or down to the version
+// down migrations are refused unless allowed (e.g., to prevent accidental data loss in production)
+func (m Migrator) MigrateTo(ctx context.Context, version int64, allowDown bool) error {
+	if m.fs == nil {
+		return trail.NewErrorBadRequest("no migrations were provided")
+	}
+
+	var migrations goose.Migrations
+	err := m.goose(func() error {
+		var err error
+		migrations, err = goose.CollectMigrations(dir, 0, goose.MaxVersion)
+		return err
+	})
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	exists := version == 0
+	for _, migration := range migrations {
+		exists = exists || migration.Version == version
+	}
+
+	if !exists {
+		return trail.NewErrorBadRequest(fmt.Sprintf("migration version %d does not exist", version))
+	}
+
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	defer unlock()
+
+	current, err := m.version()
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	if version >= current {
+		return m.up(ctx, version)
+	}
+
+	if !allowDown {
+		return trail.NewErrorBadRequest(fmt.Sprintf("migrating down from version %d to %d is not allowed", current, version))
+	}
+
+	if err := m.goose(func() error { return goose.DownTo(m.db, dir, version) }); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	return m.observeVersion()
+}
+
+// up applies pending migrations through the target version
+func (m Migrator) up(ctx context.Context, target int64) error {
+	var migrations goose.Migrations
+	err := m.goose(func() error {
+		var err error
+		migrations, err = m.pending()
+		return err
+	})
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	for _, migration := range migrations {
+		if migration.Version > target {
+			break
+		}
+
+		if m.hook != nil {
+			stmt, err := m.stmt(migration)
+			if err != nil {
+				return trail.Stacktrace(err)
+			}
+
+			if err := m.hook.Before(ctx, migration.Version, stmt); err != nil {
+				return trail.Stacktrace(err)
+			}
+		}
+
+		start := time.Now()
+		err := m.goose(func() error {
+			if err := goose.UpTo(m.db, dir, migration.Version); err != nil {
+				_ = goose.Down(m.db, dir)
+				return err
+			}
+
+			return nil
+		})
+		if err != nil {
+			return trail.Stacktrace(err)
+		}
+
+		duration := time.Since(start)
+		if m.metrics != nil {
+			m.metrics.ObserveMigration(migration.Version, duration)
+		} else {
+			trail.Debugf("migration: applied version %d in %s", migration.Version, duration)
+		}
+
+		if m.hook != nil {
+			if err := m.hook.After(ctx, migration.Version, duration); err != nil {
+				return trail.Stacktrace(err)
+			}
+		}
+	}
+
+	return m.observeVersion()
+}
+
+// observeVersion records the schema version, if metrics are configured
+func (m Migrator) observeVersion() error {
+	if m.metrics == nil {
+		return nil
+	}
+
+	current, err := m.version()
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	m.metrics.SetMigrationVersion(current)
+	return nil
+}
+
+// version gets the schema version of the database
+func (m Migrator) version() (int64, error) {
+	var current int64
+	err := m.goose(func() error {
+		var err error
+		current, err = goose.GetDBVersion(m.db)
+		return err
+	})
+
+	return current, trail.Stacktrace(err)
+}
+
+// Pending gets the up sql of migrations not yet applied without applying them
+func (m Migrator) Pending() ([]string, error) {
+	if m.fs == nil {
+		return nil, nil
+	}
+
+	var migrations goose.Migrations
+	err := m.goose(func() error {
+		var err error
+		migrations, err = m.pending()
+		return err
+	})
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	var pending []string
+	for _, migration := range migrations {
+		if filepath.Ext(migration.Source) != ".sql" {
+			continue
+		}
+
+		stmt, err := m.stmt(migration)
+		if err != nil {
+			return nil, trail.Stacktrace(err)
+		}
+
+		pending = append(pending, stmt)
+	}
+
+	return pending, nil
+}
+
+// Status gets the status of each migration
+func (m Migrator) Status() ([]provider.MigrationVersion, error) {
+	status := []provider.MigrationVersion{}
+	if m.fs == nil {
+		return status, nil
+	}
+
+	var migrations goose.Migrations
+	err := m.goose(func() error {
+		var err error
+		if migrations, err = goose.CollectMigrations(dir, 0, goose.MaxVersion); err != nil {
+			return err
+		}
+
+		_, err = goose.EnsureDBVersion(m.db)
+		return err
+	})
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	rows, err := m.db.Query(fmt.Sprintf("SELECT version_id, is_applied, tstamp FROM %s ORDER BY id", m.tableName()))
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	defer rows.Close()
+	records := make(map[int64]goose.MigrationRecord)
+	for rows.Next() {
+		var record goose.MigrationRecord
+		var tstamp sql.NullTime
+		if err := rows.Scan(&record.VersionID, &record.IsApplied, &tstamp); err != nil {
+			return nil, trail.Stacktrace(err)
+		}
+
+		record.TStamp = tstamp.Time
+		records[record.VersionID] = record
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	for _, migration := range migrations {
+		version := provider.MigrationVersion{
+			Version: migration.Version,
+			Name:    filepath.Base(migration.Source),
+		}
+
+		if record, present := records[migration.Version]; present && record.IsApplied {
+			appliedAt := record.TStamp
+			version.Applied = true
+			version.AppliedAt = &appliedAt
+		}
+
+		status = append(status, version)
+	}
+
+	return status, nil
+}
+
+// lock waits for the migration lock, if supported by the dialect, returning a func to release it
+// instances waiting for the lock find the migrations applied by the instance holding it once released
+func (m Migrator) lock(ctx context.Context) (func(), error) {
+	if m.dialect != "pgx" && m.dialect != "postgres" {
+		return func() {}, nil
+	}
+
+	if m.db == nil {
+		return nil, trail.NewError("no database was provided")
+	}
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	key := lockKey(m.tableName())
+	deadline := time.Now().Add(m.lockTimeout)
+	for {
+		var locked bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+			_ = conn.Close()
+			return nil, trail.Stacktrace(err)
+		}
+
+		if locked {
+			break
+		}
+
+		if !time.Now().Before(deadline) {
+			_ = conn.Close()
+			return nil, trail.NewErrorf("timed out waiting for migration lock %d", key)
+		}
+
+		trail.Infof("migration: waiting for lock %d held by another instance", key)
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+			return nil, trail.Stacktrace(ctx.Err())
+		case <-time.After(m.lockInterval):
+		}
+	}
+
+	return func() {
+		_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+		_ = conn.Close()
+	}, nil
+}
+
+// lockKey gets a stable advisory lock key for the migration table
+func lockKey(table string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(table))
+	return int64(h.Sum64())
+}
+
+// goose runs the callback with goose configured for the migrator, holding mu
+func (m Migrator) goose(fn func() error) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if err := m.setup(); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	return fn()
+}
+
+// tableName gets the migration table of the migrator
+func (m Migrator) tableName() string {
+	if m.table != "" {
+		return m.table
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return defaultTable
+}
+
+// setup goose for the migrator, mu must be held
+func (m Migrator) setup() error {
+	table := m.table
+	if table == "" {
+		table = defaultTable
+	}
+
+	goose.SetLogger(gooseLogger{})
+	goose.SetBaseFS(sqlFS{FS: m.fs})
+	goose.SetTableName(table)
+	return goose.SetDialect(m.dialect)
+}
+
+// pending gets the migrations newer than the database version, mu must be held
+func (m Migrator) pending() (goose.Migrations, error) {
+	migrations, err := goose.CollectMigrations(dir, 0, goose.MaxVersion)
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	current, err := goose.GetDBVersion(m.db)
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	var pending goose.Migrations
+	for _, migration := range migrations {
+		if migration.Version > current {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// stmt gets the up sql of the migration, if any
+func (m Migrator) stmt(migration *goose.Migration) (string, error) {
+	if filepath.Ext(migration.Source) != ".sql" {
+		return "", nil
+	}
+
+	data, err := fs.ReadFile(m.fs, migration.Source)
+	if err != nil {
+		return "", trail.Stacktrace(err)
+	}
+
+	return upSQL(string(data)), nil
+}
+
+// New creates a new migrator for the database
+func New(db *sql.DB, dialect string, fs fs.FS, hook provider.MigrationHook) Migrator {
+	return Migrator{
+		db:           db,
+		dialect:      dialect,
+		fs:           fs,
+		hook:         hook,
+		lockInterval: time.Second,
+		lockTimeout:  5 * time.Minute,
+	}
+}
+
+// IsLocal checks if the database host is local (e.g., for development)
+func IsLocal(host string) bool {
+	switch host {
+	case "", "localhost", "127.0.0.1", "::1":
+		return true
+	}
+
+	return strings.HasPrefix(host, "/")
+}
+
+// upSQL gets the up section of a sql migration
+func upSQL(data string) string {
+	var lines []string
+	up := false
+	for _, line := range strings.Split(data, "\n") {
+		if annotation := strings.TrimSpace(line); strings.HasPrefix(annotation, "-- +goose") {
+			switch strings.TrimSpace(strings.TrimPrefix(annotation, "-- +goose")) {
+			case "Up":
+				up = true
+			case "Down":
+				up = false
+			}
+
+			continue
+		}
+
+		if up {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// sqlFS a fs annotating sql migrations so goose does not split dollar quoted bodies (e.g., of functions or DO blocks) on semicolons
+type sqlFS struct {
+	fs.FS
+}
+
+func (f sqlFS) Open(name string) (fs.File, error) {
+	file, err := f.FS.Open(name)
+	if err != nil || filepath.Ext(name) != ".sql" {
+		return file, err
+	}
+
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := fs.ReadFile(f.FS, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqlFile{info: info, Reader: bytes.NewReader([]byte(annotate(string(data))))}, nil
+}
+
+// sqlFile an annotated sql migration
+type sqlFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f sqlFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f sqlFile) Close() error {
+	return nil
+}
+
+// annotate wraps statements containing dollar quoted strings in StatementBegin and StatementEnd annotations
+// statements within explicit annotations are left as is
+func annotate(data string) string {
+	var lines, stmt []string
+	quote, quoted, explicit := "", false, false
+	flush := func() {
+		if quoted {
+			lines = append(lines, "-- +goose StatementBegin")
+			lines = append(lines, stmt...)
+			lines = append(lines, "-- +goose StatementEnd")
+		} else {
+			lines = append(lines, stmt...)
+		}
+
+		stmt, quote, quoted = nil, "", false
+	}
+
+	for _, line := range strings.Split(data, "\n") {
+		if quote == "" && strings.HasPrefix(line, "-- +goose") {
+			flush()
+			switch strings.TrimSpace(strings.TrimPrefix(line, "-- +goose")) {
+			case "StatementBegin":
+				explicit = true
+			case "StatementEnd":
+				explicit = false
+			}
+
+			lines = append(lines, line)
+			continue
+		}
+
+		if explicit {
+			lines = append(lines, line)
+			continue
+		}
+
+		stmt = append(stmt, line)
+		for _, match := range dollarQuote.FindAllString(line, -1) {
+			switch quote {
+			case "":
+				quote, quoted = match, true
+			case match:
+				quote = ""
+			}
+		}
+
+		if quote == "" && endsStatement(line) {
+			flush()
+		}
+	}
+
+	flush()
+	return strings.Join(lines, "\n")
+}
+
+// endsStatement checks if the line ends a statement with a semicolon before any comment, as goose does
+func endsStatement(line string) bool {
+	prev := ""
+	for _, word := range strings.Fields(line) {
+		if strings.HasPrefix(word, "--") {
+			break
+		}
+
+		prev = word
+	}
+
+	return strings.HasSuffix(prev, ";")
+}
+
+// gooseLogger Custom goose logger implementation
+type gooseLogger struct{}
+
+func (g gooseLogger) Fatal(v ...interface{}) {
+	trail.Fatal(fmt.Sprint(v...))
+}
+
+func (g gooseLogger) Fatalf(format string, v ...interface{}) {
+	trail.Fatalf(format, v...)
+}
+
+func (g gooseLogger) Print(v ...interface{}) {
+	trail.Info(fmt.Sprint(v...))
+}
+
+func (g gooseLogger) Println(v ...interface{}) {
+	trail.Info(fmt.Sprint(v...))
+}
+
+func (g gooseLogger) Printf(format string, v ...interface{}) {
+	trail.Infof(format, v...)
+}
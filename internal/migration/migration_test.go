@@ -0,0 +1,625 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pghq/go-store/provider"
+	"github.com/pghq/go-store/provider/pg/pgtest"
+)
+
+func TestMigrator_Apply(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("no migrations", func(t *testing.T) {
+		assert.Nil(t, New(nil, "pgx", nil, nil).Apply(context.TODO()))
+	})
+
+	t.Run("bad dialect", func(t *testing.T) {
+		assert.NotNil(t, New(nil, "", fstest.MapFS{}, nil).Apply(context.TODO()))
+	})
+
+	t.Run("bad migration", func(t *testing.T) {
+		assert.NotNil(t, New(nil, "pgx", fstest.MapFS{}, nil).Apply(context.TODO()))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		dsn, cleanup, err := pgtest.Start()
+		if err != nil {
+			panic(err)
+		}
+
+		defer cleanup()
+
+		db, _ := sql.Open("pgx", dsn)
+		assert.Nil(t, New(db, "pgx", fstest.MapFS{
+			"migrations/00001_test.sql": &fstest.MapFile{
+				Data: []byte("-- +goose Up\nCREATE TABLE tests (id text primary key, name text, num int); create index idx_tests_name ON tests (name);"),
+			},
+		}, nil).Apply(context.TODO()))
+	})
+}
+
+func TestMigrator_DollarQuoted(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	dsn, cleanup, err := pgtest.Start()
+	if err != nil {
+		panic(err)
+	}
+
+	defer cleanup()
+
+	db, _ := sql.Open("pgx", dsn)
+	assert.Nil(t, New(db, "pgx", fstest.MapFS{
+		"migrations/00001_functions.sql": &fstest.MapFile{
+			Data: []byte(`-- +goose Up
+CREATE TABLE counters (id text primary key, n int);
+
+CREATE FUNCTION increment(counter text) RETURNS int AS $$
+DECLARE
+  result int;
+BEGIN
+  UPDATE counters SET n = n + 1 WHERE id = counter RETURNING n INTO result;
+  RETURN result;
+END;
+$$ LANGUAGE plpgsql;
+
+DO $body$
+BEGIN
+  INSERT INTO counters (id, n) VALUES ('foo', 0);
+END
+$body$;
+
+-- +goose StatementBegin
+CREATE FUNCTION decrement(counter text) RETURNS int AS $$
+BEGIN
+  UPDATE counters SET n = n - 1 WHERE id = counter;
+  RETURN 0;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION decrement;
+DROP FUNCTION increment;
+DROP TABLE counters;
+`),
+		},
+	}, nil).Apply(context.TODO()))
+
+	var n int
+	assert.Nil(t, db.QueryRow("SELECT increment('foo')").Scan(&n))
+	assert.Equal(t, 1, n)
+}
+
+func TestMigrator_Status(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("no migrations", func(t *testing.T) {
+		status, err := New(nil, "pgx", nil, nil).Status()
+		assert.Nil(t, err)
+		assert.NotNil(t, status)
+		assert.Empty(t, status)
+	})
+
+	t.Run("bad migration", func(t *testing.T) {
+		_, err := New(nil, "pgx", fstest.MapFS{}, nil).Status()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		dsn, cleanup, err := pgtest.Start()
+		if err != nil {
+			panic(err)
+		}
+
+		defer cleanup()
+
+		db, _ := sql.Open("pgx", dsn)
+		m := New(db, "pgx", fstest.MapFS{
+			"migrations/00001_first.sql": &fstest.MapFile{
+				Data: []byte("-- +goose Up\nCREATE TABLE first (id text primary key);"),
+			},
+		}, nil)
+
+		status, err := m.Status()
+		assert.Nil(t, err)
+		assert.Equal(t, []provider.MigrationVersion{{Version: 1, Name: "00001_first.sql"}}, status)
+
+		assert.Nil(t, m.Apply(context.TODO()))
+		status, err = m.Status()
+		assert.Nil(t, err)
+		assert.Len(t, status, 1)
+		assert.True(t, status[0].Applied)
+		assert.NotNil(t, status[0].AppliedAt)
+	})
+}
+
+func TestMigrator_MigrateTo(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("no migrations", func(t *testing.T) {
+		assert.NotNil(t, New(nil, "pgx", nil, nil).MigrateTo(context.TODO(), 1, false))
+	})
+
+	t.Run("bad dialect", func(t *testing.T) {
+		assert.NotNil(t, New(nil, "", fstest.MapFS{}, nil).MigrateTo(context.TODO(), 1, false))
+	})
+
+	t.Run("bad migration", func(t *testing.T) {
+		assert.NotNil(t, New(nil, "pgx", fstest.MapFS{}, nil).MigrateTo(context.TODO(), 1, false))
+	})
+
+	dsn, cleanup, err := pgtest.Start()
+	if err != nil {
+		panic(err)
+	}
+
+	defer cleanup()
+
+	db, _ := sql.Open("pgx", dsn)
+	m := New(db, "pgx", fstest.MapFS{
+		"migrations/00001_first.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nCREATE TABLE first (id text primary key);\n-- +goose Down\nDROP TABLE first;"),
+		},
+		"migrations/00002_second.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nCREATE TABLE second (id text primary key);\n-- +goose Down\nDROP TABLE second;"),
+		},
+		"migrations/00003_third.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nCREATE TABLE third (id text primary key);\n-- +goose Down\nDROP TABLE third;"),
+		},
+	}, nil)
+
+	t.Run("missing version", func(t *testing.T) {
+		err := m.MigrateTo(context.TODO(), 4, true)
+		assert.NotNil(t, err)
+		assert.True(t, trail.IsBadRequest(err))
+	})
+
+	t.Run("up then down", func(t *testing.T) {
+		assert.Nil(t, m.MigrateTo(context.TODO(), 2, false))
+		version, _ := m.version()
+		assert.Equal(t, int64(2), version)
+
+		assert.NotNil(t, m.MigrateTo(context.TODO(), 1, false))
+		version, _ = m.version()
+		assert.Equal(t, int64(2), version)
+
+		assert.Nil(t, m.MigrateTo(context.TODO(), 1, true))
+		version, _ = m.version()
+		assert.Equal(t, int64(1), version)
+	})
+}
+
+func TestMigrator_Hook(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	dsn, cleanup, err := pgtest.Start()
+	if err != nil {
+		panic(err)
+	}
+
+	defer cleanup()
+
+	db, _ := sql.Open("pgx", dsn)
+	migrations := fstest.MapFS{
+		"migrations/00001_first.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nCREATE TABLE first (id text primary key);"),
+		},
+		"migrations/00002_second.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nCREATE TABLE second (id text primary key);"),
+		},
+		"migrations/00003_third.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nCREATE TABLE third (id text primary key);"),
+		},
+	}
+
+	t.Run("before error", func(t *testing.T) {
+		h := hook{fail: 2}
+		assert.NotNil(t, New(db, "pgx", migrations, &h).Apply(context.TODO()))
+		assert.Equal(t, []string{"before 1: CREATE TABLE first (id text primary key);", "after 1", "before 2: CREATE TABLE second (id text primary key);"}, h.calls)
+
+		version, _ := New(db, "pgx", nil, nil).version()
+		assert.Equal(t, int64(1), version)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		h := hook{}
+		assert.Nil(t, New(db, "pgx", migrations, &h).Apply(context.TODO()))
+		assert.Equal(t, []string{"before 2: CREATE TABLE second (id text primary key);", "after 2", "before 3: CREATE TABLE third (id text primary key);", "after 3"}, h.calls)
+	})
+}
+
+func TestMigrator_Lock(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	dsn, cleanup, err := pgtest.Start()
+	if err != nil {
+		panic(err)
+	}
+
+	defer cleanup()
+
+	db, _ := sql.Open("pgx", dsn)
+	migrations := func(name string) fstest.MapFS {
+		return fstest.MapFS{
+			fmt.Sprintf("migrations/00001_%s.sql", name): &fstest.MapFile{
+				Data: []byte(fmt.Sprintf("-- +goose Up\nCREATE TABLE %s (id text primary key);", name)),
+			},
+		}
+	}
+
+	hold := func() *sql.Conn {
+		conn, _ := db.Conn(context.TODO())
+		_, _ = conn.ExecContext(context.TODO(), "SELECT pg_advisory_lock($1)", lockKey("goose_db_version"))
+		return conn
+	}
+
+	release := func(conn *sql.Conn) {
+		_, _ = conn.ExecContext(context.TODO(), "SELECT pg_advisory_unlock($1)", lockKey("goose_db_version"))
+		_ = conn.Close()
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		conn := hold()
+		defer release(conn)
+		assert.NotNil(t, New(db, "pgx", migrations("timeout"), nil).WithLock(10*time.Millisecond, 50*time.Millisecond).Apply(context.TODO()))
+	})
+
+	t.Run("bad context", func(t *testing.T) {
+		conn := hold()
+		defer release(conn)
+		ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+		defer cancel()
+		assert.NotNil(t, New(db, "pgx", migrations("timeout"), nil).WithLock(10*time.Millisecond, time.Minute).Apply(ctx))
+	})
+
+	t.Run("waits for lock", func(t *testing.T) {
+		conn := hold()
+		done := make(chan error, 1)
+		go func() {
+			done <- New(db, "pgx", migrations("waits"), nil).WithLock(10*time.Millisecond, time.Minute).Apply(context.TODO())
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("migrations applied while locked")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		release(conn)
+		assert.Nil(t, <-done)
+		version, _ := New(db, "pgx", nil, nil).version()
+		assert.Equal(t, int64(1), version)
+	})
+
+	t.Run("waiting does not block other migrators", func(t *testing.T) {
+		conn := hold()
+		defer release(conn)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- New(db, "pgx", migrations("blocked"), nil).WithLock(10*time.Millisecond, time.Minute).Apply(context.TODO())
+		}()
+
+		other, _ := sql.Open("pgx", dsn)
+		defer other.Close()
+		assert.Nil(t, New(other, "pgx", migrations("unblocked"), nil).WithTable("unblocked_versions").WithLock(10*time.Millisecond, time.Second).Apply(context.TODO()))
+
+		select {
+		case <-done:
+			t.Fatal("migrations applied while locked")
+		default:
+		}
+
+		release(conn)
+		assert.Nil(t, <-done)
+	})
+
+	t.Run("race", func(t *testing.T) {
+		// each migrator uses its own pool, so they contend on the advisory lock rather than a connection
+		pools := make([]*sql.DB, 3)
+		for i := range pools {
+			pools[i], _ = sql.Open("pgx", dsn)
+			defer pools[i].Close()
+		}
+
+		hooks := []*hook{{}, {}, {}}
+		fs := fstest.MapFS{
+			"migrations/00001_waits.sql": migrations("waits")["migrations/00001_waits.sql"],
+			"migrations/00002_race.sql": &fstest.MapFile{
+				Data: []byte("-- +goose Up\nCREATE TABLE race (id text primary key);"),
+			},
+		}
+
+		conn := hold()
+		errs := make(chan error, len(pools))
+		for i, db := range pools {
+			go func(h *hook, db *sql.DB) {
+				errs <- New(db, "pgx", fs, h).WithLock(10*time.Millisecond, time.Minute).Apply(context.TODO())
+			}(hooks[i], db)
+		}
+
+		select {
+		case <-errs:
+			t.Fatal("migrations applied while locked")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		release(conn)
+		for range pools {
+			assert.Nil(t, <-errs)
+		}
+
+		assert.Equal(t, 2, len(hooks[0].calls)+len(hooks[1].calls)+len(hooks[2].calls))
+	})
+}
+
+// hook records migration calls for tests
+type hook struct {
+	fail  int64
+	calls []string
+}
+
+func (h *hook) Before(_ context.Context, version int64, sql string) error {
+	h.calls = append(h.calls, fmt.Sprintf("before %d: %s", version, sql))
+	if version == h.fail {
+		return trail.NewError("an error has occurred")
+	}
+
+	return nil
+}
+
+func (h *hook) After(_ context.Context, version int64, _ time.Duration) error {
+	h.calls = append(h.calls, fmt.Sprintf("after %d", version))
+	return nil
+}
+
+func TestMigrator_Metrics(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	dsn, cleanup, err := pgtest.Start()
+	if err != nil {
+		panic(err)
+	}
+
+	defer cleanup()
+
+	db, _ := sql.Open("pgx", dsn)
+	m := New(db, "pgx", fstest.MapFS{
+		"migrations/00001_first.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nCREATE TABLE first (id text primary key);\n-- +goose Down\nDROP TABLE first;"),
+		},
+		"migrations/00002_second.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nCREATE TABLE second (id text primary key);\n-- +goose Down\nDROP TABLE second;"),
+		},
+	}, nil)
+
+	t.Run("without metrics", func(t *testing.T) {
+		assert.Nil(t, m.MigrateTo(context.TODO(), 1, false))
+		assert.Nil(t, m.MigrateTo(context.TODO(), 0, true))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		metrics := migrationMetrics{durations: make(map[int64]time.Duration)}
+		m := m.WithMetrics(&metrics)
+		assert.Nil(t, m.Apply(context.TODO()))
+		assert.Equal(t, int64(2), metrics.version)
+		assert.Len(t, metrics.durations, 2)
+
+		assert.Nil(t, m.MigrateTo(context.TODO(), 1, true))
+		assert.Equal(t, int64(1), metrics.version)
+		assert.Len(t, metrics.durations, 2)
+	})
+}
+
+type migrationMetrics struct {
+	durations map[int64]time.Duration
+	version   int64
+}
+
+func (m *migrationMetrics) ObserveMigration(version int64, duration time.Duration) {
+	m.durations[version] = duration
+}
+
+func (m *migrationMetrics) SetMigrationVersion(version int64) {
+	m.version = version
+}
+
+func TestMigrator_Table(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	dsn, cleanup, err := pgtest.Start()
+	if err != nil {
+		panic(err)
+	}
+
+	defer cleanup()
+
+	db, _ := sql.Open("pgx", dsn)
+	first := New(db, "pgx", fstest.MapFS{
+		"migrations/00001_first.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nCREATE TABLE first (id text primary key);"),
+		},
+	}, nil).WithTable("first_versions")
+
+	second := New(db, "pgx", fstest.MapFS{
+		"migrations/00001_second.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nCREATE TABLE second (id text primary key);"),
+		},
+		"migrations/00002_second.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nALTER TABLE second ADD COLUMN name text;"),
+		},
+	}, nil).WithTable("second_versions")
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, m := range []Migrator{first, second} {
+		wg.Add(1)
+		go func(i int, m Migrator) {
+			defer wg.Done()
+			errs[i] = m.Apply(context.TODO())
+		}(i, m)
+	}
+
+	wg.Wait()
+	assert.Equal(t, []error{nil, nil}, errs)
+
+	status, err := first.Status()
+	assert.Nil(t, err)
+	assert.Len(t, status, 1)
+	assert.True(t, status[0].Applied)
+
+	status, err = second.Status()
+	assert.Nil(t, err)
+	assert.Len(t, status, 2)
+	assert.True(t, status[1].Applied)
+
+	var n int
+	assert.Nil(t, db.QueryRow("SELECT COUNT(*) FROM first_versions WHERE version_id > 0").Scan(&n))
+	assert.Equal(t, 1, n)
+}
+
+// TestSetDefaultTable is not parallel, as the default table is shared by the migrators of other tests
+func TestSetDefaultTable(t *testing.T) {
+	trail.Testing()
+
+	defer SetDefaultTable("goose_db_version")
+	SetDefaultTable("default_versions")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Nil(t, New(nil, "pgx", fstest.MapFS{}, nil).setup())
+	assert.Equal(t, "default_versions", goose.TableName())
+
+	assert.Nil(t, New(nil, "pgx", fstest.MapFS{}, nil).WithTable("other_versions").setup())
+	assert.Equal(t, "other_versions", goose.TableName())
+}
+
+func TestMigrator_Pending(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("no migrations", func(t *testing.T) {
+		pending, err := New(nil, "pgx", nil, nil).Pending()
+		assert.Nil(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("bad dialect", func(t *testing.T) {
+		_, err := New(nil, "", fstest.MapFS{}, nil).Pending()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		dsn, cleanup, err := pgtest.Start()
+		if err != nil {
+			panic(err)
+		}
+
+		defer cleanup()
+
+		db, _ := sql.Open("pgx", dsn)
+		migrations := fstest.MapFS{
+			"migrations/00001_first.sql": &fstest.MapFile{
+				Data: []byte("-- +goose Up\nCREATE TABLE first (id text primary key);\n-- +goose Down\nDROP TABLE first;"),
+			},
+			"migrations/00002_second.sql": &fstest.MapFile{
+				Data: []byte("-- +goose Up\nCREATE TABLE second (id text primary key);\n-- +goose Down\nDROP TABLE second;"),
+			},
+		}
+		assert.Nil(t, New(db, "pgx", migrations, nil).Apply(context.TODO()))
+
+		migrations["migrations/00003_third.sql"] = &fstest.MapFile{
+			Data: []byte("-- +goose Up\n-- +goose StatementBegin\nCREATE TABLE third (id text primary key);\n-- +goose StatementEnd\n-- +goose Down\nDROP TABLE third;"),
+		}
+
+		pending, err := New(db, "pgx", migrations, nil).Pending()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"CREATE TABLE third (id text primary key);"}, pending)
+
+		pending, _ = New(db, "pgx", migrations, nil).Pending()
+		assert.Len(t, pending, 1)
+	})
+}
+
+func TestAnnotate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no dollar quotes", func(t *testing.T) {
+		data := "-- +goose Up\nCREATE TABLE tests (id text);\nINSERT INTO tests VALUES ($1);"
+		assert.Equal(t, data, annotate(data))
+	})
+
+	t.Run("dollar quotes", func(t *testing.T) {
+		data := "-- +goose Up\nCREATE TABLE tests (id text);\nDO $$\nBEGIN\n  PERFORM 1;\nEND\n$$;\n-- +goose Down\nDROP TABLE tests;"
+		assert.Equal(t, "-- +goose Up\nCREATE TABLE tests (id text);\n-- +goose StatementBegin\nDO $$\nBEGIN\n  PERFORM 1;\nEND\n$$;\n-- +goose StatementEnd\n-- +goose Down\nDROP TABLE tests;", annotate(data))
+	})
+
+	t.Run("nested dollar quotes", func(t *testing.T) {
+		data := "-- +goose Up\nDO $outer$\nBEGIN\n  EXECUTE $$SELECT 1;$$;\nEND\n$outer$;"
+		assert.Equal(t, "-- +goose Up\n-- +goose StatementBegin\nDO $outer$\nBEGIN\n  EXECUTE $$SELECT 1;$$;\nEND\n$outer$;\n-- +goose StatementEnd", annotate(data))
+	})
+
+	t.Run("explicit statements", func(t *testing.T) {
+		data := "-- +goose Up\n-- +goose StatementBegin\nDO $$\nBEGIN\n  PERFORM 1;\nEND\n$$;\n-- +goose StatementEnd"
+		assert.Equal(t, data, annotate(data))
+	})
+}
+
+func TestIsLocal(t *testing.T) {
+	t.Parallel()
+
+	t.Run("local", func(t *testing.T) {
+		assert.True(t, IsLocal("localhost"))
+		assert.True(t, IsLocal("127.0.0.1"))
+		assert.True(t, IsLocal("/var/run/postgresql"))
+	})
+
+	t.Run("remote", func(t *testing.T) {
+		assert.False(t, IsLocal("db.example.com"))
+	})
+}
+
+func TestGooseLogger(t *testing.T) {
+	t.Parallel()
+
+	l := gooseLogger{}
+	t.Run("print", func(t *testing.T) {
+		l.Print("an error has occurred")
+	})
+
+	t.Run("printf", func(t *testing.T) {
+		l.Printf("an %s has occurred", "error")
+	})
+
+	t.Run("println", func(t *testing.T) {
+		l.Println("an error has occurred")
+	})
+
+	t.Run("fatal", func(t *testing.T) {
+		l.Fatal("an error has occurred")
+	})
+
+	t.Run("fatalf", func(t *testing.T) {
+		l.Fatalf("an %s has occurred", "error")
+	})
+}
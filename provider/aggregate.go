@@ -0,0 +1,65 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pghq/go-tea/trail"
+)
+
+// aggregateFuncs the aggregate functions allowed
+var aggregateFuncs = map[string]bool{
+	"COUNT": true, "SUM": true, "AVG": true, "MIN": true, "MAX": true,
+	"BOOL_AND": true, "BOOL_OR": true,
+}
+
+// Aggregate an aggregate function of a column selected as the alias (e.g., {Func: "SUM", Column: "amount", Alias: "total"})
+// distinct aggregates only the distinct values of the column (e.g., COUNT(DISTINCT col))
+type Aggregate struct {
+	Func     string
+	Column   string
+	Alias    string
+	Distinct bool
+}
+
+// expr gets the select expression of the aggregate
+func (a Aggregate) expr() (string, error) {
+	fn := strings.ToUpper(a.Func)
+	if !aggregateFuncs[fn] {
+		return "", trail.NewErrorf("aggregate function %s is not supported", a.Func)
+	}
+
+	if !identifier.MatchString(a.Alias) {
+		return "", trail.NewErrorf("aggregate alias %s is not valid", a.Alias)
+	}
+
+	column := a.Column
+	switch {
+	case column == "*" && fn == "COUNT" && !a.Distinct:
+	case qualified(column):
+		if a.Distinct {
+			column = "DISTINCT " + column
+		}
+	default:
+		return "", trail.NewErrorf("aggregate column %s is not valid", a.Column)
+	}
+
+	return fmt.Sprintf("%s(%s) AS %s", fn, column, a.Alias), nil
+}
+
+// Aggregate gets a copy of the query also selecting the aggregates
+func (b *Builder) Aggregate(aggs ...Aggregate) *Builder {
+	c := *b
+	c.columns = append([]string(nil), b.columns...)
+	for _, agg := range aggs {
+		expr, err := agg.expr()
+		if err != nil {
+			c.err = err
+			return &c
+		}
+
+		c.columns = append(c.columns, expr)
+	}
+
+	return &c
+}
@@ -0,0 +1,43 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder_Aggregate(t *testing.T) {
+	t.Parallel()
+
+	base := func() *Builder {
+		return NewBuilder().From("orders").Where("status = ?", "paid")
+	}
+
+	t.Run("bad aggregate", func(t *testing.T) {
+		for _, agg := range []Aggregate{
+			{Func: "DROP", Column: "amount", Alias: "total"},
+			{Func: "SUM", Column: "amount; DROP TABLE orders", Alias: "total"},
+			{Func: "SUM", Column: "amount", Alias: "total amount"},
+			{Func: "SUM", Column: "*", Alias: "total"},
+			{Func: "COUNT", Column: "*", Alias: "n", Distinct: true},
+		} {
+			_, _, err := base().Aggregate(agg).Build()
+			assert.NotNil(t, err, agg)
+		}
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		query := base().Select("customer_id").GroupBy("customer_id")
+		stmt, args, err := query.Aggregate(
+			Aggregate{Func: "sum", Column: "amount", Alias: "total"},
+			Aggregate{Func: "COUNT", Column: "*", Alias: "n"},
+			Aggregate{Func: "COUNT", Column: "orders.product_id", Alias: "products", Distinct: true},
+		).Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT customer_id, SUM(amount) AS total, COUNT(*) AS n, COUNT(DISTINCT orders.product_id) AS products FROM orders WHERE status = ? GROUP BY customer_id", stmt)
+		assert.Equal(t, []interface{}{"paid"}, args)
+
+		stmt, _, _ = query.Build()
+		assert.Equal(t, "SELECT customer_id FROM orders WHERE status = ? GROUP BY customer_id", stmt)
+	})
+}
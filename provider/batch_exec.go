@@ -0,0 +1,27 @@
+package provider
+
+// BatchExecItem a single batch statement
+type BatchExecItem struct {
+	Spec Spec
+	Err  error
+}
+
+// BatchExec a list of batch statements
+type BatchExec []*BatchExecItem
+
+// Exec append a statement to the batch
+func (b *BatchExec) Exec(spec Spec) {
+	*b = append(*b, &BatchExecItem{Spec: spec})
+}
+
+// Errors gets the statement errors in the order they were queued
+func (b BatchExec) Errors() []error {
+	var errs []error
+	for _, item := range b {
+		if item.Err != nil {
+			errs = append(errs, item.Err)
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,36 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchExec_Exec(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		batch := BatchExec{}
+		batch.Exec(nil)
+		assert.NotEmpty(t, batch)
+	})
+}
+
+func TestBatchExec_Errors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no errors", func(t *testing.T) {
+		batch := BatchExec{}
+		batch.Exec(nil)
+		assert.Empty(t, batch.Errors())
+	})
+
+	t.Run("with errors", func(t *testing.T) {
+		batch := BatchExec{}
+		batch.Exec(nil)
+		batch.Exec(nil)
+		batch[1].Err = trail.NewError("an error has occurred")
+		assert.Len(t, batch.Errors(), 1)
+	})
+}
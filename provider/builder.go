@@ -0,0 +1,677 @@
+package provider
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/pghq/go-tea/trail"
+)
+
+var _ Spec = &Builder{}
+
+var (
+	// identifier matches plain (unqualified) column names
+	identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// reference matches column references, keywords and function names in expressions
+	reference = regexp.MustCompile(`'(?:[^']|'')*'|(?:::)?[A-Za-z_][A-Za-z0-9_.]*\s*\(?`)
+
+	// keywords that are not column references in expressions
+	keywords = map[string]bool{
+		"AND": true, "OR": true, "NOT": true, "NULL": true, "IS": true, "IN": true,
+		"LIKE": true, "ILIKE": true, "BETWEEN": true, "TRUE": true, "FALSE": true,
+		"ANY": true, "ALL": true, "EXISTS": true, "CASE": true, "WHEN": true,
+		"THEN": true, "ELSE": true, "END": true, "AS": true, "DISTINCT": true,
+	}
+)
+
+// Builder a fluent select query builder
+// queries are emitted with ? placeholders and are deterministic for identical inputs
+type Builder struct {
+	sb        squirrel.SelectBuilder
+	table     string
+	columns   []string
+	joins     int
+	strict    bool
+	filters   []string
+	ctes      []cte
+	recursive bool
+	grouped   bool
+	distinct  []string
+	orders    []order
+	lock      string
+	noWait    bool
+	skip      bool
+	indexes   []string
+	forced    []string
+	hintPlan  bool
+	having    bool
+	limit     int
+	cursor    string
+	cursorCol string
+	cursorDir string
+	fullText  []fullText
+	tsConfig  string
+	tsRank    bool
+	err       error
+}
+
+// order a sort expression of the ORDER BY clause
+type order struct {
+	expr string
+	args []interface{}
+}
+
+// column gets the column sorted by, if the expression is a plain column
+func (o order) column() string {
+	fields := strings.Fields(o.expr)
+	if len(fields) == 0 || len(fields) > 2 || !qualified(fields[0]) {
+		return ""
+	}
+
+	return fields[0]
+}
+
+// cte a named subquery for the WITH clause
+type cte struct {
+	name  string
+	query string
+	args  []interface{}
+}
+
+// With adds a common table expression to the query
+func (b *Builder) With(name, query string, args ...interface{}) *Builder {
+	b.ctes = append(b.ctes, cte{name: name, query: query, args: args})
+	return b
+}
+
+// WithRecursive allows common table expressions to reference themselves
+func (b *Builder) WithRecursive() *Builder {
+	b.recursive = true
+	return b
+}
+
+// Select adds columns to the query
+func (b *Builder) Select(cols ...string) *Builder {
+	b.columns = append(b.columns, cols...)
+	return b
+}
+
+// Window adds a window function column (e.g., ROW_NUMBER(), RANK(), LAG(col)) to the query
+func (b *Builder) Window(alias string, partition []string, orderBy []string, fn string) *Builder {
+	if !identifier.MatchString(alias) {
+		b.err = trail.NewErrorf("window alias %s is not valid", alias)
+		return b
+	}
+
+	var over []string
+	if len(partition) > 0 {
+		over = append(over, fmt.Sprintf("PARTITION BY %s", strings.Join(partition, ", ")))
+	}
+
+	if len(orderBy) > 0 {
+		over = append(over, fmt.Sprintf("ORDER BY %s", strings.Join(orderBy, ", ")))
+	}
+
+	b.columns = append(b.columns, fmt.Sprintf("%s OVER (%s) AS %s", fn, strings.Join(over, " "), alias))
+	return b
+}
+
+// From sets the table to query
+func (b *Builder) From(table string) *Builder {
+	b.table = table
+	b.sb = b.sb.From(table)
+	return b
+}
+
+// Join adds a join to the query
+func (b *Builder) Join(table, on string, args ...interface{}) *Builder {
+	b.joins++
+	b.sb = b.sb.Join(fmt.Sprintf("%s ON %s", table, on), args...)
+	return b
+}
+
+// InnerJoin adds an inner join to the query
+func (b *Builder) InnerJoin(table, on string, args ...interface{}) *Builder {
+	b.joins++
+	b.sb = b.sb.InnerJoin(fmt.Sprintf("%s ON %s", table, on), args...)
+	return b
+}
+
+// LeftJoin adds a left join to the query
+func (b *Builder) LeftJoin(table, on string, args ...interface{}) *Builder {
+	b.joins++
+	b.sb = b.sb.LeftJoin(fmt.Sprintf("%s ON %s", table, on), args...)
+	return b
+}
+
+// RightJoin adds a right join to the query
+func (b *Builder) RightJoin(table, on string, args ...interface{}) *Builder {
+	b.joins++
+	b.sb = b.sb.RightJoin(fmt.Sprintf("%s ON %s", table, on), args...)
+	return b
+}
+
+// WithStrictColumns requires columns in filter expressions to be qualified with a table name when joining
+// the schema is not known, so any unqualified column is considered ambiguous
+func (b *Builder) WithStrictColumns() *Builder {
+	b.strict = true
+	return b
+}
+
+// Where adds a filter expression to the query
+func (b *Builder) Where(expr string, args ...interface{}) *Builder {
+	b.filters = append(b.filters, expr)
+	b.sb = b.sb.Where(expr, args...)
+	return b
+}
+
+// WhereIn adds a filter matching the column against a list of values
+func (b *Builder) WhereIn(column string, values interface{}) *Builder {
+	return b.whereIn(column, values, false)
+}
+
+// WhereNotIn adds a filter excluding a list of values for the column
+func (b *Builder) WhereNotIn(column string, values interface{}) *Builder {
+	return b.whereIn(column, values, true)
+}
+
+// whereIn adds an IN or NOT IN filter to the query
+func (b *Builder) whereIn(column string, values interface{}, not bool) *Builder {
+	expr, args, err := inExpr(column, values, not)
+	if err != nil {
+		b.err = err
+		return b
+	}
+
+	b.sb = b.sb.Where(expr, args...)
+	return b
+}
+
+// WhereBetween adds a filter matching the column against an inclusive range
+func (b *Builder) WhereBetween(column string, low, high interface{}) *Builder {
+	return b.whereBetween(column, low, high, false)
+}
+
+// WhereNotBetween adds a filter excluding an inclusive range for the column
+func (b *Builder) WhereNotBetween(column string, low, high interface{}) *Builder {
+	return b.whereBetween(column, low, high, true)
+}
+
+// whereBetween adds a BETWEEN or NOT BETWEEN filter to the query
+func (b *Builder) whereBetween(column string, low, high interface{}, not bool) *Builder {
+	if reflect.ValueOf(low).Kind() != reflect.ValueOf(high).Kind() {
+		b.err = trail.NewErrorf("range of %T and %T is not valid", low, high)
+		return b
+	}
+
+	op := "BETWEEN"
+	if not {
+		op = "NOT BETWEEN"
+	}
+
+	b.sb = b.sb.Where(fmt.Sprintf("%s %s ? AND ?", column, op), low, high)
+	return b
+}
+
+// WhereJSON adds a filter expression on a jsonb column
+// nested paths (e.g., metadata.user.id) are separated by dots and op is a comparison (e.g., =, <)
+// or a jsonb operator (->>, ->, @>, ?, ?|, ?&)
+func (b *Builder) WhereJSON(column, path, op string, value interface{}) *Builder {
+	expr, arg, err := jsonExpr(column, path, op, value)
+	if err != nil {
+		b.err = err
+		return b
+	}
+
+	b.sb = b.sb.Where(expr, arg)
+	return b
+}
+
+// WhereFullText adds a full text search filter on the column
+// the text search config (lang) defaults to the builder config or english if empty
+func (b *Builder) WhereFullText(column, query, lang string) *Builder {
+	b.fullText = append(b.fullText, fullText{column: column, query: query, lang: lang})
+	return b
+}
+
+// WithTSConfig sets the default text search config for full text search filters
+func (b *Builder) WithTSConfig(config string) *Builder {
+	b.tsConfig = config
+	return b
+}
+
+// WithTSRank sorts results by the rank of the first full text search filter, after any other sort columns
+func (b *Builder) WithTSRank() *Builder {
+	b.tsRank = true
+	return b
+}
+
+// GroupBy adds grouping columns to the query
+func (b *Builder) GroupBy(cols ...string) *Builder {
+	b.grouped = true
+	b.sb = b.sb.GroupBy(cols...)
+	return b
+}
+
+// Having adds a filter expression on groups to the query
+func (b *Builder) Having(expr string, args ...interface{}) *Builder {
+	b.having = true
+	b.sb = b.sb.Having(expr, args...)
+	return b
+}
+
+// OrderBy adds a sort column to the query
+func (b *Builder) OrderBy(col string, desc bool) *Builder {
+	if desc {
+		col += " DESC"
+	}
+
+	b.orders = append(b.orders, order{expr: col})
+	return b
+}
+
+// OrderByExpr adds a sort expression to the query (e.g., a function of a column)
+func (b *Builder) OrderByExpr(expr string, args ...interface{}) *Builder {
+	b.orders = append(b.orders, order{expr: expr, args: args})
+	return b
+}
+
+// DistinctOn keeps the first row of each distinct combination of the columns (pg only)
+// the rows are sorted by the columns first, as pg requires, followed by any other sort expressions
+func (b *Builder) DistinctOn(cols ...string) *Builder {
+	for _, col := range cols {
+		if !qualified(col) {
+			b.err = trail.NewErrorf("distinct column %s is not a valid name", col)
+			return b
+		}
+	}
+
+	b.distinct = append(b.distinct, cols...)
+	return b
+}
+
+// UseIndex documents the index the query is expected to use with a comment (e.g., /* index: idx_tests_name */)
+func (b *Builder) UseIndex(index string) *Builder {
+	if !identifier.MatchString(index) {
+		b.err = trail.NewErrorf("index %s is not a valid name", index)
+		return b
+	}
+
+	b.indexes = append(b.indexes, index)
+	return b
+}
+
+// ForceIndex hints the planner to scan the table with the index
+// the hint is only emitted WithPgHintPlan, as it requires the pg_hint_plan extension
+func (b *Builder) ForceIndex(index string) *Builder {
+	if !identifier.MatchString(index) {
+		b.err = trail.NewErrorf("index %s is not a valid name", index)
+		return b
+	}
+
+	b.forced = append(b.forced, index)
+	return b
+}
+
+// WithPgHintPlan emits the forced index hints in the pg_hint_plan format (e.g., /*+ IndexScan(tests idx_tests_name) */)
+func (b *Builder) WithPgHintPlan() *Builder {
+	b.hintPlan = true
+	return b
+}
+
+// Limit sets the max number of results
+func (b *Builder) Limit(n int) *Builder {
+	b.limit = n
+	b.sb = b.sb.Limit(uint64(n))
+	return b
+}
+
+// Offset sets the number of results to skip
+func (b *Builder) Offset(n int) *Builder {
+	b.sb = b.sb.Offset(uint64(n))
+	return b
+}
+
+// CursorColumn sets the column and direction (asc or desc) used for cursor pagination
+func (b *Builder) CursorColumn(col, direction string) *Builder {
+	b.cursorCol = col
+	b.cursorDir = strings.ToUpper(direction)
+	return b
+}
+
+// Cursor resumes the query after the position encoded in the cursor
+func (b *Builder) Cursor(encoded string) *Builder {
+	b.cursor = encoded
+	return b
+}
+
+// NextCursor gets the cursor for the page following the results in v
+// an empty cursor is returned when there are no more results
+func (b *Builder) NextCursor(v interface{}) (string, error) {
+	if b.cursorCol == "" {
+		return "", trail.NewError("cursor column is not set")
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() == reflect.Slice && b.limit > 0 && rv.Len() < b.limit {
+		return "", nil
+	}
+
+	value, ok := cursorValue(v, b.cursorCol)
+	if !ok {
+		return "", nil
+	}
+
+	return EncodeCursor(value)
+}
+
+// ForUpdate locks the selected rows until the transaction ends
+func (b *Builder) ForUpdate() *Builder {
+	if b.lock == "" {
+		b.lock = "FOR UPDATE"
+	}
+
+	return b
+}
+
+// ForShare locks the selected rows against writes until the transaction ends, allowing other transactions to share the lock
+func (b *Builder) ForShare() *Builder {
+	b.lock = "FOR SHARE"
+	return b
+}
+
+// SkipLocked locks the selected rows, skipping rows locked by other transactions (e.g., for job queues)
+// rows are locked for update unless ForShare is used
+func (b *Builder) SkipLocked() *Builder {
+	b.skip = true
+	return b
+}
+
+// NoWait locks the selected rows, failing rather than waiting for rows locked by other transactions
+// rows are locked for update unless ForShare is used
+func (b *Builder) NoWait() *Builder {
+	b.noWait = true
+	return b
+}
+
+// NotDeleted gets a copy of the query excluding rows soft deleted using the column
+func (b *Builder) NotDeleted(col string) *Builder {
+	c := *b
+	c.columns = append([]string(nil), b.columns...)
+	c.sb = c.sb.Where(fmt.Sprintf("%s IS NULL", col))
+	return &c
+}
+
+// Filter gets a copy of the query with the filters added
+func (b *Builder) Filter(filters ...squirrel.Sqlizer) *Builder {
+	c := *b
+	c.columns = append([]string(nil), b.columns...)
+	for _, filter := range filters {
+		c.sb = c.sb.Where(filter)
+	}
+
+	return &c
+}
+
+// Exists gets a copy of the query selecting at most one row without fetching its columns
+func (b *Builder) Exists() *Builder {
+	c := *b
+	c.columns = []string{"1"}
+	c.limit = 1
+	c.sb = c.sb.Limit(1)
+	return &c
+}
+
+// Build the sql statement and its arguments
+func (b *Builder) Build() (string, []interface{}, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+
+	if b.noWait && b.skip {
+		return "", nil, trail.NewError("rows can not be locked with both nowait and skip locked")
+	}
+
+	if b.having && !b.grouped {
+		return "", nil, trail.NewError("having requires grouping columns")
+	}
+
+	if b.joins > 0 {
+		for _, col := range b.columns {
+			if identifier.MatchString(col) {
+				return "", nil, trail.NewErrorf("column %s is ambiguous, qualify it with a table name", col)
+			}
+		}
+
+		for _, expr := range b.filters {
+			if col, present := unqualified(expr); b.strict && present {
+				return "", nil, trail.NewErrorf("column %s is ambiguous, qualify it with a table name", col)
+			}
+		}
+	}
+
+	hints, err := b.hints()
+	if err != nil {
+		return "", nil, trail.Stacktrace(err)
+	}
+
+	sb := b.sb.Columns(b.columns...)
+	if len(hints) > 0 {
+		sb = sb.Options(hints...)
+	}
+
+	orders := b.orders
+	if len(b.distinct) > 0 {
+		for _, col := range b.distinct {
+			if !b.selects(col) {
+				return "", nil, trail.NewErrorf("distinct column %s is not selected", col)
+			}
+		}
+
+		sb = sb.Options(fmt.Sprintf("DISTINCT ON (%s)", strings.Join(b.distinct, ", ")))
+		orders = b.distinctOrders()
+	}
+
+	for _, o := range orders {
+		sb = sb.OrderByClause(o.expr, o.args...)
+	}
+
+	if len(b.ctes) > 0 {
+		var defs []string
+		var args []interface{}
+		for _, c := range b.ctes {
+			defs = append(defs, fmt.Sprintf("%s AS (%s)", c.name, c.query))
+			args = append(args, c.args...)
+		}
+
+		with := "WITH"
+		if b.recursive {
+			with = "WITH RECURSIVE"
+		}
+
+		sb = sb.Prefix(fmt.Sprintf("%s %s", with, strings.Join(defs, ", ")), args...)
+	}
+
+	for i, ft := range b.fullText {
+		vector, query, err := ft.exprs(b.tsConfig)
+		if err != nil {
+			return "", nil, trail.Stacktrace(err)
+		}
+
+		sb = sb.Where(fmt.Sprintf("%s @@ %s", vector, query), ft.query)
+		if i == 0 && b.tsRank {
+			sb = sb.OrderByClause(fmt.Sprintf("ts_rank(%s, %s) DESC", vector, query), ft.query)
+		}
+	}
+
+	if b.cursorCol != "" {
+		op := ">"
+		switch b.cursorDir {
+		case "", "ASC":
+		case "DESC":
+			op = "<"
+		default:
+			return "", nil, trail.NewErrorf("cursor direction %s is not supported", b.cursorDir)
+		}
+
+		if b.cursor != "" {
+			v, err := DecodeCursor(b.cursor)
+			if err != nil {
+				return "", nil, trail.Stacktrace(err)
+			}
+
+			sb = sb.Where(fmt.Sprintf("%s %s ?", b.cursorCol, op), v)
+		}
+
+		sb = sb.OrderBy(strings.TrimSpace(fmt.Sprintf("%s %s", b.cursorCol, b.cursorDir)))
+	}
+
+	if lock := b.locking(); lock != "" {
+		sb = sb.Suffix(lock)
+	}
+
+	return sb.ToSql()
+}
+
+// locking gets the locking clause of the query, if any
+func (b *Builder) locking() string {
+	lock := b.lock
+	if lock == "" && (b.noWait || b.skip) {
+		lock = "FOR UPDATE"
+	}
+
+	switch {
+	case b.noWait:
+		lock += " NOWAIT"
+	case b.skip:
+		lock += " SKIP LOCKED"
+	}
+
+	return lock
+}
+
+// selects checks if the column is selected by the query, by name, alias or a wildcard
+func (b *Builder) selects(col string) bool {
+	name := col[strings.LastIndex(col, ".")+1:]
+	for _, c := range b.columns {
+		fields := strings.Fields(c)
+		if len(fields) == 0 {
+			continue
+		}
+
+		last := fields[len(fields)-1]
+		switch {
+		case c == col, last == name, c == "*", strings.HasSuffix(c, ".*"):
+			return true
+		case len(fields) == 1 && last[strings.LastIndex(last, ".")+1:] == name:
+			return true
+		}
+	}
+
+	return false
+}
+
+// distinctOrders gets the sort expressions led by the distinct columns
+// distinct columns not already leading the sort expressions are prepended
+func (b *Builder) distinctOrders() []order {
+	distinct := make(map[string]bool, len(b.distinct))
+	for _, col := range b.distinct {
+		distinct[col] = true
+	}
+
+	leading := make(map[string]bool)
+	for _, o := range b.orders {
+		if !distinct[o.column()] {
+			break
+		}
+
+		leading[o.column()] = true
+	}
+
+	var orders []order
+	for _, col := range b.distinct {
+		if !leading[col] {
+			orders = append(orders, order{expr: col})
+		}
+	}
+
+	return append(orders, b.orders...)
+}
+
+// hints gets the planner hint and index comments placed after the SELECT keyword
+func (b *Builder) hints() ([]string, error) {
+	var hints []string
+	if b.hintPlan && len(b.forced) > 0 {
+		fields := strings.Fields(b.table)
+		if len(fields) == 0 {
+			return nil, trail.NewError("forcing an index requires a table")
+		}
+
+		// hints refer to the table by its alias, if any
+		table := fields[len(fields)-1]
+		var scans []string
+		for _, index := range b.forced {
+			scans = append(scans, fmt.Sprintf("IndexScan(%s %s)", table, index))
+		}
+
+		hints = append(hints, fmt.Sprintf("/*+ %s */", strings.Join(scans, " ")))
+	}
+
+	for _, index := range b.indexes {
+		hints = append(hints, fmt.Sprintf("/* index: %s */", index))
+	}
+
+	return hints, nil
+}
+
+// Id gets a stable identifier for the query (e.g., for caching)
+func (b *Builder) Id() interface{} {
+	stmt, args, err := b.Build()
+	if err != nil {
+		return nil
+	}
+
+	return fmt.Sprintf("%s %v", stmt, args)
+}
+
+// ToSql converts the builder to sql
+func (b *Builder) ToSql() (string, []interface{}, error) {
+	return b.Build()
+}
+
+// unqualified gets the first column in the expression not qualified with a table name
+func unqualified(expr string) (string, bool) {
+	for _, ref := range reference.FindAllString(expr, -1) {
+		// skip string literals, casts and function names
+		if strings.HasPrefix(ref, "'") || strings.HasPrefix(ref, "::") || strings.HasSuffix(ref, "(") {
+			continue
+		}
+
+		ref = strings.TrimSpace(ref)
+		if !strings.Contains(ref, ".") && !keywords[strings.ToUpper(ref)] {
+			return ref, true
+		}
+	}
+
+	return "", false
+}
+
+// qualified checks if the name is a column name, optionally qualified with a table name
+func qualified(name string) bool {
+	for _, part := range strings.Split(name, ".") {
+		if !identifier.MatchString(part) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewBuilder creates a new query builder
+func NewBuilder() *Builder {
+	return &Builder{sb: squirrel.StatementBuilder.Select()}
+}
@@ -0,0 +1,407 @@
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	t.Parallel()
+
+	t.Run("missing columns", func(t *testing.T) {
+		_, _, err := NewBuilder().From("tests").Build()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ambiguous column", func(t *testing.T) {
+		_, _, err := NewBuilder().
+			Select("id").
+			From("tests t").
+			Join("units u", "u.test_id = t.id").
+			Build()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("join", func(t *testing.T) {
+		stmt, args, err := NewBuilder().
+			Select("t.id", "u.name").
+			From("tests t").
+			Join("units u", "u.test_id = t.id AND u.num > ?", 1).
+			Where("t.id = ?", "foo").
+			Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT t.id, u.name FROM tests t JOIN units u ON u.test_id = t.id AND u.num > ? WHERE t.id = ?", stmt)
+		assert.Equal(t, []interface{}{1, "foo"}, args)
+	})
+
+	t.Run("having without group by", func(t *testing.T) {
+		_, _, err := NewBuilder().
+			Select("COUNT(*)").
+			From("tests").
+			Having("COUNT(*) > ?", 5).
+			Build()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("group by", func(t *testing.T) {
+		stmt, args, err := NewBuilder().
+			Select("status", "COUNT(*)").
+			From("orders").
+			Where("created_at > ?", "2022-01-01").
+			GroupBy("status").
+			Having("COUNT(*) > ?", 5).
+			OrderBy("status", false).
+			Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT status, COUNT(*) FROM orders WHERE created_at > ? GROUP BY status HAVING COUNT(*) > ? ORDER BY status", stmt)
+		assert.Equal(t, []interface{}{"2022-01-01", 5}, args)
+	})
+
+	t.Run("with", func(t *testing.T) {
+		stmt, args, err := NewBuilder().
+			With("active", "SELECT id FROM tests WHERE num > ?", 1).
+			With("named", "SELECT id FROM tests WHERE name = ?", "foo").
+			Select("a.id").
+			From("active a").
+			InnerJoin("named n", "n.id = a.id").
+			Where("a.id <> ?", "bar").
+			Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "WITH active AS (SELECT id FROM tests WHERE num > ?), named AS (SELECT id FROM tests WHERE name = ?) SELECT a.id FROM active a INNER JOIN named n ON n.id = a.id WHERE a.id <> ?", stmt)
+		assert.Equal(t, []interface{}{1, "foo", "bar"}, args)
+	})
+
+	t.Run("with recursive", func(t *testing.T) {
+		stmt, args, err := NewBuilder().
+			With("n(i)", "SELECT ? UNION ALL SELECT i + 1 FROM n WHERE i < ?", 1, 5).
+			WithRecursive().
+			Select("i").
+			From("n").
+			Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "WITH RECURSIVE n(i) AS (SELECT ? UNION ALL SELECT i + 1 FROM n WHERE i < ?) SELECT i FROM n", stmt)
+		assert.Equal(t, []interface{}{1, 5}, args)
+	})
+
+	t.Run("bad window alias", func(t *testing.T) {
+		_, _, err := NewBuilder().
+			Select("id").
+			Window("row number", nil, nil, "ROW_NUMBER()").
+			From("tests").
+			Build()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("window", func(t *testing.T) {
+		inner, args, err := NewBuilder().
+			Select("id").
+			Window("rn", []string{"user_id"}, []string{"created_at DESC"}, "ROW_NUMBER()").
+			Window("prev", nil, []string{"created_at"}, "LAG(id)").
+			From("orders").
+			Where("status = ?", "paid").
+			Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY created_at DESC) AS rn, LAG(id) OVER (ORDER BY created_at) AS prev FROM orders WHERE status = ?", inner)
+
+		stmt, args, err := NewBuilder().
+			With("ranked", inner, args...).
+			Select("id").
+			From("ranked").
+			Where("rn = ?", 1).
+			Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "WITH ranked AS ("+inner+") SELECT id FROM ranked WHERE rn = ?", stmt)
+		assert.Equal(t, []interface{}{"paid", 1}, args)
+	})
+
+	t.Run("between", func(t *testing.T) {
+		base := func() *Builder {
+			return NewBuilder().Select("id").From("tests")
+		}
+
+		_, _, err := base().WhereBetween("num", 1, "10").Build()
+		assert.NotNil(t, err)
+
+		_, _, err = base().WhereBetween("num", nil, 10).Build()
+		assert.NotNil(t, err)
+
+		stmt, args, err := base().Where("name = ?", "foo").WhereBetween("num", 1, 10).Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id FROM tests WHERE name = ? AND num BETWEEN ? AND ?", stmt)
+		assert.Equal(t, []interface{}{"foo", 1, 10}, args)
+
+		stmt, args, _ = base().WhereNotBetween("score", 0.5, 1.5).Build()
+		assert.Equal(t, "SELECT id FROM tests WHERE score NOT BETWEEN ? AND ?", stmt)
+		assert.Equal(t, []interface{}{0.5, 1.5}, args)
+
+		now := time.Now()
+		_, args, _ = base().WhereBetween("created_at", now.Add(-time.Hour), now).Build()
+		assert.Equal(t, []interface{}{now.Add(-time.Hour), now}, args)
+
+		stmt, args, _ = base().WhereBetween("name", "a", "m").Build()
+		assert.Equal(t, "SELECT id FROM tests WHERE name BETWEEN ? AND ?", stmt)
+		assert.Equal(t, []interface{}{"a", "m"}, args)
+	})
+
+	t.Run("for update", func(t *testing.T) {
+		stmt, _, err := NewBuilder().Select("id").From("jobs").Where("status = ?", "pending").Limit(1).ForUpdate().Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id FROM jobs WHERE status = ? LIMIT 1 FOR UPDATE", stmt)
+
+		stmt, _, _ = NewBuilder().Select("id").From("jobs").SkipLocked().ForUpdate().Build()
+		assert.Equal(t, "SELECT id FROM jobs FOR UPDATE SKIP LOCKED", stmt)
+	})
+
+	t.Run("for share", func(t *testing.T) {
+		stmt, _, err := NewBuilder().Select("id").From("jobs").ForShare().Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id FROM jobs FOR SHARE", stmt)
+
+		stmt, _, _ = NewBuilder().Select("id").From("jobs").SkipLocked().ForShare().ForUpdate().Build()
+		assert.Equal(t, "SELECT id FROM jobs FOR SHARE SKIP LOCKED", stmt)
+
+		stmt, _, _ = NewBuilder().Select("id").From("jobs").ForShare().NoWait().Build()
+		assert.Equal(t, "SELECT id FROM jobs FOR SHARE NOWAIT", stmt)
+	})
+
+	t.Run("nowait", func(t *testing.T) {
+		stmt, _, err := NewBuilder().Select("id").From("jobs").NoWait().Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id FROM jobs FOR UPDATE NOWAIT", stmt)
+
+		_, _, err = NewBuilder().Select("id").From("jobs").NoWait().SkipLocked().Build()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("order by expression", func(t *testing.T) {
+		stmt, args, err := NewBuilder().Select("id").From("tests").OrderByExpr("array_position(?::text[], id)", []string{"b", "a"}).Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id FROM tests ORDER BY array_position(?::text[], id)", stmt)
+		assert.Equal(t, []interface{}{[]string{"b", "a"}}, args)
+	})
+
+	t.Run("bad index", func(t *testing.T) {
+		_, _, err := NewBuilder().Select("id").From("tests").UseIndex("idx */ DROP").Build()
+		assert.NotNil(t, err)
+
+		_, _, err = NewBuilder().Select("id").From("tests").ForceIndex("idx */ DROP").Build()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("force index without table", func(t *testing.T) {
+		_, _, err := NewBuilder().Select("1").ForceIndex("idx_tests_name").WithPgHintPlan().Build()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("use index", func(t *testing.T) {
+		stmt, _, err := NewBuilder().Select("id").From("tests").Where("name = ?", "foo").UseIndex("idx_tests_name").Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT /* index: idx_tests_name */ id FROM tests WHERE name = ?", stmt)
+	})
+
+	t.Run("force index", func(t *testing.T) {
+		query := NewBuilder().Select("t.id").From("tests t").Where("t.name = ?", "foo").ForceIndex("idx_tests_name").UseIndex("idx_tests_name")
+		stmt, _, err := query.Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT /* index: idx_tests_name */ t.id FROM tests t WHERE t.name = ?", stmt)
+
+		stmt, _, err = query.WithPgHintPlan().Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT /*+ IndexScan(t idx_tests_name) */ /* index: idx_tests_name */ t.id FROM tests t WHERE t.name = ?", stmt)
+	})
+
+	t.Run("distinct on", func(t *testing.T) {
+		stmt, _, err := NewBuilder().Select("name", "num").From("tests").DistinctOn("name").Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT DISTINCT ON (name) name, num FROM tests ORDER BY name", stmt)
+
+		stmt, _, err = NewBuilder().Select("name", "num").From("tests").DistinctOn("name").OrderBy("name", true).OrderBy("num", false).Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT DISTINCT ON (name) name, num FROM tests ORDER BY name DESC, num", stmt)
+
+		stmt, _, err = NewBuilder().Select("*").From("tests").DistinctOn("name", "id").OrderBy("id", false).OrderBy("num", true).Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT DISTINCT ON (name, id) * FROM tests ORDER BY name, id, num DESC", stmt)
+
+		stmt, _, err = NewBuilder().Select("t.name", "u.name AS unit").From("tests t").Join("units u", "u.test_id = t.id").DistinctOn("t.name", "unit").Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT DISTINCT ON (t.name, unit) t.name, u.name AS unit FROM tests t JOIN units u ON u.test_id = t.id ORDER BY t.name, unit", stmt)
+	})
+
+	t.Run("distinct on column not selected", func(t *testing.T) {
+		_, _, err := NewBuilder().Select("id").From("tests").DistinctOn("name").Build()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("bad distinct on column", func(t *testing.T) {
+		_, _, err := NewBuilder().Select("id").From("tests").DistinctOn("id; DROP TABLE tests").Build()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("join types", func(t *testing.T) {
+		stmt, _, err := NewBuilder().
+			Select("t.id", "u.name", "n.name").
+			From("tests t").
+			InnerJoin("units u", "u.test_id = t.id").
+			LeftJoin("labels l", "l.unit_id = u.id").
+			RightJoin("notes n", "n.test_id = t.id").
+			Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT t.id, u.name, n.name FROM tests t INNER JOIN units u ON u.test_id = t.id LEFT JOIN labels l ON l.unit_id = u.id RIGHT JOIN notes n ON n.test_id = t.id", stmt)
+	})
+
+	t.Run("strict columns", func(t *testing.T) {
+		_, _, err := NewBuilder().
+			Select("t.id").
+			From("tests t").
+			InnerJoin("units u", "u.test_id = t.id").
+			Where("name = ?", "foo").
+			WithStrictColumns().
+			Build()
+		assert.NotNil(t, err)
+
+		stmt, _, err := NewBuilder().
+			Select("t.id").
+			From("tests t").
+			InnerJoin("units u", "u.test_id = t.id").
+			Where("lower(u.name) = 'name' AND t.data->>'key' = ?::text AND u.num IS NOT NULL", "foo").
+			WithStrictColumns().
+			Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT t.id FROM tests t INNER JOIN units u ON u.test_id = t.id WHERE lower(u.name) = 'name' AND t.data->>'key' = ?::text AND u.num IS NOT NULL", stmt)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		stmt, args, err := NewBuilder().
+			Select("id", "name").
+			From("tests").
+			Where("id = ?", "foo").
+			Where("num > ?", 1).
+			OrderBy("name", true).
+			OrderBy("id", false).
+			Limit(10).
+			Offset(20).
+			Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id, name FROM tests WHERE id = ? AND num > ? ORDER BY name DESC, id LIMIT 10 OFFSET 20", stmt)
+		assert.Equal(t, []interface{}{"foo", 1}, args)
+	})
+}
+
+func TestBuilder_Cursor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("bad cursor", func(t *testing.T) {
+		_, _, err := NewBuilder().Select("id").From("tests").CursorColumn("id", "asc").Cursor("%").Build()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("bad direction", func(t *testing.T) {
+		_, _, err := NewBuilder().Select("id").From("tests").CursorColumn("id", "up").Build()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("first page", func(t *testing.T) {
+		stmt, _, err := NewBuilder().Select("id").From("tests").CursorColumn("id", "asc").Limit(2).Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id FROM tests ORDER BY id ASC LIMIT 2", stmt)
+	})
+
+	t.Run("forward", func(t *testing.T) {
+		c, _ := EncodeCursor("foo")
+		stmt, args, err := NewBuilder().Select("id").From("tests").CursorColumn("id", "asc").Cursor(c).Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id FROM tests WHERE id > ? ORDER BY id ASC", stmt)
+		assert.Equal(t, []interface{}{"foo"}, args)
+	})
+
+	t.Run("backward", func(t *testing.T) {
+		c, _ := EncodeCursor("foo")
+		stmt, _, err := NewBuilder().Select("id").From("tests").CursorColumn("id", "desc").Cursor(c).Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id FROM tests WHERE id < ? ORDER BY id DESC", stmt)
+	})
+}
+
+func TestBuilder_NextCursor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("missing cursor column", func(t *testing.T) {
+		_, err := NewBuilder().NextCursor(nil)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("last page", func(t *testing.T) {
+		v := []struct{ Id string }{{Id: "foo"}}
+		c, err := NewBuilder().CursorColumn("id", "asc").Limit(2).NextCursor(&v)
+		assert.Nil(t, err)
+		assert.Empty(t, c)
+	})
+
+	t.Run("struct", func(t *testing.T) {
+		v := []struct {
+			Key string `db:"id"`
+		}{{Key: "foo"}, {Key: "bar"}}
+		c, err := NewBuilder().CursorColumn("t.id", "asc").Limit(2).NextCursor(&v)
+		assert.Nil(t, err)
+		value, _ := DecodeCursor(c)
+		assert.Equal(t, "bar", value)
+	})
+
+	t.Run("map", func(t *testing.T) {
+		v := []map[string]interface{}{{"created_at": 1}}
+		c, err := NewBuilder().CursorColumn("created_at", "desc").NextCursor(v)
+		assert.Nil(t, err)
+		value, _ := DecodeCursor(c)
+		assert.Equal(t, int64(1), value)
+	})
+}
+
+func TestBuilder_NotDeleted(t *testing.T) {
+	t.Parallel()
+
+	t.Run("does not modify the query", func(t *testing.T) {
+		b := NewBuilder().Select("id").From("tests").Where("id = ?", "foo")
+		stmt, args, err := b.NotDeleted("deleted_at").Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id FROM tests WHERE id = ? AND deleted_at IS NULL", stmt)
+		assert.Equal(t, []interface{}{"foo"}, args)
+
+		stmt, _, _ = b.Build()
+		assert.Equal(t, "SELECT id FROM tests WHERE id = ?", stmt)
+	})
+}
+
+func TestBuilder_Filter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("does not modify the query", func(t *testing.T) {
+		b := NewBuilder().Select("id").From("tests").Where("id = ?", "foo")
+		stmt, args, err := b.Filter(squirrel.ILike{"name": "%bar%"}, squirrel.Expr("name SIMILAR TO ?", "b(a|o)r")).Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id FROM tests WHERE id = ? AND name ILIKE ? AND name SIMILAR TO ?", stmt)
+		assert.Equal(t, []interface{}{"foo", "%bar%", "b(a|o)r"}, args)
+
+		stmt, _, _ = b.Build()
+		assert.Equal(t, "SELECT id FROM tests WHERE id = ?", stmt)
+	})
+}
+
+func TestBuilder_Id(t *testing.T) {
+	t.Parallel()
+
+	t.Run("bad query", func(t *testing.T) {
+		assert.Nil(t, NewBuilder().Id())
+	})
+
+	t.Run("deterministic", func(t *testing.T) {
+		a := NewBuilder().Select("id").From("tests").Where("id = ?", "foo")
+		b := NewBuilder().Select("id").From("tests").Where("id = ?", "foo")
+		c := NewBuilder().Select("id").From("tests").Where("id = ?", "bar")
+		assert.Equal(t, a.Id(), b.Id())
+		assert.NotEqual(t, a.Id(), c.Id())
+	})
+}
@@ -0,0 +1,23 @@
+package provider
+
+import (
+	"github.com/pghq/go-tea/trail"
+)
+
+// MaxCopyArgs the max number of arguments in a single statement for providers copying rows with inserts
+const MaxCopyArgs = 30000
+
+// CheckRows checks that each row has a value for every column
+func CheckRows(columns []string, rows [][]interface{}) error {
+	if len(columns) == 0 {
+		return trail.NewError("at least one column is required")
+	}
+
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return trail.NewErrorf("row %d has %d values for %d columns", i, len(row), len(columns))
+		}
+	}
+
+	return nil
+}
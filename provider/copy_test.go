@@ -0,0 +1,24 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckRows(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no columns", func(t *testing.T) {
+		assert.NotNil(t, CheckRows(nil, nil))
+	})
+
+	t.Run("bad row", func(t *testing.T) {
+		assert.NotNil(t, CheckRows([]string{"id", "name"}, [][]interface{}{{"foo", "bar"}, {"baz"}}))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, CheckRows([]string{"id", "name"}, [][]interface{}{{"foo", "bar"}}))
+		assert.Nil(t, CheckRows([]string{"id"}, nil))
+	})
+}
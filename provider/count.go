@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"fmt"
+)
+
+// Count wraps the spec in a query counting its rows
+func Count(spec Spec) Spec {
+	return countSpec{spec: spec}
+}
+
+type countSpec struct {
+	spec Spec
+}
+
+// Id gets an identifier from the statement and arguments, as specs without an id would share one otherwise
+func (s countSpec) Id() interface{} {
+	stmt, args, err := s.ToSql()
+	if err != nil {
+		return nil
+	}
+
+	return fmt.Sprintf("%s %v", stmt, args)
+}
+
+func (s countSpec) ToSql() (string, []interface{}, error) {
+	stmt, args, err := s.spec.ToSql()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return fmt.Sprintf("SELECT COUNT(*) FROM (%s) sub", stmt), args, nil
+}
@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCount(t *testing.T) {
+	t.Parallel()
+
+	t.Run("bad query", func(t *testing.T) {
+		_, _, err := Count(NewBuilder()).ToSql()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		query := Count(NewBuilder().Select("id").From("tests").Where("name = ?", "foo"))
+		stmt, args, err := query.ToSql()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT COUNT(*) FROM (SELECT id FROM tests WHERE name = ?) sub", stmt)
+		assert.Equal(t, []interface{}{"foo"}, args)
+		assert.NotNil(t, query.Id())
+	})
+
+	t.Run("specs without id", func(t *testing.T) {
+		a := Count(NewSpec(nil, squirrel.Select("id").From("tests").Where("name = ?", "foo")))
+		b := Count(NewSpec(nil, squirrel.Select("id").From("tests").Where("name = ?", "bar")))
+		assert.NotEqual(t, a.Id(), b.Id())
+		assert.Equal(t, "SELECT COUNT(*) FROM (SELECT id FROM tests WHERE name = ?) sub [foo]", a.Id())
+	})
+}
@@ -0,0 +1,138 @@
+package provider
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pghq/go-tea/trail"
+)
+
+// Page a page of results for cursor based pagination
+type Page struct {
+	Items      interface{}
+	NextCursor string
+}
+
+// cursor the decoded form of an opaque cursor
+type cursor struct {
+	Type  string          `json:"t"`
+	Value json.RawMessage `json:"v"`
+}
+
+// EncodeCursor encodes the last seen column value as an opaque cursor
+func EncodeCursor(v interface{}) (string, error) {
+	var c cursor
+	switch v.(type) {
+	case string:
+		c.Type = "string"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		c.Type = "int"
+	case float32, float64:
+		c.Type = "float"
+	case time.Time:
+		c.Type = "time"
+	default:
+		return "", trail.NewErrorf("cursor value of type %T is not supported", v)
+	}
+
+	var err error
+	if c.Value, err = json.Marshal(v); err != nil {
+		return "", trail.Stacktrace(err)
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", trail.Stacktrace(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor decodes the last seen column value from an opaque cursor
+func DecodeCursor(encoded string) (interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, trail.ErrorBadRequest(err)
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, trail.ErrorBadRequest(err)
+	}
+
+	var v interface{}
+	switch c.Type {
+	case "string":
+		var s string
+		err = json.Unmarshal(c.Value, &s)
+		v = s
+	case "int":
+		var i int64
+		err = json.Unmarshal(c.Value, &i)
+		v = i
+	case "float":
+		var f float64
+		err = json.Unmarshal(c.Value, &f)
+		v = f
+	case "time":
+		var t time.Time
+		err = json.Unmarshal(c.Value, &t)
+		v = t
+	default:
+		return nil, trail.NewErrorBadRequest("cursor type is not supported")
+	}
+
+	if err != nil {
+		return nil, trail.ErrorBadRequest(err)
+	}
+
+	return v, nil
+}
+
+// cursorValue gets the value of the cursor column from the last item of a slice
+func cursorValue(v interface{}, col string) (interface{}, bool) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Slice || rv.Len() == 0 {
+		return nil, false
+	}
+
+	value, ok := columnValue(rv.Index(rv.Len()-1), col)
+	if !ok {
+		return nil, false
+	}
+
+	return value.Interface(), true
+}
+
+// columnValue gets the value of the column from a map or struct item, matching struct fields by db tag or name
+func columnValue(item reflect.Value, col string) (reflect.Value, bool) {
+	item = reflect.Indirect(item)
+	if item.Kind() == reflect.Interface {
+		item = reflect.Indirect(item.Elem())
+	}
+
+	if i := strings.LastIndex(col, "."); i >= 0 {
+		col = col[i+1:]
+	}
+
+	switch item.Kind() {
+	case reflect.Map:
+		if value := item.MapIndex(reflect.ValueOf(col)); value.IsValid() {
+			return value, true
+		}
+	case reflect.Struct:
+		t := item.Type()
+		for i := 0; i < item.NumField(); i++ {
+			sf := t.Field(i)
+			name := strings.Split(sf.Tag.Get("db"), ",")[0]
+			if name == col || name == "" && strings.EqualFold(sf.Name, strings.ReplaceAll(col, "_", "")) {
+				return item.Field(i), true
+			}
+		}
+	}
+
+	return reflect.Value{}, false
+}
@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeCursor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("unsupported type", func(t *testing.T) {
+		_, err := EncodeCursor(struct{}{})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+		for in, out := range map[interface{}]interface{}{
+			"foo":    "foo",
+			1:        int64(1),
+			int32(2): int64(2),
+			1.5:      1.5,
+			now:      now,
+		} {
+			c, err := EncodeCursor(in)
+			assert.Nil(t, err)
+
+			v, err := DecodeCursor(c)
+			assert.Nil(t, err)
+			assert.Equal(t, out, v)
+		}
+	})
+}
+
+func TestDecodeCursor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("bad encoding", func(t *testing.T) {
+		_, err := DecodeCursor("%")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("bad json", func(t *testing.T) {
+		_, err := DecodeCursor("bm90IGpzb24")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("unsupported type", func(t *testing.T) {
+		_, err := DecodeCursor("eyJ0IjoiYm9vbCIsInYiOnRydWV9")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("bad value", func(t *testing.T) {
+		_, err := DecodeCursor("eyJ0IjoiaW50IiwidiI6ImZvbyJ9")
+		assert.NotNil(t, err)
+	})
+}
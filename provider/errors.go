@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"time"
+
+	"github.com/pghq/go-tea/trail"
+)
+
+var (
+	// ErrNotFound is returned for get ops with no results, and writes matching no values
+	ErrNotFound = trail.ErrorNotFound(&NotFoundError{})
+
+	// ErrNoResults is returned when retrieving values by an empty list of ids
+	ErrNoResults = trail.ErrorNotFound(&NoResultsError{})
+
+	// ErrConflict is returned for write ops that violate a unique constraint
+	ErrConflict = trail.ErrorConflict(&ConflictError{})
+)
+
+// NotFoundError the context of a not found error (e.g., trail.AsError(err, &nf) with nf *NotFoundError)
+// the table is empty if unknown (e.g., raw sql of mysql and sqlite) and the query is the statement without its arguments
+// all not found errors match ErrNotFound (e.g., trail.IsError(err, ErrNotFound))
+type NotFoundError struct {
+	Table     string
+	Query     string
+	Timestamp time.Time
+}
+
+// NewNotFoundError creates a not found error for the query of the table, if known
+func NewNotFoundError(table, query string) error {
+	return trail.ErrorNotFound(&NotFoundError{Table: table, Query: query, Timestamp: time.Now()})
+}
+
+func (e *NotFoundError) Error() string {
+	return "the requested item does not exist"
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	var t *NotFoundError
+	return trail.AsError(target, &t)
+}
+
+// NoResultsError the context of a no results error, all match ErrNoResults
+type NoResultsError struct {
+	Table     string
+	Query     string
+	Timestamp time.Time
+}
+
+// NewNoResultsError creates a no results error for the query of the table
+func NewNoResultsError(table, query string) error {
+	return trail.ErrorNotFound(&NoResultsError{Table: table, Query: query, Timestamp: time.Now()})
+}
+
+func (e *NoResultsError) Error() string {
+	return "no results were found"
+}
+
+func (e *NoResultsError) Is(target error) bool {
+	var t *NoResultsError
+	return trail.AsError(target, &t)
+}
+
+// ConflictError the context of a unique constraint violation, all match ErrConflict
+type ConflictError struct {
+	Table     string
+	Query     string
+	Timestamp time.Time
+}
+
+// NewConflictError creates a conflict error for the statement writing to the table, if known
+func NewConflictError(table, query string) error {
+	return trail.ErrorConflict(&ConflictError{Table: table, Query: query, Timestamp: time.Now()})
+}
+
+func (e *ConflictError) Error() string {
+	return "an item already exists matching your request"
+}
+
+func (e *ConflictError) Is(target error) bool {
+	var t *ConflictError
+	return trail.AsError(target, &t)
+}
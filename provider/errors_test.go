@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewNotFoundError(t *testing.T) {
+	t.Parallel()
+
+	err := NewNotFoundError("tests", "SELECT * FROM tests WHERE id = $1")
+	assert.True(t, trail.IsNotFound(err))
+	assert.True(t, trail.IsError(err, ErrNotFound))
+	assert.False(t, trail.IsError(err, ErrNoResults))
+	assert.Equal(t, ErrNotFound.Error(), err.Error())
+
+	var nf *NotFoundError
+	assert.True(t, trail.AsError(err, &nf))
+	assert.Equal(t, "tests", nf.Table)
+	assert.Equal(t, "SELECT * FROM tests WHERE id = $1", nf.Query)
+	assert.False(t, nf.Timestamp.IsZero())
+}
+
+func TestNewNoResultsError(t *testing.T) {
+	t.Parallel()
+
+	err := NewNoResultsError("tests", "")
+	assert.True(t, trail.IsNotFound(err))
+	assert.True(t, trail.IsError(err, ErrNoResults))
+	assert.False(t, trail.IsError(err, ErrNotFound))
+
+	var nr *NoResultsError
+	assert.True(t, trail.AsError(err, &nr))
+	assert.Equal(t, "tests", nr.Table)
+}
+
+func TestNewConflictError(t *testing.T) {
+	t.Parallel()
+
+	err := NewConflictError("tests", "INSERT INTO tests (id) VALUES ($1)")
+	assert.True(t, trail.IsConflict(err))
+	assert.True(t, trail.IsError(err, ErrConflict))
+	assert.False(t, trail.IsError(err, ErrNotFound))
+
+	var c *ConflictError
+	assert.True(t, trail.AsError(err, &c))
+	assert.Equal(t, "INSERT INTO tests (id) VALUES ($1)", c.Query)
+}
@@ -0,0 +1,37 @@
+package provider
+
+import (
+	"fmt"
+)
+
+// Exists wraps the spec in a query selecting at most one row
+func Exists(spec Spec) Spec {
+	if b, ok := spec.(*Builder); ok {
+		return b.Exists()
+	}
+
+	return existsSpec{spec: spec}
+}
+
+type existsSpec struct {
+	spec Spec
+}
+
+// Id gets an identifier from the statement and arguments, as specs without an id would share one otherwise
+func (s existsSpec) Id() interface{} {
+	stmt, args, err := s.ToSql()
+	if err != nil {
+		return nil
+	}
+
+	return fmt.Sprintf("%s %v", stmt, args)
+}
+
+func (s existsSpec) ToSql() (string, []interface{}, error) {
+	stmt, args, err := s.spec.ToSql()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return fmt.Sprintf("SELECT 1 FROM (%s) sub LIMIT 1", stmt), args, nil
+}
@@ -0,0 +1,43 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExists(t *testing.T) {
+	t.Parallel()
+
+	t.Run("bad query", func(t *testing.T) {
+		_, _, err := Exists(NewSpec("", squirrel.Select())).ToSql()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("spec", func(t *testing.T) {
+		query := Exists(NewSpec("id", squirrel.Select("id").From("tests")))
+		stmt, _, err := query.ToSql()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT 1 FROM (SELECT id FROM tests) sub LIMIT 1", stmt)
+		assert.Equal(t, "SELECT 1 FROM (SELECT id FROM tests) sub LIMIT 1 []", query.Id())
+	})
+
+	t.Run("specs without id", func(t *testing.T) {
+		a := Exists(NewSpec(nil, squirrel.Select("id").From("tests").Where("name = ?", "foo")))
+		b := Exists(NewSpec(nil, squirrel.Select("id").From("tests").Where("name = ?", "bar")))
+		assert.NotEqual(t, a.Id(), b.Id())
+	})
+
+	t.Run("builder", func(t *testing.T) {
+		b := NewBuilder().Select("id", "name").From("tests").Where("name = ?", "foo").Limit(10)
+		stmt, args, err := Exists(b).ToSql()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT 1 FROM tests WHERE name = ? LIMIT 1", stmt)
+		assert.Equal(t, []interface{}{"foo"}, args)
+		assert.NotContains(t, stmt, "COUNT(*)")
+
+		stmt, _, _ = b.Build()
+		assert.Equal(t, "SELECT id, name FROM tests WHERE name = ? LIMIT 10", stmt)
+	})
+}
@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pghq/go-tea/trail"
+)
+
+// Explain wraps the spec in a query analyzing its plan as json (pg only)
+// the query is executed to gather actual timings, so writes take effect unless rolled back
+func Explain(spec Spec) Spec {
+	return explainSpec{spec: spec}
+}
+
+type explainSpec struct {
+	spec Spec
+}
+
+func (s explainSpec) Id() interface{} {
+	return fmt.Sprintf("EXPLAIN %v", s.spec.Id())
+}
+
+func (s explainSpec) ToSql() (string, []interface{}, error) {
+	stmt, args, err := s.spec.ToSql()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return fmt.Sprintf("EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) %s", stmt), args, nil
+}
+
+// PlanCost gets the planner's total cost estimate from a json query plan
+func PlanCost(plan string) (float64, error) {
+	var plans []struct {
+		Plan struct {
+			TotalCost float64 `json:"Total Cost"`
+		}
+	}
+
+	if err := json.Unmarshal([]byte(plan), &plans); err != nil {
+		return 0, trail.Stacktrace(err)
+	}
+
+	if len(plans) == 0 {
+		return 0, trail.NewError("query plan is empty")
+	}
+
+	return plans[0].Plan.TotalCost, nil
+}
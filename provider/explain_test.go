@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+
+	t.Run("bad query", func(t *testing.T) {
+		_, _, err := Explain(NewBuilder()).ToSql()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		query := Explain(NewBuilder().Select("id").From("tests").Where("name = ?", "foo"))
+		stmt, args, err := query.ToSql()
+		assert.Nil(t, err)
+		assert.Equal(t, "EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) SELECT id FROM tests WHERE name = ?", stmt)
+		assert.Equal(t, []interface{}{"foo"}, args)
+		assert.NotNil(t, query.Id())
+	})
+}
+
+func TestPlanCost(t *testing.T) {
+	t.Parallel()
+
+	t.Run("bad json", func(t *testing.T) {
+		_, err := PlanCost("{")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("empty plan", func(t *testing.T) {
+		_, err := PlanCost("[]")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		cost, err := PlanCost(`[{"Plan": {"Node Type": "Seq Scan", "Total Cost": 35.5}}]`)
+		assert.Nil(t, err)
+		assert.Equal(t, 35.5, cost)
+	})
+}
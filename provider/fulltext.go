@@ -0,0 +1,35 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/pghq/go-tea/trail"
+)
+
+// defaultTSConfig the text search configuration used if none is given
+const defaultTSConfig = "english"
+
+// fullText a full text search filter
+type fullText struct {
+	column string
+	query  string
+	lang   string
+}
+
+// exprs gets the tsvector and tsquery expressions for the filter
+func (f fullText) exprs(config string) (string, string, error) {
+	lang := f.lang
+	if lang == "" {
+		lang = config
+	}
+
+	if lang == "" {
+		lang = defaultTSConfig
+	}
+
+	if !identifier.MatchString(lang) {
+		return "", "", trail.NewErrorf("text search config %s is not valid", lang)
+	}
+
+	return fmt.Sprintf("to_tsvector('%s', %s)", lang, f.column), fmt.Sprintf("plainto_tsquery('%s', ?)", lang), nil
+}
@@ -0,0 +1,36 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder_WhereFullText(t *testing.T) {
+	t.Parallel()
+
+	t.Run("bad config", func(t *testing.T) {
+		_, _, err := NewBuilder().Select("id").From("tests").WhereFullText("name", "foo", "english'").Build()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("default config", func(t *testing.T) {
+		stmt, args, err := NewBuilder().Select("id").From("tests").WhereFullText("name", "foo", "").Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id FROM tests WHERE to_tsvector('english', name) @@ plainto_tsquery('english', ?)", stmt)
+		assert.Equal(t, []interface{}{"foo"}, args)
+	})
+
+	t.Run("custom config", func(t *testing.T) {
+		stmt, _, err := NewBuilder().Select("id").From("tests").WhereFullText("name", "foo", "").WithTSConfig("simple").Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id FROM tests WHERE to_tsvector('simple', name) @@ plainto_tsquery('simple', ?)", stmt)
+	})
+
+	t.Run("rank", func(t *testing.T) {
+		stmt, args, err := NewBuilder().Select("id").From("tests").WhereFullText("name", "foo", "french").WithTSRank().Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id FROM tests WHERE to_tsvector('french', name) @@ plainto_tsquery('french', ?) ORDER BY ts_rank(to_tsvector('french', name), plainto_tsquery('french', ?)) DESC", stmt)
+		assert.Equal(t, []interface{}{"foo", "foo"}, args)
+	})
+}
@@ -0,0 +1,72 @@
+package provider
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pghq/go-tea/trail"
+)
+
+// maxInValues the max number of values in a single IN list
+const maxInValues = 1000
+
+// inExpr gets the expression and arguments for matching (or not matching) a column against a list of values
+// empty lists match nothing (IN) or everything (NOT IN) and large lists are split into batches
+func inExpr(column string, values interface{}, not bool) (string, []interface{}, error) {
+	rv := reflect.ValueOf(values)
+	if values == nil || (rv.Kind() == reflect.Slice && rv.IsNil()) {
+		rv = reflect.ValueOf([]interface{}{})
+	}
+
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return "", nil, trail.NewErrorf("values of type %T are not a list", values)
+	}
+
+	if rv.Len() == 0 {
+		if not {
+			return "1=1", nil, nil
+		}
+
+		return "1=0", nil, nil
+	}
+
+	op, sep := "IN", " OR "
+	if not {
+		op, sep = "NOT IN", " AND "
+	}
+
+	var exprs []string
+	var args []interface{}
+	for start := 0; start < rv.Len(); start += maxInValues {
+		end := start + maxInValues
+		if end > rv.Len() {
+			end = rv.Len()
+		}
+
+		for i := start; i < end; i++ {
+			args = append(args, rv.Index(i).Interface())
+		}
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", end-start), ",")
+		exprs = append(exprs, fmt.Sprintf("%s %s (%s)", column, op, placeholders))
+	}
+
+	if len(exprs) == 1 {
+		return exprs[0], args, nil
+	}
+
+	return fmt.Sprintf("(%s)", strings.Join(exprs, sep)), args, nil
+}
+
+// WhereAny adds a filter matching the column against an array of values (pg only)
+// unlike WhereIn, the values are bound as a single array so the statement is the same for any number of values
+func (b *Builder) WhereAny(column string, values interface{}) *Builder {
+	if reflect.ValueOf(values).Kind() != reflect.Slice {
+		b.err = trail.NewErrorf("values of type %T are not a slice", values)
+		return b
+	}
+
+	b.sb = b.sb.Where(fmt.Sprintf("%s = ANY(?)", column), values)
+	return b
+}
@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder_WhereIn(t *testing.T) {
+	t.Parallel()
+
+	base := func() *Builder {
+		return NewBuilder().Select("id").From("tests")
+	}
+
+	t.Run("not a list", func(t *testing.T) {
+		_, _, err := base().WhereIn("id", "foo").Build()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		stmt, args, err := base().WhereIn("id", nil).Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id FROM tests WHERE 1=0", stmt)
+		assert.Empty(t, args)
+
+		var ids []string
+		stmt, _, _ = base().WhereNotIn("id", ids).Build()
+		assert.Equal(t, "SELECT id FROM tests WHERE 1=1", stmt)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		stmt, _, _ := base().WhereIn("id", []string{}).Build()
+		assert.Equal(t, "SELECT id FROM tests WHERE 1=0", stmt)
+
+		stmt, _, _ = base().WhereNotIn("id", []string{}).Build()
+		assert.Equal(t, "SELECT id FROM tests WHERE 1=1", stmt)
+	})
+
+	t.Run("single", func(t *testing.T) {
+		stmt, args, err := base().WhereIn("id", []string{"foo"}).Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id FROM tests WHERE id IN (?)", stmt)
+		assert.Equal(t, []interface{}{"foo"}, args)
+
+		stmt, args, _ = base().WhereNotIn("num", [2]int{1, 2}).Build()
+		assert.Equal(t, "SELECT id FROM tests WHERE num NOT IN (?,?)", stmt)
+		assert.Equal(t, []interface{}{1, 2}, args)
+	})
+
+	t.Run("batches", func(t *testing.T) {
+		values := make([]int, 1001)
+		for i := range values {
+			values[i] = i
+		}
+
+		stmt, args, err := base().WhereIn("num", values).Build()
+		assert.Nil(t, err)
+		first := strings.TrimSuffix(strings.Repeat("?,", 1000), ",")
+		assert.Equal(t, "SELECT id FROM tests WHERE (num IN ("+first+") OR num IN (?))", stmt)
+		assert.Len(t, args, 1001)
+		assert.Equal(t, 1000, args[1000])
+
+		stmt, _, _ = base().WhereNotIn("num", values).Build()
+		assert.Equal(t, "SELECT id FROM tests WHERE (num NOT IN ("+first+") AND num NOT IN (?))", stmt)
+	})
+}
+
+func TestBuilder_WhereAny(t *testing.T) {
+	t.Parallel()
+
+	base := func() *Builder {
+		return NewBuilder().Select("id").From("tests")
+	}
+
+	t.Run("not a slice", func(t *testing.T) {
+		_, _, err := base().WhereAny("id", "foo").Build()
+		assert.NotNil(t, err)
+
+		_, _, err = base().WhereAny("id", [2]int{1, 2}).Build()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		stmt, args, err := base().WhereAny("id", []string{"foo", "bar"}).Build()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id FROM tests WHERE id = ANY(?)", stmt)
+		assert.Equal(t, []interface{}{[]string{"foo", "bar"}}, args)
+	})
+}
@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pghq/go-tea/trail"
+)
+
+// jsonExpr builds a jsonb filter expression for the column, path, and operator
+// pg operators containing ? are escaped as ?? so they are not treated as placeholders
+func jsonExpr(column, path, op string, value interface{}) (string, interface{}, error) {
+	var keys []string
+	if path != "" {
+		keys = strings.Split(path, ".")
+	}
+
+	switch op {
+	case "->>":
+		return fmt.Sprintf("%s = ?", jsonPath(column, keys, true)), value, nil
+	case "->", "@>":
+		data, err := json.Marshal(value)
+		if err != nil {
+			return "", nil, trail.Stacktrace(err)
+		}
+
+		if op == "->" {
+			op = "="
+		}
+
+		return fmt.Sprintf("%s %s ?::jsonb", jsonPath(column, keys, false), op), string(data), nil
+	case "?", "?|", "?&":
+		return fmt.Sprintf("%s ?%s ?", jsonPath(column, keys, false), op), value, nil
+	case "=", "!=", "<>", "<", "<=", ">", ">=", "LIKE", "ILIKE":
+		expr := jsonPath(column, keys, true)
+		switch reflect.Indirect(reflect.ValueOf(value)).Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			expr = fmt.Sprintf("(%s)::numeric", expr)
+		case reflect.Bool:
+			expr = fmt.Sprintf("(%s)::boolean", expr)
+		case reflect.Struct, reflect.Map, reflect.Slice:
+			data, err := json.Marshal(value)
+			if err != nil {
+				return "", nil, trail.Stacktrace(err)
+			}
+
+			value = string(data)
+		}
+
+		return fmt.Sprintf("%s %s ?", expr, op), value, nil
+	default:
+		return "", nil, trail.NewErrorf("json operator %s is not supported", op)
+	}
+}
+
+// jsonPath chains -> operators for each key, using ->> for the last if text is requested
+func jsonPath(column string, keys []string, text bool) string {
+	if text && len(keys) == 0 {
+		return fmt.Sprintf("%s#>>'{}'", column)
+	}
+
+	path := column
+	for i, key := range keys {
+		op := "->"
+		if text && i == len(keys)-1 {
+			op = "->>"
+		}
+
+		path = fmt.Sprintf("%s%s'%s'", path, op, strings.ReplaceAll(key, "'", "''"))
+	}
+
+	return path
+}
@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder_WhereJSON(t *testing.T) {
+	t.Parallel()
+
+	base := func() *Builder {
+		return NewBuilder().Select("id").From("tests")
+	}
+
+	t.Run("unsupported operator", func(t *testing.T) {
+		_, _, err := base().WhereJSON("data", "name", "~", "foo").Build()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("bad json value", func(t *testing.T) {
+		_, _, err := base().WhereJSON("data", "", "@>", func() {}).Build()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("bad comparison value", func(t *testing.T) {
+		_, _, err := base().WhereJSON("data", "", "=", map[string]interface{}{"fn": func() {}}).Build()
+		assert.NotNil(t, err)
+	})
+
+	tests := []struct {
+		name  string
+		path  string
+		op    string
+		value interface{}
+		stmt  string
+		arg   interface{}
+	}{
+		{"text", "metadata.user.id", "->>", "foo", "data->'metadata'->'user'->>'id' = ?", "foo"},
+		{"text root", "", "->>", "foo", "data#>>'{}' = ?", "foo"},
+		{"json", "metadata.user", "->", map[string]string{"id": "foo"}, "data->'metadata'->'user' = ?::jsonb", `{"id":"foo"}`},
+		{"containment", "", "@>", map[string]string{"id": "foo"}, "data @> ?::jsonb", `{"id":"foo"}`},
+		{"key exists", "metadata", "?", "user", "data->'metadata' ?? ?", "user"},
+		{"any key exists", "metadata", "?|", []string{"user", "org"}, "data->'metadata' ??| ?", []string{"user", "org"}},
+		{"all keys exist", "metadata", "?&", []string{"user", "org"}, "data->'metadata' ??& ?", []string{"user", "org"}},
+		{"numeric comparison", "metadata.age", ">=", 21, "(data->'metadata'->>'age')::numeric >= ?", 21},
+		{"boolean comparison", "metadata.active", "=", true, "(data->'metadata'->>'active')::boolean = ?", true},
+		{"struct comparison", "metadata.user", "=", struct{ Id string }{"foo"}, "data->'metadata'->>'user' = ?", `{"Id":"foo"}`},
+		{"escaped key", "o'brien", "LIKE", "foo%", "data->>'o''brien' LIKE ?", "foo%"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, args, err := base().WhereJSON("data", tt.path, tt.op, tt.value).Build()
+			assert.Nil(t, err)
+			assert.Equal(t, "SELECT id FROM tests WHERE "+tt.stmt, stmt)
+			assert.Equal(t, []interface{}{tt.arg}, args)
+		})
+	}
+}
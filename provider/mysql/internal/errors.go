@@ -0,0 +1,30 @@
+package internal
+
+import (
+	"github.com/go-sql-driver/mysql"
+	"github.com/pghq/go-tea/trail"
+)
+
+const (
+	// ErrCodeDupKey expected mysql error code for duplicate keys on write
+	ErrCodeDupKey = 1022
+
+	// ErrCodeDupEntry expected mysql error code for duplicate entries for a key
+	ErrCodeDupEntry = 1062
+
+	// ErrCodeDupEntryWithKeyName expected mysql error code for duplicate entries for a named key
+	ErrCodeDupEntryWithKeyName = 1586
+)
+
+// IsErrorCode checks if error code matches underlying mysql code
+func IsErrorCode(err error, code uint16) bool {
+	var icv *mysql.MySQLError
+	return err != nil && trail.AsError(err, &icv) && code == icv.Number
+}
+
+// IsIntegrityViolation checks if the error is any of the mysql unique constraint violations
+func IsIntegrityViolation(err error) bool {
+	return IsErrorCode(err, ErrCodeDupKey) ||
+		IsErrorCode(err, ErrCodeDupEntry) ||
+		IsErrorCode(err, ErrCodeDupEntryWithKeyName)
+}
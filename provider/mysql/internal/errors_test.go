@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsErrorCode(t *testing.T) {
+	t.Parallel()
+
+	t.Run("duplicate entry", func(t *testing.T) {
+		assert.True(t, IsErrorCode(&mysql.MySQLError{Number: ErrCodeDupEntry}, ErrCodeDupEntry))
+	})
+}
+
+func TestIsIntegrityViolation(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil error", func(t *testing.T) {
+		assert.False(t, IsIntegrityViolation(nil))
+	})
+
+	t.Run("duplicate key", func(t *testing.T) {
+		assert.True(t, IsIntegrityViolation(&mysql.MySQLError{Number: ErrCodeDupKey}))
+	})
+
+	t.Run("duplicate entry with key name", func(t *testing.T) {
+		assert.True(t, IsIntegrityViolation(&mysql.MySQLError{Number: ErrCodeDupEntryWithKeyName}))
+	})
+}
@@ -0,0 +1,236 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"net"
+	"strings"
+	"time"
+
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/pghq/go-tea/trail"
+
+	"github.com/pghq/go-store/internal/migration"
+	"github.com/pghq/go-store/provider"
+)
+
+// Provider to mysql database
+type Provider struct {
+	db       *sql.DB
+	conf     ProviderConfig
+	migrator migration.Migrator
+	local    bool
+}
+
+func (p Provider) Repository() provider.Repository {
+	return repository{db: p.db}
+}
+
+// IsLocal checks if the database host is local
+func (p Provider) IsLocal() bool {
+	return p.local
+}
+
+// PendingMigrations gets the sql of migrations not yet applied
+func (p Provider) PendingMigrations(_ context.Context) ([]string, error) {
+	return p.migrator.Pending()
+}
+
+// SchemaDiff gets the statements of migrations not yet applied which the schema does not already reflect
+func (p Provider) SchemaDiff(ctx context.Context) ([]string, error) {
+	return p.migrator.Diff(ctx)
+}
+
+// MigrationStatus gets the status of each migration
+func (p Provider) MigrationStatus(_ context.Context) ([]provider.MigrationVersion, error) {
+	return p.migrator.Status()
+}
+
+// MigrateTo migrates up or down to the version
+// down migrations are only allowed for local databases unless forced
+func (p Provider) MigrateTo(ctx context.Context, version int64) error {
+	return p.migrator.MigrateTo(ctx, version, p.local || p.conf.ForceDownMigrations)
+}
+
+func (p Provider) Begin(ctx context.Context, opts ...provider.TxOption) (provider.UnitOfWork, error) {
+	conf := provider.TxConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: conf.ReadOnly})
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	return unitOfWork{tx: tx}, nil
+}
+
+// New creates a new mysql database provider
+func New(dsn string, migrations fs.FS, opts ...Option) (*Provider, error) {
+	conf := ProviderConfig{
+		MaxConns:        100,
+		MaxConnLifetime: time.Hour,
+		SQLOpen:         sql.Open,
+		ConnectTimeout:  30 * time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	mysqlConf, err := driver.ParseDSN(dsn)
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	// report matched rather than changed rows so edits without changes are not mistaken for missing rows
+	mysqlConf.ClientFoundRows = true
+	db, err := conf.SQLOpen("mysql", mysqlConf.FormatDSN())
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	db.SetMaxOpenConns(int(conf.MaxConns))
+	db.SetConnMaxLifetime(conf.MaxConnLifetime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectTimeout)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	migrator := migration.New(db, "mysql", migrations, conf.MigrationHook)
+	if conf.MigrationTable != "" {
+		migrator = migrator.WithTable(conf.MigrationTable)
+	}
+
+	if !conf.MigrationDryRun {
+		if err := migrator.Apply(context.Background()); err != nil {
+			return nil, trail.Stacktrace(err)
+		}
+	}
+
+	host, _, _ := net.SplitHostPort(mysqlConf.Addr)
+	p := Provider{db: db, conf: conf, migrator: migrator, local: mysqlConf.Net == "unix" || migration.IsLocal(host)}
+	return &p, nil
+}
+
+// ProviderConfig custom options for mysql configuration
+type ProviderConfig struct {
+	MaxConns            int32
+	MaxConnLifetime     time.Duration
+	ConnectTimeout      time.Duration
+	SQLOpen             func(driverName, dsn string) (*sql.DB, error)
+	MigrationDryRun     bool
+	MigrationHook       provider.MigrationHook
+	MigrationTable      string
+	ForceDownMigrations bool
+}
+
+// Option A mysql provider option
+type Option func(conf *ProviderConfig)
+
+// WithMaxConns configure mysql with custom max connections
+func WithMaxConns(n int32) Option {
+	return func(conf *ProviderConfig) {
+		conf.MaxConns = n
+	}
+}
+
+// WithMaxConnLifetime configure mysql with custom max connection lifetime
+func WithMaxConnLifetime(d time.Duration) Option {
+	return func(conf *ProviderConfig) {
+		conf.MaxConnLifetime = d
+	}
+}
+
+// WithConnectTimeout configure mysql with custom connect timeout
+func WithConnectTimeout(d time.Duration) Option {
+	return func(conf *ProviderConfig) {
+		conf.ConnectTimeout = d
+	}
+}
+
+// WithSQLOpenFunc configure mysql to open databases with a custom sql.Open (e.g., a tracing wrapper such as otelsql.Open)
+func WithSQLOpenFunc(fn func(driverName, dsn string) (*sql.DB, error)) Option {
+	return func(conf *ProviderConfig) {
+		if fn != nil {
+			conf.SQLOpen = fn
+		}
+	}
+}
+
+// WithMigrationDryRun configure mysql to not apply migrations (e.g., to preview pending migrations)
+func WithMigrationDryRun() Option {
+	return func(conf *ProviderConfig) {
+		conf.MigrationDryRun = true
+	}
+}
+
+// WithMigrationTable configure mysql to record applied migrations in the table
+func WithMigrationTable(name string) Option {
+	return func(conf *ProviderConfig) {
+		conf.MigrationTable = name
+	}
+}
+
+// WithMigrationHook configure mysql to call the hook around each migration
+func WithMigrationHook(hook provider.MigrationHook) Option {
+	return func(conf *ProviderConfig) {
+		conf.MigrationHook = hook
+	}
+}
+
+// WithForceDownMigrations configure mysql to allow down migrations for non-local databases
+func WithForceDownMigrations() Option {
+	return func(conf *ProviderConfig) {
+		conf.ForceDownMigrations = true
+	}
+}
+
+type unitOfWork struct {
+	tx *sql.Tx
+}
+
+func (u unitOfWork) Commit(_ context.Context) error {
+	return u.tx.Commit()
+}
+
+func (u unitOfWork) Rollback(_ context.Context) {
+	_ = u.tx.Rollback()
+}
+
+func (u unitOfWork) Repository() provider.Repository {
+	return repository{db: u.tx}
+}
+
+func (u unitOfWork) Savepoint(ctx context.Context, name string) (provider.UnitOfWork, error) {
+	if _, err := u.tx.ExecContext(ctx, "SAVEPOINT "+quote(name)); err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	return savepoint{unitOfWork: u, name: name}, nil
+}
+
+// savepoint a unit of work nested within a transaction
+type savepoint struct {
+	unitOfWork
+	name string
+}
+
+func (s savepoint) Commit(ctx context.Context) error {
+	_, err := s.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+quote(s.name))
+	return trail.Stacktrace(err)
+}
+
+func (s savepoint) Rollback(ctx context.Context) {
+	_, _ = s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+quote(s.name))
+}
+
+// quote an identifier
+func quote(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
@@ -0,0 +1,56 @@
+package mysqltest
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/pghq/go-tea/trail"
+)
+
+// Start a test database
+func Start() (string, func() error, error) {
+	pool, err := dockertest.NewPool("")
+	opts := dockertest.RunOptions{
+		Repository: "mysql",
+		Tag:        "8",
+		Env: []string{
+			"MYSQL_ROOT_PASSWORD=secret",
+			"MYSQL_DATABASE=db",
+		},
+	}
+
+	var resource *dockertest.Resource
+	if err == nil {
+		resource, err = pool.RunWithOptions(&opts, func(config *docker.HostConfig) {
+			config.AutoRemove = true
+			config.RestartPolicy = docker.RestartPolicy{Name: "no"}
+		})
+	}
+
+	if err == nil {
+		err = resource.Expire(60)
+	}
+
+	var dsn string
+	var cleanup func() error
+	var conn *sql.DB
+
+	if err == nil {
+		pool.MaxWait = 60 * time.Second
+		dsn = fmt.Sprintf("root:secret@tcp(%s)/db?parseTime=true", resource.GetHostPort("3306/tcp"))
+		conn, err = sql.Open("mysql", dsn)
+	}
+
+	if err == nil {
+		err = pool.Retry(conn.Ping)
+		cleanup = func() error {
+			return pool.Purge(resource)
+		}
+	}
+
+	return dsn, cleanup, trail.Stacktrace(err)
+}
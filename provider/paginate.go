@@ -0,0 +1,35 @@
+package provider
+
+// PaginatedResult a page of results for offset based pagination, with the total number of results
+type PaginatedResult struct {
+	Items      interface{}
+	Total      int64
+	Page       int
+	PageSize   int
+	TotalPages int
+}
+
+// NewPaginatedResult creates a page of results, rounding the number of pages up
+func NewPaginatedResult(items interface{}, total int64, page, pageSize int) PaginatedResult {
+	r := PaginatedResult{
+		Items:    items,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}
+
+	if pageSize > 0 {
+		r.TotalPages = int((total + int64(pageSize) - 1) / int64(pageSize))
+	}
+
+	return r
+}
+
+// Paginate gets a copy of the query limited to the page of results (starting at 1)
+func (b *Builder) Paginate(page, pageSize int) *Builder {
+	c := *b
+	c.columns = append([]string(nil), b.columns...)
+	c.limit = pageSize
+	c.sb = c.sb.Limit(uint64(pageSize)).Offset(uint64((page - 1) * pageSize))
+	return &c
+}
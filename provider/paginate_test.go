@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPaginatedResult(t *testing.T) {
+	t.Parallel()
+
+	t.Run("rounds up", func(t *testing.T) {
+		for total, pages := range map[int64]int{0: 0, 1: 1, 9: 1, 10: 1, 11: 2, 20: 2, 21: 3} {
+			r := NewPaginatedResult(nil, total, 1, 10)
+			assert.Equal(t, pages, r.TotalPages, total)
+			assert.Equal(t, total, r.Total)
+		}
+	})
+
+	t.Run("empty page size", func(t *testing.T) {
+		assert.Equal(t, 0, NewPaginatedResult(nil, 5, 1, 0).TotalPages)
+	})
+}
+
+func TestBuilder_Paginate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		query := NewBuilder().Select("id").From("tests").Where("name = ?", "foo").OrderBy("id", false)
+		stmt, args, err := query.Paginate(3, 10).ToSql()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT id FROM tests WHERE name = ? ORDER BY id LIMIT 10 OFFSET 20", stmt)
+		assert.Equal(t, []interface{}{"foo"}, args)
+
+		stmt, args, err = Count(query).ToSql()
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT COUNT(*) FROM (SELECT id FROM tests WHERE name = ? ORDER BY id) sub", stmt)
+		assert.Equal(t, []interface{}{"foo"}, args)
+	})
+}
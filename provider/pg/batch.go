@@ -0,0 +1,100 @@
+package pg
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/pghq/go-tea/trail"
+
+	"github.com/pghq/go-store/internal/encode"
+	"github.com/pghq/go-store/provider"
+	"github.com/pghq/go-store/provider/pg/internal"
+)
+
+const (
+	// maxBatchRows the most rows added by a single statement
+	maxBatchRows = 1000
+
+	// maxParams the most parameters pg accepts for a single statement
+	maxParams = 65535
+)
+
+// AddBatch adds the values to the collection with multi-row inserts of up to 1000 rows each
+// values must all be of the same type and encode to the same columns (e.g., omitempty fields must be set for all or none)
+func (r repository) AddBatch(ctx context.Context, collection string, values []interface{}) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	var cols []string
+	rows := make([][]interface{}, len(values))
+	for i, v := range values {
+		if reflect.TypeOf(v) != reflect.TypeOf(values[0]) {
+			return trail.NewErrorf("values must all be of type %T, got %T", values[0], v)
+		}
+
+		data, err := encode.Map(v)
+		if err != nil {
+			return trail.Stacktrace(err)
+		}
+
+		if i == 0 {
+			cols = columns(data)
+		}
+
+		if len(data) != len(cols) {
+			return trail.NewErrorf("value %d has %d columns, expected %d", i, len(data), len(cols))
+		}
+
+		rows[i] = make([]interface{}, len(cols))
+		for j, key := range cols {
+			value, present := data[key]
+			if !present {
+				return trail.NewErrorf("column %s is missing from value %d", key, i)
+			}
+
+			rows[i][j] = value
+		}
+	}
+
+	size := maxBatchRows
+	if len(cols) > 0 && size*len(cols) > maxParams {
+		size = maxParams / len(cols)
+	}
+
+	for start := 0; start < len(rows); start += size {
+		end := start + size
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		builder := squirrel.StatementBuilder.
+			PlaceholderFormat(squirrel.Dollar).
+			Insert(collection).
+			Columns(cols...)
+
+		for _, row := range rows[start:end] {
+			builder = builder.Values(row...)
+		}
+
+		stmt, args, err := builder.ToSql()
+		if err != nil {
+			return trail.Stacktrace(err)
+		}
+
+		done := r.instrument(ctx, internal.Operation(stmt), collection, stmt, args)
+		_, err = r.db.Exec(ctx, stmt, args...)
+		done(err)
+		switch {
+		case internal.IsErrorCode(err, internal.ErrCodeUniqueViolation):
+			return provider.NewConflictError(collection, stmt)
+		case internal.IsRetryable(err):
+			return retryable(err)
+		case err != nil:
+			return trail.Stacktrace(err)
+		}
+	}
+
+	return nil
+}
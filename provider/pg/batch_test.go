@@ -0,0 +1,73 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_AddBatch(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	type value struct {
+		Id   int    `db:"id"`
+		Name string `db:"name"`
+	}
+
+	logger := &queryLogger{}
+	p, err := New(dsn, nil, WithQueryLogger(logger, 0))
+	assert.Nil(t, err)
+
+	_, err = p.db.Exec(context.TODO(), "CREATE TABLE batches (id int primary key, name text)")
+	assert.Nil(t, err)
+
+	r := p.Repository().(repository)
+
+	t.Run("empty", func(t *testing.T) {
+		assert.Nil(t, r.AddBatch(context.TODO(), "batches", nil))
+	})
+
+	t.Run("mixed types", func(t *testing.T) {
+		assert.NotNil(t, r.AddBatch(context.TODO(), "batches", []interface{}{value{Id: -1}, &value{Id: -2}}))
+	})
+
+	t.Run("mixed columns", func(t *testing.T) {
+		values := []interface{}{map[string]interface{}{"id": -1}, map[string]interface{}{"name": "foo"}}
+		assert.NotNil(t, r.AddBatch(context.TODO(), "batches", values))
+	})
+
+	t.Run("unique", func(t *testing.T) {
+		assert.True(t, trail.IsError(r.AddBatch(context.TODO(), "batches", []interface{}{value{Id: -1}, value{Id: -1}}), ErrUnique))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		values := make([]interface{}, 2500)
+		for i := range values {
+			values[i] = value{Id: i, Name: fmt.Sprintf("batch:%d", i)}
+		}
+
+		assert.Nil(t, r.AddBatch(context.TODO(), "batches", values))
+
+		var n int
+		assert.Nil(t, p.db.QueryRow(context.TODO(), "SELECT count(*) FROM batches").Scan(&n))
+		assert.Equal(t, 2500, n)
+
+		logger.mu.Lock()
+		defer logger.mu.Unlock()
+
+		var stmts int
+		for _, query := range logger.queries {
+			if strings.HasPrefix(query, "INSERT INTO batches") && strings.Contains(query, "(id,name) VALUES") {
+				stmts++
+			}
+		}
+
+		// one for the unique violation and three batches of at most 1000 rows
+		assert.Equal(t, 4, stmts)
+	})
+}
@@ -0,0 +1,107 @@
+package pg
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/pghq/go-tea/trail"
+)
+
+// Collector gathers pool stats, query error counts and migrations applied (e.g., for a prometheus exporter)
+type Collector struct {
+	mu         sync.Mutex
+	errors     map[string]uint64
+	migrations map[int64]time.Duration
+	version    int64
+}
+
+// Stats a point in time view of the pool, query errors and migrations
+type Stats struct {
+	AcquiredConns      int32
+	IdleConns          int32
+	TotalConns         int32
+	WaitDuration       time.Duration
+	Errors             map[string]uint64
+	MigrationDurations map[int64]time.Duration
+	MigrationVersion   int64
+}
+
+// NewCollector creates a new collector
+func NewCollector() *Collector {
+	return &Collector{
+		errors:     make(map[string]uint64),
+		migrations: make(map[int64]time.Duration),
+	}
+}
+
+// ObserveQuery counts the query error by type
+func (c *Collector) ObserveQuery(_, _ string, _ time.Duration, err error) {
+	if err == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errors[errorType(err)] += 1
+}
+
+// ObserveMigration records the duration of the migration
+func (c *Collector) ObserveMigration(version int64, duration time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.migrations[version] = duration
+}
+
+// SetMigrationVersion records the current schema version
+func (c *Collector) SetMigrationVersion(version int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version = version
+}
+
+// Collect the current stats for the provider
+func (c *Collector) Collect(p *Provider) Stats {
+	stat := p.Stats()
+	stats := Stats{
+		AcquiredConns:      stat.AcquiredConns(),
+		IdleConns:          stat.IdleConns(),
+		TotalConns:         stat.TotalConns(),
+		WaitDuration:       stat.AcquireDuration(),
+		Errors:             make(map[string]uint64),
+		MigrationDurations: make(map[int64]time.Duration),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, value := range c.errors {
+		stats.Errors[key] = value
+	}
+
+	for key, value := range c.migrations {
+		stats.MigrationDurations[key] = value
+	}
+
+	stats.MigrationVersion = c.version
+
+	return stats
+}
+
+// errorType gets the pg error code or a short description of the error
+func errorType(err error) string {
+	var pgErr *pgconn.PgError
+	switch {
+	case trail.AsError(err, &pgErr):
+		return pgErr.Code
+	case trail.IsError(err, pgx.ErrNoRows):
+		return "no_rows"
+	case trail.IsError(err, context.DeadlineExceeded):
+		return "timeout"
+	case trail.IsError(err, context.Canceled):
+		return "canceled"
+	default:
+		return "unknown"
+	}
+}
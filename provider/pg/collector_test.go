@@ -0,0 +1,58 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pghq/go-store/provider"
+	"github.com/pghq/go-store/provider/pg/internal"
+)
+
+func TestCollector(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("counts errors by type", func(t *testing.T) {
+		c := NewCollector()
+		c.ObserveQuery("SELECT", "tests", 0, nil)
+		c.ObserveQuery("SELECT", "tests", 0, pgx.ErrNoRows)
+		c.ObserveQuery("INSERT", "tests", 0, &pgconn.PgError{Code: internal.ErrCodeUniqueViolation})
+		c.ObserveQuery("INSERT", "tests", 0, &pgconn.PgError{Code: internal.ErrCodeUniqueViolation})
+		c.ObserveQuery("SELECT", "tests", 0, context.Canceled)
+		c.ObserveQuery("SELECT", "tests", 0, errors.New("an error has occurred"))
+
+		stats := c.Collect(db)
+		assert.Equal(t, map[string]uint64{"no_rows": 1, internal.ErrCodeUniqueViolation: 2, "canceled": 1, "unknown": 1}, stats.Errors)
+	})
+
+	t.Run("records migrations", func(t *testing.T) {
+		c := NewCollector()
+		c.ObserveMigration(1, time.Second)
+		c.ObserveMigration(2, time.Millisecond)
+		c.SetMigrationVersion(2)
+
+		stats := c.Collect(db)
+		assert.Equal(t, map[int64]time.Duration{1: time.Second, 2: time.Millisecond}, stats.MigrationDurations)
+		assert.Equal(t, int64(2), stats.MigrationVersion)
+	})
+
+	t.Run("collects pool stats", func(t *testing.T) {
+		c := NewCollector()
+		p, _ := New(dsn, nil, WithMetrics(c))
+		var v struct{ Id string }
+		_ = p.Repository().One(context.TODO(), provider.NewSpec("", squirrel.Select("id").From("tests").Where("id = 'missing'")), &v)
+
+		stats := c.Collect(p)
+		assert.Equal(t, uint64(1), stats.Errors["no_rows"])
+		assert.Equal(t, int32(0), stats.AcquiredConns)
+		assert.Equal(t, stats.TotalConns, stats.IdleConns)
+	})
+}
@@ -0,0 +1,167 @@
+package pg
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/pghq/go-tea/trail"
+)
+
+var (
+	// composites the registered composite types by type name
+	composites   = make(map[string]reflect.Type)
+	compositesMu sync.RWMutex
+)
+
+// RegisterComposite maps the fields of the struct T to the attributes of the pg composite type
+// fields are matched by db tag or lowercase name, attributes without a field are written as null
+// connections made afterwards encode T as the composite type and decode the composite type as T, the type must exist when they connect
+func RegisterComposite[T any](typeName string) {
+	var v T
+	compositesMu.Lock()
+	defer compositesMu.Unlock()
+	composites[typeName] = reflect.TypeOf(v)
+}
+
+// registerComposites registers the data type of each registered composite type existing in the database with the connection
+func registerComposites(ctx context.Context, conn *pgx.Conn) error {
+	compositesMu.RLock()
+	defer compositesMu.RUnlock()
+	for name, typ := range composites {
+		var oid uint32
+		if err := conn.QueryRow(ctx, "SELECT COALESCE(to_regtype($1)::oid, 0)", name).Scan(&oid); err != nil {
+			return trail.Stacktrace(err)
+		}
+
+		if oid == 0 {
+			trail.Debugf("pg: composite type %s does not exist", name)
+			continue
+		}
+
+		rows, err := conn.Query(ctx, "SELECT a.attname, a.atttypid FROM pg_type t JOIN pg_attribute a ON a.attrelid = t.typrelid WHERE t.oid = $1 AND a.attnum > 0 AND NOT a.attisdropped ORDER BY a.attnum", oid)
+		if err != nil {
+			return trail.Stacktrace(err)
+		}
+
+		var fields []pgtype.CompositeTypeField
+		for rows.Next() {
+			var field pgtype.CompositeTypeField
+			if err := rows.Scan(&field.Name, &field.OID); err != nil {
+				rows.Close()
+				return trail.Stacktrace(err)
+			}
+
+			fields = append(fields, field)
+		}
+
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return trail.Stacktrace(err)
+		}
+
+		ct, err := pgtype.NewCompositeType(name, fields, conn.ConnInfo())
+		if err != nil {
+			return trail.Stacktrace(err)
+		}
+
+		value := compositeValue{CompositeType: ct, typ: typ, index: make([][]int, len(fields))}
+		for i, field := range fields {
+			value.index[i] = fieldIndex(typ, field.Name)
+		}
+
+		conn.ConnInfo().RegisterDataType(pgtype.DataType{Value: &value, Name: name, OID: oid})
+	}
+
+	return nil
+}
+
+// fieldIndex gets the index of the struct field for the attribute, if any
+func fieldIndex(typ reflect.Type, name string) []int {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := strings.Split(field.Tag.Get("db"), ",")[0]
+		if tag == name || (tag == "" && strings.ToLower(field.Name) == name) {
+			return field.Index
+		}
+	}
+
+	return nil
+}
+
+// compositeValue a pg composite value mapped to a struct
+type compositeValue struct {
+	*pgtype.CompositeType
+	typ   reflect.Type
+	index [][]int
+}
+
+func (v *compositeValue) NewTypeValue() pgtype.Value {
+	return &compositeValue{
+		CompositeType: v.CompositeType.NewTypeValue().(*pgtype.CompositeType),
+		typ:           v.typ,
+		index:         v.index,
+	}
+}
+
+func (v *compositeValue) Set(src interface{}) error {
+	rv := reflect.ValueOf(src)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	if !rv.IsValid() || rv.Type() != v.typ {
+		return v.CompositeType.Set(src)
+	}
+
+	values := make([]interface{}, len(v.index))
+	for i, index := range v.index {
+		if index != nil {
+			values[i] = rv.FieldByIndex(index).Interface()
+		}
+	}
+
+	return v.CompositeType.Set(values)
+}
+
+func (v *compositeValue) AssignTo(dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return v.CompositeType.AssignTo(dst)
+	}
+
+	elem := rv.Elem()
+	if elem.Kind() == reflect.Ptr && elem.Type().Elem() == v.typ {
+		if v.CompositeType.Get() == nil {
+			elem.Set(reflect.Zero(elem.Type()))
+			return nil
+		}
+
+		elem.Set(reflect.New(v.typ))
+		elem = elem.Elem()
+	}
+
+	if elem.Type() != v.typ {
+		return v.CompositeType.AssignTo(dst)
+	}
+
+	if v.CompositeType.Get() == nil {
+		return trail.NewErrorf("cannot assign a null %s to %T", v.CompositeType.TypeName(), dst)
+	}
+
+	fields := make([]interface{}, len(v.index))
+	for i, index := range v.index {
+		if index != nil {
+			fields[i] = elem.FieldByIndex(index).Addr().Interface()
+		}
+	}
+
+	return v.CompositeType.AssignTo(fields)
+}
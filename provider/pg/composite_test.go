@@ -0,0 +1,68 @@
+package pg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pghq/go-store/provider"
+)
+
+type address struct {
+	Street string `db:"street"`
+	City   string
+	Note   string `db:"-"`
+}
+
+func TestRegisterComposite(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	assert.Nil(t, db.Repository().ExecRaw(context.TODO(), `
+		CREATE TYPE address AS (street text, city text);
+		CREATE TABLE addresses (id text primary key, address address);
+		CREATE FUNCTION upper_address(a address) RETURNS address AS $$ SELECT ROW(upper(a.street), upper(a.city))::address $$ LANGUAGE sql;
+	`))
+	RegisterComposite[address]("address")
+
+	p, err := New(dsn, nil)
+	assert.Nil(t, err)
+	repo := p.Repository()
+
+	t.Run("function call", func(t *testing.T) {
+		var v struct {
+			Address address `db:"address"`
+		}
+
+		query := provider.NewSpec("", squirrel.Expr("SELECT upper_address(?) AS address", address{Street: "1 main st", City: "springfield", Note: "ignored"}))
+		assert.Nil(t, repo.One(context.TODO(), query, &v))
+		assert.Equal(t, address{Street: "1 MAIN ST", City: "SPRINGFIELD"}, v.Address)
+	})
+
+	t.Run("exec", func(t *testing.T) {
+		assert.Nil(t, repo.ExecRaw(context.TODO(), "INSERT INTO addresses (id, address) VALUES ($1, upper_address($2))", "composite:1", address{Street: "2 elm st", City: "shelbyville"}))
+
+		var v struct {
+			Id      string   `db:"id"`
+			Address *address `db:"address"`
+		}
+
+		assert.Nil(t, repo.One(context.TODO(), provider.NewSpec("", squirrel.Expr("SELECT id, address FROM addresses WHERE id = 'composite:1'")), &v))
+		assert.Equal(t, &address{Street: "2 ELM ST", City: "SHELBYVILLE"}, v.Address)
+	})
+
+	t.Run("null", func(t *testing.T) {
+		assert.Nil(t, repo.ExecRaw(context.TODO(), "INSERT INTO addresses (id) VALUES ('composite:2')"))
+
+		var v struct {
+			Id      string   `db:"id"`
+			Address *address `db:"address"`
+		}
+
+		assert.Nil(t, repo.One(context.TODO(), provider.NewSpec("", squirrel.Expr("SELECT id, address FROM addresses WHERE id = 'composite:2'")), &v))
+		assert.Nil(t, v.Address)
+	})
+}
@@ -0,0 +1,198 @@
+package pg
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/pghq/go-tea/trail"
+)
+
+var (
+	// ErrUnknownEnumValue is returned for enum values without a registered label, or labels without a value
+	ErrUnknownEnumValue = trail.NewErrorBadRequest("the enum value is not registered")
+
+	// enums the registered enums by type name
+	enums   = make(map[string]*enum)
+	enumsMu sync.RWMutex
+)
+
+// enum a mapping between the values of a go integer type and the labels of a pg enum
+type enum struct {
+	name   string
+	typ    reflect.Type
+	labels map[int64]string
+	values map[string]int64
+}
+
+// RegisterEnum maps the values of T (e.g., iota constants) to the labels of the pg enum type, in order
+// connections made afterwards encode T as its label and decode labels as T, the enum type must exist when they connect
+func RegisterEnum[T ~int](typeName string, labels []string) {
+	e := enum{
+		name:   typeName,
+		typ:    reflect.TypeOf(T(0)),
+		labels: make(map[int64]string, len(labels)),
+		values: make(map[string]int64, len(labels)),
+	}
+
+	for i, label := range labels {
+		e.labels[int64(i)] = label
+		e.values[label] = int64(i)
+	}
+
+	enumsMu.Lock()
+	defer enumsMu.Unlock()
+	enums[typeName] = &e
+}
+
+// registerEnums registers the data type of each registered enum existing in the database with the connection
+func registerEnums(ctx context.Context, conn *pgx.Conn) error {
+	enumsMu.RLock()
+	defer enumsMu.RUnlock()
+	for name, e := range enums {
+		var oid uint32
+		if err := conn.QueryRow(ctx, "SELECT COALESCE(to_regtype($1)::oid, 0)", name).Scan(&oid); err != nil {
+			return trail.Stacktrace(err)
+		}
+
+		if oid == 0 {
+			trail.Debugf("pg: enum type %s does not exist", name)
+			continue
+		}
+
+		conn.ConnInfo().RegisterDataType(pgtype.DataType{Value: &enumValue{enum: e}, Name: name, OID: oid})
+	}
+
+	return nil
+}
+
+// enumValue a pg enum value
+type enumValue struct {
+	enum   *enum
+	label  string
+	status pgtype.Status
+}
+
+func (v *enumValue) NewTypeValue() pgtype.Value {
+	return &enumValue{enum: v.enum}
+}
+
+func (v *enumValue) TypeName() string {
+	return v.enum.name
+}
+
+func (v *enumValue) Set(src interface{}) error {
+	if src == nil {
+		*v = enumValue{enum: v.enum, status: pgtype.Null}
+		return nil
+	}
+
+	if label, ok := src.(string); ok {
+		if _, present := v.enum.values[label]; !present {
+			return trail.Stacktrace(ErrUnknownEnumValue)
+		}
+
+		*v = enumValue{enum: v.enum, label: label, status: pgtype.Present}
+		return nil
+	}
+
+	rv := reflect.ValueOf(src)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return v.Set(nil)
+		}
+
+		return v.Set(rv.Elem().Interface())
+	}
+
+	if rv.Type() != v.enum.typ {
+		return trail.NewErrorf("cannot convert %T to enum %s", src, v.enum.name)
+	}
+
+	label, present := v.enum.labels[rv.Int()]
+	if !present {
+		return trail.Stacktrace(ErrUnknownEnumValue)
+	}
+
+	*v = enumValue{enum: v.enum, label: label, status: pgtype.Present}
+	return nil
+}
+
+func (v *enumValue) Get() interface{} {
+	if v.status != pgtype.Present {
+		return nil
+	}
+
+	return v.label
+}
+
+func (v *enumValue) AssignTo(dst interface{}) error {
+	if v.status == pgtype.Null {
+		return pgtype.NullAssignTo(dst)
+	}
+
+	if v.status != pgtype.Present {
+		return trail.NewErrorf("cannot assign an undefined enum %s", v.enum.name)
+	}
+
+	if d, ok := dst.(*string); ok {
+		*d = v.label
+		return nil
+	}
+
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return trail.NewErrorf("cannot assign enum %s to %T", v.enum.name, dst)
+	}
+
+	elem := rv.Elem()
+	if elem.Kind() == reflect.Ptr && elem.Type().Elem() == v.enum.typ {
+		elem.Set(reflect.New(v.enum.typ))
+		elem = elem.Elem()
+	}
+
+	if elem.Type() != v.enum.typ {
+		return trail.NewErrorf("cannot assign enum %s to %T", v.enum.name, dst)
+	}
+
+	value, present := v.enum.values[v.label]
+	if !present {
+		return trail.Stacktrace(ErrUnknownEnumValue)
+	}
+
+	elem.SetInt(value)
+	return nil
+}
+
+func (v *enumValue) DecodeText(_ *pgtype.ConnInfo, src []byte) error {
+	if src == nil {
+		*v = enumValue{enum: v.enum, status: pgtype.Null}
+		return nil
+	}
+
+	*v = enumValue{enum: v.enum, label: string(src), status: pgtype.Present}
+	return nil
+}
+
+// DecodeBinary the binary format of enums is the label, as in the text format
+func (v *enumValue) DecodeBinary(ci *pgtype.ConnInfo, src []byte) error {
+	return v.DecodeText(ci, src)
+}
+
+func (v enumValue) EncodeText(_ *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch v.status {
+	case pgtype.Null:
+		return nil, nil
+	case pgtype.Undefined:
+		return nil, trail.NewErrorf("cannot encode an undefined enum %s", v.enum.name)
+	}
+
+	return append(buf, v.label...), nil
+}
+
+// EncodeBinary the binary format of enums is the label, as in the text format
+func (v enumValue) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	return v.EncodeText(ci, buf)
+}
@@ -0,0 +1,67 @@
+package pg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pghq/go-store/provider"
+)
+
+type mood int
+
+const (
+	moodSad mood = iota
+	moodOk
+	moodHappy
+)
+
+func TestRegisterEnum(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	type value struct {
+		Id       string `db:"id"`
+		Mood     mood   `db:"mood"`
+		Previous *mood  `db:"previous"`
+	}
+
+	assert.Nil(t, db.Repository().ExecRaw(context.TODO(), "CREATE TYPE mood AS ENUM ('sad', 'ok', 'happy'); CREATE TABLE moods (id text primary key, mood mood, previous mood)"))
+	RegisterEnum[mood]("mood", []string{"sad", "ok", "happy"})
+
+	p, err := New(dsn, nil)
+	assert.Nil(t, err)
+	repo := p.Repository()
+
+	t.Run("round trip", func(t *testing.T) {
+		previous := moodOk
+		assert.Nil(t, repo.Add(context.TODO(), "moods", value{Id: "enum:1", Mood: moodHappy, Previous: &previous}))
+		assert.Nil(t, repo.Add(context.TODO(), "moods", value{Id: "enum:2", Mood: moodSad}))
+
+		var v value
+		assert.Nil(t, repo.One(context.TODO(), provider.NewSpec("", squirrel.Expr("SELECT id, mood, previous FROM moods WHERE id = 'enum:1'")), &v))
+		assert.Equal(t, value{Id: "enum:1", Mood: moodHappy, Previous: &previous}, v)
+
+		assert.Nil(t, repo.One(context.TODO(), provider.NewSpec("", squirrel.Expr("SELECT id, mood, previous FROM moods WHERE id = 'enum:2'")), &v))
+		assert.Equal(t, value{Id: "enum:2", Mood: moodSad}, v)
+
+		var labels []string
+		assert.Nil(t, repo.All(context.TODO(), provider.NewSpec("", squirrel.Expr("SELECT mood FROM moods WHERE mood = ?", moodHappy)), &labels))
+		assert.Equal(t, []string{"happy"}, labels)
+	})
+
+	t.Run("unknown value", func(t *testing.T) {
+		assert.NotNil(t, repo.Add(context.TODO(), "moods", value{Id: "enum:3", Mood: mood(7)}))
+	})
+
+	t.Run("unknown label", func(t *testing.T) {
+		assert.Nil(t, repo.ExecRaw(context.TODO(), "ALTER TYPE mood ADD VALUE 'angry'"))
+		assert.Nil(t, repo.ExecRaw(context.TODO(), "INSERT INTO moods (id, mood) VALUES ('enum:4', 'angry')"))
+
+		var v value
+		assert.NotNil(t, repo.One(context.TODO(), provider.NewSpec("", squirrel.Expr("SELECT id, mood, previous FROM moods WHERE id = 'enum:4'")), &v))
+	})
+}
@@ -0,0 +1,80 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pghq/go-tea/trail"
+
+	"github.com/pghq/go-store/provider"
+)
+
+// progressInterval the time to wait between reports of the progress of index creation
+var progressInterval = 100 * time.Millisecond
+
+// CreateIndexConcurrently creates the index on the expression (e.g., "lower(email)") of the table, if it does not exist, without locking out writes
+// the index is created on a dedicated connection outside of any transaction, reporting its progress to the callback, if any
+// an index left invalid by a failure is dropped so the creation can be retried
+func (p Provider) CreateIndexConcurrently(ctx context.Context, schema, table, name, expression string, progress func(provider.IndexProgress)) error {
+	conn, err := p.db.Acquire(ctx)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	defer conn.Release()
+	qualifiedName := pgx.Identifier{name}
+	qualifiedTable := pgx.Identifier{table}
+	if schema != "" {
+		qualifiedName = pgx.Identifier{schema, name}
+		qualifiedTable = pgx.Identifier{schema, table}
+	}
+
+	if progress != nil {
+		done, stopped := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(stopped)
+			p.reportIndexProgress(ctx, conn.Conn().PgConn().PID(), progress, done)
+		}()
+
+		defer func() {
+			close(done)
+			<-stopped
+		}()
+	}
+
+	stmt := fmt.Sprintf("CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON %s (%s)", pgx.Identifier{name}.Sanitize(), qualifiedTable.Sanitize(), expression)
+	if _, err := conn.Exec(ctx, stmt); err != nil {
+		var valid bool
+		if p.db.QueryRow(context.Background(), "SELECT indisvalid FROM pg_index WHERE indexrelid = to_regclass($1)", qualifiedName.Sanitize()).Scan(&valid) == nil && !valid {
+			_, _ = p.db.Exec(context.Background(), fmt.Sprintf("DROP INDEX CONCURRENTLY IF EXISTS %s", qualifiedName.Sanitize()))
+		}
+
+		return trail.Stacktrace(err)
+	}
+
+	return nil
+}
+
+// reportIndexProgress reports the progress of index creation by the backend until done
+func (p Provider) reportIndexProgress(ctx context.Context, pid uint32, progress func(provider.IndexProgress), done <-chan struct{}) {
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var v provider.IndexProgress
+		err := p.db.QueryRow(ctx, "SELECT phase, blocks_done, blocks_total, tuples_done, tuples_total FROM pg_stat_progress_create_index WHERE pid = $1", pid).
+			Scan(&v.Phase, &v.BlocksDone, &v.BlocksTotal, &v.TuplesDone, &v.TuplesTotal)
+		if err == nil {
+			progress(v)
+		}
+	}
+}
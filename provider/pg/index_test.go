@@ -0,0 +1,53 @@
+package pg
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pghq/go-store/provider"
+)
+
+func TestProvider_CreateIndexConcurrently(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	_, err := db.db.Exec(context.TODO(), "CREATE TABLE indexed (id int primary key, name text); INSERT INTO indexed SELECT n, 'name:' || (n % 10) FROM generate_series(1, 100000) n")
+	assert.Nil(t, err)
+
+	exists := func(name string) bool {
+		var n int
+		assert.Nil(t, db.db.QueryRow(context.TODO(), "SELECT count(*) FROM pg_indexes WHERE schemaname = 'public' AND tablename = 'indexed' AND indexname = $1", name).Scan(&n))
+		return n == 1
+	}
+
+	t.Run("bad context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+		assert.NotNil(t, db.CreateIndexConcurrently(ctx, "", "indexed", "idx_indexed_canceled", "name", nil))
+	})
+
+	t.Run("bad expression", func(t *testing.T) {
+		assert.NotNil(t, db.CreateIndexConcurrently(context.TODO(), "", "indexed", "idx_indexed_bad", "missing", nil))
+		assert.False(t, exists("idx_indexed_bad"))
+	})
+
+	t.Run("invalid index", func(t *testing.T) {
+		assert.NotNil(t, db.CreateIndexConcurrently(context.TODO(), "public", "indexed", "idx_indexed_invalid", "(1 / (id - 50000))", nil))
+		assert.False(t, exists("idx_indexed_invalid"))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		var reports int32
+		progress := func(p provider.IndexProgress) {
+			atomic.AddInt32(&reports, 1)
+		}
+
+		assert.Nil(t, db.CreateIndexConcurrently(context.TODO(), "public", "indexed", "idx_indexed_name", "name", progress))
+		assert.True(t, exists("idx_indexed_name"))
+		assert.Nil(t, db.CreateIndexConcurrently(context.TODO(), "", "indexed", "idx_indexed_name", "name", progress))
+	})
+}
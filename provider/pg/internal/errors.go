@@ -8,6 +8,12 @@ import (
 const (
 	// ErrCodeUniqueViolation expected pg error code for unique violations
 	ErrCodeUniqueViolation = "23505"
+
+	// ErrCodeSerializationFailure expected pg error code for serialization failures
+	ErrCodeSerializationFailure = "40001"
+
+	// ErrCodeDeadlockDetected expected pg error code for deadlocks
+	ErrCodeDeadlockDetected = "40P01"
 )
 
 // IsErrorCode checks if error code matches underlying pg code
@@ -15,3 +21,8 @@ func IsErrorCode(err error, code string) bool {
 	var icv *pgconn.PgError
 	return err != nil && trail.AsError(err, &icv) && code == icv.Code
 }
+
+// IsRetryable checks if the error is safe to retry from the start of the transaction
+func IsRetryable(err error) bool {
+	return IsErrorCode(err, ErrCodeSerializationFailure) || IsErrorCode(err, ErrCodeDeadlockDetected)
+}
@@ -14,3 +14,19 @@ func TestIsErrorCode(t *testing.T) {
 		assert.True(t, IsErrorCode(&pgconn.PgError{Code: ErrCodeUniqueViolation}, ErrCodeUniqueViolation))
 	})
 }
+
+func TestIsRetryable(t *testing.T) {
+	t.Parallel()
+
+	t.Run("serialization failure", func(t *testing.T) {
+		assert.True(t, IsRetryable(&pgconn.PgError{Code: ErrCodeSerializationFailure}))
+	})
+
+	t.Run("deadlock detected", func(t *testing.T) {
+		assert.True(t, IsRetryable(&pgconn.PgError{Code: ErrCodeDeadlockDetected}))
+	})
+
+	t.Run("unique violation", func(t *testing.T) {
+		assert.False(t, IsRetryable(&pgconn.PgError{Code: ErrCodeUniqueViolation}))
+	})
+}
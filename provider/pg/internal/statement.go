@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// literalPattern matches quoted strings, numbers, and pg placeholders
+	literalPattern = regexp.MustCompile(`'(?:[^']|'')*'|\$?\b\d+(?:\.\d+)?\b`)
+
+	// tablePattern matches the first table referenced by a statement
+	tablePattern = regexp.MustCompile(`(?i)\b(?:FROM|INTO|UPDATE)\s+([\w."]+)`)
+)
+
+// Sanitize replaces the literal values in a sql statement with ?
+func Sanitize(stmt string) string {
+	return literalPattern.ReplaceAllStringFunc(stmt, func(s string) string {
+		if strings.HasPrefix(s, "$") {
+			return s
+		}
+
+		return "?"
+	})
+}
+
+// Operation gets the sql operation (e.g., SELECT) of a statement
+func Operation(stmt string) string {
+	fields := strings.Fields(stmt)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	return strings.ToUpper(fields[0])
+}
+
+// Table gets the first table referenced by a statement
+func Table(stmt string) string {
+	if match := tablePattern.FindStringSubmatch(stmt); match != nil {
+		return match[1]
+	}
+
+	return ""
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitize(t *testing.T) {
+	t.Parallel()
+
+	t.Run("redacts literals", func(t *testing.T) {
+		stmt := Sanitize("SELECT id FROM tests WHERE name = 'o''brien' AND age > 21 AND t1.score < 0.5")
+		assert.Equal(t, "SELECT id FROM tests WHERE name = ? AND age > ? AND t1.score < ?", stmt)
+	})
+
+	t.Run("keeps placeholders", func(t *testing.T) {
+		stmt := Sanitize("SELECT id FROM tests WHERE id = $1 LIMIT $2")
+		assert.Equal(t, "SELECT id FROM tests WHERE id = $1 LIMIT $2", stmt)
+	})
+}
+
+func TestOperation(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty", func(t *testing.T) {
+		assert.Equal(t, "", Operation(" "))
+	})
+
+	t.Run("select", func(t *testing.T) {
+		assert.Equal(t, "SELECT", Operation("select id FROM tests"))
+	})
+}
+
+func TestTable(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no table", func(t *testing.T) {
+		assert.Equal(t, "", Table("SELECT 1"))
+	})
+
+	t.Run("select", func(t *testing.T) {
+		assert.Equal(t, "public.tests", Table("SELECT id FROM public.tests WHERE id = $1"))
+	})
+
+	t.Run("insert", func(t *testing.T) {
+		assert.Equal(t, "tests", Table("INSERT INTO tests (id) VALUES ($1)"))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		assert.Equal(t, "tests", Table("UPDATE tests SET id = $1"))
+	})
+}
@@ -0,0 +1,61 @@
+package pg
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pghq/go-tea/trail"
+)
+
+// sessionLocks the connections holding session advisory locks
+// session locks belong to the connection that acquired them, so it is held until they are released
+type sessionLocks struct {
+	mu    sync.Mutex
+	conns map[int64][]*pgxpool.Conn
+}
+
+// SessionAdvisoryLock waits for an advisory lock on the key held until SessionAdvisoryUnlock is called
+// locks are reentrant and must be unlocked as many times as they were locked
+func (p Provider) SessionAdvisoryLock(ctx context.Context, key int64) error {
+	conn, err := p.db.Acquire(ctx)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+		conn.Release()
+		return trail.Stacktrace(err)
+	}
+
+	p.sessions.mu.Lock()
+	defer p.sessions.mu.Unlock()
+	p.sessions.conns[key] = append(p.sessions.conns[key], conn)
+	return nil
+}
+
+// SessionAdvisoryUnlock releases an advisory lock on the key acquired with SessionAdvisoryLock
+func (p Provider) SessionAdvisoryUnlock(ctx context.Context, key int64) error {
+	p.sessions.mu.Lock()
+	conns := p.sessions.conns[key]
+	if len(conns) == 0 {
+		p.sessions.mu.Unlock()
+		return trail.NewErrorf("advisory lock %d is not held", key)
+	}
+
+	conn := conns[len(conns)-1]
+	p.sessions.conns[key] = conns[:len(conns)-1]
+	if len(conns) == 1 {
+		delete(p.sessions.conns, key)
+	}
+	p.sessions.mu.Unlock()
+
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", key); err != nil {
+		// the lock is released with the session if the connection can not be reused
+		_ = conn.Conn().Close(context.Background())
+		return trail.Stacktrace(err)
+	}
+
+	return nil
+}
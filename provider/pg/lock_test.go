@@ -0,0 +1,43 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider_SessionAdvisoryLock(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	tryLock := func(key int64) bool {
+		var locked bool
+		_ = db.Repository().One(context.TODO(), spec(fmt.Sprintf("SELECT pg_try_advisory_xact_lock(%d)", key)), &locked)
+		return locked
+	}
+
+	t.Run("not held", func(t *testing.T) {
+		assert.NotNil(t, db.SessionAdvisoryUnlock(context.TODO(), 4241))
+	})
+
+	t.Run("bad context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+		assert.NotNil(t, db.SessionAdvisoryLock(ctx, 4241))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, db.SessionAdvisoryLock(context.TODO(), 4242))
+		assert.Nil(t, db.SessionAdvisoryLock(context.TODO(), 4242))
+		assert.False(t, tryLock(4242))
+
+		assert.Nil(t, db.SessionAdvisoryUnlock(context.TODO(), 4242))
+		assert.False(t, tryLock(4242))
+
+		assert.Nil(t, db.SessionAdvisoryUnlock(context.TODO(), 4242))
+		assert.True(t, tryLock(4242))
+	})
+}
@@ -0,0 +1,133 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/pghq/go-tea/trail"
+
+	"github.com/pghq/go-store/internal/encode"
+	"github.com/pghq/go-store/provider"
+	"github.com/pghq/go-store/provider/pg/internal"
+)
+
+// mergeVersion the first server_version_num supporting MERGE (pg 15)
+const mergeVersion = 150000
+
+// Merge adds the value to the collection, or updates the columns of the row matching it
+// MERGE is used on pg 15+, otherwise INSERT ... ON CONFLICT, which requires a unique constraint on the match columns
+func (r repository) Merge(ctx context.Context, collection string, v interface{}, match []string, insertDefaults map[string]interface{}, update []string) error {
+	if len(match) == 0 {
+		return trail.NewError("at least one match column is required")
+	}
+
+	data, err := encode.Map(v)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	for _, cols := range [][]string{match, update} {
+		for _, key := range cols {
+			if _, present := data[key]; !present {
+				return trail.NewErrorf("column %s is missing from the value", key)
+			}
+		}
+	}
+
+	if len(update) == 0 {
+		for _, key := range columns(data) {
+			if !contains(match, key) {
+				update = append(update, key)
+			}
+		}
+	}
+
+	insert := make(map[string]interface{}, len(data)+len(insertDefaults))
+	for key, value := range insertDefaults {
+		insert[key] = value
+	}
+
+	for key, value := range data {
+		insert[key] = value
+	}
+
+	var stmt string
+	var args []interface{}
+	if r.conf.ServerVersion >= mergeVersion {
+		stmt, args = mergeStmt(collection, data, match, insert, update)
+	} else {
+		stmt, args, err = squirrel.StatementBuilder.
+			PlaceholderFormat(squirrel.Dollar).
+			Insert(collection).
+			SetMap(insert).
+			Suffix(mergeConflict(match, update)).
+			ToSql()
+	}
+
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	done := r.instrument(ctx, internal.Operation(stmt), collection, stmt, args)
+	_, err = r.db.Exec(ctx, stmt, args...)
+	done(err)
+	switch {
+	case internal.IsErrorCode(err, internal.ErrCodeUniqueViolation):
+		err = provider.NewConflictError(collection, stmt)
+	case internal.IsRetryable(err):
+		err = retryable(err)
+	}
+
+	return trail.Stacktrace(err)
+}
+
+// mergeStmt builds a MERGE statement matching the target rows on the match columns of the data
+// the values are bound directly in the conditions and actions, so their types are inferred from the target columns
+func mergeStmt(collection string, data map[string]interface{}, match []string, insert map[string]interface{}, update []string) (string, []interface{}) {
+	var args []interface{}
+	bind := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	on := make([]string, len(match))
+	for i, key := range match {
+		on[i] = fmt.Sprintf("t.%s = %s", key, bind(data[key]))
+	}
+
+	stmt := fmt.Sprintf("MERGE INTO %s AS t USING (SELECT 1) AS s ON %s", collection, strings.Join(on, " AND "))
+	if len(update) > 0 {
+		set := make([]string, len(update))
+		for i, key := range update {
+			set[i] = fmt.Sprintf("%s = %s", key, bind(data[key]))
+		}
+
+		stmt += " WHEN MATCHED THEN UPDATE SET " + strings.Join(set, ", ")
+	}
+
+	keys := columns(insert)
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i] = bind(insert[key])
+	}
+
+	stmt += fmt.Sprintf(" WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)", strings.Join(keys, ","), strings.Join(values, ","))
+	return stmt, args
+}
+
+// mergeConflict gets the ON CONFLICT clause updating the columns of rows matching on the match columns
+func mergeConflict(match, update []string) string {
+	clause := fmt.Sprintf("ON CONFLICT (%s)", strings.Join(match, ", "))
+	if len(update) == 0 {
+		return clause + " DO NOTHING"
+	}
+
+	set := make([]string, len(update))
+	for i, key := range update {
+		set[i] = fmt.Sprintf("%s = EXCLUDED.%s", key, key)
+	}
+
+	return clause + " DO UPDATE SET " + strings.Join(set, ", ")
+}
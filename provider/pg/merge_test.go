@@ -0,0 +1,102 @@
+package pg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pghq/go-store/provider"
+	"github.com/pghq/go-store/provider/pg/pgtest"
+)
+
+func TestRepository_Merge(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	type value struct {
+		Id   string `db:"id"`
+		Name string `db:"name"`
+		Num  int    `db:"num"`
+	}
+
+	merge := func(t *testing.T, p *Provider) {
+		r := p.Repository().(repository)
+
+		t.Run("no match columns", func(t *testing.T) {
+			assert.NotNil(t, r.Merge(context.TODO(), "tests", value{Id: "merge:1"}, nil, nil, nil))
+		})
+
+		t.Run("missing column", func(t *testing.T) {
+			assert.NotNil(t, r.Merge(context.TODO(), "tests", map[string]interface{}{"name": "foo"}, []string{"id"}, nil, nil))
+			assert.NotNil(t, r.Merge(context.TODO(), "tests", map[string]interface{}{"id": "merge:1"}, []string{"id"}, nil, []string{"name"}))
+		})
+
+		t.Run("insert then update", func(t *testing.T) {
+			defaults := map[string]interface{}{"num": 42}
+			assert.Nil(t, r.Merge(context.TODO(), "tests", map[string]interface{}{"id": "merge:1", "name": "foo"}, []string{"id"}, defaults, nil))
+
+			var v value
+			assert.Nil(t, r.One(context.TODO(), provider.NewBuilder().Select("id, name, num").From("tests").Where("id = ?", "merge:1"), &v))
+			assert.Equal(t, value{Id: "merge:1", Name: "foo", Num: 42}, v)
+
+			assert.Nil(t, r.Merge(context.TODO(), "tests", value{Id: "merge:1", Name: "bar", Num: 1}, []string{"id"}, defaults, []string{"name"}))
+			assert.Nil(t, r.One(context.TODO(), provider.NewBuilder().Select("id, name, num").From("tests").Where("id = ?", "merge:1"), &v))
+			assert.Equal(t, value{Id: "merge:1", Name: "bar", Num: 42}, v)
+		})
+	}
+
+	t.Run("fallback", func(t *testing.T) {
+		logger := &queryLogger{}
+		p, err := New(dsn, nil, WithServerVersion(140000), WithQueryLogger(logger, 0))
+		assert.Nil(t, err)
+
+		merge(t, p)
+		assert.Contains(t, logger.queries[len(logger.queries)-2], "ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name")
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		dsn, cleanup, err := pgtest.StartVersion("15")
+		if err != nil {
+			panic(err)
+		}
+
+		defer cleanup()
+
+		logger := &queryLogger{}
+		p, err := New(dsn, nil, WithQueryLogger(logger, 0))
+		assert.Nil(t, err)
+		assert.GreaterOrEqual(t, p.conf.ServerVersion, mergeVersion)
+
+		_, err = p.db.Exec(context.TODO(), "CREATE TABLE tests (id text primary key, name text, num int)")
+		assert.Nil(t, err)
+
+		merge(t, p)
+		assert.Contains(t, logger.queries[len(logger.queries)-2], "MERGE INTO tests AS t")
+	})
+}
+
+func TestNew_ServerVersion(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	p, err := New(dsn, nil)
+	assert.Nil(t, err)
+	assert.GreaterOrEqual(t, p.conf.ServerVersion, 120000)
+	assert.Less(t, p.conf.ServerVersion, mergeVersion)
+}
+
+func TestMergeStmt(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]interface{}{"id": "foo", "name": "bar"}
+	insert := map[string]interface{}{"id": "foo", "name": "bar", "num": 1}
+
+	stmt, args := mergeStmt("tests", data, []string{"id"}, insert, []string{"name"})
+	assert.Equal(t, "MERGE INTO tests AS t USING (SELECT 1) AS s ON t.id = $1 WHEN MATCHED THEN UPDATE SET name = $2 WHEN NOT MATCHED THEN INSERT (id,name,num) VALUES ($3,$4,$5)", stmt)
+	assert.Equal(t, []interface{}{"foo", "bar", "foo", "bar", 1}, args)
+
+	stmt, _ = mergeStmt("tests", data, []string{"id", "name"}, data, nil)
+	assert.Equal(t, "MERGE INTO tests AS t USING (SELECT 1) AS s ON t.id = $1 AND t.name = $2 WHEN NOT MATCHED THEN INSERT (id,name) VALUES ($3,$4)", stmt)
+}
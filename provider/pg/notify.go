@@ -0,0 +1,84 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/pghq/go-tea/trail"
+)
+
+// reconnectInterval the time to wait between attempts to reconnect subscriptions
+const reconnectInterval = time.Second
+
+// Subscribe calls the handler with the payload of each notification sent on the channel
+// a dedicated connection is used, outside of the pool, and re-established if lost.
+// the subscription ends when the returned func is called or ctx is done.
+func (p Provider) Subscribe(ctx context.Context, channel string, handler func(payload string)) (func(), error) {
+	conn, err := p.listen(ctx, channel)
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for conn != nil {
+			n, err := conn.WaitForNotification(ctx)
+			if err == nil {
+				handler(n.Payload)
+				continue
+			}
+
+			_ = conn.Close(context.Background())
+			conn = p.reconnect(ctx, channel, err)
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}, nil
+}
+
+// Notify sends the payload on the channel
+func (p Provider) Notify(ctx context.Context, channel, payload string) error {
+	_, err := p.db.Exec(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+	return trail.Stacktrace(err)
+}
+
+// listen connects and listens on the channel
+func (p Provider) listen(ctx context.Context, channel string) (*pgx.Conn, error) {
+	conn, err := pgx.ConnectConfig(ctx, p.db.Config().ConnConfig)
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	if _, err := conn.Exec(ctx, fmt.Sprintf("LISTEN %s", pgx.Identifier{channel}.Sanitize())); err != nil {
+		_ = conn.Close(context.Background())
+		return nil, trail.Stacktrace(err)
+	}
+
+	return conn, nil
+}
+
+// reconnect listens on the channel again after the connection was lost
+// nil is returned once ctx is done
+func (p Provider) reconnect(ctx context.Context, channel string, err error) *pgx.Conn {
+	for ctx.Err() == nil {
+		trail.Warnf("pg: subscription to %s lost, reconnecting: %s", channel, err)
+		var conn *pgx.Conn
+		if conn, err = p.listen(ctx, channel); err == nil {
+			return conn
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(reconnectInterval):
+		}
+	}
+
+	return nil
+}
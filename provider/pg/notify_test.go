@@ -0,0 +1,60 @@
+package pg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider_Subscribe(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("bad connection", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+		_, err := db.Subscribe(ctx, "tests", func(string) {})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		payloads := make(chan string, 1)
+		cancel, err := db.Subscribe(context.TODO(), "subscribe:ok", func(payload string) {
+			payloads <- payload
+		})
+		assert.Nil(t, err)
+		defer cancel()
+
+		assert.Nil(t, db.Notify(context.TODO(), "subscribe:ok", "foo"))
+		select {
+		case payload := <-payloads:
+			assert.Equal(t, "foo", payload)
+		case <-time.After(100 * time.Millisecond):
+			t.Fatal("notification was not delivered")
+		}
+	})
+
+	t.Run("reconnects", func(t *testing.T) {
+		payloads := make(chan string, 10)
+		cancel, err := db.Subscribe(context.TODO(), "subscribe:reconnect", func(payload string) {
+			payloads <- payload
+		})
+		assert.Nil(t, err)
+		defer cancel()
+
+		_, err = db.db.Exec(context.TODO(), "SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE query = 'LISTEN \"subscribe:reconnect\"'")
+		assert.Nil(t, err)
+		assert.Eventually(t, func() bool {
+			_ = db.Notify(context.TODO(), "subscribe:reconnect", "foo")
+			select {
+			case <-payloads:
+				return true
+			case <-time.After(100 * time.Millisecond):
+				return false
+			}
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,65 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pghq/go-tea/trail"
+)
+
+// PartitionedTable a table partitioned by range, list or hash of a key (e.g., events partitioned by month)
+type PartitionedTable struct {
+	Name     string
+	Columns  []string
+	Strategy string
+	Key      string
+}
+
+// SQL gets the statement creating the table
+// columns are definitions (e.g., "created_at timestamptz not null") and the key is a column list or expression
+func (t PartitionedTable) SQL() (string, error) {
+	if !namePattern.MatchString(t.Name) {
+		return "", trail.NewErrorf("table %s is not a valid name", t.Name)
+	}
+
+	strategy := strings.ToUpper(t.Strategy)
+	switch strategy {
+	case "RANGE", "LIST", "HASH":
+	default:
+		return "", trail.NewErrorf("partition strategy %s is not supported", t.Strategy)
+	}
+
+	if len(t.Columns) == 0 || t.Key == "" {
+		return "", trail.NewError("partitioned tables require columns and a key")
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (%s) PARTITION BY %s (%s);", t.Name, strings.Join(t.Columns, ", "), strategy, t.Key), nil
+}
+
+// Migration gets the path and contents of a goose migration creating the table (e.g., to add to the migrations fs)
+// the path is within the migrations directory, named after the version and table
+func (t PartitionedTable) Migration(version int64) (string, []byte, error) {
+	stmt, err := t.SQL()
+	if err != nil {
+		return "", nil, trail.Stacktrace(err)
+	}
+
+	name := fmt.Sprintf("migrations/%05d_create_%s.sql", version, strings.ReplaceAll(t.Name, ".", "_"))
+	data := fmt.Sprintf("-- +goose Up\n%s\n-- +goose Down\nDROP TABLE %s;\n", stmt, t.Name)
+	return name, []byte(data), nil
+}
+
+// CreatePartition creates a partition of the parent table, if it does not exist, outside of any transaction
+// the bound is the partition bound spec (e.g., "FOR VALUES FROM ('2022-01-01') TO ('2022-02-01')" or "DEFAULT")
+func (p Provider) CreatePartition(ctx context.Context, parent, name, bound string) error {
+	for _, table := range []string{parent, name} {
+		if !namePattern.MatchString(table) {
+			return trail.NewErrorBadRequest(fmt.Sprintf("table %s is not a valid name", table))
+		}
+	}
+
+	// the names are not quoted, so they are case folded as they are in the sql of migrations
+	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s %s", name, parent, bound)
+	return p.Repository().ExecRaw(ctx, stmt)
+}
@@ -0,0 +1,81 @@
+package pg
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionedTable_SQL(t *testing.T) {
+	t.Parallel()
+
+	t.Run("bad table", func(t *testing.T) {
+		for _, table := range []PartitionedTable{
+			{Name: "events; DROP TABLE tests", Columns: []string{"id int"}, Strategy: "RANGE", Key: "id"},
+			{Name: "events", Columns: []string{"id int"}, Strategy: "ROUND ROBIN", Key: "id"},
+			{Name: "events", Strategy: "RANGE", Key: "id"},
+			{Name: "events", Columns: []string{"id int"}, Strategy: "RANGE"},
+		} {
+			_, err := table.SQL()
+			assert.NotNil(t, err)
+
+			_, _, err = table.Migration(1)
+			assert.NotNil(t, err)
+		}
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		table := PartitionedTable{Name: "public.events", Columns: []string{"id bigint", "created_at date not null"}, Strategy: "range", Key: "created_at"}
+		stmt, err := table.SQL()
+		assert.Nil(t, err)
+		assert.Equal(t, "CREATE TABLE public.events (id bigint, created_at date not null) PARTITION BY RANGE (created_at);", stmt)
+
+		name, data, err := table.Migration(3)
+		assert.Nil(t, err)
+		assert.Equal(t, "migrations/00003_create_public_events.sql", name)
+		assert.Equal(t, "-- +goose Up\n"+stmt+"\n-- +goose Down\nDROP TABLE public.events;\n", string(data))
+	})
+}
+
+func TestProvider_CreatePartition(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	name, data, err := PartitionedTable{Name: "events", Columns: []string{"id int", "created_at date not null"}, Strategy: "RANGE", Key: "created_at"}.Migration(1)
+	assert.Nil(t, err)
+
+	p, err := New(dsn, fstest.MapFS{name: &fstest.MapFile{Data: data}}, WithMigrationTable("partitions_version"))
+	assert.Nil(t, err)
+
+	t.Run("bad name", func(t *testing.T) {
+		err := p.CreatePartition(context.TODO(), "events", "events_2022_01; DROP TABLE tests", "DEFAULT")
+		assert.NotNil(t, err)
+		assert.True(t, trail.IsBadRequest(err))
+	})
+
+	t.Run("bad bound", func(t *testing.T) {
+		assert.NotNil(t, p.CreatePartition(context.TODO(), "events", "events_bad", "FOR VALUES IN (1)"))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, p.CreatePartition(context.TODO(), "events", "events_2022_01", "FOR VALUES FROM ('2022-01-01') TO ('2022-02-01')"))
+		assert.Nil(t, p.CreatePartition(context.TODO(), "public.events", "public.events_2022_02", "FOR VALUES FROM ('2022-02-01') TO ('2022-03-01')"))
+		assert.Nil(t, p.CreatePartition(context.TODO(), "events", "events_2022_01", "FOR VALUES FROM ('2022-01-01') TO ('2022-02-01')"))
+
+		_, err := p.db.Exec(context.TODO(), "INSERT INTO events VALUES (1, '2022-01-15'), (2, '2022-02-15')")
+		assert.Nil(t, err)
+
+		var n int
+		assert.Nil(t, p.db.QueryRow(context.TODO(), "SELECT count(*) FROM events").Scan(&n))
+		assert.Equal(t, 2, n)
+
+		assert.Nil(t, p.db.QueryRow(context.TODO(), "SELECT count(*) FROM ONLY events_2022_02").Scan(&n))
+		assert.Equal(t, 1, n)
+
+		_, err = p.db.Exec(context.TODO(), "INSERT INTO events VALUES (3, '2022-03-15')")
+		assert.NotNil(t, err)
+	})
+}
@@ -2,25 +2,77 @@ package pg
 
 import (
 	"context"
+	"crypto/tls"
 	"io/fs"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgconn/stmtcache"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/jackc/pgx/v4/stdlib"
 	"github.com/pghq/go-tea/trail"
 
+	"github.com/pghq/go-store/internal/migration"
 	"github.com/pghq/go-store/provider"
 	"github.com/pghq/go-store/provider/pg/internal"
 )
 
+var (
+	// schemaPattern the schema names allowed in the search path
+	schemaPattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+	// namePattern the table and view names allowed, optionally qualified with a schema
+	namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+)
+
 // Provider to sql database
 type Provider struct {
-	db *pgxpool.Pool
+	db       *pgxpool.Pool
+	replica  *pgxpool.Pool
+	conf     ProviderConfig
+	migrator migration.Migrator
+	local    bool
+	sessions *sessionLocks
 }
 
 func (p Provider) Repository() provider.Repository {
-	return repository(p)
+	return repository{db: p.db, conf: p.conf}
+}
+
+// IsLocal checks if the database host is local
+func (p Provider) IsLocal() bool {
+	return p.local
+}
+
+// Stats gets the connection pool statistics of the primary
+func (p Provider) Stats() *pgxpool.Stat {
+	return p.db.Stat()
+}
+
+// PendingMigrations gets the sql of migrations not yet applied
+func (p Provider) PendingMigrations(_ context.Context) ([]string, error) {
+	return p.migrator.Pending()
+}
+
+// SchemaDiff gets the statements of migrations not yet applied which the schema does not already reflect
+func (p Provider) SchemaDiff(ctx context.Context) ([]string, error) {
+	return p.migrator.Diff(ctx)
+}
+
+// MigrationStatus gets the status of each migration
+func (p Provider) MigrationStatus(_ context.Context) ([]provider.MigrationVersion, error) {
+	return p.migrator.Status()
+}
+
+// MigrateTo migrates up or down to the version
+// down migrations are only allowed for local databases unless forced
+func (p Provider) MigrateTo(ctx context.Context, version int64) error {
+	return p.migrator.MigrateTo(ctx, version, p.local || p.conf.ForceDownMigrations)
 }
 
 func (p Provider) Begin(ctx context.Context, opts ...provider.TxOption) (provider.UnitOfWork, error) {
@@ -34,34 +86,63 @@ func (p Provider) Begin(ctx context.Context, opts ...provider.TxOption) (provide
 		pgxOpts.AccessMode = pgx.ReadOnly
 	}
 
+	if conf.ReadOnly && p.replica != nil {
+		tx, err := p.replica.BeginTx(ctx, pgxOpts)
+		if err == nil {
+			return unitOfWork{tx: tx, conf: p.conf}, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, trail.Stacktrace(err)
+		}
+
+		trail.Warnf("pg: read replica unavailable, falling back to primary: %s", err)
+	}
+
 	tx, err := p.db.BeginTx(ctx, pgxOpts)
 	if err != nil {
 		return nil, trail.Stacktrace(err)
 	}
 
-	return unitOfWork{tx: tx}, nil
+	return unitOfWork{tx: tx, conf: p.conf}, nil
 }
 
 // New creates a new pg database provider
 func New(dsn string, migrations fs.FS, opts ...Option) (*Provider, error) {
 	conf := ProviderConfig{
-		MaxConns:        100,
-		MaxConnLifetime: time.Hour,
-		ConnectTimeout:  30 * time.Second,
+		MaxConns:              100,
+		MaxConnLifetime:       time.Hour,
+		ConnectTimeout:        30 * time.Second,
+		MigrationLockInterval: time.Second,
+		MigrationLockTimeout:  5 * time.Minute,
 	}
 
 	for _, opt := range opts {
 		opt(&conf)
 	}
 
-	pgxConf, err := pgxpool.ParseConfig(dsn)
+	pgxConf, err := conf.poolConfig(dsn)
 	if err != nil {
 		return nil, trail.Stacktrace(err)
 	}
 
-	pgxConf.MaxConns = conf.MaxConns
-	pgxConf.MaxConnLifetime = conf.MaxConnLifetime
+	migrator := migration.New(stdlib.OpenDB(*pgxConf.ConnConfig), "pgx", migrations, conf.MigrationHook).
+		WithLock(conf.MigrationLockInterval, conf.MigrationLockTimeout)
+	if conf.MigrationTable != "" {
+		migrator = migrator.WithTable(conf.MigrationTable)
+	}
+
+	if metrics, ok := conf.Metrics.(provider.MigrationMetrics); ok {
+		migrator = migrator.WithMetrics(metrics)
+	}
+
+	if !conf.MigrationDryRun {
+		if err := migrator.Apply(context.Background()); err != nil {
+			return nil, trail.Stacktrace(err)
+		}
+	}
 
+	// the pool connects after migrations are applied, so enum and composite types created by them are registered
 	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectTimeout)
 	defer cancel()
 
@@ -70,19 +151,145 @@ func New(dsn string, migrations fs.FS, opts ...Option) (*Provider, error) {
 		return nil, trail.Stacktrace(err)
 	}
 
-	if err := internal.Apply(stdlib.OpenDB(*pgxConf.ConnConfig), migrations); err != nil {
-		return nil, trail.Stacktrace(err)
+	if conf.ServerVersion == 0 {
+		var version string
+		if err := db.QueryRow(ctx, "SHOW server_version_num").Scan(&version); err != nil {
+			db.Close()
+			return nil, trail.Stacktrace(err)
+		}
+
+		if conf.ServerVersion, err = strconv.Atoi(version); err != nil {
+			db.Close()
+			return nil, trail.Stacktrace(err)
+		}
+	}
+
+	p := Provider{
+		db:       db,
+		conf:     conf,
+		migrator: migrator,
+		local:    migration.IsLocal(pgxConf.ConnConfig.Host),
+		sessions: &sessionLocks{conns: make(map[int64][]*pgxpool.Conn)},
+	}
+
+	if conf.ReplicaDSN != "" {
+		replicaConf, err := conf.poolConfig(conf.ReplicaDSN)
+		if err != nil {
+			db.Close()
+			return nil, trail.Stacktrace(err)
+		}
+
+		// read-only transactions use the primary if the replica is unreachable
+		if p.replica, err = pgxpool.ConnectConfig(ctx, replicaConf); err != nil {
+			trail.Warnf("pg: read replica unavailable, falling back to primary: %s", err)
+		}
 	}
 
-	p := Provider{db: db}
 	return &p, nil
 }
 
 // ProviderConfig custom options for pg configuration
 type ProviderConfig struct {
-	MaxConns        int32
-	MaxConnLifetime time.Duration
-	ConnectTimeout  time.Duration
+	MaxConns              int32
+	MinConns              int32
+	MaxConnLifetime       time.Duration
+	MaxConnIdleTime       time.Duration
+	ConnectTimeout        time.Duration
+	SimpleProtocol        bool
+	ReplicaDSN            string
+	Metrics               Metrics
+	QueryLogger           QueryLogger
+	SlowQueryThreshold    time.Duration
+	MigrationDryRun       bool
+	MigrationHook         provider.MigrationHook
+	ForceDownMigrations   bool
+	MigrationLockInterval time.Duration
+	MigrationLockTimeout  time.Duration
+	MigrationTable        string
+	SSLMode               string
+	TLSConfig             *tls.Config
+	Schema                string
+	StatementCache        pgx.BuildStatementCacheFunc
+	ServerVersion         int
+}
+
+// poolConfig parses the dsn and applies the custom options
+func (c ProviderConfig) poolConfig(dsn string) (*pgxpool.Config, error) {
+	if c.SSLMode != "" {
+		var err error
+		if dsn, err = withSSLMode(dsn, c.SSLMode); err != nil {
+			return nil, trail.Stacktrace(err)
+		}
+	}
+
+	pgxConf, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	pgxConf.MaxConns = c.MaxConns
+	pgxConf.MinConns = c.MinConns
+	pgxConf.MaxConnLifetime = c.MaxConnLifetime
+	pgxConf.MaxConnIdleTime = c.MaxConnIdleTime
+	pgxConf.ConnConfig.ConnectTimeout = c.ConnectTimeout
+	pgxConf.ConnConfig.PreferSimpleProtocol = c.SimpleProtocol
+	pgxConf.AfterConnect = registerTypes
+
+	if c.StatementCache != nil {
+		pgxConf.ConnConfig.BuildStatementCache = c.StatementCache
+	}
+
+	if c.TLSConfig != nil {
+		pgxConf.ConnConfig.TLSConfig = c.TLSConfig.Clone()
+		pgxConf.ConnConfig.Fallbacks = nil
+	}
+
+	if c.Schema != "" {
+		if !schemaPattern.MatchString(c.Schema) {
+			return nil, trail.NewErrorf("schema %q is not valid", c.Schema)
+		}
+
+		pgxConf.ConnConfig.RuntimeParams["search_path"] = c.Schema
+	}
+
+	return pgxConf, nil
+}
+
+// registerTypes registers the registered enum and composite types with the connection
+func registerTypes(ctx context.Context, conn *pgx.Conn) error {
+	if err := registerEnums(ctx, conn); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	return registerComposites(ctx, conn)
+}
+
+// withSSLMode overrides the sslmode of a url or keyword/value dsn
+func withSSLMode(dsn, mode string) (string, error) {
+	switch mode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		return "", trail.NewErrorf("ssl mode %s is not supported", mode)
+	}
+
+	if !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") {
+		return dsn + " sslmode=" + mode, nil
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", trail.Stacktrace(err)
+	}
+
+	query := u.Query()
+	query.Set("sslmode", mode)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// isSlow checks if the query duration exceeds the slow query threshold
+func (c ProviderConfig) isSlow(d time.Duration) bool {
+	return c.SlowQueryThreshold > 0 && d >= c.SlowQueryThreshold
 }
 
 // Option A sql provider option
@@ -95,6 +302,13 @@ func WithMaxConns(n int32) Option {
 	}
 }
 
+// WithMinConns configure pg with custom min connections
+func WithMinConns(n int32) Option {
+	return func(conf *ProviderConfig) {
+		conf.MinConns = n
+	}
+}
+
 // WithMaxConnLifetime configure pg with custom max connection lifetime
 func WithMaxConnLifetime(d time.Duration) Option {
 	return func(conf *ProviderConfig) {
@@ -102,6 +316,13 @@ func WithMaxConnLifetime(d time.Duration) Option {
 	}
 }
 
+// WithMaxConnIdleTime configure pg with custom max connection idle time
+func WithMaxConnIdleTime(d time.Duration) Option {
+	return func(conf *ProviderConfig) {
+		conf.MaxConnIdleTime = d
+	}
+}
+
 // WithConnectTimeout configure pg with custom connect timeout
 func WithConnectTimeout(d time.Duration) Option {
 	return func(conf *ProviderConfig) {
@@ -109,14 +330,186 @@ func WithConnectTimeout(d time.Duration) Option {
 	}
 }
 
+// WithSimpleProtocol configure pg to use the simple protocol (e.g., for cockroachdb)
+func WithSimpleProtocol(flag bool) Option {
+	return func(conf *ProviderConfig) {
+		conf.SimpleProtocol = flag
+	}
+}
+
+// WithMigrationDryRun configure pg to not apply migrations (e.g., to preview pending migrations)
+func WithMigrationDryRun() Option {
+	return func(conf *ProviderConfig) {
+		conf.MigrationDryRun = true
+	}
+}
+
+// WithMigrationHook configure pg to call the hook around each migration
+func WithMigrationHook(hook provider.MigrationHook) Option {
+	return func(conf *ProviderConfig) {
+		conf.MigrationHook = hook
+	}
+}
+
+// WithForceDownMigrations configure pg to allow down migrations for non-local databases
+func WithForceDownMigrations() Option {
+	return func(conf *ProviderConfig) {
+		conf.ForceDownMigrations = true
+	}
+}
+
+// WithMigrationTable configure pg to record applied migrations in the table
+func WithMigrationTable(name string) Option {
+	return func(conf *ProviderConfig) {
+		conf.MigrationTable = name
+	}
+}
+
+// WithMigrationLock configure pg to poll for the migration lock at the interval until the timeout
+// the lock prevents instances from migrating concurrently, waiting instances skip migrations applied meanwhile
+func WithMigrationLock(interval, timeout time.Duration) Option {
+	return func(conf *ProviderConfig) {
+		conf.MigrationLockInterval = interval
+		conf.MigrationLockTimeout = timeout
+	}
+}
+
+// WithSSLMode configure pg with an ssl mode (e.g., disable, require or verify-full), overriding the dsn
+func WithSSLMode(mode string) Option {
+	return func(conf *ProviderConfig) {
+		conf.SSLMode = mode
+	}
+}
+
+// WithTLSConfig configure pg with a custom tls config (e.g., for client certificates or a custom ca bundle)
+// connections do not fall back to plaintext, regardless of the dsn sslmode
+func WithTLSConfig(tlsConf *tls.Config) Option {
+	return func(conf *ProviderConfig) {
+		conf.TLSConfig = tlsConf
+	}
+}
+
+// WithSchema configure pg to set the search path of each connection to the schema (e.g., for a tenant)
+// the schema must exist and only contain lowercase letters, digits and underscores
+func WithSchema(name string) Option {
+	return func(conf *ProviderConfig) {
+		conf.Schema = name
+	}
+}
+
+// WithStatementCache configure pg to prepare up to capacity statements per connection, reusing them for identical sql
+// pgx caches 512 statements by default (or the dsn statement_cache_capacity), a capacity of 0 disables the cache
+func WithStatementCache(capacity int) Option {
+	return func(conf *ProviderConfig) {
+		conf.StatementCache = func(conn *pgconn.PgConn) stmtcache.Cache {
+			if capacity <= 0 {
+				return nil
+			}
+
+			return stmtcache.New(conn, stmtcache.ModePrepare, capacity)
+		}
+	}
+}
+
+// WithReadReplica configure pg to route read-only transactions to a replica
+// if the replica is unreachable, read-only transactions use the primary
+func WithReadReplica(dsn string) Option {
+	return func(conf *ProviderConfig) {
+		conf.ReplicaDSN = dsn
+	}
+}
+
+// WithServerVersion configure pg to act as if the server_version_num were the version instead of detecting it
+// e.g., 140000 to upsert with INSERT ... ON CONFLICT instead of MERGE
+func WithServerVersion(version int) Option {
+	return func(conf *ProviderConfig) {
+		conf.ServerVersion = version
+	}
+}
+
+// Metrics records the outcome of pg queries (e.g., to an otel meter)
+// metrics also implementing provider.MigrationMetrics record the migrations applied
+type Metrics interface {
+	ObserveQuery(operation, table string, duration time.Duration, err error)
+}
+
+// WithMetrics configure pg to record query durations and errors
+func WithMetrics(m Metrics) Option {
+	return func(conf *ProviderConfig) {
+		conf.Metrics = m
+	}
+}
+
+// QueryLogger logs executed queries, sql is sanitized of literal values
+type QueryLogger interface {
+	LogQuery(ctx context.Context, sql string, args []interface{}, duration time.Duration, err error)
+}
+
+// TrailQueryLogger logs queries at debug level with trail
+type TrailQueryLogger struct{}
+
+func (l TrailQueryLogger) LogQuery(_ context.Context, sql string, args []interface{}, duration time.Duration, err error) {
+	if err != nil {
+		trail.Debugf("pg: query (%s, %d args): %s: %s", duration, len(args), sql, err)
+		return
+	}
+
+	trail.Debugf("pg: query (%s, %d args): %s", duration, len(args), sql)
+}
+
+// WithQueryLogger configure pg to log queries, warning for those slower than the threshold
+func WithQueryLogger(logger QueryLogger, slowThreshold time.Duration) Option {
+	return func(conf *ProviderConfig) {
+		conf.QueryLogger = logger
+		conf.SlowQueryThreshold = slowThreshold
+	}
+}
+
 type unitOfWork struct {
-	tx pgx.Tx
+	tx   pgx.Tx
+	conf ProviderConfig
 }
 
 func (u unitOfWork) Commit(ctx context.Context) error {
-	return u.tx.Commit(ctx)
+	err := u.tx.Commit(ctx)
+	if internal.IsRetryable(err) {
+		err = retryable(err)
+	}
+
+	return trail.Stacktrace(err)
 }
 
 func (u unitOfWork) Rollback(ctx context.Context) {
 	_ = u.tx.Rollback(ctx)
 }
+
+func (u unitOfWork) Repository() provider.Repository {
+	return repository{db: u.tx, conf: u.conf}
+}
+
+func (u unitOfWork) Savepoint(ctx context.Context, name string) (provider.UnitOfWork, error) {
+	if _, err := u.tx.Exec(ctx, "SAVEPOINT "+pgx.Identifier{name}.Sanitize()); err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	return savepoint{unitOfWork: u, name: name}, nil
+}
+
+// savepoint a unit of work nested within a transaction
+type savepoint struct {
+	unitOfWork
+	name string
+}
+
+func (s savepoint) Commit(ctx context.Context) error {
+	_, err := s.tx.Exec(ctx, "RELEASE SAVEPOINT "+pgx.Identifier{s.name}.Sanitize())
+	if internal.IsRetryable(err) {
+		err = retryable(err)
+	}
+
+	return trail.Stacktrace(err)
+}
+
+func (s savepoint) Rollback(ctx context.Context) {
+	_, _ = s.tx.Exec(ctx, "ROLLBACK TO SAVEPOINT "+pgx.Identifier{s.name}.Sanitize())
+}
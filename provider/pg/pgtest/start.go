@@ -13,10 +13,15 @@ import (
 
 // Start a test database
 func Start() (string, func() error, error) {
+	return StartVersion("12")
+}
+
+// StartVersion starts a test database of the postgres version (e.g., "15")
+func StartVersion(version string) (string, func() error, error) {
 	pool, err := dockertest.NewPool("")
 	opts := dockertest.RunOptions{
 		Repository: "postgres",
-		Tag:        "12",
+		Tag:        version,
 		Env: []string{
 			"POSTGRES_USER=postgres",
 			"POSTGRES_PASSWORD=secret",
@@ -0,0 +1,35 @@
+package pg
+
+import (
+	"context"
+	"time"
+
+	"github.com/pghq/go-tea/trail"
+)
+
+// ReplicationLag gets the replay lag of each replication slot by slot name
+// slots without a connected replica, or a replica which has caught up, report no lag
+func (p Provider) ReplicationLag(ctx context.Context) (map[string]time.Duration, error) {
+	rows, err := p.db.Query(ctx, "SELECT s.slot_name, COALESCE(EXTRACT(EPOCH FROM r.replay_lag), 0)::float8 FROM pg_replication_slots s LEFT JOIN pg_stat_replication r ON r.pid = s.active_pid")
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	defer rows.Close()
+	lag := make(map[string]time.Duration)
+	for rows.Next() {
+		var name string
+		var seconds float64
+		if err := rows.Scan(&name, &seconds); err != nil {
+			return nil, trail.Stacktrace(err)
+		}
+
+		lag[name] = time.Duration(seconds * float64(time.Second))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	return lag, nil
+}
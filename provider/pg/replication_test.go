@@ -0,0 +1,33 @@
+package pg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider_ReplicationLag(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("bad context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+		_, err := db.ReplicationLag(ctx)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		_, err := db.db.Exec(context.TODO(), "SELECT pg_create_physical_replication_slot('replication_lag')")
+		assert.Nil(t, err)
+		defer db.db.Exec(context.TODO(), "SELECT pg_drop_replication_slot('replication_lag')")
+
+		lag, err := db.ReplicationLag(context.TODO())
+		assert.Nil(t, err)
+		assert.Contains(t, lag, "replication_lag")
+		assert.Equal(t, time.Duration(0), lag["replication_lag"])
+	})
+}
@@ -2,9 +2,15 @@ package pg
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/pghq/go-tea/trail"
 
@@ -14,20 +20,46 @@ import (
 )
 
 var (
-	// ErrNotFound is returned for get ops with no results
-	ErrNotFound = trail.NewErrorNotFound("the requested item does not exist")
+	// ErrNotFound is returned for get ops with no results, see provider.NotFoundError
+	ErrNotFound = provider.ErrNotFound
 
-	// ErrUnique is return for write ops that violate unique constraint
-	ErrUnique = trail.NewErrorConflict("an item already exists matching your request")
+	// ErrUnique is return for write ops that violate unique constraint, see provider.ConflictError
+	ErrUnique = provider.ErrConflict
+
+	// ErrRetryable is returned for ops that failed due to a serialization failure
+	ErrRetryable = trail.NewErrorConflict("the request conflicted with another and may be retried")
+
+	// ErrDeadlock is returned for ops that failed due to a deadlock, and may be retried as ErrRetryable
+	ErrDeadlock = trail.NewErrorConflict("the request deadlocked with another and may be retried")
 )
 
-type repository Provider
+// retryable gets the error to return for a serialization failure or deadlock
+func retryable(err error) error {
+	if internal.IsErrorCode(err, internal.ErrCodeDeadlockDetected) {
+		return ErrDeadlock
+	}
+
+	return ErrRetryable
+}
+
+// conn is the subset of pgxpool.Pool and pgx.Tx used by the repository
+type conn interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+type repository struct {
+	db   conn
+	conf ProviderConfig
+}
 
 func (r repository) BatchQuery(ctx context.Context, query provider.BatchQuery) error {
 	queue := pgx.Batch{}
 	for _, item := range query {
 		if !item.Skip {
-			sql, args, err := item.Spec.ToSql()
+			sql, args, err := toSql(item.Spec)
 			if err != nil {
 				return trail.Stacktrace(err)
 			}
@@ -35,6 +67,7 @@ func (r repository) BatchQuery(ctx context.Context, query provider.BatchQuery) e
 		}
 	}
 
+	done := r.instrument(ctx, "BATCH", "", "", nil)
 	res := r.db.SendBatch(ctx, &queue)
 	defer res.Close()
 
@@ -51,62 +84,162 @@ func (r repository) BatchQuery(ctx context.Context, query provider.BatchQuery) e
 				}
 
 				if !item.Optional || trail.IsFatal(err) {
+					done(err)
 					return trail.Stacktrace(err)
 				}
 			}
 		}
 	}
 
+	done(nil)
+	return nil
+}
+
+func (r repository) BatchExec(ctx context.Context, exec provider.BatchExec) error {
+	queue := pgx.Batch{}
+	for _, item := range exec {
+		sql, args, err := toSql(item.Spec)
+		if err != nil {
+			return trail.Stacktrace(err)
+		}
+
+		queue.Queue(sql, args...)
+	}
+
+	done := r.instrument(ctx, "BATCH", "", "", nil)
+	res := r.db.SendBatch(ctx, &queue)
+	defer res.Close()
+
+	// pg runs the batch atomically, so statements queued after a failure report errors as well
+	for _, item := range exec {
+		_, err := res.Exec()
+		switch {
+		case internal.IsErrorCode(err, internal.ErrCodeUniqueViolation):
+			err = ErrUnique
+		case internal.IsRetryable(err):
+			err = retryable(err)
+		}
+
+		item.Err = trail.Stacktrace(err)
+	}
+
+	if errs := exec.Errors(); len(errs) > 0 {
+		done(errs[0])
+		return errs[0]
+	}
+
+	done(nil)
 	return nil
 }
 
 func (r repository) One(ctx context.Context, spec provider.Spec, v interface{}) error {
-	stmt, args, err := spec.ToSql()
+	stmt, args, err := toSql(spec)
 	if err != nil {
 		return trail.Stacktrace(err)
 	}
 
-	if err = pgxscan.Get(ctx, r.db, v, stmt, args...); trail.IsError(err, pgx.ErrNoRows) {
-		err = ErrNotFound
+	done := r.instrument(ctx, internal.Operation(stmt), internal.Table(stmt), stmt, args)
+	err = pgxscan.Get(ctx, r.db, v, stmt, args...)
+	switch {
+	case trail.IsError(err, pgx.ErrNoRows):
+		err = provider.NewNotFoundError(internal.Table(stmt), internal.Sanitize(stmt))
+	case internal.IsRetryable(err):
+		err = retryable(err)
 	}
 
+	done(err)
+
 	return trail.Stacktrace(err)
 }
 
 func (r repository) All(ctx context.Context, spec provider.Spec, v interface{}) error {
-	stmt, args, err := spec.ToSql()
+	stmt, args, err := toSql(spec)
 	if err != nil {
 		return trail.Stacktrace(err)
 	}
 
-	return pgxscan.Select(ctx, r.db, v, stmt, args...)
+	done := r.instrument(ctx, internal.Operation(stmt), internal.Table(stmt), stmt, args)
+	err = pgxscan.Select(ctx, r.db, v, stmt, args...)
+	if internal.IsRetryable(err) {
+		err = retryable(err)
+	}
+
+	done(err)
+	return trail.Stacktrace(err)
+}
+
+func (r repository) Scan(ctx context.Context, spec provider.Spec) (provider.Rows, error) {
+	stmt, args, err := toSql(spec)
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	done := r.instrument(ctx, internal.Operation(stmt), internal.Table(stmt), stmt, args)
+	res, err := r.db.Query(ctx, stmt, args...)
+	if internal.IsRetryable(err) {
+		err = retryable(err)
+	}
+
+	done(err)
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	return rows{rows: res, scanner: pgxscan.NewRowScanner(res)}, nil
 }
 
-func (r repository) Add(ctx context.Context, collection string, v interface{}) error {
+func (r repository) Add(ctx context.Context, collection string, v interface{}, opts ...provider.WriteOption) error {
+	conf := provider.WriteConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
 	data, err := encode.Map(v)
 	if err != nil {
 		return trail.Stacktrace(err)
 	}
 
+	data = conf.Filter(data)
 	builder := squirrel.StatementBuilder.
 		PlaceholderFormat(squirrel.Dollar).
 		Insert(collection).
 		SetMap(data)
 
+	if len(conf.Returning) > 0 {
+		builder = builder.Suffix(conf.Suffix())
+	}
+
 	stmt, args, err := builder.ToSql()
 	if err != nil {
 		return trail.Stacktrace(err)
 	}
 
-	if _, err = r.db.Exec(ctx, stmt, args...); internal.IsErrorCode(err, internal.ErrCodeUniqueViolation) {
-		err = ErrUnique
+	done := r.instrument(ctx, internal.Operation(stmt), collection, stmt, args)
+	_, err = r.exec(ctx, conf, stmt, args)
+	done(err)
+	switch {
+	case internal.IsErrorCode(err, internal.ErrCodeUniqueViolation):
+		err = provider.NewConflictError(collection, stmt)
+	case internal.IsRetryable(err):
+		err = retryable(err)
 	}
 
 	return trail.Stacktrace(err)
 }
 
-func (r repository) Edit(ctx context.Context, collection string, spec provider.Spec, v interface{}) error {
-	data, err := encode.Map(v)
+func (r repository) Edit(ctx context.Context, collection string, spec provider.Spec, v interface{}, opts ...provider.WriteOption) error {
+	conf := provider.WriteConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	data, err := encode.Map(v, "readonly")
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	data = conf.Filter(data)
+	where, err := conf.Versioned(spec, data)
 	if err != nil {
 		return trail.Stacktrace(err)
 	}
@@ -114,36 +247,221 @@ func (r repository) Edit(ctx context.Context, collection string, spec provider.S
 	builder := squirrel.StatementBuilder.
 		PlaceholderFormat(squirrel.Dollar).
 		Update(collection).
-		Where(spec).
+		Where(where).
 		SetMap(data)
 
+	if len(conf.Returning) > 0 {
+		builder = builder.Suffix(conf.Suffix())
+	}
+
+	stmt, args, err := builder.ToSql()
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	done := r.instrument(ctx, internal.Operation(stmt), collection, stmt, args)
+	n, err := r.exec(ctx, conf, stmt, args)
+	done(err)
+	switch {
+	case internal.IsErrorCode(err, internal.ErrCodeUniqueViolation):
+		err = provider.NewConflictError(collection, stmt)
+	case internal.IsRetryable(err):
+		err = retryable(err)
+	case err == nil && conf.VersionColumn != "" && n == 0:
+		err = provider.ErrVersionConflict
+	case err == nil && n == 0:
+		err = provider.NewNotFoundError(collection, stmt)
+	}
+
+	return trail.Stacktrace(err)
+}
+
+func (r repository) Upsert(ctx context.Context, collection string, v interface{}, conflict []string) error {
+	if len(conflict) == 0 {
+		return trail.NewError("at least one conflict column is required")
+	}
+
+	data, err := encode.Map(v)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	builder := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Insert(collection).
+		SetMap(data).
+		Suffix(onConflict(data, conflict))
+
 	stmt, args, err := builder.ToSql()
 	if err != nil {
 		return trail.Stacktrace(err)
 	}
 
-	if _, err = r.db.Exec(ctx, stmt, args...); internal.IsErrorCode(err, internal.ErrCodeUniqueViolation) {
-		err = ErrUnique
+	done := r.instrument(ctx, internal.Operation(stmt), collection, stmt, args)
+	_, err = r.db.Exec(ctx, stmt, args...)
+	done(err)
+	switch {
+	case internal.IsErrorCode(err, internal.ErrCodeUniqueViolation):
+		err = provider.NewConflictError(collection, stmt)
+	case internal.IsRetryable(err):
+		err = retryable(err)
 	}
 
 	return trail.Stacktrace(err)
 }
 
-func (r repository) Remove(ctx context.Context, collection string, spec provider.Spec) error {
+func (r repository) Remove(ctx context.Context, collection string, spec provider.Spec, opts ...provider.WriteOption) error {
+	conf := provider.WriteConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
 	builder := squirrel.StatementBuilder.
 		PlaceholderFormat(squirrel.Dollar).
 		Delete(collection).
 		Where(spec)
 
+	if len(conf.Returning) > 0 {
+		builder = builder.Suffix(conf.Suffix())
+	}
+
 	stmt, args, err := builder.ToSql()
 	if err != nil {
 		return trail.Stacktrace(err)
 	}
 
-	_, err = r.db.Exec(ctx, stmt, args...)
+	done := r.instrument(ctx, internal.Operation(stmt), collection, stmt, args)
+	_, err = r.exec(ctx, conf, stmt, args)
+	done(err)
+	if internal.IsRetryable(err) {
+		err = retryable(err)
+	}
+
+	return trail.Stacktrace(err)
+}
+
+// CopyFrom adds rows to the collection using the copy protocol
+func (r repository) CopyFrom(ctx context.Context, collection string, columns []string, rows [][]interface{}) (int64, error) {
+	if err := provider.CheckRows(columns, rows); err != nil {
+		return 0, trail.Stacktrace(err)
+	}
+
+	stmt := fmt.Sprintf("COPY %s (%s) FROM STDIN", collection, strings.Join(columns, ", "))
+	done := r.instrument(ctx, "COPY", collection, stmt, nil)
+	n, err := r.db.CopyFrom(ctx, strings.Split(collection, "."), columns, pgx.CopyFromRows(rows))
+	done(err)
+	switch {
+	case internal.IsErrorCode(err, internal.ErrCodeUniqueViolation):
+		err = provider.NewConflictError(collection, stmt)
+	case internal.IsRetryable(err):
+		err = retryable(err)
+	}
+
+	return n, trail.Stacktrace(err)
+}
+
+// ExecRaw executes the statement as is, without replacing placeholders
+func (r repository) ExecRaw(ctx context.Context, stmt string, args ...interface{}) error {
+	done := r.instrument(ctx, internal.Operation(stmt), internal.Table(stmt), stmt, args)
+	_, err := r.db.Exec(ctx, stmt, args...)
+	done(err)
+	switch {
+	case internal.IsErrorCode(err, internal.ErrCodeUniqueViolation):
+		err = provider.NewConflictError(internal.Table(stmt), internal.Sanitize(stmt))
+	case internal.IsRetryable(err):
+		err = retryable(err)
+	}
+
 	return trail.Stacktrace(err)
 }
 
+// instrument starts a span for the query and returns a func recording its outcome
+func (r repository) instrument(ctx context.Context, operation, table, stmt string, args []interface{}) func(err error) {
+	stmt = internal.Sanitize(stmt)
+	span := trail.StartSpan(ctx, "pg."+operation)
+	span.Tags.Set("db.system", "postgresql")
+	span.Tags.Set("db.operation", operation)
+	span.Tags.Set("db.sql.table", table)
+	span.Tags.Set("db.statement", stmt)
+	start := time.Now()
+
+	return func(err error) {
+		duration := time.Since(start)
+		if r.conf.Metrics != nil {
+			r.conf.Metrics.ObserveQuery(operation, table, duration, err)
+		}
+
+		if r.conf.QueryLogger != nil {
+			if r.conf.isSlow(duration) {
+				trail.Warnf("pg: slow query (%s): %s", duration, stmt)
+			}
+
+			r.conf.QueryLogger.LogQuery(ctx, stmt, args, duration, err)
+		}
+
+		span.Finish()
+	}
+}
+
+// exec runs the write and records the number of rows affected, if requested
+func (r repository) exec(ctx context.Context, conf provider.WriteConfig, stmt string, args []interface{}) (int64, error) {
+	n, err := r.write(ctx, conf, stmt, args)
+	if err == nil {
+		conf.Affected(n)
+	}
+
+	return n, err
+}
+
+// write runs the write, decoding the RETURNING columns if any, and gets the number of rows affected
+func (r repository) write(ctx context.Context, conf provider.WriteConfig, stmt string, args []interface{}) (int64, error) {
+	switch {
+	case len(conf.Returning) == 0:
+		tag, err := r.db.Exec(ctx, stmt, args...)
+		return tag.RowsAffected(), err
+	case conf.Many():
+		err := pgxscan.Select(ctx, r.db, conf.ReturningDest, stmt, args...)
+		return int64(reflect.ValueOf(conf.ReturningDest).Elem().Len()), err
+	}
+
+	err := pgxscan.Get(ctx, r.db, conf.ReturningDest, stmt, args...)
+	if trail.IsError(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+
+	return 1, err
+}
+
+// toSql converts the spec to sql using pg placeholders
+func toSql(spec provider.Spec) (string, []interface{}, error) {
+	stmt, args, err := spec.ToSql()
+	if err != nil {
+		return "", nil, trail.Stacktrace(err)
+	}
+
+	stmt, err = squirrel.Dollar.ReplacePlaceholders(stmt)
+	return stmt, args, trail.Stacktrace(err)
+}
+
+// rows iterates over pgx rows
+type rows struct {
+	rows    pgx.Rows
+	scanner *pgxscan.RowScanner
+}
+
+func (r rows) Next() bool {
+	return r.rows.Next()
+}
+
+func (r rows) Decode(v interface{}) error {
+	return trail.Stacktrace(r.scanner.Scan(v))
+}
+
+func (r rows) Close() error {
+	r.rows.Close()
+	return trail.Stacktrace(r.rows.Err())
+}
+
 type batchResults struct {
 	pgx.BatchResults
 }
@@ -151,3 +469,42 @@ type batchResults struct {
 func (b batchResults) Query(_ context.Context, _ string, _ ...interface{}) (pgx.Rows, error) {
 	return b.BatchResults.Query()
 }
+
+// onConflict builds the conflict clause updating all non-conflict columns
+func onConflict(data map[string]interface{}, conflict []string) string {
+	var set []string
+	for _, key := range columns(data) {
+		if !contains(conflict, key) {
+			set = append(set, fmt.Sprintf("%s = EXCLUDED.%s", key, key))
+		}
+	}
+
+	clause := fmt.Sprintf("ON CONFLICT (%s)", strings.Join(conflict, ", "))
+	if len(set) == 0 {
+		return clause + " DO NOTHING"
+	}
+
+	return clause + " DO UPDATE SET " + strings.Join(set, ", ")
+}
+
+// columns gets the sorted column names of the encoded data
+func columns(data map[string]interface{}) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// contains checks if the column is in the list
+func contains(cols []string, col string) bool {
+	for _, c := range cols {
+		if c == col {
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,12 +2,18 @@ package pg
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
 	"github.com/pghq/go-tea/trail"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/pghq/go-store/provider"
+	"github.com/pghq/go-store/provider/pg/internal"
 )
 
 func TestRepository_Add(t *testing.T) {
@@ -32,6 +38,32 @@ func TestRepository_Add(t *testing.T) {
 		assert.NotNil(t, err)
 		assert.True(t, trail.IsConflict(err))
 	})
+
+	t.Run("returning", func(t *testing.T) {
+		type base struct {
+			Id string `db:"id"`
+		}
+
+		type value struct {
+			base
+			Name *string `db:"name"`
+			Num  int     `db:"num,omitempty"`
+		}
+
+		name := "foo"
+		var id string
+		assert.Nil(t, repo.Add(context.TODO(), "tests", value{base: base{Id: "returning:1234"}, Name: &name}, provider.WithReturning(&id, "id")))
+		assert.Equal(t, "returning:1234", id)
+
+		var v struct {
+			Name *string `db:"name"`
+			Num  *int    `db:"num"`
+		}
+
+		assert.Nil(t, repo.One(context.TODO(), provider.NewSpec("", squirrel.Expr("SELECT name, num FROM tests WHERE id = 'returning:1234'")), &v))
+		assert.Equal(t, &name, v.Name)
+		assert.Nil(t, v.Num)
+	})
 }
 
 func TestRepository_All(t *testing.T) {
@@ -49,6 +81,214 @@ func TestRepository_All(t *testing.T) {
 		assert.Nil(t, repo.All(context.TODO(), spec("SELECT id FROM tests WHERE id = 'all:1234'"), &v))
 		assert.NotEmpty(t, v)
 	})
+
+	t.Run("arrays", func(t *testing.T) {
+		var v struct {
+			Names []string    `db:"names"`
+			Nums  []int32     `db:"nums"`
+			Ids   []uuid.UUID `db:"ids"`
+		}
+
+		query := spec("SELECT ARRAY['foo', 'bar']::text[] AS names, ARRAY[1, 2]::int[] AS nums, ARRAY['6ba7b810-9dad-11d1-80b4-00c04fd430c8']::uuid[] AS ids")
+		assert.Nil(t, repo.One(context.TODO(), query, &v))
+		assert.Equal(t, []string{"foo", "bar"}, v.Names)
+		assert.Equal(t, []int32{1, 2}, v.Nums)
+		assert.Equal(t, []uuid.UUID{uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")}, v.Ids)
+	})
+
+	t.Run("distinct on", func(t *testing.T) {
+		_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "distinct:1", "name": "distinct:a", "num": 1})
+		_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "distinct:2", "name": "distinct:a", "num": 2})
+		_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "distinct:3", "name": "distinct:b", "num": 3})
+
+		var v []struct {
+			Id   string `db:"id"`
+			Name string `db:"name"`
+		}
+
+		query := provider.NewBuilder().
+			Select("id", "name").
+			From("tests").
+			Where("name LIKE ?", "distinct:%").
+			DistinctOn("name").
+			OrderBy("num", true)
+
+		assert.Nil(t, repo.All(context.TODO(), query, &v))
+		assert.Len(t, v, 2)
+		assert.Equal(t, "distinct:2", v[0].Id)
+		assert.Equal(t, "distinct:3", v[1].Id)
+	})
+
+	t.Run("group by", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": fmt.Sprintf("all:group:%d", i), "name": "all:group", "num": i})
+		}
+
+		query := provider.NewBuilder().
+			Select("name", "COUNT(*) AS count", "SUM(num) AS total").
+			From("tests").
+			Where("id LIKE ?", "all:group:%").
+			GroupBy("name").
+			Having("COUNT(*) > ?", 2)
+
+		var v []struct {
+			Name  string
+			Count int
+			Total int
+		}
+
+		assert.Nil(t, repo.All(context.TODO(), query, &v))
+		assert.Len(t, v, 1)
+		assert.Equal(t, "all:group", v[0].Name)
+		assert.Equal(t, 3, v[0].Count)
+		assert.Equal(t, 3, v[0].Total)
+	})
+
+	t.Run("with recursive", func(t *testing.T) {
+		query := provider.NewBuilder().
+			With("seq(i)", "SELECT 1 UNION ALL SELECT i + 1 FROM seq WHERE i < ?::int", 5).
+			With("evens", "SELECT i FROM seq WHERE i % 2 = ?::int", 0).
+			WithRecursive().
+			Select("e.i").
+			From("evens e").
+			Where("e.i > ?::int", 2).
+			OrderBy("e.i", false)
+
+		var v []int
+		assert.Nil(t, repo.All(context.TODO(), query, &v))
+		assert.Equal(t, []int{4}, v)
+	})
+
+	t.Run("window", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": fmt.Sprintf("all:window:%d", i), "name": "all:window", "num": i})
+		}
+
+		inner, args, _ := provider.NewBuilder().
+			Select("id").
+			Window("rn", []string{"name"}, []string{"num DESC"}, "ROW_NUMBER()").
+			From("tests").
+			Where("name = ?", "all:window").
+			Build()
+
+		query := provider.NewBuilder().
+			With("ranked", inner, args...).
+			Select("id").
+			From("ranked").
+			Where("rn = ?::int", 1)
+
+		var v []string
+		assert.Nil(t, repo.All(context.TODO(), query, &v))
+		assert.Equal(t, []string{"all:window:2"}, v)
+	})
+
+	t.Run("join", func(t *testing.T) {
+		_ = repo.Add(context.TODO(), "units", map[string]interface{}{"id": "all:unit", "test_id": "all:1234", "name": "foo"})
+		query := provider.NewBuilder().
+			Select("t.id", "u.name").
+			From("tests t").
+			InnerJoin("units u", "u.test_id = t.id").
+			Where("u.name = ?", "foo").
+			WithStrictColumns()
+
+		var v []struct {
+			Id   string
+			Name string
+		}
+
+		assert.Nil(t, repo.All(context.TODO(), query, &v))
+		assert.Len(t, v, 1)
+		assert.Equal(t, "all:1234", v[0].Id)
+		assert.Equal(t, "foo", v[0].Name)
+	})
+
+	t.Run("embedded", func(t *testing.T) {
+		_ = repo.Add(context.TODO(), "units", map[string]interface{}{"id": "all:embedded", "test_id": "all:1234", "name": "bar"})
+		query := provider.NewBuilder().
+			Select("t.id", `u.id AS "unit.id"`, `u.name AS "unit.name"`).
+			From("tests t").
+			InnerJoin("units u", "u.test_id = t.id").
+			Where("u.id = ?", "all:embedded")
+
+		type Test struct {
+			Id string
+		}
+
+		type Unit struct {
+			Id   string
+			Name string
+		}
+
+		var v []struct {
+			Test
+			*Unit `db:"unit"`
+		}
+
+		assert.Nil(t, repo.All(context.TODO(), query, &v))
+		assert.Len(t, v, 1)
+		assert.Equal(t, "all:1234", v[0].Test.Id)
+		assert.Equal(t, "all:embedded", v[0].Unit.Id)
+		assert.Equal(t, "bar", v[0].Name)
+	})
+}
+
+func TestRepository_Scan(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	repo := db.Repository()
+	t.Run("bad sql", func(t *testing.T) {
+		_, err := repo.Scan(context.TODO(), spec("SELECT bad FROM"))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("bad decode", func(t *testing.T) {
+		rows, err := repo.Scan(context.TODO(), spec("SELECT n FROM generate_series(1, 10) n"))
+		assert.Nil(t, err)
+		defer rows.Close()
+		assert.True(t, rows.Next())
+		assert.NotNil(t, rows.Decode(nil))
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.TODO())
+		rows, err := repo.Scan(ctx, spec("SELECT n FROM generate_series(1, 10000000) n"))
+		assert.Nil(t, err)
+		cancel()
+		for rows.Next() {
+		}
+		assert.NotNil(t, rows.Close())
+	})
+
+	t.Run("close before exhaustion", func(t *testing.T) {
+		p, _ := New(dsn, nil, WithMaxConns(1))
+		rows, err := p.Repository().Scan(context.TODO(), spec("SELECT n FROM generate_series(1, 100000) n"))
+		assert.Nil(t, err)
+		assert.True(t, rows.Next())
+		assert.Nil(t, rows.Close())
+
+		ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+		defer cancel()
+		var v struct{ N int }
+		assert.Nil(t, p.Repository().One(ctx, spec("SELECT 1 AS n"), &v))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		rows, err := repo.Scan(context.TODO(), spec("SELECT n FROM generate_series(1, 100000) n"))
+		assert.Nil(t, err)
+
+		var count, sum int
+		for rows.Next() {
+			var v struct{ N int }
+			assert.Nil(t, rows.Decode(&v))
+			count += 1
+			sum += v.N
+		}
+
+		assert.Nil(t, rows.Close())
+		assert.Equal(t, 100000, count)
+		assert.Equal(t, 100000*100001/2, sum)
+	})
 }
 
 func TestRepository_BatchQuery(t *testing.T) {
@@ -85,6 +325,76 @@ func TestRepository_BatchQuery(t *testing.T) {
 	})
 }
 
+func TestRepository_CopyFrom(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	repo := db.Repository()
+	t.Run("bad rows", func(t *testing.T) {
+		_, err := repo.CopyFrom(context.TODO(), "tests", []string{"id", "num"}, [][]interface{}{{"copy:bad"}})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		rows := make([][]interface{}, 100000)
+		for i := range rows {
+			rows[i] = []interface{}{fmt.Sprintf("copy:%d", i), i}
+		}
+
+		start := time.Now()
+		n, err := repo.CopyFrom(context.TODO(), "tests", []string{"id", "num"}, rows)
+		elapsed := time.Since(start)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(len(rows)), n)
+
+		var count int64
+		assert.Nil(t, repo.One(context.TODO(), spec("SELECT COUNT(*) FROM tests WHERE id LIKE 'copy:%'"), &count))
+		assert.Equal(t, int64(len(rows)), count)
+
+		start = time.Now()
+		for i := 0; i < 1000; i++ {
+			_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": fmt.Sprintf("copy:add:%d", i), "num": i})
+		}
+
+		t.Logf("copy: %s per row, add: %s per row", elapsed/time.Duration(len(rows)), time.Since(start)/1000)
+	})
+
+	t.Run("unique violation error", func(t *testing.T) {
+		_, err := repo.CopyFrom(context.TODO(), "tests", []string{"id"}, [][]interface{}{{"copy:0"}})
+		assert.True(t, trail.IsConflict(err))
+	})
+}
+
+func TestRepository_BatchExec(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	repo := db.Repository()
+	t.Run("bad sql", func(t *testing.T) {
+		batch := provider.BatchExec{}
+		batch.Exec(spec(""))
+		assert.NotNil(t, repo.BatchExec(context.TODO(), batch))
+	})
+
+	t.Run("unique violation error", func(t *testing.T) {
+		batch := provider.BatchExec{}
+		batch.Exec(provider.NewSpec(nil, squirrel.Expr("INSERT INTO tests (id) VALUES (?)", "batch.exec:unique")))
+		batch.Exec(provider.NewSpec(nil, squirrel.Expr("INSERT INTO tests (id) VALUES (?)", "batch.exec:unique")))
+		err := repo.BatchExec(context.TODO(), batch)
+		assert.NotNil(t, err)
+		assert.True(t, trail.IsConflict(err))
+		assert.NotNil(t, batch[1].Err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		batch := provider.BatchExec{}
+		batch.Exec(provider.NewSpec(nil, squirrel.Expr("INSERT INTO tests (id) VALUES (?)", "batch.exec:1234")))
+		batch.Exec(provider.NewSpec(nil, squirrel.Expr("UPDATE tests SET num = ? WHERE id = ?", 1, "batch.exec:1234")))
+		assert.Nil(t, repo.BatchExec(context.TODO(), batch))
+		assert.Empty(t, batch.Errors())
+	})
+}
+
 func TestRepository_Edit(t *testing.T) {
 	trail.Testing()
 	t.Parallel()
@@ -95,6 +405,27 @@ func TestRepository_Edit(t *testing.T) {
 		assert.NotNil(t, repo.Edit(context.TODO(), "", spec(""), func() {}))
 	})
 
+	t.Run("returning", func(t *testing.T) {
+		_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "edit:returning:1", "num": 1})
+		_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "edit:returning:2", "num": 1})
+
+		var ids []string
+		data := map[string]interface{}{"name": "returning"}
+		assert.Nil(t, repo.Edit(context.TODO(), "tests", spec("id LIKE 'edit:returning:%' AND num = 1"), data, provider.WithReturning(&ids, "id")))
+		assert.ElementsMatch(t, []string{"edit:returning:1", "edit:returning:2"}, ids)
+
+		var name string
+		assert.Nil(t, repo.Edit(context.TODO(), "tests", spec("id = 'edit:returning:1'"), data, provider.WithReturning(&name, "name")))
+		assert.Equal(t, "returning", name)
+
+		err := repo.Edit(context.TODO(), "tests", spec("id = 'edit:returning:missing'"), data, provider.WithReturning(&name, "name"))
+		assert.True(t, trail.IsNotFound(err))
+
+		ids = nil
+		err = repo.Edit(context.TODO(), "tests", spec("id = 'edit:returning:missing'"), data, provider.WithReturning(&ids, "id"))
+		assert.True(t, trail.IsNotFound(err))
+	})
+
 	t.Run("bad sql", func(t *testing.T) {
 		assert.NotNil(t, repo.Edit(context.TODO(), "", spec(""), nil))
 	})
@@ -113,6 +444,38 @@ func TestRepository_Edit(t *testing.T) {
 		assert.NotNil(t, err)
 		assert.True(t, trail.IsConflict(err))
 	})
+
+	t.Run("not found", func(t *testing.T) {
+		err := repo.Edit(context.TODO(), "tests", spec("id = 'edit:missing'"), map[string]interface{}{"name": "foo"})
+		assert.True(t, trail.IsNotFound(err))
+	})
+
+	t.Run("columns", func(t *testing.T) {
+		type value struct {
+			Id   string `db:"id,readonly"`
+			Name string `db:"name"`
+			Num  int    `db:"num"`
+		}
+
+		_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "edit:columns", "num": 1})
+		assert.Nil(t, repo.Edit(context.TODO(), "tests", spec("id = 'edit:columns'"), value{Id: "edit:other", Name: "foo"}, provider.WithColumns("name")))
+
+		var v value
+		assert.Nil(t, repo.One(context.TODO(), spec("SELECT id, name, num FROM tests WHERE id = 'edit:columns'"), &v))
+		assert.Equal(t, value{Id: "edit:columns", Name: "foo", Num: 1}, v)
+	})
+
+	t.Run("missing version", func(t *testing.T) {
+		err := repo.Edit(context.TODO(), "tests", spec("id = 'edit:1234'"), map[string]interface{}{"id": "edit:1234"}, provider.WithVersion("num"))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("version conflict", func(t *testing.T) {
+		_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "edit:version", "num": 1})
+		assert.Nil(t, repo.Edit(context.TODO(), "tests", spec("id = 'edit:version'"), map[string]interface{}{"name": "first", "num": 1}, provider.WithVersion("num")))
+		err := repo.Edit(context.TODO(), "tests", spec("id = 'edit:version'"), map[string]interface{}{"name": "second", "num": 1}, provider.WithVersion("num"))
+		assert.Equal(t, provider.ErrVersionConflict, err)
+	})
 }
 
 func TestRepository_One(t *testing.T) {
@@ -137,6 +500,83 @@ func TestRepository_One(t *testing.T) {
 		assert.Nil(t, repo.One(context.TODO(), spec("SELECT id FROM tests WHERE id = 'one:1234'"), &v))
 		assert.NotEqual(t, "", v.Id)
 	})
+
+	t.Run("builder", func(t *testing.T) {
+		var v struct{ Id string }
+		query := provider.NewBuilder().Select("id").From("tests").Where("id = ?", "one:1234")
+		assert.Nil(t, repo.One(context.TODO(), query, &v))
+		assert.Equal(t, "one:1234", v.Id)
+	})
+}
+
+func TestRepository_Upsert(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	repo := db.Repository()
+	t.Run("missing conflict columns", func(t *testing.T) {
+		assert.NotNil(t, repo.Upsert(context.TODO(), "tests", map[string]interface{}{"id": "upsert:1234"}, nil))
+	})
+
+	t.Run("bad data encode", func(t *testing.T) {
+		assert.NotNil(t, repo.Upsert(context.TODO(), "tests", func() {}, []string{"id"}))
+	})
+
+	t.Run("bad sql", func(t *testing.T) {
+		assert.NotNil(t, repo.Upsert(context.TODO(), "", nil, []string{"id"}))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, repo.Upsert(context.TODO(), "tests", map[string]interface{}{"id": "upsert:1234", "num": 1}, []string{"id"}))
+		assert.Nil(t, repo.Upsert(context.TODO(), "tests", map[string]interface{}{"id": "upsert:1234", "num": 2}, []string{"id"}))
+
+		var v struct{ Num int }
+		assert.Nil(t, repo.One(context.TODO(), spec("SELECT num FROM tests WHERE id = 'upsert:1234'"), &v))
+		assert.Equal(t, 2, v.Num)
+	})
+
+	t.Run("conflict columns only", func(t *testing.T) {
+		assert.Nil(t, repo.Upsert(context.TODO(), "tests", map[string]interface{}{"id": "upsert:1234"}, []string{"id"}))
+	})
+}
+
+func TestRepository_ExecRaw(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	repo := db.Repository()
+	t.Run("bad sql", func(t *testing.T) {
+		assert.NotNil(t, repo.ExecRaw(context.TODO(), "bad sql"))
+	})
+
+	t.Run("unique", func(t *testing.T) {
+		_ = repo.ExecRaw(context.TODO(), "INSERT INTO tests (id) VALUES ($1)", "raw:unique")
+		err := repo.ExecRaw(context.TODO(), "INSERT INTO tests (id) VALUES ($1)", "raw:unique")
+		assert.True(t, trail.IsConflict(err))
+	})
+
+	t.Run("literal placeholders", func(t *testing.T) {
+		assert.Nil(t, repo.ExecRaw(context.TODO(), "INSERT INTO tests (id, name) VALUES ($1, 'what? $2')", "raw:1234"))
+
+		var name string
+		assert.Nil(t, repo.One(context.TODO(), spec("SELECT name FROM tests WHERE id = 'raw:1234'"), &name))
+		assert.Equal(t, "what? $2", name)
+	})
+
+	t.Run("procedural", func(t *testing.T) {
+		stmt := `DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM tests WHERE id = 'raw:do') THEN
+		INSERT INTO tests (id, name) VALUES ('raw:do', '$1 ?');
+	END IF;
+END
+$$`
+		assert.Nil(t, repo.ExecRaw(context.TODO(), stmt))
+
+		var name string
+		assert.Nil(t, repo.One(context.TODO(), spec("SELECT name FROM tests WHERE id = 'raw:do'"), &name))
+		assert.Equal(t, "$1 ?", name)
+	})
 }
 
 func TestRepository_Remove(t *testing.T) {
@@ -152,6 +592,72 @@ func TestRepository_Remove(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
 		assert.Nil(t, repo.Remove(context.TODO(), "tests", spec("id = 'remove:1234'")))
 	})
+
+	t.Run("returning", func(t *testing.T) {
+		_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "remove:returning", "name": "foo"})
+
+		var names []string
+		assert.Nil(t, repo.Remove(context.TODO(), "tests", spec("id = 'remove:returning'"), provider.WithReturning(&names, "name")))
+		assert.Equal(t, []string{"foo"}, names)
+	})
+}
+
+func BenchmarkRepository_BatchExec(b *testing.B) {
+	trail.Testing()
+	repo := db.Repository()
+
+	b.Run("loop", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < 10; j++ {
+				id := fmt.Sprintf("bench.loop:%d:%d", i, j)
+				_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": id})
+			}
+		}
+	})
+
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			batch := provider.BatchExec{}
+			for j := 0; j < 10; j++ {
+				id := fmt.Sprintf("bench.batch:%d:%d", i, j)
+				batch.Exec(provider.NewSpec(nil, squirrel.Expr("INSERT INTO tests (id) VALUES (?)", id)))
+			}
+			_ = repo.BatchExec(context.TODO(), batch)
+		}
+	})
+}
+
+func BenchmarkRepository_All(b *testing.B) {
+	trail.Testing()
+
+	for _, capacity := range []int{0, 512} {
+		p, err := New(dsn, nil, WithStatementCache(capacity))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		repo := p.Repository()
+		b.Run(fmt.Sprintf("statement cache %d", capacity), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var v []string
+				_ = repo.All(context.TODO(), provider.NewSpec(nil, squirrel.Expr("SELECT id FROM tests WHERE id = ? AND name IS NULL AND num IS NULL", "bench")), &v)
+			}
+		})
+	}
+}
+
+func TestOnConflict(t *testing.T) {
+	t.Parallel()
+
+	t.Run("do update", func(t *testing.T) {
+		clause := onConflict(map[string]interface{}{"id": 1, "num": 2, "name": "foo"}, []string{"id"})
+		assert.Equal(t, "ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, num = EXCLUDED.num", clause)
+	})
+
+	t.Run("do nothing", func(t *testing.T) {
+		clause := onConflict(map[string]interface{}{"id": 1}, []string{"id"})
+		assert.Equal(t, "ON CONFLICT (id) DO NOTHING", clause)
+	})
 }
 
 type spec string
@@ -167,3 +673,15 @@ func (s spec) ToSql() (string, []interface{}, error) {
 
 	return string(s), nil, nil
 }
+
+func TestRetryable(t *testing.T) {
+	t.Parallel()
+
+	t.Run("serialization failure", func(t *testing.T) {
+		assert.Equal(t, ErrRetryable, retryable(&pgconn.PgError{Code: internal.ErrCodeSerializationFailure}))
+	})
+
+	t.Run("deadlock detected", func(t *testing.T) {
+		assert.Equal(t, ErrDeadlock, retryable(&pgconn.PgError{Code: internal.ErrCodeDeadlockDetected}))
+	})
+}
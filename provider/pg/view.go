@@ -0,0 +1,25 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pghq/go-tea/trail"
+)
+
+// RefreshMaterializedView replaces the contents of the materialized view, outside of any transaction
+// refreshing concurrently does not lock out reads of the view, but requires a unique index on it
+func (p Provider) RefreshMaterializedView(ctx context.Context, name string, concurrently bool) error {
+	if !namePattern.MatchString(name) {
+		return trail.NewErrorBadRequest(fmt.Sprintf("materialized view %s is not a valid name", name))
+	}
+
+	stmt := "REFRESH MATERIALIZED VIEW "
+	if concurrently {
+		stmt += "CONCURRENTLY "
+	}
+
+	// the name is not quoted, so it is case folded as it is in the sql of migrations
+	stmt += name
+	return p.Repository().ExecRaw(ctx, stmt)
+}
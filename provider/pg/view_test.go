@@ -0,0 +1,47 @@
+package pg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider_RefreshMaterializedView(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	_, err := db.db.Exec(context.TODO(), "CREATE TABLE viewed (id int primary key, name text); CREATE MATERIALIZED VIEW viewed_names AS SELECT id, name FROM viewed; CREATE UNIQUE INDEX idx_viewed_names_id ON viewed_names (id)")
+	assert.Nil(t, err)
+
+	count := func() int {
+		var n int
+		assert.Nil(t, db.db.QueryRow(context.TODO(), "SELECT count(*) FROM viewed_names").Scan(&n))
+		return n
+	}
+
+	t.Run("bad name", func(t *testing.T) {
+		err := db.RefreshMaterializedView(context.TODO(), "viewed_names; DROP TABLE viewed", false)
+		assert.NotNil(t, err)
+		assert.True(t, trail.IsBadRequest(err))
+	})
+
+	t.Run("missing view", func(t *testing.T) {
+		assert.NotNil(t, db.RefreshMaterializedView(context.TODO(), "missing_names", false))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		_, err := db.db.Exec(context.TODO(), "INSERT INTO viewed VALUES (1, 'foo'), (2, 'bar')")
+		assert.Nil(t, err)
+		assert.Equal(t, 0, count())
+
+		assert.Nil(t, db.RefreshMaterializedView(context.TODO(), "viewed_names", false))
+		assert.Equal(t, 2, count())
+
+		_, err = db.db.Exec(context.TODO(), "INSERT INTO viewed VALUES (3, 'baz')")
+		assert.Nil(t, err)
+		assert.Nil(t, db.RefreshMaterializedView(context.TODO(), "public.viewed_names", true))
+		assert.Equal(t, 3, count())
+	})
+}
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 )
@@ -12,22 +13,118 @@ var _ Spec = spec{}
 type Provider interface {
 	Repository() Repository
 	Begin(ctx context.Context, opts ...TxOption) (UnitOfWork, error)
+	PendingMigrations(ctx context.Context) ([]string, error)
+	SchemaDiff(ctx context.Context) ([]string, error)
+	MigrationStatus(ctx context.Context) ([]MigrationVersion, error)
+	MigrateTo(ctx context.Context, version int64) error
+}
+
+// MigrationVersion the status of a migration
+type MigrationVersion struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// MigrationHook is called around each migration applied (e.g., for audit logging)
+// an error from Before aborts the migration
+type MigrationHook interface {
+	Before(ctx context.Context, version int64, sql string) error
+	After(ctx context.Context, version int64, duration time.Duration) error
+}
+
+// MigrationMetrics records the migrations applied (e.g., to a histogram and gauge)
+type MigrationMetrics interface {
+	ObserveMigration(version int64, duration time.Duration)
+	SetMigrationVersion(version int64)
+}
+
+// Local a provider which knows if its database is local (e.g., to guard destructive operations)
+type Local interface {
+	IsLocal() bool
+}
+
+// PubSub a provider supporting notifications (e.g., pg LISTEN/NOTIFY)
+type PubSub interface {
+	Subscribe(ctx context.Context, channel string, handler func(payload string)) (func(), error)
+	Notify(ctx context.Context, channel, payload string) error
+}
+
+// Locker a provider supporting session level advisory locks
+type Locker interface {
+	SessionAdvisoryLock(ctx context.Context, key int64) error
+	SessionAdvisoryUnlock(ctx context.Context, key int64) error
+}
+
+// Replicator a provider which knows the replication lag of its replication slots
+type Replicator interface {
+	ReplicationLag(ctx context.Context) (map[string]time.Duration, error)
+}
+
+// Indexer a provider able to create indexes without blocking writes to the table
+type Indexer interface {
+	CreateIndexConcurrently(ctx context.Context, schema, table, name, expression string, progress func(IndexProgress)) error
+}
+
+// Merger a repository able to add values or update the rows matching them in a single statement (e.g., pg MERGE)
+type Merger interface {
+	Merge(ctx context.Context, collection string, v interface{}, match []string, insertDefaults map[string]interface{}, update []string) error
+}
+
+// BatchAdder a repository able to add many values with few statements
+type BatchAdder interface {
+	AddBatch(ctx context.Context, collection string, values []interface{}) error
+}
+
+// ViewRefresher a provider able to refresh materialized views
+type ViewRefresher interface {
+	RefreshMaterializedView(ctx context.Context, name string, concurrently bool) error
+}
+
+// Partitioner a provider able to create partitions of partitioned tables
+type Partitioner interface {
+	CreatePartition(ctx context.Context, parent, name, bound string) error
+}
+
+// IndexProgress the progress of an index being created
+type IndexProgress struct {
+	Phase       string
+	BlocksDone  int64
+	BlocksTotal int64
+	TuplesDone  int64
+	TuplesTotal int64
 }
 
 // UnitOfWork to do
 type UnitOfWork interface {
 	Commit(ctx context.Context) error
 	Rollback(ctx context.Context)
+	Repository() Repository
+	Savepoint(ctx context.Context, name string) (UnitOfWork, error)
 }
 
 // Repository abstraction for a collection of objects.
 type Repository interface {
 	One(ctx context.Context, spec Spec, v interface{}) error
 	All(ctx context.Context, spec Spec, v interface{}) error
-	Add(ctx context.Context, collection string, v interface{}) error
-	Edit(ctx context.Context, collection string, spec Spec, v interface{}) error
-	Remove(ctx context.Context, collection string, spec Spec) error
+	Scan(ctx context.Context, spec Spec) (Rows, error)
+	Add(ctx context.Context, collection string, v interface{}, opts ...WriteOption) error
+	Edit(ctx context.Context, collection string, spec Spec, v interface{}, opts ...WriteOption) error
+	Upsert(ctx context.Context, collection string, v interface{}, conflict []string) error
+	Remove(ctx context.Context, collection string, spec Spec, opts ...WriteOption) error
 	BatchQuery(ctx context.Context, query BatchQuery) error
+	BatchExec(ctx context.Context, exec BatchExec) error
+	CopyFrom(ctx context.Context, collection string, columns []string, rows [][]interface{}) (int64, error)
+	ExecRaw(ctx context.Context, stmt string, args ...interface{}) error
+}
+
+// Rows an iterator over the results of a query
+// rows are read from the database as they are iterated and must be closed
+type Rows interface {
+	Next() bool
+	Decode(v interface{}) error
+	Close() error
 }
 
 // Spec for querying objects
@@ -0,0 +1,28 @@
+package provider
+
+// TableInfo the schema of a table
+type TableInfo struct {
+	Schema     string
+	Name       string
+	Columns    []ColumnInfo
+	References []string
+}
+
+// Column gets a column by name
+func (t TableInfo) Column(name string) (ColumnInfo, bool) {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+
+	return ColumnInfo{}, false
+}
+
+// ColumnInfo the schema of a column
+type ColumnInfo struct {
+	Name     string  `db:"name"`
+	Type     string  `db:"type"`
+	Nullable bool    `db:"nullable"`
+	Default  *string `db:"default_value"`
+}
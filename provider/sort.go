@@ -0,0 +1,169 @@
+package provider
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pghq/go-tea/trail"
+)
+
+// Sort sorts the results in v as the ORDER BY clause of the query would (e.g., to merge the results of several queries)
+// only plain sort columns are supported, and nulls sort last for ascending columns and first for descending ones, as in pg
+func (b *Builder) Sort(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return trail.NewErrorf("results of type %T are not a pointer to a slice", v)
+	}
+
+	type key struct {
+		col  string
+		desc bool
+	}
+
+	var keys []key
+	for _, o := range b.orders {
+		col := o.column()
+		if col == "" {
+			return trail.NewErrorf("sort expression %s is not a column", o.expr)
+		}
+
+		keys = append(keys, key{col: col, desc: strings.HasSuffix(strings.ToUpper(o.expr), " DESC")})
+	}
+
+	items := rv.Elem()
+	var err error
+	sort.SliceStable(items.Interface(), func(i, j int) bool {
+		for _, k := range keys {
+			a, aok := columnValue(items.Index(i), k.col)
+			c, cok := columnValue(items.Index(j), k.col)
+			if !aok || !cok {
+				err = trail.NewErrorf("sort column %s is missing from the results", k.col)
+				return false
+			}
+
+			n, ok := compare(a, c)
+			if !ok {
+				err = trail.NewErrorf("sort column %s of type %s can not be compared", k.col, a.Type())
+				return false
+			}
+
+			if k.desc {
+				n = -n
+			}
+
+			if n != 0 {
+				return n < 0
+			}
+		}
+
+		return false
+	})
+
+	return err
+}
+
+// compare compares two values of the same column, nulls sorting after all other values
+func compare(a, b reflect.Value) (int, bool) {
+	for _, v := range []*reflect.Value{&a, &b} {
+		for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				break
+			}
+
+			*v = v.Elem()
+		}
+	}
+
+	anull, bnull := isNull(a), isNull(b)
+	switch {
+	case anull && bnull:
+		return 0, true
+	case anull:
+		return 1, true
+	case bnull:
+		return -1, true
+	}
+
+	if at, ok := a.Interface().(time.Time); ok {
+		bt, ok := b.Interface().(time.Time)
+		if !ok {
+			return 0, false
+		}
+
+		return sign(at.Sub(bt).Nanoseconds()), true
+	}
+
+	switch {
+	case a.Kind() == reflect.String && b.Kind() == reflect.String:
+		return strings.Compare(a.String(), b.String()), true
+	case a.Kind() == reflect.Bool && b.Kind() == reflect.Bool:
+		if a.Bool() == b.Bool() {
+			return 0, true
+		}
+
+		if b.Bool() {
+			return -1, true
+		}
+
+		return 1, true
+	}
+
+	if a.CanInt() && b.CanInt() {
+		switch {
+		case a.Int() < b.Int():
+			return -1, true
+		case a.Int() > b.Int():
+			return 1, true
+		}
+
+		return 0, true
+	}
+
+	af, aok := number(a)
+	bf, bok := number(b)
+	if !aok || !bok {
+		return 0, false
+	}
+
+	switch {
+	case af < bf:
+		return -1, true
+	case af > bf:
+		return 1, true
+	}
+
+	return 0, true
+}
+
+// isNull checks if the value is a nil pointer, interface or invalid
+func isNull(v reflect.Value) bool {
+	return !v.IsValid() || (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil()
+}
+
+// number gets the value of numeric kinds
+func number(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+
+	return 0, false
+}
+
+// sign gets -1, 0 or 1 for negative, zero and positive numbers
+func sign(n int64) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder_Sort(t *testing.T) {
+	t.Parallel()
+
+	type value struct {
+		Id        int        `db:"id"`
+		Name      string     `db:"name"`
+		Score     *float64   `db:"score"`
+		CreatedAt *time.Time `db:"created_at"`
+	}
+
+	score := func(f float64) *float64 { return &f }
+	at := func(day int) *time.Time {
+		t := time.Date(2022, 5, day, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+
+	t.Run("bad values", func(t *testing.T) {
+		assert.NotNil(t, NewBuilder().OrderBy("id", false).Sort([]value{}))
+	})
+
+	t.Run("expression", func(t *testing.T) {
+		v := []value{{Id: 1}, {Id: 2}}
+		assert.NotNil(t, NewBuilder().OrderByExpr("lower(name)").Sort(&v))
+	})
+
+	t.Run("missing column", func(t *testing.T) {
+		v := []value{{Id: 1}, {Id: 2}}
+		assert.NotNil(t, NewBuilder().OrderBy("missing", false).Sort(&v))
+	})
+
+	t.Run("not comparable", func(t *testing.T) {
+		v := []map[string]interface{}{{"id": struct{}{}}, {"id": struct{}{}}}
+		assert.NotNil(t, NewBuilder().OrderBy("id", false).Sort(&v))
+	})
+
+	t.Run("columns", func(t *testing.T) {
+		v := []value{
+			{Id: 1, Name: "b", Score: score(1)},
+			{Id: 2, Name: "a", Score: nil},
+			{Id: 3, Name: "a", Score: score(2)},
+			{Id: 4, Name: "b", Score: score(1.5)},
+		}
+
+		assert.Nil(t, NewBuilder().OrderBy("t.name", false).OrderBy("score", true).Sort(&v))
+		assert.Equal(t, []int{2, 3, 4, 1}, []int{v[0].Id, v[1].Id, v[2].Id, v[3].Id})
+
+		assert.Nil(t, NewBuilder().OrderBy("score", false).Sort(&v))
+		assert.Equal(t, []int{1, 4, 3, 2}, []int{v[0].Id, v[1].Id, v[2].Id, v[3].Id})
+	})
+
+	t.Run("times", func(t *testing.T) {
+		v := []value{{Id: 1, CreatedAt: at(2)}, {Id: 2, CreatedAt: at(1)}, {Id: 3, CreatedAt: at(3)}}
+		assert.Nil(t, NewBuilder().OrderBy("created_at", false).Sort(&v))
+		assert.Equal(t, []int{2, 1, 3}, []int{v[0].Id, v[1].Id, v[2].Id})
+	})
+
+	t.Run("maps", func(t *testing.T) {
+		v := []map[string]interface{}{{"id": int64(2)}, {"id": int64(10)}, {"id": int64(1)}}
+		assert.Nil(t, NewBuilder().OrderBy("id", true).Sort(&v))
+		assert.Equal(t, []map[string]interface{}{{"id": int64(10)}, {"id": int64(2)}, {"id": int64(1)}}, v)
+	})
+}
@@ -0,0 +1,25 @@
+package internal
+
+import (
+	"github.com/pghq/go-tea/trail"
+	"modernc.org/sqlite"
+)
+
+const (
+	// ErrCodeConstraintPrimaryKey expected sqlite extended error code for primary key violations
+	ErrCodeConstraintPrimaryKey = 1555
+
+	// ErrCodeConstraintUnique expected sqlite extended error code for unique violations
+	ErrCodeConstraintUnique = 2067
+)
+
+// IsErrorCode checks if error code matches underlying sqlite code
+func IsErrorCode(err error, code int) bool {
+	var icv *sqlite.Error
+	return err != nil && trail.AsError(err, &icv) && code == icv.Code()
+}
+
+// IsIntegrityViolation checks if the error is any of the sqlite unique constraint violations
+func IsIntegrityViolation(err error) bool {
+	return IsErrorCode(err, ErrCodeConstraintPrimaryKey) || IsErrorCode(err, ErrCodeConstraintUnique)
+}
@@ -0,0 +1,401 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/sqlscan"
+	"github.com/pghq/go-tea/trail"
+
+	"github.com/pghq/go-store/internal/encode"
+	"github.com/pghq/go-store/provider"
+	"github.com/pghq/go-store/provider/sqlite/internal"
+)
+
+var (
+	// ErrNotFound is returned for get ops with no results, see provider.NotFoundError
+	ErrNotFound = provider.ErrNotFound
+
+	// ErrUnique is return for write ops that violate unique constraint, see provider.ConflictError
+	ErrUnique = provider.ErrConflict
+)
+
+// conn is the subset of sql.DB and sql.Tx used by the repository
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type repository struct {
+	db conn
+}
+
+func (r repository) BatchQuery(ctx context.Context, query provider.BatchQuery) error {
+	// sqlite has no pipelining equivalent to pgx.Batch, so items are sent in order
+	for _, item := range query {
+		if !item.Skip {
+			stmt, args, err := item.Spec.ToSql()
+			if err != nil {
+				return trail.Stacktrace(err)
+			}
+
+			handler := sqlscan.Select
+			if item.One {
+				handler = sqlscan.Get
+			}
+
+			if err := handler(ctx, r.db, item.Value, stmt, args...); err != nil {
+				if trail.IsError(err, sql.ErrNoRows) {
+					err = ErrNotFound
+				}
+
+				if !item.Optional || trail.IsFatal(err) {
+					return trail.Stacktrace(err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r repository) BatchExec(ctx context.Context, exec provider.BatchExec) error {
+	for _, item := range exec {
+		stmt, args, err := item.Spec.ToSql()
+		if err != nil {
+			return trail.Stacktrace(err)
+		}
+
+		if _, err = r.db.ExecContext(ctx, stmt, args...); internal.IsIntegrityViolation(err) {
+			err = ErrUnique
+		}
+
+		if err != nil {
+			item.Err = trail.Stacktrace(err)
+			return item.Err
+		}
+	}
+
+	return nil
+}
+
+func (r repository) One(ctx context.Context, spec provider.Spec, v interface{}) error {
+	stmt, args, err := spec.ToSql()
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	if err = sqlscan.Get(ctx, r.db, v, stmt, args...); trail.IsError(err, sql.ErrNoRows) {
+		err = provider.NewNotFoundError("", stmt)
+	}
+
+	return trail.Stacktrace(err)
+}
+
+func (r repository) All(ctx context.Context, spec provider.Spec, v interface{}) error {
+	stmt, args, err := spec.ToSql()
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	return sqlscan.Select(ctx, r.db, v, stmt, args...)
+}
+
+func (r repository) Scan(ctx context.Context, spec provider.Spec) (provider.Rows, error) {
+	stmt, args, err := spec.ToSql()
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	res, err := r.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	return rows{rows: res, scanner: sqlscan.NewRowScanner(res)}, nil
+}
+
+func (r repository) Add(ctx context.Context, collection string, v interface{}, opts ...provider.WriteOption) error {
+	conf := provider.WriteConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	data, err := encode.Map(v)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	data = conf.Filter(data)
+	builder := squirrel.StatementBuilder.
+		Insert(collection).
+		SetMap(data)
+
+	if len(conf.Returning) > 0 {
+		builder = builder.Suffix(conf.Suffix())
+	}
+
+	stmt, args, err := builder.ToSql()
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	if _, err = r.exec(ctx, conf, stmt, args); internal.IsIntegrityViolation(err) {
+		err = provider.NewConflictError(collection, stmt)
+	}
+
+	return trail.Stacktrace(err)
+}
+
+func (r repository) Edit(ctx context.Context, collection string, spec provider.Spec, v interface{}, opts ...provider.WriteOption) error {
+	conf := provider.WriteConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	data, err := encode.Map(v, "readonly")
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	data = conf.Filter(data)
+	where, err := conf.Versioned(spec, data)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	builder := squirrel.StatementBuilder.
+		Update(collection).
+		Where(where).
+		SetMap(data)
+
+	if len(conf.Returning) > 0 {
+		builder = builder.Suffix(conf.Suffix())
+	}
+
+	stmt, args, err := builder.ToSql()
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	n, err := r.exec(ctx, conf, stmt, args)
+	if internal.IsIntegrityViolation(err) {
+		return provider.NewConflictError(collection, stmt)
+	}
+
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	if n == 0 {
+		if conf.VersionColumn != "" {
+			return trail.Stacktrace(provider.ErrVersionConflict)
+		}
+
+		return provider.NewNotFoundError(collection, stmt)
+	}
+
+	return nil
+}
+
+func (r repository) Upsert(ctx context.Context, collection string, v interface{}, conflict []string) error {
+	if len(conflict) == 0 {
+		return trail.NewError("at least one conflict column is required")
+	}
+
+	data, err := encode.Map(v)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	builder := squirrel.StatementBuilder.
+		Insert(collection).
+		SetMap(data).
+		Suffix(onConflict(data, conflict))
+
+	stmt, args, err := builder.ToSql()
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	if _, err = r.db.ExecContext(ctx, stmt, args...); internal.IsIntegrityViolation(err) {
+		err = provider.NewConflictError(collection, stmt)
+	}
+
+	return trail.Stacktrace(err)
+}
+
+func (r repository) Remove(ctx context.Context, collection string, spec provider.Spec, opts ...provider.WriteOption) error {
+	conf := provider.WriteConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	builder := squirrel.StatementBuilder.
+		Delete(collection).
+		Where(spec)
+
+	if len(conf.Returning) > 0 {
+		builder = builder.Suffix(conf.Suffix())
+	}
+
+	stmt, args, err := builder.ToSql()
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	_, err = r.exec(ctx, conf, stmt, args)
+	return trail.Stacktrace(err)
+}
+
+// exec runs the write and records the number of rows affected, if requested
+func (r repository) exec(ctx context.Context, conf provider.WriteConfig, stmt string, args []interface{}) (int64, error) {
+	n, err := r.write(ctx, conf, stmt, args)
+	if err == nil {
+		conf.Affected(n)
+	}
+
+	return n, err
+}
+
+// write runs the write, decoding the RETURNING columns if any, and gets the number of rows affected
+func (r repository) write(ctx context.Context, conf provider.WriteConfig, stmt string, args []interface{}) (int64, error) {
+	switch {
+	case len(conf.Returning) == 0:
+		res, err := r.db.ExecContext(ctx, stmt, args...)
+		if err != nil {
+			return 0, err
+		}
+
+		return res.RowsAffected()
+	case conf.Many():
+		err := sqlscan.Select(ctx, r.db, conf.ReturningDest, stmt, args...)
+		return int64(reflect.ValueOf(conf.ReturningDest).Elem().Len()), err
+	}
+
+	err := sqlscan.Get(ctx, r.db, conf.ReturningDest, stmt, args...)
+	if trail.IsError(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+
+	return 1, err
+}
+
+// onConflict builds the conflict clause updating all non-conflict columns
+func onConflict(data map[string]interface{}, conflict []string) string {
+	var set []string
+	for _, key := range columns(data) {
+		if !contains(conflict, key) {
+			set = append(set, fmt.Sprintf("%s = excluded.%s", key, key))
+		}
+	}
+
+	clause := fmt.Sprintf("ON CONFLICT (%s)", strings.Join(conflict, ", "))
+	if len(set) == 0 {
+		return clause + " DO NOTHING"
+	}
+
+	return clause + " DO UPDATE SET " + strings.Join(set, ", ")
+}
+
+// columns gets the sorted column names of the encoded data
+func columns(data map[string]interface{}) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// contains checks if the column is in the list
+func contains(cols []string, col string) bool {
+	for _, c := range cols {
+		if c == col {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rows iterates over sql rows
+type rows struct {
+	rows    *sql.Rows
+	scanner *sqlscan.RowScanner
+}
+
+func (r rows) Next() bool {
+	return r.rows.Next()
+}
+
+func (r rows) Decode(v interface{}) error {
+	return trail.Stacktrace(r.scanner.Scan(v))
+}
+
+func (r rows) Close() error {
+	if err := r.rows.Close(); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	return trail.Stacktrace(r.rows.Err())
+}
+
+// CopyFrom adds rows to the collection using multi-row inserts
+func (r repository) CopyFrom(ctx context.Context, collection string, columns []string, rows [][]interface{}) (int64, error) {
+	if err := provider.CheckRows(columns, rows); err != nil {
+		return 0, trail.Stacktrace(err)
+	}
+
+	var n int64
+	size := provider.MaxCopyArgs / len(columns)
+	for start := 0; start < len(rows); start += size {
+		end := start + size
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		builder := squirrel.StatementBuilder.
+			Insert(collection).
+			Columns(columns...)
+
+		for _, row := range rows[start:end] {
+			builder = builder.Values(row...)
+		}
+
+		stmt, args, err := builder.ToSql()
+		if err != nil {
+			return n, trail.Stacktrace(err)
+		}
+
+		res, err := r.db.ExecContext(ctx, stmt, args...)
+		if internal.IsIntegrityViolation(err) {
+			err = provider.NewConflictError(collection, stmt)
+		}
+
+		if err != nil {
+			return n, trail.Stacktrace(err)
+		}
+
+		affected, _ := res.RowsAffected()
+		n += affected
+	}
+
+	return n, nil
+}
+
+// ExecRaw executes the statement as is
+func (r repository) ExecRaw(ctx context.Context, stmt string, args ...interface{}) error {
+	_, err := r.db.ExecContext(ctx, stmt, args...)
+	if internal.IsIntegrityViolation(err) {
+		err = provider.NewConflictError("", stmt)
+	}
+
+	return trail.Stacktrace(err)
+}
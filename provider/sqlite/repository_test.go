@@ -0,0 +1,354 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pghq/go-store/provider"
+)
+
+func TestRepository_Add(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	repo := db.Repository()
+	t.Run("bad data encode", func(t *testing.T) {
+		assert.NotNil(t, repo.Add(context.TODO(), "tests", func() {}))
+	})
+
+	t.Run("bad sql", func(t *testing.T) {
+		assert.NotNil(t, repo.Add(context.TODO(), "", nil))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "1234"}))
+	})
+
+	t.Run("unique violation error", func(t *testing.T) {
+		err := repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "1234"})
+		assert.NotNil(t, err)
+		assert.True(t, trail.IsConflict(err))
+	})
+
+	t.Run("returning", func(t *testing.T) {
+		type base struct {
+			Id string `db:"id"`
+		}
+
+		type value struct {
+			base
+			Name *string `db:"name"`
+			Num  int     `db:"num,omitempty"`
+		}
+
+		name := "foo"
+		var id string
+		assert.Nil(t, repo.Add(context.TODO(), "tests", value{base: base{Id: "returning:1234"}, Name: &name}, provider.WithReturning(&id, "id")))
+		assert.Equal(t, "returning:1234", id)
+
+		var v struct {
+			Name *string `db:"name"`
+			Num  *int    `db:"num"`
+		}
+
+		assert.Nil(t, repo.One(context.TODO(), provider.NewSpec("", squirrel.Expr("SELECT name, num FROM tests WHERE id = 'returning:1234'")), &v))
+		assert.Equal(t, &name, v.Name)
+		assert.Nil(t, v.Num)
+	})
+}
+
+func TestRepository_All(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	repo := db.Repository()
+	_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "all:1234"})
+	t.Run("bad sql", func(t *testing.T) {
+		assert.NotNil(t, repo.All(context.TODO(), spec(""), nil))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		var v []struct{ Id string }
+		assert.Nil(t, repo.All(context.TODO(), spec("SELECT id FROM tests WHERE id = 'all:1234'"), &v))
+		assert.NotEmpty(t, v)
+	})
+}
+
+func TestRepository_BatchQuery(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	repo := db.Repository()
+	_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "batch.query:1234"})
+
+	t.Run("bad sql", func(t *testing.T) {
+		batch := provider.BatchQuery{}
+		batch.One(spec(""), nil)
+		assert.NotNil(t, repo.BatchQuery(context.TODO(), batch))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		batch := provider.BatchQuery{}
+		var one struct{ Id string }
+		batch.One(spec("SELECT id FROM tests WHERE id = 'batch.query:foo'"), &one)
+		assert.NotNil(t, repo.BatchQuery(context.TODO(), batch))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		batch := provider.BatchQuery{}
+		var all []struct{ Id string }
+		batch.All(spec("SELECT id FROM tests WHERE id = 'batch.query:1234'"), &all)
+
+		var one struct{ Id string }
+		batch.One(spec("SELECT id FROM tests WHERE id = 'batch.query:1234'"), &one)
+
+		assert.Nil(t, repo.BatchQuery(context.TODO(), batch))
+		assert.NotEmpty(t, all)
+		assert.NotEqual(t, "", one.Id)
+	})
+}
+
+func TestRepository_CopyFrom(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	repo := db.Repository()
+	t.Run("bad rows", func(t *testing.T) {
+		_, err := repo.CopyFrom(context.TODO(), "tests", []string{"id", "num"}, [][]interface{}{{"copy:bad"}})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		rows := make([][]interface{}, provider.MaxCopyArgs/2+1)
+		for i := range rows {
+			rows[i] = []interface{}{fmt.Sprintf("copy:%d", i), i}
+		}
+
+		n, err := repo.CopyFrom(context.TODO(), "tests", []string{"id", "num"}, rows)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(len(rows)), n)
+	})
+
+	t.Run("unique violation error", func(t *testing.T) {
+		_, err := repo.CopyFrom(context.TODO(), "tests", []string{"id"}, [][]interface{}{{"copy:0"}})
+		assert.True(t, trail.IsConflict(err))
+	})
+}
+
+func TestRepository_BatchExec(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	repo := db.Repository()
+	t.Run("bad sql", func(t *testing.T) {
+		batch := provider.BatchExec{}
+		batch.Exec(spec(""))
+		assert.NotNil(t, repo.BatchExec(context.TODO(), batch))
+	})
+
+	t.Run("unique violation error", func(t *testing.T) {
+		batch := provider.BatchExec{}
+		batch.Exec(provider.NewSpec(nil, squirrel.Expr("INSERT INTO tests (id) VALUES (?)", "batch.exec:unique")))
+		batch.Exec(provider.NewSpec(nil, squirrel.Expr("INSERT INTO tests (id) VALUES (?)", "batch.exec:unique")))
+		err := repo.BatchExec(context.TODO(), batch)
+		assert.NotNil(t, err)
+		assert.True(t, trail.IsConflict(err))
+		assert.NotNil(t, batch[1].Err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		batch := provider.BatchExec{}
+		batch.Exec(provider.NewSpec(nil, squirrel.Expr("INSERT INTO tests (id) VALUES (?)", "batch.exec:1234")))
+		batch.Exec(provider.NewSpec(nil, squirrel.Expr("UPDATE tests SET num = ? WHERE id = ?", 1, "batch.exec:1234")))
+		assert.Nil(t, repo.BatchExec(context.TODO(), batch))
+		assert.Empty(t, batch.Errors())
+	})
+}
+
+func TestRepository_Edit(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	repo := db.Repository()
+	_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "edit:1234"})
+	t.Run("bad data encode", func(t *testing.T) {
+		assert.NotNil(t, repo.Edit(context.TODO(), "", spec(""), func() {}))
+	})
+
+	t.Run("returning", func(t *testing.T) {
+		_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "edit:returning:1", "num": 1})
+		_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "edit:returning:2", "num": 1})
+
+		var ids []string
+		data := map[string]interface{}{"name": "returning"}
+		assert.Nil(t, repo.Edit(context.TODO(), "tests", spec("id LIKE 'edit:returning:%' AND num = 1"), data, provider.WithReturning(&ids, "id")))
+		assert.ElementsMatch(t, []string{"edit:returning:1", "edit:returning:2"}, ids)
+
+		var name string
+		assert.Nil(t, repo.Edit(context.TODO(), "tests", spec("id = 'edit:returning:1'"), data, provider.WithReturning(&name, "name")))
+		assert.Equal(t, "returning", name)
+
+		err := repo.Edit(context.TODO(), "tests", spec("id = 'edit:returning:missing'"), data, provider.WithReturning(&name, "name"))
+		assert.True(t, trail.IsNotFound(err))
+
+		ids = nil
+		err = repo.Edit(context.TODO(), "tests", spec("id = 'edit:returning:missing'"), data, provider.WithReturning(&ids, "id"))
+		assert.True(t, trail.IsNotFound(err))
+	})
+
+	t.Run("bad sql", func(t *testing.T) {
+		assert.NotNil(t, repo.Edit(context.TODO(), "", spec(""), nil))
+	})
+
+	t.Run("unique violation error", func(t *testing.T) {
+		assert.Nil(t, repo.Edit(context.TODO(), "tests", spec("id = 'edit:1234'"), map[string]interface{}{"id": "edit:1234"}))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, repo.Edit(context.TODO(), "tests", spec("id = 'edit:1234'"), map[string]interface{}{"id": "edit:1234"}))
+	})
+
+	t.Run("unique violation error", func(t *testing.T) {
+		_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "edit:12345"})
+		err := repo.Edit(context.TODO(), "tests", spec("id = 'edit:12345'"), map[string]interface{}{"id": "edit:1234"})
+		assert.NotNil(t, err)
+		assert.True(t, trail.IsConflict(err))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		err := repo.Edit(context.TODO(), "tests", spec("id = 'edit:missing'"), map[string]interface{}{"name": "foo"})
+		assert.True(t, trail.IsNotFound(err))
+	})
+
+	t.Run("columns", func(t *testing.T) {
+		type value struct {
+			Id   string `db:"id,readonly"`
+			Name string `db:"name"`
+			Num  int    `db:"num"`
+		}
+
+		_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "edit:columns", "num": 1})
+		assert.Nil(t, repo.Edit(context.TODO(), "tests", spec("id = 'edit:columns'"), value{Id: "edit:other", Name: "foo"}, provider.WithColumns("name")))
+
+		var v value
+		assert.Nil(t, repo.One(context.TODO(), spec("SELECT id, name, num FROM tests WHERE id = 'edit:columns'"), &v))
+		assert.Equal(t, value{Id: "edit:columns", Name: "foo", Num: 1}, v)
+	})
+}
+
+func TestRepository_One(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	repo := db.Repository()
+	_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "one:1234"})
+	t.Run("bad sql", func(t *testing.T) {
+		assert.NotNil(t, repo.One(context.TODO(), spec(""), nil))
+	})
+
+	t.Run("not found error", func(t *testing.T) {
+		var v struct{ Id string }
+		err := repo.One(context.TODO(), spec("SELECT id FROM tests WHERE id = 'one:foo'"), &v)
+		assert.NotNil(t, err)
+		assert.True(t, trail.IsNotFound(err))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		var v struct{ Id string }
+		assert.Nil(t, repo.One(context.TODO(), spec("SELECT id FROM tests WHERE id = 'one:1234'"), &v))
+		assert.NotEqual(t, "", v.Id)
+	})
+}
+
+func TestRepository_Upsert(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	repo := db.Repository()
+	t.Run("missing conflict columns", func(t *testing.T) {
+		assert.NotNil(t, repo.Upsert(context.TODO(), "tests", map[string]interface{}{"id": "upsert:1234"}, nil))
+	})
+
+	t.Run("bad data encode", func(t *testing.T) {
+		assert.NotNil(t, repo.Upsert(context.TODO(), "tests", func() {}, []string{"id"}))
+	})
+
+	t.Run("bad sql", func(t *testing.T) {
+		assert.NotNil(t, repo.Upsert(context.TODO(), "", nil, []string{"id"}))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, repo.Upsert(context.TODO(), "tests", map[string]interface{}{"id": "upsert:1234", "num": 1}, []string{"id"}))
+		assert.Nil(t, repo.Upsert(context.TODO(), "tests", map[string]interface{}{"id": "upsert:1234", "num": 2}, []string{"id"}))
+
+		var v struct{ Num int }
+		assert.Nil(t, repo.One(context.TODO(), spec("SELECT num FROM tests WHERE id = 'upsert:1234'"), &v))
+		assert.Equal(t, 2, v.Num)
+	})
+
+	t.Run("conflict columns only", func(t *testing.T) {
+		assert.Nil(t, repo.Upsert(context.TODO(), "tests", map[string]interface{}{"id": "upsert:1234"}, []string{"id"}))
+	})
+}
+
+func TestRepository_ExecRaw(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	repo := db.Repository()
+	t.Run("bad sql", func(t *testing.T) {
+		assert.NotNil(t, repo.ExecRaw(context.TODO(), "bad sql"))
+	})
+
+	t.Run("unique", func(t *testing.T) {
+		_ = repo.ExecRaw(context.TODO(), "INSERT INTO tests (id) VALUES (?)", "raw:unique")
+		err := repo.ExecRaw(context.TODO(), "INSERT INTO tests (id) VALUES (?)", "raw:unique")
+		assert.True(t, trail.IsConflict(err))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, repo.ExecRaw(context.TODO(), "INSERT INTO tests (id, name) VALUES (?, 'what?')", "raw:1234"))
+	})
+}
+
+func TestRepository_Remove(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	repo := db.Repository()
+	_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "remove:1234"})
+	t.Run("bad sql", func(t *testing.T) {
+		assert.NotNil(t, repo.Remove(context.TODO(), "", spec("")))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, repo.Remove(context.TODO(), "tests", spec("id = 'remove:1234'")))
+	})
+
+	t.Run("returning", func(t *testing.T) {
+		_ = repo.Add(context.TODO(), "tests", map[string]interface{}{"id": "remove:returning", "name": "foo"})
+
+		var names []string
+		assert.Nil(t, repo.Remove(context.TODO(), "tests", spec("id = 'remove:returning'"), provider.WithReturning(&names, "name")))
+		assert.Equal(t, []string{"foo"}, names)
+	})
+}
+
+type spec string
+
+func (s spec) Id() interface{} {
+	return string(s)
+}
+
+func (s spec) ToSql() (string, []interface{}, error) {
+	if s == "" {
+		return "", nil, trail.NewError("bad SQL statement")
+	}
+
+	return string(s), nil, nil
+}
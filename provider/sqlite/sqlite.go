@@ -0,0 +1,214 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/pghq/go-tea/trail"
+	_ "modernc.org/sqlite"
+
+	"github.com/pghq/go-store/internal/migration"
+	"github.com/pghq/go-store/provider"
+)
+
+// Provider to sqlite database
+type Provider struct {
+	db       *sql.DB
+	migrator migration.Migrator
+}
+
+func (p Provider) Repository() provider.Repository {
+	return repository{db: p.db}
+}
+
+// IsLocal sqlite databases are always local
+func (p Provider) IsLocal() bool {
+	return true
+}
+
+// PendingMigrations gets the sql of migrations not yet applied
+func (p Provider) PendingMigrations(_ context.Context) ([]string, error) {
+	return p.migrator.Pending()
+}
+
+// SchemaDiff gets the statements of migrations not yet applied which the schema does not already reflect
+func (p Provider) SchemaDiff(ctx context.Context) ([]string, error) {
+	return p.migrator.Diff(ctx)
+}
+
+// MigrationStatus gets the status of each migration
+func (p Provider) MigrationStatus(_ context.Context) ([]provider.MigrationVersion, error) {
+	return p.migrator.Status()
+}
+
+// MigrateTo migrates up or down to the version
+// sqlite databases are always local, so down migrations are allowed
+func (p Provider) MigrateTo(ctx context.Context, version int64) error {
+	return p.migrator.MigrateTo(ctx, version, true)
+}
+
+func (p Provider) Begin(ctx context.Context, _ ...provider.TxOption) (provider.UnitOfWork, error) {
+	// sqlite has no read-only transaction mode, so tx options are ignored
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	return unitOfWork{tx: tx}, nil
+}
+
+// New creates a new sqlite database provider
+func New(dsn string, migrations fs.FS, opts ...Option) (*Provider, error) {
+	// connections are never recycled by default as closing the last
+	// connection to an in-memory database discards its contents
+	conf := ProviderConfig{
+		MaxConns:       100,
+		SQLOpen:        sql.Open,
+		ConnectTimeout: 30 * time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	db, err := conf.SQLOpen("sqlite", dsn)
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	db.SetMaxOpenConns(int(conf.MaxConns))
+	db.SetConnMaxLifetime(conf.MaxConnLifetime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectTimeout)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	migrator := migration.New(db, "sqlite3", migrations, conf.MigrationHook)
+	if conf.MigrationTable != "" {
+		migrator = migrator.WithTable(conf.MigrationTable)
+	}
+
+	if !conf.MigrationDryRun {
+		if err := migrator.Apply(context.Background()); err != nil {
+			return nil, trail.Stacktrace(err)
+		}
+	}
+
+	p := Provider{db: db, migrator: migrator}
+	return &p, nil
+}
+
+// ProviderConfig custom options for sqlite configuration
+type ProviderConfig struct {
+	MaxConns        int32
+	MaxConnLifetime time.Duration
+	ConnectTimeout  time.Duration
+	SQLOpen         func(driverName, dsn string) (*sql.DB, error)
+	MigrationDryRun bool
+	MigrationHook   provider.MigrationHook
+	MigrationTable  string
+}
+
+// Option A sqlite provider option
+type Option func(conf *ProviderConfig)
+
+// WithMaxConns configure sqlite with custom max connections
+func WithMaxConns(n int32) Option {
+	return func(conf *ProviderConfig) {
+		conf.MaxConns = n
+	}
+}
+
+// WithMaxConnLifetime configure sqlite with custom max connection lifetime
+func WithMaxConnLifetime(d time.Duration) Option {
+	return func(conf *ProviderConfig) {
+		conf.MaxConnLifetime = d
+	}
+}
+
+// WithConnectTimeout configure sqlite with custom connect timeout
+func WithConnectTimeout(d time.Duration) Option {
+	return func(conf *ProviderConfig) {
+		conf.ConnectTimeout = d
+	}
+}
+
+// WithSQLOpenFunc configure sqlite to open databases with a custom sql.Open (e.g., a tracing wrapper such as otelsql.Open)
+func WithSQLOpenFunc(fn func(driverName, dsn string) (*sql.DB, error)) Option {
+	return func(conf *ProviderConfig) {
+		if fn != nil {
+			conf.SQLOpen = fn
+		}
+	}
+}
+
+// WithMigrationDryRun configure sqlite to not apply migrations (e.g., to preview pending migrations)
+func WithMigrationDryRun() Option {
+	return func(conf *ProviderConfig) {
+		conf.MigrationDryRun = true
+	}
+}
+
+// WithMigrationTable configure sqlite to record applied migrations in the table
+func WithMigrationTable(name string) Option {
+	return func(conf *ProviderConfig) {
+		conf.MigrationTable = name
+	}
+}
+
+// WithMigrationHook configure sqlite to call the hook around each migration
+func WithMigrationHook(hook provider.MigrationHook) Option {
+	return func(conf *ProviderConfig) {
+		conf.MigrationHook = hook
+	}
+}
+
+type unitOfWork struct {
+	tx *sql.Tx
+}
+
+func (u unitOfWork) Commit(_ context.Context) error {
+	return u.tx.Commit()
+}
+
+func (u unitOfWork) Rollback(_ context.Context) {
+	_ = u.tx.Rollback()
+}
+
+func (u unitOfWork) Repository() provider.Repository {
+	return repository{db: u.tx}
+}
+
+func (u unitOfWork) Savepoint(ctx context.Context, name string) (provider.UnitOfWork, error) {
+	if _, err := u.tx.ExecContext(ctx, "SAVEPOINT "+quote(name)); err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	return savepoint{unitOfWork: u, name: name}, nil
+}
+
+// savepoint a unit of work nested within a transaction
+type savepoint struct {
+	unitOfWork
+	name string
+}
+
+func (s savepoint) Commit(ctx context.Context) error {
+	_, err := s.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+quote(s.name))
+	return trail.Stacktrace(err)
+}
+
+func (s savepoint) Rollback(ctx context.Context) {
+	_, _ = s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+quote(s.name))
+}
+
+// quote an identifier
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
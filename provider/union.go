@@ -0,0 +1,52 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pghq/go-tea/trail"
+)
+
+var _ Spec = union{}
+
+// union a query combining the rows of a query run against each of several tables
+type union struct {
+	query  *Builder
+	tables []string
+}
+
+// UnionAll gets a query combining the rows of the query on each of the tables with UNION ALL (e.g., of tables sharing their columns)
+// the query is built once for each table in place of its own, so its filters, sorting and limit apply to each table separately
+func UnionAll(query *Builder, tables ...string) Spec {
+	return union{query: query, tables: tables}
+}
+
+func (u union) Id() interface{} {
+	stmt, args, err := u.ToSql()
+	if err != nil {
+		return nil
+	}
+
+	return fmt.Sprintf("%s %v", stmt, args)
+}
+
+func (u union) ToSql() (string, []interface{}, error) {
+	if len(u.tables) == 0 {
+		return "", nil, trail.NewError("at least one table is required")
+	}
+
+	var stmts []string
+	var args []interface{}
+	for _, table := range u.tables {
+		c := *u.query
+		stmt, tableArgs, err := c.From(table).Build()
+		if err != nil {
+			return "", nil, trail.Stacktrace(err)
+		}
+
+		stmts = append(stmts, fmt.Sprintf("(%s)", stmt))
+		args = append(args, tableArgs...)
+	}
+
+	return strings.Join(stmts, " UNION ALL "), args, nil
+}
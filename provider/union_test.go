@@ -0,0 +1,36 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnionAll(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no tables", func(t *testing.T) {
+		_, _, err := UnionAll(NewBuilder().Select("*")).ToSql()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("bad query", func(t *testing.T) {
+		spec := UnionAll(NewBuilder().Select("*").WhereIn("id", "foo"), "tests")
+		_, _, err := spec.ToSql()
+		assert.NotNil(t, err)
+		assert.Nil(t, spec.Id())
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		query := NewBuilder().Select("*").From("tests").Where("name = ?", "foo").OrderBy("id", false).Limit(10)
+		spec := UnionAll(query, "tests", "archived_tests")
+		stmt, args, err := spec.ToSql()
+		assert.Nil(t, err)
+		assert.Equal(t, "(SELECT * FROM tests WHERE name = ? ORDER BY id LIMIT 10) UNION ALL (SELECT * FROM archived_tests WHERE name = ? ORDER BY id LIMIT 10)", stmt)
+		assert.Equal(t, []interface{}{"foo", "foo"}, args)
+		assert.NotNil(t, spec.Id())
+
+		stmt, _, _ = query.Build()
+		assert.Equal(t, "SELECT * FROM tests WHERE name = ? ORDER BY id LIMIT 10", stmt)
+	})
+}
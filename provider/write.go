@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/pghq/go-tea/trail"
+)
+
+// ErrVersionConflict is returned for versioned writes when the version no longer matches
+var ErrVersionConflict = trail.NewErrorConflict("the item was modified by another request")
+
+// WriteConfig a configuration for write ops
+type WriteConfig struct {
+	VersionColumn string
+	Columns       []string
+	Excluded      []string
+	Returning     []string
+	ReturningDest interface{}
+	RowsAffected  *int64
+}
+
+// WriteOption a configuration option for write ops
+type WriteOption func(conf *WriteConfig)
+
+// WithVersion use optimistic locking on the version column
+func WithVersion(col string) WriteOption {
+	return func(conf *WriteConfig) {
+		conf.VersionColumn = col
+	}
+}
+
+// WithColumns only write the columns (the version column is always written)
+func WithColumns(cols ...string) WriteOption {
+	return func(conf *WriteConfig) {
+		conf.Columns = cols
+	}
+}
+
+// WithExcludeColumns never write the columns (the version column is always written)
+func WithExcludeColumns(cols ...string) WriteOption {
+	return func(conf *WriteConfig) {
+		conf.Excluded = cols
+	}
+}
+
+// Filter removes values for columns not being written
+func (c WriteConfig) Filter(data map[string]interface{}) map[string]interface{} {
+	if len(c.Columns) == 0 && len(c.Excluded) == 0 {
+		return data
+	}
+
+	filtered := make(map[string]interface{})
+	for col, v := range data {
+		if col == c.VersionColumn || (len(c.Columns) == 0 || contains(c.Columns, col)) && !contains(c.Excluded, col) {
+			filtered[col] = v
+		}
+	}
+
+	return filtered
+}
+
+// contains checks if the column is one of the columns
+func contains(cols []string, col string) bool {
+	for _, c := range cols {
+		if c == col {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WithReturning decode the columns of written values into v
+func WithReturning(v interface{}, cols ...string) WriteOption {
+	return func(conf *WriteConfig) {
+		conf.ReturningDest = v
+		conf.Returning = cols
+	}
+}
+
+// WithRowsAffected record the number of rows affected by the write in n
+func WithRowsAffected(n *int64) WriteOption {
+	return func(conf *WriteConfig) {
+		conf.RowsAffected = n
+	}
+}
+
+// Affected records the number of rows affected by the write, if requested
+func (c WriteConfig) Affected(n int64) {
+	if c.RowsAffected != nil {
+		*c.RowsAffected = n
+	}
+}
+
+// Many checks if the RETURNING columns are decoded into a slice (e.g., for writes matching many rows)
+func (c WriteConfig) Many() bool {
+	rv := reflect.ValueOf(c.ReturningDest)
+	return rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Slice
+}
+
+// Suffix gets the RETURNING clause for the write, if any
+func (c WriteConfig) Suffix() string {
+	if len(c.Returning) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("RETURNING %s", strings.Join(c.Returning, ", "))
+}
+
+// Versioned matches the spec against the version in the data and increments it
+func (c WriteConfig) Versioned(spec Spec, data map[string]interface{}) (squirrel.Sqlizer, error) {
+	if c.VersionColumn == "" {
+		return spec, nil
+	}
+
+	version, present := data[c.VersionColumn]
+	if !present {
+		return nil, trail.NewErrorf("version column %s is missing", c.VersionColumn)
+	}
+
+	data[c.VersionColumn] = squirrel.Expr(fmt.Sprintf("%s + 1", c.VersionColumn))
+	return squirrel.And{spec, squirrel.Eq{c.VersionColumn: version}}, nil
+}
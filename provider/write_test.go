@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteConfig_Versioned(t *testing.T) {
+	t.Parallel()
+
+	t.Run("not versioned", func(t *testing.T) {
+		where, err := WriteConfig{}.Versioned(NewSpec("", squirrel.Expr("id = ?", "foo")), map[string]interface{}{"id": "foo"})
+		assert.Nil(t, err)
+		stmt, _, _ := where.ToSql()
+		assert.Equal(t, "id = ?", stmt)
+	})
+
+	t.Run("missing version", func(t *testing.T) {
+		conf := WriteConfig{}
+		WithVersion("version")(&conf)
+		_, err := conf.Versioned(NewSpec("", squirrel.Expr("id = ?", "foo")), map[string]interface{}{"id": "foo"})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		conf := WriteConfig{}
+		WithVersion("version")(&conf)
+		data := map[string]interface{}{"id": "foo", "version": 1}
+		where, err := conf.Versioned(NewSpec("", squirrel.Expr("id = ?", "foo")), data)
+		assert.Nil(t, err)
+		stmt, args, _ := where.ToSql()
+		assert.Equal(t, "(id = ? AND version = ?)", stmt)
+		assert.Equal(t, []interface{}{"foo", 1}, args)
+		assert.Equal(t, squirrel.Expr("version + 1"), data["version"])
+	})
+}
+
+func TestWriteConfig_Filter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("all columns", func(t *testing.T) {
+		data := map[string]interface{}{"id": "foo", "name": "bar"}
+		assert.Equal(t, data, WriteConfig{}.Filter(data))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		conf := WriteConfig{}
+		WithColumns("name", "missing")(&conf)
+		WithVersion("version")(&conf)
+		data := map[string]interface{}{"id": "foo", "name": "bar", "version": 1}
+		assert.Equal(t, map[string]interface{}{"name": "bar", "version": 1}, conf.Filter(data))
+	})
+
+	t.Run("excluded columns", func(t *testing.T) {
+		data := map[string]interface{}{"id": "foo", "name": "bar", "num": 1, "version": 1}
+		conf := WriteConfig{}
+		WithVersion("version")(&conf)
+		WithExcludeColumns("num", "version")(&conf)
+		assert.Equal(t, map[string]interface{}{"id": "foo", "name": "bar", "version": 1}, conf.Filter(data))
+
+		WithColumns("id", "name")(&conf)
+		WithExcludeColumns("name")(&conf)
+		assert.Equal(t, map[string]interface{}{"id": "foo", "version": 1}, conf.Filter(data))
+	})
+}
+
+func TestWriteConfig_Suffix(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no returning", func(t *testing.T) {
+		assert.Equal(t, "", WriteConfig{}.Suffix())
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		var id string
+		conf := WriteConfig{}
+		WithReturning(&id, "id", "name")(&conf)
+		assert.Equal(t, "RETURNING id, name", conf.Suffix())
+		assert.Equal(t, &id, conf.ReturningDest)
+	})
+}
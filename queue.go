@@ -0,0 +1,177 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/pghq/go-tea/trail"
+
+	"github.com/pghq/go-store/provider"
+)
+
+// ErrNoJobs is returned when dequeuing from a queue without jobs ready to run
+var ErrNoJobs = trail.NewErrorNotFound("no jobs are ready to run")
+
+// Queue a job queue backed by a table (pg only)
+// the table has the columns id text, payload jsonb, attempts int and run_at timestamptz (defaulting to now())
+// jobs failing too many times are moved to the dead letter table (e.g., dead_letter_jobs), with the columns id, payload and attempts
+type Queue struct {
+	store      *Store
+	table      string
+	deadLetter string
+	visibility time.Duration
+}
+
+// Job a dequeued job
+type Job struct {
+	Id       string `db:"id"`
+	Payload  []byte `db:"payload"`
+	Attempts int    `db:"attempts"`
+}
+
+// Decode decodes the json payload of the job
+func (j Job) Decode(v interface{}) error {
+	return trail.Stacktrace(json.Unmarshal(j.Payload, v))
+}
+
+// NewQueue creates a queue backed by the table
+// jobs not acknowledged within the visibility timeout of being dequeued are dequeued again
+func NewQueue(s *Store, table string, visibility time.Duration) *Queue {
+	return &Queue{store: s, table: table, deadLetter: "dead_letter_" + table, visibility: visibility}
+}
+
+// Enqueue adds a job with the json encoded payload, ready to run
+func (q Queue) Enqueue(ctx context.Context, payload interface{}) (string, error) {
+	span := trail.StartSpan(ctx, "Queue.Enqueue")
+	defer span.Finish()
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", trail.Stacktrace(err)
+	}
+
+	id := uuid.NewString()
+	if err := q.store.Add(ctx, q.table, map[string]interface{}{"id": id, "payload": string(data)}); err != nil {
+		return "", trail.Stacktrace(err)
+	}
+
+	return id, nil
+}
+
+// Dequeue gets the job ready to run the longest, hiding it from other consumers for the visibility timeout
+// jobs locked by concurrent dequeues are skipped rather than waited for
+func (q Queue) Dequeue(ctx context.Context) (*Job, error) {
+	span := trail.StartSpan(ctx, "Queue.Dequeue")
+	defer span.Finish()
+
+	var job Job
+	err := q.store.Do(ctx, func(tx Txn) error {
+		query := q.job(q.table).
+			Where("run_at <= now()").
+			OrderBy("run_at", false).
+			Limit(1).
+			SkipLocked()
+
+		if err := tx.OneForUpdate(query, &job); err != nil {
+			return trail.Stacktrace(err)
+		}
+
+		job.Attempts++
+		return tx.Edit(q.table, provider.NewSpec(nil, squirrel.Eq{"id": job.Id}), map[string]interface{}{
+			"attempts": job.Attempts,
+			"run_at":   fromNow(q.visibility),
+		})
+	})
+
+	if trail.IsNotFound(err) {
+		return nil, trail.Stacktrace(ErrNoJobs)
+	}
+
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	return &job, nil
+}
+
+// Ack acknowledges the job ran, removing it from the queue
+func (q Queue) Ack(ctx context.Context, id string) error {
+	span := trail.StartSpan(ctx, "Queue.Ack")
+	defer span.Finish()
+
+	return q.store.Remove(ctx, q.table, provider.NewSpec(nil, squirrel.Eq{"id": id}))
+}
+
+// Nack returns the job to the queue, ready to run again after the delay
+// jobs dequeued at least the max retries times are moved to the dead letter table instead
+func (q Queue) Nack(ctx context.Context, id string, delay time.Duration, maxRetries int) error {
+	span := trail.StartSpan(ctx, "Queue.Nack")
+	defer span.Finish()
+
+	return q.store.Do(ctx, func(tx Txn) error {
+		var job Job
+		if err := tx.OneForUpdate(q.job(q.table).Where("id = ?", id), &job); err != nil {
+			return trail.Stacktrace(err)
+		}
+
+		if job.Attempts >= maxRetries {
+			return q.move(tx, q.table, q.deadLetter, id, "attempts")
+		}
+
+		return tx.Edit(q.table, provider.NewSpec(nil, squirrel.Eq{"id": id}), map[string]interface{}{"run_at": fromNow(delay)})
+	})
+}
+
+// DrainDeadLetter calls the handler for each job of the dead letter table, returning those it handles to the queue
+// jobs are returned ready to run and without attempts, draining stops at the first error of the handler
+func (q Queue) DrainDeadLetter(ctx context.Context, handler func(job *Job) error) (int, error) {
+	span := trail.StartSpan(ctx, "Queue.DrainDeadLetter")
+	defer span.Finish()
+
+	var n int
+	for {
+		err := q.store.Do(ctx, func(tx Txn) error {
+			var job Job
+			if err := tx.OneForUpdate(q.job(q.deadLetter).Limit(1).SkipLocked(), &job); err != nil {
+				return trail.Stacktrace(err)
+			}
+
+			if err := handler(&job); err != nil {
+				return trail.Stacktrace(err)
+			}
+
+			return q.move(tx, q.deadLetter, q.table, job.Id, "0")
+		})
+
+		if trail.IsNotFound(err) {
+			return n, nil
+		}
+
+		if err != nil {
+			return n, trail.Stacktrace(err)
+		}
+
+		n++
+	}
+}
+
+// job gets a query of the jobs of the table
+func (q Queue) job(table string) *provider.Builder {
+	return provider.NewBuilder().Select("id", "payload::text AS payload", "attempts").From(table)
+}
+
+// move moves the job between the queue and dead letter tables, with the attempts expression
+func (q Queue) move(tx Txn, from, to, id, attempts string) error {
+	stmt := fmt.Sprintf("WITH moved AS (DELETE FROM %s WHERE id = $1 RETURNING id, payload, attempts) "+
+		"INSERT INTO %s (id, payload, attempts) SELECT id, payload, %s FROM moved", from, to, attempts)
+	return tx.ExecRaw(stmt, id)
+}
+
+// fromNow gets the database time after the duration
+func fromNow(d time.Duration) squirrel.Sqlizer {
+	return squirrel.Expr("now() + make_interval(secs => ?)", d.Seconds())
+}
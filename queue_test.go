@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueue(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	table := func(name string) string {
+		assert.Nil(t, store.ExecRaw(context.TODO(), fmt.Sprintf("CREATE TABLE %s (id text primary key, payload jsonb not null, attempts int not null default 0, run_at timestamptz not null default now())", name)))
+		assert.Nil(t, store.ExecRaw(context.TODO(), fmt.Sprintf("CREATE TABLE dead_letter_%s (id text primary key, payload jsonb not null, attempts int not null)", name)))
+		return name
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		q := NewQueue(store, table("jobs_empty"), time.Minute)
+		_, err := q.Dequeue(context.TODO())
+		assert.True(t, trail.IsError(err, ErrNoJobs))
+	})
+
+	t.Run("bad payload", func(t *testing.T) {
+		q := NewQueue(store, table("jobs_bad"), time.Minute)
+		_, err := q.Enqueue(context.TODO(), func() {})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("visibility timeout", func(t *testing.T) {
+		q := NewQueue(store, table("jobs_visibility"), 50*time.Millisecond)
+		id, err := q.Enqueue(context.TODO(), map[string]interface{}{"n": 1})
+		assert.Nil(t, err)
+
+		job, err := q.Dequeue(context.TODO())
+		assert.Nil(t, err)
+		assert.Equal(t, id, job.Id)
+		assert.Equal(t, 1, job.Attempts)
+
+		_, err = q.Dequeue(context.TODO())
+		assert.True(t, trail.IsError(err, ErrNoJobs))
+
+		time.Sleep(100 * time.Millisecond)
+		job, err = q.Dequeue(context.TODO())
+		assert.Nil(t, err)
+		assert.Equal(t, id, job.Id)
+		assert.Equal(t, 2, job.Attempts)
+
+		var payload struct{ N int }
+		assert.Nil(t, job.Decode(&payload))
+		assert.Equal(t, 1, payload.N)
+	})
+
+	t.Run("nack", func(t *testing.T) {
+		q := NewQueue(store, table("jobs_nack"), time.Minute)
+		id, err := q.Enqueue(context.TODO(), "payload")
+		assert.Nil(t, err)
+
+		job, err := q.Dequeue(context.TODO())
+		assert.Nil(t, err)
+		assert.Nil(t, q.Nack(context.TODO(), job.Id, 0, 3))
+
+		job, err = q.Dequeue(context.TODO())
+		assert.Nil(t, err)
+		assert.Equal(t, id, job.Id)
+
+		assert.Nil(t, q.Nack(context.TODO(), job.Id, time.Minute, 3))
+		_, err = q.Dequeue(context.TODO())
+		assert.True(t, trail.IsError(err, ErrNoJobs))
+	})
+
+	t.Run("dead letter", func(t *testing.T) {
+		q := NewQueue(store, table("jobs_dead"), time.Minute)
+		count := func(table string) int {
+			var n int
+			assert.Nil(t, store.One(context.TODO(), spec("SELECT count(*) FROM "+table), &n))
+			return n
+		}
+
+		assert.NotNil(t, q.Nack(context.TODO(), "missing", 0, 2))
+
+		id, err := q.Enqueue(context.TODO(), "payload")
+		assert.Nil(t, err)
+
+		for i := 0; i < 2; i++ {
+			job, err := q.Dequeue(context.TODO())
+			assert.Nil(t, err)
+			assert.Nil(t, q.Nack(context.TODO(), job.Id, 0, 2))
+		}
+
+		assert.Equal(t, 0, count("jobs_dead"))
+		assert.Equal(t, 1, count("dead_letter_jobs_dead"))
+
+		_, err = q.Dequeue(context.TODO())
+		assert.True(t, trail.IsError(err, ErrNoJobs))
+
+		n, err := q.DrainDeadLetter(context.TODO(), func(job *Job) error {
+			return trail.NewError("an error has occurred")
+		})
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, n)
+		assert.Equal(t, 1, count("dead_letter_jobs_dead"))
+
+		var drained []*Job
+		n, err = q.DrainDeadLetter(context.TODO(), func(job *Job) error {
+			drained = append(drained, job)
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, n)
+		assert.Len(t, drained, 1)
+		assert.Equal(t, id, drained[0].Id)
+		assert.Equal(t, 2, drained[0].Attempts)
+		assert.Equal(t, 0, count("dead_letter_jobs_dead"))
+
+		job, err := q.Dequeue(context.TODO())
+		assert.Nil(t, err)
+		assert.Equal(t, id, job.Id)
+		assert.Equal(t, 1, job.Attempts)
+	})
+
+	t.Run("concurrent workers", func(t *testing.T) {
+		q := NewQueue(store, table("jobs_concurrent"), time.Minute)
+		for i := 0; i < 100; i++ {
+			_, err := q.Enqueue(context.TODO(), i)
+			assert.Nil(t, err)
+		}
+
+		var mu sync.Mutex
+		processed := make(map[int]int)
+		var wg sync.WaitGroup
+		for w := 0; w < 5; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					job, err := q.Dequeue(context.TODO())
+					if trail.IsError(err, ErrNoJobs) {
+						return
+					}
+
+					if !assert.Nil(t, err) {
+						return
+					}
+
+					var n int
+					assert.Nil(t, job.Decode(&n))
+					mu.Lock()
+					processed[n]++
+					mu.Unlock()
+
+					assert.Nil(t, q.Ack(context.TODO(), job.Id))
+				}
+			}()
+		}
+
+		wg.Wait()
+		assert.Len(t, processed, 100)
+		for n, count := range processed {
+			assert.Equal(t, 1, count, "job %d", n)
+		}
+	})
+}
@@ -0,0 +1,194 @@
+package store
+
+import (
+	"context"
+
+	"github.com/pghq/go-tea/trail"
+
+	"github.com/pghq/go-store/provider"
+)
+
+// rlsUserKey the context key of the row level security user
+type rlsUserKey struct{}
+
+// rlsSetting the setting holding the row level security user within transactions
+const rlsSetting = "app.current_user_id"
+
+// WithRLSUser attaches the user to the context for row level security policies (pg only)
+// transactions begun with the context set app.current_user_id for their duration, for policies checking current_setting('app.current_user_id')
+// queries outside of transactions run in a transaction of their own setting it
+func WithRLSUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, rlsUserKey{}, userID)
+}
+
+// RLSUser gets the row level security user attached to the context, if any
+func RLSUser(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(rlsUserKey{}).(string)
+	return userID, ok
+}
+
+// setRLSUser sets the row level security user of the unit of work, if the context has one
+func setRLSUser(ctx context.Context, uow provider.UnitOfWork) error {
+	userID, ok := RLSUser(ctx)
+	if !ok {
+		return nil
+	}
+
+	// set_config is used as SET LOCAL does not take parameters
+	err := uow.Repository().ExecRaw(ctx, "SELECT set_config($1, $2, true)", rlsSetting, userID)
+	return trail.Stacktrace(err)
+}
+
+// rlsRepository a repository running each query in a transaction setting the row level security user
+type rlsRepository struct {
+	db provider.Provider
+}
+
+// do runs the callback with the repository of a transaction setting the user, committed if the callback succeeds
+func (r rlsRepository) do(ctx context.Context, fn func(repo provider.Repository) error) error {
+	uow, err := r.begin(ctx)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	if err := fn(uow.Repository()); err != nil {
+		uow.Rollback(ctx)
+		return err
+	}
+
+	return trail.Stacktrace(uow.Commit(ctx))
+}
+
+// begin a transaction setting the user
+func (r rlsRepository) begin(ctx context.Context) (provider.UnitOfWork, error) {
+	uow, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	if err := setRLSUser(ctx, uow); err != nil {
+		uow.Rollback(ctx)
+		return nil, trail.Stacktrace(err)
+	}
+
+	return uow, nil
+}
+
+func (r rlsRepository) One(ctx context.Context, spec provider.Spec, v interface{}) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		return repo.One(ctx, spec, v)
+	})
+}
+
+func (r rlsRepository) All(ctx context.Context, spec provider.Spec, v interface{}) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		return repo.All(ctx, spec, v)
+	})
+}
+
+// Scan the transaction includes iterating over the rows, and is committed when they are closed
+func (r rlsRepository) Scan(ctx context.Context, spec provider.Spec) (provider.Rows, error) {
+	uow, err := r.begin(ctx)
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	rows, err := uow.Repository().Scan(ctx, spec)
+	if err != nil {
+		uow.Rollback(ctx)
+		return nil, err
+	}
+
+	return rlsRows{Rows: rows, ctx: ctx, uow: uow}, nil
+}
+
+func (r rlsRepository) Add(ctx context.Context, collection string, v interface{}, opts ...provider.WriteOption) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		return repo.Add(ctx, collection, v, opts...)
+	})
+}
+
+func (r rlsRepository) Edit(ctx context.Context, collection string, spec provider.Spec, v interface{}, opts ...provider.WriteOption) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		return repo.Edit(ctx, collection, spec, v, opts...)
+	})
+}
+
+func (r rlsRepository) Upsert(ctx context.Context, collection string, v interface{}, conflict []string) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		return repo.Upsert(ctx, collection, v, conflict)
+	})
+}
+
+func (r rlsRepository) Merge(ctx context.Context, collection string, v interface{}, match []string, insertDefaults map[string]interface{}, update []string) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		m, ok := repo.(provider.Merger)
+		if !ok {
+			return trail.NewErrorf("repository %T does not support merge", repo)
+		}
+
+		return m.Merge(ctx, collection, v, match, insertDefaults, update)
+	})
+}
+
+func (r rlsRepository) AddBatch(ctx context.Context, collection string, values []interface{}) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		b, ok := repo.(provider.BatchAdder)
+		if !ok {
+			return trail.NewErrorf("repository %T does not support batch adds", repo)
+		}
+
+		return b.AddBatch(ctx, collection, values)
+	})
+}
+
+func (r rlsRepository) Remove(ctx context.Context, collection string, spec provider.Spec, opts ...provider.WriteOption) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		return repo.Remove(ctx, collection, spec, opts...)
+	})
+}
+
+func (r rlsRepository) BatchQuery(ctx context.Context, query provider.BatchQuery) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		return repo.BatchQuery(ctx, query)
+	})
+}
+
+func (r rlsRepository) BatchExec(ctx context.Context, exec provider.BatchExec) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		return repo.BatchExec(ctx, exec)
+	})
+}
+
+func (r rlsRepository) CopyFrom(ctx context.Context, collection string, columns []string, rows [][]interface{}) (int64, error) {
+	var n int64
+	err := r.do(ctx, func(repo provider.Repository) error {
+		var err error
+		n, err = repo.CopyFrom(ctx, collection, columns, rows)
+		return err
+	})
+
+	return n, err
+}
+
+func (r rlsRepository) ExecRaw(ctx context.Context, stmt string, args ...interface{}) error {
+	return r.do(ctx, func(repo provider.Repository) error {
+		return repo.ExecRaw(ctx, stmt, args...)
+	})
+}
+
+// rlsRows rows committing the transaction setting the user when closed
+type rlsRows struct {
+	provider.Rows
+	ctx context.Context
+	uow provider.UnitOfWork
+}
+
+func (r rlsRows) Close() error {
+	if err := r.Rows.Close(); err != nil {
+		r.uow.Rollback(r.ctx)
+		return err
+	}
+
+	return trail.Stacktrace(r.uow.Commit(r.ctx))
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pghq/go-store/provider"
+)
+
+func TestWithRLSUser(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	// superusers bypass row level security, so the policy is checked through a store connected as another role
+	for _, stmt := range []string{
+		"CREATE TABLE rls_items (id text primary key, owner text)",
+		"ALTER TABLE rls_items ENABLE ROW LEVEL SECURITY",
+		"CREATE POLICY rls_items_owner ON rls_items USING (owner = current_setting('app.current_user_id', true))",
+		"CREATE ROLE rls_user LOGIN PASSWORD 'secret'",
+		"GRANT SELECT ON rls_items TO rls_user",
+		"INSERT INTO rls_items VALUES ('rls:1', 'alice'), ('rls:2', 'alice'), ('rls:3', 'bob')",
+	} {
+		assert.Nil(t, store.ExecRaw(context.TODO(), stmt))
+	}
+
+	s, err := New(WithDSN(strings.Replace(dsn, "postgres:secret", "rls_user:secret", 1)))
+	assert.Nil(t, err)
+
+	items := func(ctx context.Context) []string {
+		var ids []string
+		assert.Nil(t, s.Do(ctx, func(tx Txn) error {
+			return tx.All(provider.NewBuilder().Select("id").From("rls_items").OrderBy("id", false), &ids)
+		}))
+
+		return ids
+	}
+
+	t.Run("user", func(t *testing.T) {
+		userID, ok := RLSUser(WithRLSUser(context.TODO(), "alice"))
+		assert.True(t, ok)
+		assert.Equal(t, "alice", userID)
+
+		_, ok = RLSUser(context.TODO())
+		assert.False(t, ok)
+	})
+
+	t.Run("no user", func(t *testing.T) {
+		assert.Empty(t, items(context.TODO()))
+	})
+
+	t.Run("bad provider", func(t *testing.T) {
+		s, err := New(WithInMemory())
+		assert.Nil(t, err)
+		assert.NotNil(t, s.Do(WithRLSUser(context.TODO(), "alice"), func(tx Txn) error {
+			return nil
+		}))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Equal(t, []string{"rls:1", "rls:2"}, items(WithRLSUser(context.TODO(), "alice")))
+		assert.Equal(t, []string{"rls:3"}, items(WithRLSUser(context.TODO(), "bob")))
+		assert.Empty(t, items(WithRLSUser(context.TODO(), "carol")))
+	})
+
+	t.Run("outside of transactions", func(t *testing.T) {
+		query := provider.NewBuilder().Select("id").From("rls_items").OrderBy("id", false)
+		var ids []string
+		assert.Nil(t, s.All(WithRLSUser(context.TODO(), "alice"), query, &ids))
+		assert.Equal(t, []string{"rls:1", "rls:2"}, ids)
+
+		var id string
+		assert.Nil(t, s.One(WithRLSUser(context.TODO(), "bob"), query, &id))
+		assert.Equal(t, "rls:3", id)
+
+		n, err := s.Count(WithRLSUser(context.TODO(), "carol"), query)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), n)
+
+		rows, err := s.Scan(WithRLSUser(context.TODO(), "alice"), query)
+		assert.Nil(t, err)
+		ids = nil
+		for rows.Next() {
+			var row struct {
+				Id string `db:"id"`
+			}
+			assert.Nil(t, rows.Decode(&row))
+			ids = append(ids, row.Id)
+		}
+		assert.Nil(t, rows.Close())
+		assert.Equal(t, []string{"rls:1", "rls:2"}, ids)
+	})
+
+	t.Run("outside of transactions with a bad provider", func(t *testing.T) {
+		s, err := New(WithInMemory())
+		assert.Nil(t, err)
+		assert.NotNil(t, s.ExecRaw(WithRLSUser(context.TODO(), "alice"), "SELECT 1"))
+	})
+
+	t.Run("local to the transaction", func(t *testing.T) {
+		assert.Equal(t, []string{"rls:3"}, items(WithRLSUser(context.TODO(), "bob")))
+
+		var setting *string
+		assert.Nil(t, s.One(context.TODO(), spec("SELECT current_setting('app.current_user_id', true)"), &setting))
+		assert.True(t, setting == nil || *setting == "")
+	})
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+
+	"github.com/pghq/go-tea/trail"
+
+	"github.com/pghq/go-store/provider"
+)
+
+// ShardRouter routes keys (e.g., tenant ids) to the store of their shard
+type ShardRouter interface {
+	Shard(ctx context.Context, key interface{}) (*Store, error)
+	Shards() []*Store
+}
+
+// RangeShardRouter routes integer keys to shards by range
+// keys less than the first bound are routed to the first shard, less than the second to the second, and so on
+type RangeShardRouter struct {
+	bounds []int64
+	shards []*Store
+}
+
+// NewRangeShardRouter creates a router for ascending bounds, with one shard more than bounds for keys above the last
+func NewRangeShardRouter(bounds []int64, shards ...*Store) (*RangeShardRouter, error) {
+	if len(shards) != len(bounds)+1 {
+		return nil, trail.NewErrorf("%d bounds require %d shards, got %d", len(bounds), len(bounds)+1, len(shards))
+	}
+
+	if !sort.SliceIsSorted(bounds, func(i, j int) bool { return bounds[i] <= bounds[j] }) {
+		return nil, trail.NewError("bounds must be ascending")
+	}
+
+	return &RangeShardRouter{bounds: bounds, shards: shards}, nil
+}
+
+func (r RangeShardRouter) Shard(_ context.Context, key interface{}) (*Store, error) {
+	n, ok := shardInt(key)
+	if !ok {
+		return nil, trail.NewErrorBadRequest(fmt.Sprintf("key of type %T is not an integer", key))
+	}
+
+	i := sort.Search(len(r.bounds), func(i int) bool { return n < r.bounds[i] })
+	return r.shards[i], nil
+}
+
+func (r RangeShardRouter) Shards() []*Store {
+	return r.shards
+}
+
+// HashShardRouter routes keys to shards by hash
+// integer keys are routed by their value modulo the number of shards (e.g., even and odd keys for two shards)
+// all other keys by the FNV-1a hash of their string representation
+type HashShardRouter struct {
+	shards []*Store
+}
+
+// NewHashShardRouter creates a router for the shards
+func NewHashShardRouter(shards ...*Store) (*HashShardRouter, error) {
+	if len(shards) == 0 {
+		return nil, trail.NewError("at least one shard is required")
+	}
+
+	return &HashShardRouter{shards: shards}, nil
+}
+
+func (r HashShardRouter) Shard(_ context.Context, key interface{}) (*Store, error) {
+	var sum uint64
+	if n, ok := shardInt(key); ok {
+		sum = uint64(n)
+	} else {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(fmt.Sprint(key)))
+		sum = h.Sum64()
+	}
+
+	return r.shards[sum%uint64(len(r.shards))], nil
+}
+
+func (r HashShardRouter) Shards() []*Store {
+	return r.shards
+}
+
+// DoShard calls fn within a transaction of the shard the router routes the key to
+func DoShard(ctx context.Context, router ShardRouter, key interface{}, fn func(tx Txn) error, opts ...provider.TxOption) error {
+	span := trail.StartSpan(ctx, "Store.DoShard")
+	defer span.Finish()
+
+	s, err := router.Shard(ctx, key)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	return s.Do(ctx, fn, opts...)
+}
+
+// ScatterAll retrieves a listing of values from all shards concurrently, merged and sorted by the ORDER BY columns of the query
+// the first error cancels the queries of the other shards, and a context deadline (or WithQueryTimeout) bounds slow shards
+// limits apply to each shard rather than the merged listing
+func ScatterAll(ctx context.Context, router ShardRouter, query *provider.Builder, v interface{}, opts ...QueryOption) error {
+	span := trail.StartSpan(ctx, "Store.ScatterAll")
+	defer span.Finish()
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return trail.NewErrorf("values of type %T are not a pointer to a slice", v)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	shards := router.Shards()
+	results := make([]reflect.Value, len(shards))
+	errs := make(chan error, len(shards))
+	for i, s := range shards {
+		results[i] = reflect.New(rv.Elem().Type())
+		go func(s *Store, v interface{}) {
+			errs <- s.All(ctx, query, v, opts...)
+		}(s, results[i].Interface())
+	}
+
+	for range shards {
+		if err := <-errs; err != nil {
+			return trail.Stacktrace(err)
+		}
+	}
+
+	merged := reflect.MakeSlice(rv.Elem().Type(), 0, 0)
+	for _, result := range results {
+		merged = reflect.AppendSlice(merged, result.Elem())
+	}
+
+	rv.Elem().Set(merged)
+	return query.Sort(v)
+}
+
+// shardInt gets the value of integer keys
+func shardInt(key interface{}) (int64, bool) {
+	rv := reflect.ValueOf(key)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pghq/go-store/provider"
+)
+
+func TestShardRouter(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	shard := func(name string) *Store {
+		s, err := New(WithDialect("sqlite"), WithDSN(fmt.Sprintf("file:%s?mode=memory&cache=shared", name)), WithMigration(fstest.MapFS{
+			"migrations/00001_tenants.sql": &fstest.MapFile{
+				Data: []byte("-- +goose Up\nCREATE TABLE tenants (id int primary key, name text);"),
+			},
+		}))
+		if err != nil {
+			panic(err)
+		}
+
+		return s
+	}
+
+	even, odd := shard("shard_even"), shard("shard_odd")
+	count := func(s *Store) int {
+		var n int
+		assert.Nil(t, s.One(context.TODO(), spec("SELECT count(*) FROM tenants"), &n))
+		return n
+	}
+
+	t.Run("bad routers", func(t *testing.T) {
+		_, err := NewHashShardRouter()
+		assert.NotNil(t, err)
+
+		_, err = NewRangeShardRouter([]int64{10}, even)
+		assert.NotNil(t, err)
+
+		_, err = NewRangeShardRouter([]int64{10, 10}, even, odd, even)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("bad key", func(t *testing.T) {
+		r, err := NewRangeShardRouter([]int64{10}, even, odd)
+		assert.Nil(t, err)
+
+		err = DoShard(context.TODO(), r, "tenant:1", func(tx Txn) error { return nil })
+		assert.True(t, trail.IsBadRequest(err))
+	})
+
+	t.Run("range", func(t *testing.T) {
+		r, err := NewRangeShardRouter([]int64{10, 20}, even, odd, even)
+		assert.Nil(t, err)
+
+		for key, expect := range map[int]*Store{-1: even, 9: even, 10: odd, 19: odd, 20: even} {
+			s, err := r.Shard(context.TODO(), key)
+			assert.Nil(t, err)
+			assert.Same(t, expect, s)
+		}
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		r, err := NewHashShardRouter(even, odd)
+		assert.Nil(t, err)
+
+		s, err := r.Shard(context.TODO(), "tenant:1")
+		assert.Nil(t, err)
+		assert.NotNil(t, s)
+
+		for i := 0; i < 10; i++ {
+			id := i
+			assert.Nil(t, DoShard(context.TODO(), r, id, func(tx Txn) error {
+				return tx.Add("tenants", map[string]interface{}{"id": id, "name": fmt.Sprintf("tenant:%d", id)})
+			}))
+		}
+
+		assert.Equal(t, 5, count(even))
+		assert.Equal(t, 5, count(odd))
+
+		var ids []int
+		assert.Nil(t, odd.All(context.TODO(), spec("SELECT id FROM tenants ORDER BY id"), &ids))
+		assert.Equal(t, []int{1, 3, 5, 7, 9}, ids)
+	})
+	t.Run("scatter", func(t *testing.T) {
+		r, err := NewHashShardRouter(even, odd)
+		assert.Nil(t, err)
+
+		query := func() *provider.Builder {
+			return provider.NewBuilder().Select("id", "name").From("tenants")
+		}
+
+		t.Run("bad values", func(t *testing.T) {
+			var v []int
+			assert.NotNil(t, ScatterAll(context.TODO(), r, query(), v))
+		})
+
+		t.Run("cancelled", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.TODO())
+			cancel()
+
+			var v []map[string]interface{}
+			assert.NotNil(t, ScatterAll(ctx, r, query(), &v))
+		})
+
+		t.Run("ok", func(t *testing.T) {
+			var v []struct {
+				Id   int    `db:"id"`
+				Name string `db:"name"`
+			}
+
+			assert.Nil(t, ScatterAll(context.TODO(), r, query().OrderBy("id", true), &v))
+			assert.Len(t, v, 10)
+			for i, tenant := range v {
+				assert.Equal(t, 9-i, tenant.Id)
+				assert.Equal(t, fmt.Sprintf("tenant:%d", 9-i), tenant.Name)
+			}
+		})
+	})
+}
@@ -14,25 +14,90 @@ package store
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"io/fs"
+	"math/rand"
+	"net/http"
 	"os"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/dgraph-io/ristretto"
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
 	"github.com/pghq/go-tea/trail"
 
+	"github.com/pghq/go-store/internal/encode"
+	"github.com/pghq/go-store/internal/migration"
 	"github.com/pghq/go-store/provider"
+	"github.com/pghq/go-store/provider/mysql"
 	"github.com/pghq/go-store/provider/pg"
+	"github.com/pghq/go-store/provider/sqlite"
 )
 
 type contextKey = struct{}
 
+var (
+	// ErrFullTableDelete is returned when removing values without a condition
+	ErrFullTableDelete = trail.NewErrorBadRequest("refusing to remove all values without a condition")
+
+	// ErrFullTableUpdate is returned when editing values without a condition
+	ErrFullTableUpdate = trail.NewErrorBadRequest("refusing to edit all values without a condition")
+
+	// ErrBuilderCondition is returned when editing or removing values with a query builder, which builds a full SELECT
+	// conditions are given as specs of a WHERE clause instead (e.g., provider.NewSpec(nil, squirrel.Eq{"id": id}))
+	ErrBuilderCondition = trail.NewErrorBadRequest("query builders can not be used as edit or remove conditions")
+
+	// ErrQueryPlanExceeded is returned when the planner's cost estimate for a query exceeds the budget
+	ErrQueryPlanExceeded = trail.NewErrorBadRequest("the query plan exceeds the cost budget")
+
+	// ErrTableNotFound is returned when inspecting a table that does not exist
+	ErrTableNotFound = trail.NewErrorNotFound("the requested table does not exist")
+
+	// ErrNotPermitted is returned when truncating tables of a non-local database without WithForceDelete
+	ErrNotPermitted = trail.NewErrorWithCode("refusing to truncate tables of a non-local database", http.StatusForbidden)
+
+	// ErrNoResults is returned when retrieving values by an empty list of ids, see NoResultsError
+	ErrNoResults = provider.ErrNoResults
+
+	// ErrNotFound is returned when retrieving a value that does not exist, or editing values matching none, see NotFoundError
+	ErrNotFound = provider.ErrNotFound
+
+	// ErrConflict is returned for writes violating a unique constraint, see ConflictError
+	ErrConflict = provider.ErrConflict
+
+	// ErrReplicationLag is returned by health checks when the lag of a replication slot exceeds WithMaxReplicationLag
+	ErrReplicationLag = trail.NewErrorWithCode("the replication lag exceeds the maximum", http.StatusServiceUnavailable)
+
+	// ErrUnknownEnumValue is returned for values of a registered enum without a label, or labels without a value
+	ErrUnknownEnumValue = pg.ErrUnknownEnumValue
+)
+
+type (
+	// NotFoundError the table and query of a not found error (e.g., trail.AsError(err, &nf) with nf *NotFoundError)
+	NotFoundError = provider.NotFoundError
+
+	// NoResultsError the table of a no results error
+	NoResultsError = provider.NoResultsError
+
+	// ConflictError the table and statement of a unique constraint violation
+	ConflictError = provider.ConflictError
+)
+
 // Store an abstraction over database persistence
 type Store struct {
-	db    provider.Provider
-	cache *ristretto.Cache
+	db      provider.Provider
+	cache   Cache
+	conf    Config
+	healthy *int32
+	hooks   *writeHooks
+	done    chan struct{}
+	closing *sync.Once
 }
 
 // Begin a transaction
@@ -43,10 +108,58 @@ func (s Store) Begin(ctx context.Context, opts ...provider.TxOption) (Txn, error
 }
 
 // Do execute callback in a transaction
+// if configured, the transaction is replayed on deadlocks and retried on retryable errors (deadlocks included), with backoff
 func (s Store) Do(ctx context.Context, fn func(tx Txn) error, opts ...provider.TxOption) error {
 	span := trail.StartSpan(ctx, "Store.Do")
 	defer span.Finish()
 
+	attempts, replays := 1, 0
+	if _, ok := ctx.Value(contextKey{}).(Txn); !ok {
+		if s.conf.RetryAttempts > 1 {
+			attempts = s.conf.RetryAttempts
+		}
+
+		replays = s.conf.DeadlockRetries
+	}
+
+	var err error
+	for attempt, replay := 0, 0; attempt < attempts; {
+		err = s.do(ctx, fn, opts...)
+		deadlock := errors.Is(err, pg.ErrDeadlock)
+		if !deadlock && !errors.Is(err, pg.ErrRetryable) {
+			return err
+		}
+
+		if deadlock && replay < replays {
+			replay++
+			span.Tags.Set("Store.Replays", fmt.Sprintf("%d", replay))
+			select {
+			case <-ctx.Done():
+				return trail.Stacktrace(ctx.Err())
+			case <-time.After(backoff(s.conf.RetryBackoff, replay)):
+			}
+
+			continue
+		}
+
+		attempt++
+		span.Tags.Set("Store.Attempts", fmt.Sprintf("%d", attempt))
+		if attempt == attempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return trail.Stacktrace(ctx.Err())
+		case <-time.After(backoff(s.conf.RetryBackoff, attempt)):
+		}
+	}
+
+	return err
+}
+
+// do execute callback in a single transaction
+func (s Store) do(ctx context.Context, fn func(tx Txn) error, opts ...provider.TxOption) error {
 	tx, err := s.Begin(ctx, opts...)
 	if err != nil {
 		return trail.Stacktrace(err)
@@ -60,6 +173,331 @@ func (s Store) Do(ctx context.Context, fn func(tx Txn) error, opts ...provider.T
 	return tx.commit()
 }
 
+// HealthCheck checks that a transaction can be run against the database
+// pools dial new connections after failures, so a failed check is retried once to reconnect
+func (s Store) HealthCheck(ctx context.Context) error {
+	span := trail.StartSpan(ctx, "Store.HealthCheck")
+	defer span.Finish()
+
+	err := s.healthCheck(ctx)
+	if err != nil && ctx.Err() == nil {
+		trail.Warnf("store: health check failed, reconnecting: %s", err)
+		err = s.healthCheck(ctx)
+	}
+
+	healthy := int32(1)
+	if err != nil {
+		healthy = 0
+	}
+
+	atomic.StoreInt32(s.healthy, healthy)
+	return trail.Stacktrace(err)
+}
+
+// IsHealthy checks if the last health check succeeded
+func (s Store) IsHealthy() bool {
+	return atomic.LoadInt32(s.healthy) == 1
+}
+
+// healthCheck runs a query within a transaction
+func (s Store) healthCheck(ctx context.Context) error {
+	uow, err := s.db.Begin(ctx)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	defer uow.Rollback(ctx)
+	var v int
+	if err := uow.Repository().One(ctx, provider.NewSpec(nil, squirrel.Expr("SELECT 1")), &v); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	if s.conf.MaxReplicationLag <= 0 {
+		return nil
+	}
+
+	lag, err := s.ReplicationLag(ctx)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	for slot, d := range lag {
+		if d > s.conf.MaxReplicationLag {
+			trail.Warnf("store: replication slot %s is %s behind", slot, d)
+			return trail.Stacktrace(ErrReplicationLag)
+		}
+	}
+
+	return nil
+}
+
+// ReplicationLag gets the replay lag of each replication slot by slot name (e.g., to monitor the staleness of read replicas)
+func (s Store) ReplicationLag(ctx context.Context) (map[string]time.Duration, error) {
+	span := trail.StartSpan(ctx, "Store.ReplicationLag")
+	defer span.Finish()
+
+	r, ok := s.db.(provider.Replicator)
+	if !ok {
+		return nil, trail.NewErrorf("provider %T does not support replication", s.db)
+	}
+
+	lag, err := r.ReplicationLag(ctx)
+	return lag, trail.Stacktrace(err)
+}
+
+// monitor runs health checks periodically until the store is closed
+func (s Store) monitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		_ = s.HealthCheck(ctx)
+		cancel()
+	}
+}
+
+// Close stops the background work of the store (periodic health checks and materialized view refreshes)
+func (s Store) Close() {
+	s.closing.Do(func() { close(s.done) })
+}
+
+// Invalidate removes cached query results for the keys (spec ids or names given with WithCacheKey)
+func (s Store) Invalidate(keys ...interface{}) {
+	s.cache.Delete(keys...)
+}
+
+// InvalidateAll removes all cached query results
+func (s Store) InvalidateAll() {
+	s.cache.Clear()
+}
+
+// Subscribe calls the handler with the payload of each notification sent on the channel
+// the subscription ends when the returned func is called or ctx is done
+func (s Store) Subscribe(ctx context.Context, channel string, handler func(payload string)) (func(), error) {
+	ps, ok := s.db.(provider.PubSub)
+	if !ok {
+		return nil, trail.NewErrorf("provider %T does not support notifications", s.db)
+	}
+
+	cancel, err := ps.Subscribe(ctx, channel, handler)
+	return cancel, trail.Stacktrace(err)
+}
+
+// Notify sends the payload to subscribers of the channel
+func (s Store) Notify(ctx context.Context, channel, payload string) error {
+	span := trail.StartSpan(ctx, "Store.Notify")
+	defer span.Finish()
+
+	ps, ok := s.db.(provider.PubSub)
+	if !ok {
+		return trail.NewErrorf("provider %T does not support notifications", s.db)
+	}
+
+	return ps.Notify(ctx, channel, payload)
+}
+
+// SessionAdvisoryLock waits for an advisory lock on the key held until SessionAdvisoryUnlock is called
+func (s Store) SessionAdvisoryLock(ctx context.Context, key int64) error {
+	l, ok := s.db.(provider.Locker)
+	if !ok {
+		return trail.NewErrorf("provider %T does not support advisory locks", s.db)
+	}
+
+	return l.SessionAdvisoryLock(ctx, key)
+}
+
+// SessionAdvisoryUnlock releases an advisory lock on the key acquired with SessionAdvisoryLock
+func (s Store) SessionAdvisoryUnlock(ctx context.Context, key int64) error {
+	l, ok := s.db.(provider.Locker)
+	if !ok {
+		return trail.NewErrorf("provider %T does not support advisory locks", s.db)
+	}
+
+	return l.SessionAdvisoryUnlock(ctx, key)
+}
+
+// CreateIndexConcurrently creates the index on the expression of the table, if it does not exist, without locking out writes (pg only)
+// the index can not be created within a transaction, progress is reported to the callback, if any, while it is built
+func (s Store) CreateIndexConcurrently(ctx context.Context, schema, table, name, expression string, progress func(provider.IndexProgress)) error {
+	span := trail.StartSpan(ctx, "Store.CreateIndexConcurrently")
+	defer span.Finish()
+
+	if _, ok := ctx.Value(contextKey{}).(Txn); ok {
+		return trail.NewErrorBadRequest("indexes can not be created concurrently within a transaction")
+	}
+
+	i, ok := s.db.(provider.Indexer)
+	if !ok {
+		return trail.NewErrorf("provider %T does not support concurrent index creation", s.db)
+	}
+
+	return i.CreateIndexConcurrently(ctx, schema, table, name, expression, progress)
+}
+
+// CreatePartition creates a partition of the parent table, if it does not exist (pg only)
+// the bound is the partition bound spec (e.g., "FOR VALUES FROM ('2022-01-01') TO ('2022-02-01')"), see pg.PartitionedTable
+// partitions are created outside of any transaction, as creating them locks the parent table
+func (s Store) CreatePartition(ctx context.Context, parent, name, bound string) error {
+	span := trail.StartSpan(ctx, "Store.CreatePartition")
+	defer span.Finish()
+
+	if _, ok := ctx.Value(contextKey{}).(Txn); ok {
+		return trail.NewErrorBadRequest("partitions can not be created within a transaction")
+	}
+
+	p, ok := s.db.(provider.Partitioner)
+	if !ok {
+		return trail.NewErrorf("provider %T does not support partitions", s.db)
+	}
+
+	return p.CreatePartition(ctx, parent, name, bound)
+}
+
+// RefreshMaterializedView replaces the contents of the materialized view (pg only)
+// views are refreshed outside of any transaction, concurrently refreshing requires a unique index on the view
+func (s Store) RefreshMaterializedView(ctx context.Context, name string, concurrently bool) error {
+	span := trail.StartSpan(ctx, "Store.RefreshMaterializedView")
+	defer span.Finish()
+
+	if _, ok := ctx.Value(contextKey{}).(Txn); ok {
+		return trail.NewErrorBadRequest("materialized views can not be refreshed within a transaction")
+	}
+
+	r, ok := s.db.(provider.ViewRefresher)
+	if !ok {
+		return trail.NewErrorf("provider %T does not support materialized views", s.db)
+	}
+
+	return r.RefreshMaterializedView(ctx, name, concurrently)
+}
+
+// refresh refreshes the materialized view concurrently at the interval until the store is closed
+func (s Store) refresh(name string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if err := s.RefreshMaterializedView(ctx, name, true); err != nil {
+			trail.Warnf("store: refresh of materialized view %s failed: %s", name, err)
+		}
+
+		cancel()
+	}
+}
+
+// PendingMigrations gets the sql of migrations not yet applied (e.g., to preview changes before a deploy)
+func (s Store) PendingMigrations(ctx context.Context) ([]string, error) {
+	span := trail.StartSpan(ctx, "Store.PendingMigrations")
+	defer span.Finish()
+
+	return s.db.PendingMigrations(ctx)
+}
+
+// SchemaDiff gets the statements needed to bring the schema up to date with the migrations (e.g., for drift detection in CI)
+// unlike PendingMigrations, statements of pending migrations already reflected in the schema (e.g., tables created by hand) are left out
+func (s Store) SchemaDiff(ctx context.Context) ([]string, error) {
+	span := trail.StartSpan(ctx, "Store.SchemaDiff")
+	defer span.Finish()
+
+	return s.db.SchemaDiff(ctx)
+}
+
+// MigrationStatus gets the status of each migration (e.g., for health checks)
+func (s Store) MigrationStatus(ctx context.Context) ([]provider.MigrationVersion, error) {
+	span := trail.StartSpan(ctx, "Store.MigrationStatus")
+	defer span.Finish()
+
+	return s.db.MigrationStatus(ctx)
+}
+
+// MigrateTo migrates up or down to the version
+// migrating down non-local databases requires WithForceDownMigrations
+func (s Store) MigrateTo(ctx context.Context, version int64) error {
+	span := trail.StartSpan(ctx, "Store.MigrateTo")
+	defer span.Finish()
+
+	return s.db.MigrateTo(ctx, version)
+}
+
+// InspectTable gets the columns of a table from the information schema (pg and mysql)
+func (s Store) InspectTable(ctx context.Context, schema, table string) (*provider.TableInfo, error) {
+	span := trail.StartSpan(ctx, "Store.InspectTable")
+	defer span.Finish()
+
+	query := provider.NewBuilder().
+		Select("column_name AS name", "data_type AS type", "is_nullable = 'YES' AS nullable", "column_default AS default_value").
+		From("information_schema.columns").
+		Where("table_schema = ?", schema).
+		Where("table_name = ?", table).
+		OrderBy("ordinal_position", false)
+
+	info := provider.TableInfo{Schema: schema, Name: table}
+	if err := s.repository(ctx).All(ctx, query, &info.Columns); err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	if len(info.Columns) == 0 {
+		return nil, trail.Stacktrace(ErrTableNotFound)
+	}
+
+	if err := s.repository(ctx).All(ctx, s.referencesQuery(schema, table), &info.References); err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	return &info, nil
+}
+
+// referencesQuery gets the tables referenced by foreign keys on the table
+func (s Store) referencesQuery(schema, table string) *provider.Builder {
+	if s.conf.Dialect == "mysql" {
+		return provider.NewBuilder().
+			Select("DISTINCT referenced_table_name AS name").
+			From("information_schema.key_column_usage").
+			Where("table_schema = ?", schema).
+			Where("table_name = ?", table).
+			Where("referenced_table_name IS NOT NULL")
+	}
+
+	return provider.NewBuilder().
+		Select("DISTINCT ccu.table_name AS name").
+		From("information_schema.table_constraints tc").
+		Join("information_schema.constraint_column_usage ccu", "ccu.constraint_schema = tc.constraint_schema AND ccu.constraint_name = tc.constraint_name").
+		Where("tc.constraint_type = 'FOREIGN KEY'").
+		Where("tc.table_schema = ?", schema).
+		Where("tc.table_name = ?", table)
+}
+
+// InspectTables gets the names of the tables in a schema from the information schema (pg and mysql)
+func (s Store) InspectTables(ctx context.Context, schema string) ([]string, error) {
+	span := trail.StartSpan(ctx, "Store.InspectTables")
+	defer span.Finish()
+
+	query := provider.NewBuilder().
+		Select("table_name AS name").
+		From("information_schema.tables").
+		Where("table_schema = ?", schema).
+		OrderBy("table_name", false)
+
+	var tables []string
+	if err := s.repository(ctx).All(ctx, query, &tables); err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	return tables, nil
+}
+
 // BatchQuery query
 func (s Store) BatchQuery(ctx context.Context, query provider.BatchQuery, opts ...QueryOption) error {
 	span := trail.StartSpan(ctx, "Store.BatchQuery")
@@ -71,6 +509,7 @@ func (s Store) BatchQuery(ctx context.Context, query provider.BatchQuery, opts .
 	}
 
 	for _, item := range query {
+		item.Spec = s.filter(item.Spec, conf)
 		cv, present := s.cache.Get(item.Spec.Id())
 		if present {
 			if err := hydrate(item.Value, cv); err != nil {
@@ -80,14 +519,14 @@ func (s Store) BatchQuery(ctx context.Context, query provider.BatchQuery, opts .
 		}
 	}
 
-	if err := s.db.Repository().BatchQuery(ctx, query); err != nil {
+	if err := s.repository(ctx).BatchQuery(ctx, query); err != nil {
 		return trail.Stacktrace(err)
 	}
 
 	if conf.QueryTTL != 0 {
 		for _, item := range query {
 			if !item.Skip {
-				s.cache.SetWithTTL(item.Spec.Id(), item.Value, 1, conf.QueryTTL)
+				s.cache.Set(item.Spec.Id(), item.Value, conf.QueryTTL)
 			}
 		}
 	}
@@ -95,6 +534,34 @@ func (s Store) BatchQuery(ctx context.Context, query provider.BatchQuery, opts .
 	return nil
 }
 
+// BatchExec sends a batch of statements in a single round trip
+func (s Store) BatchExec(ctx context.Context, exec provider.BatchExec) error {
+	span := trail.StartSpan(ctx, "Store.BatchExec")
+	defer span.Finish()
+
+	return s.repository(ctx).BatchExec(ctx, exec)
+}
+
+// ExecRaw executes the statement as is, without replacing ? placeholders (e.g., for ddl or procedural sql)
+// args must use the native placeholders of the database (e.g., $1 for pg)
+// this is unsafe for user supplied input, which must only ever be passed as args, and cached results are not invalidated
+func (s Store) ExecRaw(ctx context.Context, stmt string, args ...interface{}) error {
+	span := trail.StartSpan(ctx, "Store.ExecRaw")
+	defer span.Finish()
+
+	return s.repository(ctx).ExecRaw(ctx, stmt, args...)
+}
+
+// CopyFrom adds rows of values for the columns to the collection, returning the number of rows added
+// pg uses the copy protocol, which is much faster than inserts for bulk loads
+func (s Store) CopyFrom(ctx context.Context, collection string, columns []string, rows [][]interface{}) (int64, error) {
+	span := trail.StartSpan(ctx, "Store.CopyFrom")
+	defer span.Finish()
+
+	n, err := s.repository(ctx).CopyFrom(ctx, collection, columns, rows)
+	return n, trail.Stacktrace(err)
+}
+
 // One retrieve the first value matching the spec
 func (s Store) One(ctx context.Context, spec provider.Spec, v interface{}, opts ...QueryOption) error {
 	span := trail.StartSpan(ctx, "Store.One")
@@ -105,18 +572,20 @@ func (s Store) One(ctx context.Context, spec provider.Spec, v interface{}, opts
 		opt(&conf)
 	}
 
-	cv, present := s.cache.Get(spec.Id())
+	spec = s.filter(spec, conf)
+
+	cv, present := s.cache.Get(conf.cacheKey(spec))
 	span.Tags.Set("Store.CacheHit", fmt.Sprintf("%t", present))
 	if present {
 		return hydrate(v, cv)
 	}
 
-	if err := s.db.Repository().One(ctx, spec, v); err != nil {
+	if err := s.repository(ctx).One(ctx, spec, v); err != nil {
 		return trail.Stacktrace(err)
 	}
 
 	if conf.QueryTTL != 0 {
-		s.cache.SetWithTTL(spec.Id(), v, 1, conf.QueryTTL)
+		s.cache.Set(conf.cacheKey(spec), v, conf.QueryTTL)
 	}
 
 	return nil
@@ -132,115 +601,1001 @@ func (s Store) All(ctx context.Context, spec provider.Spec, v interface{}, opts
 		opt(&conf)
 	}
 
-	cv, present := s.cache.Get(spec.Id())
+	spec = s.filter(spec, conf)
+
+	cv, present := s.cache.Get(conf.cacheKey(spec))
 	span.Tags.Set("Store.CacheHit", fmt.Sprintf("%t", present))
 	if present {
 		return hydrate(v, cv)
 	}
 
-	if err := s.db.Repository().All(ctx, spec, v); err != nil {
+	if err := s.repository(ctx).All(ctx, spec, v); err != nil {
 		return trail.Stacktrace(err)
 	}
 
 	if conf.QueryTTL != 0 {
-		s.cache.SetWithTTL(spec.Id(), v, 1, conf.QueryTTL)
+		s.cache.Set(conf.cacheKey(spec), v, conf.QueryTTL)
 	}
 
 	return nil
 }
 
-// Add appends a value to the collection
-func (s Store) Add(ctx context.Context, collection string, v interface{}) error {
-	span := trail.StartSpan(ctx, "Store.Add")
+// AllUnion retrieves the values matching the query in each of the tables, combined with UNION ALL (pg only)
+// the tables must have the same columns, unqualified tables are inspected in the store schema (public by default)
+func (s Store) AllUnion(ctx context.Context, tables []string, query *provider.Builder, v interface{}, opts ...QueryOption) error {
+	span := trail.StartSpan(ctx, "Store.AllUnion")
 	defer span.Finish()
 
-	return s.db.Repository().Add(ctx, collection, v)
+	var first *provider.TableInfo
+	for _, table := range tables {
+		schema, name := s.conf.Schema, table
+		if i := strings.LastIndex(table, "."); i >= 0 {
+			schema, name = table[:i], table[i+1:]
+		}
+
+		if schema == "" {
+			schema = "public"
+		}
+
+		info, err := s.InspectTable(ctx, schema, name)
+		if err != nil {
+			return trail.Stacktrace(err)
+		}
+
+		if first == nil {
+			first = info
+			continue
+		}
+
+		if !sameColumns(first.Columns, info.Columns) {
+			return trail.NewErrorBadRequest(fmt.Sprintf("table %s does not have the same columns as %s", table, tables[0]))
+		}
+	}
+
+	conf := QueryConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	query, _ = s.filter(query, conf).(*provider.Builder)
+	return s.All(ctx, provider.UnionAll(query, tables...), v, opts...)
 }
 
-// Edit updates value(s) in the collection
-func (s Store) Edit(ctx context.Context, collection string, spec provider.Spec, v interface{}) error {
-	span := trail.StartSpan(ctx, "Store.Edit")
-	defer span.Finish()
+// sameColumns checks if the columns have the same names and types in the same order
+func sameColumns(a, b []provider.ColumnInfo) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Type != b[i].Type {
+			return false
+		}
+	}
 
-	return s.db.Repository().Edit(ctx, collection, spec, v)
+	return true
 }
 
-// Remove deletes values(s) in the collection
-func (s Store) Remove(ctx context.Context, collection string, spec provider.Spec) error {
-	span := trail.StartSpan(ctx, "Store.Remove")
+// Refresh reloads the value from the collection by its primary key (e.g., to load columns with defaults after an Add)
+// the primary key is the struct fields tagged pk (e.g., db:"id,pk"), see WithPrimaryKeyTag
+func (s Store) Refresh(ctx context.Context, collection string, v interface{}, opts ...QueryOption) error {
+	span := trail.StartSpan(ctx, "Store.Refresh")
 	defer span.Finish()
 
-	s.cache.Del(spec.Id())
-	return s.db.Repository().Remove(ctx, collection, spec)
-}
+	tag := s.conf.PrimaryKeyTag
+	if tag == "" {
+		tag = "pk"
+	}
 
-// NewStore creates a new store instance
-func NewStore(db provider.Provider) *Store {
-	s := Store{}
-	s.cache, _ = ristretto.NewCache(&ristretto.Config{
-		NumCounters: 1e7,
-		MaxCost:     1 << 30,
-		BufferItems: 64,
-	})
-	s.db = db
-	return &s
-}
+	keys, err := encode.Tagged(v, tag)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
 
-// New creates a new instance of the data store
-func New(opts ...Option) (*Store, error) {
-	conf := Config{
-		DSN: os.Getenv("DATABASE_URL"),
+	if len(keys) == 0 {
+		return trail.NewErrorf("value of type %T has no fields tagged %s", v, tag)
 	}
 
-	for _, opt := range opts {
-		opt(&conf)
+	columns := make([]string, 0, len(keys))
+	for column := range keys {
+		columns = append(columns, column)
 	}
 
-	db, err := pg.New(conf.DSN, conf.Migration, conf.PgOptions...)
-	if err != nil {
-		return nil, trail.Stacktrace(err)
+	sort.Strings(columns)
+	query := provider.NewBuilder().Select("*").From(collection)
+	for _, column := range columns {
+		query = query.Where(fmt.Sprintf("%s = ?", column), keys[column])
 	}
 
-	return NewStore(db), nil
+	return s.One(ctx, query, v, opts...)
 }
 
-// Txn A unit of work
-type Txn struct {
-	ctx   context.Context
-	uow   provider.UnitOfWork
-	store *Store
-	root  bool
-	done  bool
-}
+// AllByIds retrieves the values with the ids in a single query, in the order of the ids (pg only)
+// ids may be any slice, the values of an []interface{} must all have the type of the first
+func (s Store) AllByIds(ctx context.Context, collection, column string, ids interface{}, v interface{}, opts ...QueryOption) error {
+	span := trail.StartSpan(ctx, "Store.AllByIds")
+	defer span.Finish()
 
-// Context gets the context of the transaction
-func (tx Txn) Context() context.Context {
-	return tx.ctx
-}
+	values, err := typedSlice(ids)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
 
-// One retrieve the first value matching the spec
-func (tx Txn) One(spec provider.Spec, v interface{}, opts ...QueryOption) error {
-	return tx.store.One(tx.Context(), spec, v, opts...)
-}
+	rv := reflect.ValueOf(values)
+	if rv.Len() == 0 {
+		return provider.NewNoResultsError(collection, "")
+	}
 
-// All retrieves a listing of values
-func (tx Txn) All(spec provider.Spec, v interface{}, opts ...QueryOption) error {
-	return tx.store.All(tx.Context(), spec, v, opts...)
-}
+	// ids are compared as text for ordering, as the position array can not take the type of the column
+	positions := make([]string, rv.Len())
+	for i := range positions {
+		positions[i] = fmt.Sprint(rv.Index(i).Interface())
+	}
 
-// Add appends a value to the collection
-func (tx Txn) Add(collection string, v interface{}) error {
-	return tx.store.Add(tx.Context(), collection, v)
-}
+	query := provider.NewBuilder().
+		Select("*").
+		From(collection).
+		WhereAny(column, values).
+		OrderByExpr(fmt.Sprintf("array_position(?::text[], %s::text)", column), positions)
 
-// Edit updates value(s) in the collection
-func (tx Txn) Edit(collection string, spec provider.Spec, v interface{}) error {
-	return tx.store.Edit(tx.Context(), collection, spec, v)
+	return s.All(ctx, query, v, opts...)
 }
 
-// Remove deletes values(s) in the collection
-func (tx Txn) Remove(collection string, spec provider.Spec) error {
-	return tx.store.Remove(tx.Context(), collection, spec)
+// AllWhere retrieves the values where the column matches any of the values in a single query (pg only)
+// values are bound as one array (e.g., uuid[], text[], int4[], int8[]), so the query plan is the same for any number of values
+func (s Store) AllWhere(ctx context.Context, collection, column string, values interface{}, v interface{}, opts ...QueryOption) error {
+	span := trail.StartSpan(ctx, "Store.AllWhere")
+	defer span.Finish()
+
+	values, err := typedSlice(values)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	if reflect.ValueOf(values).Len() == 0 {
+		return provider.NewNoResultsError(collection, "")
+	}
+
+	query := provider.NewBuilder().
+		Select("*").
+		From(collection).
+		WhereAny(column, values)
+
+	return s.All(ctx, query, v, opts...)
+}
+
+// typedSlice converts a slice of interface values to a slice of the type of its first value (e.g., for pg arrays)
+func typedSlice(v interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil, trail.NewErrorf("ids of type %T are not a slice", v)
+	}
+
+	if rv.Type().Elem().Kind() != reflect.Interface || rv.Len() == 0 {
+		return v, nil
+	}
+
+	first := rv.Index(0).Elem()
+	if !first.IsValid() {
+		return nil, trail.NewError("ids must not be nil")
+	}
+
+	typed := reflect.MakeSlice(reflect.SliceOf(first.Type()), rv.Len(), rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		item := rv.Index(i).Elem()
+		if !item.IsValid() || item.Type() != first.Type() {
+			return nil, trail.NewErrorf("ids must all be of type %s", first.Type())
+		}
+
+		typed.Index(i).Set(item)
+	}
+
+	return typed.Interface(), nil
+}
+
+// Scan iterates over the values matching the spec without loading them all into memory
+// the rows must be closed before other queries are made within the same transaction
+func (s Store) Scan(ctx context.Context, spec provider.Spec, opts ...QueryOption) (provider.Rows, error) {
+	span := trail.StartSpan(ctx, "Store.Scan")
+	defer span.Finish()
+
+	conf := QueryConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	return s.repository(ctx).Scan(ctx, s.filter(spec, conf))
+}
+
+// Count gets the number of values matching the spec
+// counts are only cached if a query ttl is given, under the id of the count spec as WithCacheKey is ignored
+func (s Store) Count(ctx context.Context, spec provider.Spec, opts ...QueryOption) (int64, error) {
+	span := trail.StartSpan(ctx, "Store.Count")
+	defer span.Finish()
+
+	conf := QueryConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	var n int64
+	query := provider.Count(s.filter(spec, conf))
+	if conf.QueryTTL != 0 {
+		// a cache key given for the values would otherwise be shared with the count
+		opts = append(opts[:len(opts):len(opts)], WithCacheKey(""))
+		err := s.One(ctx, query, &n, opts...)
+		return n, trail.Stacktrace(err)
+	}
+
+	err := s.repository(ctx).One(ctx, query, &n)
+	return n, trail.Stacktrace(err)
+}
+
+// AggregateScan retrieves the aggregates of the values matching the query, scanned into the fields of v by alias
+// e.g., SUM(amount) AS total into a field tagged db:"total"
+func (s Store) AggregateScan(ctx context.Context, query *provider.Builder, aggs []provider.Aggregate, v interface{}, opts ...QueryOption) error {
+	span := trail.StartSpan(ctx, "Store.AggregateScan")
+	defer span.Finish()
+
+	if len(aggs) == 0 {
+		return trail.NewError("at least one aggregate is required")
+	}
+
+	return s.One(ctx, query.Aggregate(aggs...), v, opts...)
+}
+
+// Exists checks if any value matches the spec
+func (s Store) Exists(ctx context.Context, spec provider.Spec, opts ...QueryOption) (bool, error) {
+	span := trail.StartSpan(ctx, "Store.Exists")
+	defer span.Finish()
+
+	conf := QueryConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	var v int
+	err := s.repository(ctx).One(ctx, provider.Exists(s.filter(spec, conf)), &v)
+	if trail.IsNotFound(err) {
+		return false, nil
+	}
+
+	return err == nil, trail.Stacktrace(err)
+}
+
+// Explain gets the json plan of the query, including actual timings and buffer usage (pg only)
+// the query is executed, so writes should be explained within a transaction that is rolled back
+func (s Store) Explain(ctx context.Context, spec provider.Spec) (string, error) {
+	span := trail.StartSpan(ctx, "Store.Explain")
+	defer span.Finish()
+
+	var plan string
+	err := s.repository(ctx).One(ctx, provider.Explain(spec), &plan)
+	return plan, trail.Stacktrace(err)
+}
+
+// ExplainBudget checks that the planner's total cost estimate for the query is within the budget (pg only)
+func (s Store) ExplainBudget(ctx context.Context, maxCost float64, spec provider.Spec) error {
+	plan, err := s.Explain(ctx, spec)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	cost, err := provider.PlanCost(plan)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	if cost > maxCost {
+		return trail.Stacktrace(ErrQueryPlanExceeded)
+	}
+
+	return nil
+}
+
+// Page retrieves a page of values using cursor pagination
+func (s Store) Page(ctx context.Context, query *provider.Builder, v interface{}, opts ...QueryOption) (provider.Page, error) {
+	span := trail.StartSpan(ctx, "Store.Page")
+	defer span.Finish()
+
+	if err := s.All(ctx, query, v, opts...); err != nil {
+		return provider.Page{}, trail.Stacktrace(err)
+	}
+
+	next, err := query.NextCursor(v)
+	if err != nil {
+		return provider.Page{}, trail.Stacktrace(err)
+	}
+
+	return provider.Page{Items: v, NextCursor: next}, nil
+}
+
+// AllPaginated retrieves a page (starting at 1) of values matching the query, with the total number of values
+// the values and their count are retrieved within the same transaction, so the total is consistent with the page
+func (s Store) AllPaginated(ctx context.Context, query *provider.Builder, page, pageSize int, v interface{}, opts ...QueryOption) (provider.PaginatedResult, error) {
+	span := trail.StartSpan(ctx, "Store.AllPaginated")
+	defer span.Finish()
+
+	if page < 1 || pageSize < 1 {
+		return provider.PaginatedResult{}, trail.NewErrorBadRequest("page and page size must be positive")
+	}
+
+	if _, ok := ctx.Value(contextKey{}).(Txn); !ok {
+		var res provider.PaginatedResult
+		err := s.Do(ctx, func(tx Txn) error {
+			var err error
+			res, err = s.AllPaginated(tx.Context(), query, page, pageSize, v, opts...)
+			return err
+		}, provider.WithReadOnly(true))
+
+		return res, trail.Stacktrace(err)
+	}
+
+	total, err := s.Count(ctx, query, opts...)
+	if err != nil {
+		return provider.PaginatedResult{}, trail.Stacktrace(err)
+	}
+
+	if err := s.All(ctx, query.Paginate(page, pageSize), v, opts...); err != nil {
+		return provider.PaginatedResult{}, trail.Stacktrace(err)
+	}
+
+	return provider.NewPaginatedResult(v, total, page, pageSize), nil
+}
+
+// Add appends a value to the collection
+func (s Store) Add(ctx context.Context, collection string, v interface{}, opts ...QueryOption) error {
+	span := trail.StartSpan(ctx, "Store.Add")
+	defer span.Finish()
+
+	if s.auditing(ctx) {
+		return s.Do(ctx, func(tx Txn) error {
+			return s.Add(tx.Context(), collection, v, opts...)
+		})
+	}
+
+	conf := QueryConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	if err := s.beforeWrite(ctx, WriteAdd, collection, v); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	var n int64
+	writeOpts := []provider.WriteOption{provider.WithRowsAffected(&n)}
+	writeOpts = append(writeOpts, conf.columns()...)
+	if len(conf.Returning) > 0 {
+		writeOpts = append(writeOpts, provider.WithReturning(conf.ReturningDest, conf.Returning...))
+	}
+
+	if err := s.repository(ctx).Add(ctx, collection, v, writeOpts...); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	if err := s.audit(ctx, WriteAdd, collection, nil, v, writeOpts...); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	s.afterWrite(ctx, WriteAdd, collection, n)
+	return nil
+}
+
+// AddBatch appends the values to the collection with multi-row inserts of up to 1000 rows each (pg only)
+// values must all be of the same type, and are added atomically only within a transaction
+func (s Store) AddBatch(ctx context.Context, collection string, values []interface{}) error {
+	span := trail.StartSpan(ctx, "Store.AddBatch")
+	defer span.Finish()
+
+	b, ok := s.repository(ctx).(provider.BatchAdder)
+	if !ok {
+		return trail.NewErrorf("provider %T does not support batch adds", s.db)
+	}
+
+	if s.auditing(ctx) {
+		return s.Do(ctx, func(tx Txn) error {
+			return s.AddBatch(tx.Context(), collection, values)
+		})
+	}
+
+	for _, v := range values {
+		if err := s.beforeWrite(ctx, WriteAdd, collection, v); err != nil {
+			return trail.Stacktrace(err)
+		}
+	}
+
+	if err := b.AddBatch(ctx, collection, values); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	for _, v := range values {
+		if err := s.audit(ctx, WriteAdd, collection, nil, v); err != nil {
+			return trail.Stacktrace(err)
+		}
+	}
+
+	s.afterWrite(ctx, WriteAdd, collection, int64(len(values)))
+	return nil
+}
+
+// Edit updates value(s) in the collection
+// struct fields tagged readonly (e.g., db:"id,readonly") are never updated
+// not found errors are returned if no values match, and query builders are refused as conditions (see ErrBuilderCondition)
+func (s Store) Edit(ctx context.Context, collection string, spec provider.Spec, v interface{}, opts ...QueryOption) error {
+	span := trail.StartSpan(ctx, "Store.Edit")
+	defer span.Finish()
+
+	if _, ok := spec.(*provider.Builder); ok {
+		return trail.Stacktrace(ErrBuilderCondition)
+	}
+
+	if unconditional(spec) {
+		return trail.Stacktrace(ErrFullTableUpdate)
+	}
+
+	conf := QueryConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	if s.auditing(ctx) {
+		return s.Do(ctx, func(tx Txn) error {
+			return s.Edit(tx.Context(), collection, spec, v, opts...)
+		})
+	}
+
+	if err := s.beforeWrite(ctx, WriteEdit, collection, v); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	old, err := s.audited(ctx, collection, spec)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	var n int64
+	writeOpts := []provider.WriteOption{provider.WithRowsAffected(&n)}
+	if conf.VersionColumn != "" {
+		writeOpts = append(writeOpts, provider.WithVersion(conf.VersionColumn))
+	}
+
+	writeOpts = append(writeOpts, conf.columns()...)
+	if len(conf.Returning) > 0 {
+		writeOpts = append(writeOpts, provider.WithReturning(conf.ReturningDest, conf.Returning...))
+	}
+
+	if err := s.repository(ctx).Edit(ctx, collection, spec, v, writeOpts...); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	if err := s.audit(ctx, WriteEdit, collection, old, v, writeOpts...); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	s.afterWrite(ctx, WriteEdit, collection, n)
+	return nil
+}
+
+// Upsert adds a value to the collection or updates it on conflict
+func (s Store) Upsert(ctx context.Context, collection string, v interface{}, conflict []string) error {
+	span := trail.StartSpan(ctx, "Store.Upsert")
+	defer span.Finish()
+
+	if err := s.beforeWrite(ctx, WriteUpsert, collection, v); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	if err := s.repository(ctx).Upsert(ctx, collection, v, conflict); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	s.afterWrite(ctx, WriteUpsert, collection, 1)
+	return nil
+}
+
+// Merge adds a value to the collection or updates the columns of the row matching it on the match columns (pg only)
+// insert defaults are only used for new rows, and all columns other than the match columns are updated if none are given
+// MERGE is used on pg 15+, older versions fall back to INSERT ... ON CONFLICT, which requires a unique constraint on the match columns
+func (s Store) Merge(ctx context.Context, collection string, v interface{}, match []string, insertDefaults map[string]interface{}, update []string) error {
+	span := trail.StartSpan(ctx, "Store.Merge")
+	defer span.Finish()
+
+	m, ok := s.repository(ctx).(provider.Merger)
+	if !ok {
+		return trail.NewErrorf("provider %T does not support merge", s.db)
+	}
+
+	if err := s.beforeWrite(ctx, WriteUpsert, collection, v); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	if err := m.Merge(ctx, collection, v, match, insertDefaults, update); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	s.afterWrite(ctx, WriteUpsert, collection, 1)
+	return nil
+}
+
+// Remove deletes values(s) in the collection
+// deleting all values requires WithUnsafeFullTableDelete, and query builders are refused as conditions (see ErrBuilderCondition)
+func (s Store) Remove(ctx context.Context, collection string, spec provider.Spec, opts ...QueryOption) error {
+	span := trail.StartSpan(ctx, "Store.Remove")
+	defer span.Finish()
+
+	conf := QueryConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	if _, ok := spec.(*provider.Builder); ok {
+		return trail.Stacktrace(ErrBuilderCondition)
+	}
+
+	if unconditional(spec) {
+		if !conf.UnsafeFullTableDelete {
+			return trail.Stacktrace(ErrFullTableDelete)
+		}
+
+		spec = provider.NewSpec(collection, squirrel.Expr("1=1"))
+		s.cache.Clear()
+	}
+
+	if s.auditing(ctx) {
+		return s.Do(ctx, func(tx Txn) error {
+			return s.Remove(tx.Context(), collection, spec, opts...)
+		})
+	}
+
+	if err := s.beforeWrite(ctx, WriteRemove, collection, spec); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	old, err := s.audited(ctx, collection, spec)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	var n int64
+	writeOpts := []provider.WriteOption{provider.WithRowsAffected(&n)}
+	if len(conf.Returning) > 0 {
+		writeOpts = append(writeOpts, provider.WithReturning(conf.ReturningDest, conf.Returning...))
+	}
+
+	s.cache.Delete(spec.Id())
+	if s.conf.SoftDeleteColumn != "" {
+		err = s.repository(ctx).Edit(ctx, collection, spec, map[string]interface{}{s.conf.SoftDeleteColumn: time.Now().UTC()}, writeOpts...)
+		if trail.IsNotFound(err) {
+			err = nil
+		}
+	} else {
+		err = s.repository(ctx).Remove(ctx, collection, spec, writeOpts...)
+	}
+
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	if err := s.audit(ctx, WriteRemove, collection, old, nil); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	s.afterWrite(ctx, WriteRemove, collection, n)
+	return nil
+}
+
+// Truncate removes all values from the tables, restarting identities (pg and mysql)
+// pg cascades to referencing tables, while mysql truncates each table in turn and commits implicitly
+// truncating non-local databases requires WithForceDelete
+func (s Store) Truncate(ctx context.Context, tables ...string) error {
+	span := trail.StartSpan(ctx, "Store.Truncate")
+	defer span.Finish()
+
+	if len(tables) == 0 {
+		return trail.NewError("no tables were provided")
+	}
+
+	if !s.conf.ForceDelete {
+		if l, ok := s.db.(provider.Local); !ok || !l.IsLocal() {
+			return trail.Stacktrace(ErrNotPermitted)
+		}
+	}
+
+	stmts, err := truncateStmts(s.conf.Dialect, tables)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	var exec provider.BatchExec
+	for _, stmt := range stmts {
+		exec.Exec(provider.NewSpec(nil, squirrel.Expr(stmt)))
+	}
+
+	if err := s.repository(ctx).BatchExec(ctx, exec); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	s.cache.Clear()
+	return nil
+}
+
+// truncateStmts gets the statements truncating the tables, quoting their names for the dialect
+func truncateStmts(dialect string, tables []string) ([]string, error) {
+	switch dialect {
+	case "sqlite":
+		return nil, trail.NewErrorf("dialect %s does not support truncating tables", dialect)
+	case "mysql":
+		stmts := make([]string, len(tables))
+		for i, table := range tables {
+			parts := strings.Split(table, ".")
+			for j, part := range parts {
+				parts[j] = "`" + strings.ReplaceAll(part, "`", "``") + "`"
+			}
+
+			stmts[i] = fmt.Sprintf("TRUNCATE TABLE %s", strings.Join(parts, "."))
+		}
+
+		return stmts, nil
+	}
+
+	identifiers := make([]string, len(tables))
+	for i, table := range tables {
+		identifiers[i] = pgx.Identifier(strings.Split(table, ".")).Sanitize()
+	}
+
+	return []string{fmt.Sprintf("TRUNCATE %s RESTART IDENTITY CASCADE", strings.Join(identifiers, ", "))}, nil
+}
+
+// unconditional checks if the spec matches all values
+func unconditional(spec provider.Spec) bool {
+	if spec == nil {
+		return true
+	}
+
+	sql, _, err := spec.ToSql()
+	sql = strings.TrimSpace(sql)
+	return err == nil && (sql == "" || sql == "(1=1)" || sql == "1=1")
+}
+
+// filter excludes soft deleted rows from builder queries unless configured otherwise, and adds the query filters
+func (s Store) filter(spec provider.Spec, conf QueryConfig) provider.Spec {
+	b, ok := spec.(*provider.Builder)
+	if !ok {
+		return spec
+	}
+
+	if s.conf.SoftDeleteColumn != "" && !conf.IncludeDeleted {
+		b = b.NotDeleted(s.conf.SoftDeleteColumn)
+	}
+
+	if len(conf.Filters) > 0 {
+		b = b.Filter(conf.Filters...)
+	}
+
+	return b
+}
+
+// repository gets the repository for the transaction in context, if any
+// outside of transactions, queries with a row level security user run in a transaction of their own setting it
+func (s Store) repository(ctx context.Context) provider.Repository {
+	repo := s.db.Repository()
+	if tx, ok := ctx.Value(contextKey{}).(Txn); ok {
+		repo = tx.uow.Repository()
+	} else if _, ok := RLSUser(ctx); ok {
+		repo = rlsRepository{db: s.db}
+	}
+
+	if s.conf.QueryTimeout > 0 {
+		repo = timeoutRepository{Repository: repo, timeout: s.conf.QueryTimeout}
+	}
+
+	return repo
+}
+
+// NewStore creates a new store instance
+func NewStore(db provider.Provider) *Store {
+	s := Store{}
+	s.cache = newMemoryCache()
+	s.db = db
+	s.healthy = new(int32)
+	*s.healthy = 1
+	s.hooks = &writeHooks{}
+	s.done = make(chan struct{})
+	s.closing = &sync.Once{}
+	return &s
+}
+
+// New creates a new instance of the data store
+func New(opts ...Option) (*Store, error) {
+	conf := Config{
+		Dialect: "postgres",
+		DSN:     os.Getenv("DATABASE_URL"),
+	}
+
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	if err := conf.Validate(); err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	if conf.MigrationDryRun {
+		conf.PgOptions = append(conf.PgOptions, pg.WithMigrationDryRun())
+		conf.MySQLOptions = append(conf.MySQLOptions, mysql.WithMigrationDryRun())
+		conf.SQLiteOptions = append(conf.SQLiteOptions, sqlite.WithMigrationDryRun())
+	}
+
+	if conf.ForceDownMigrations {
+		conf.PgOptions = append(conf.PgOptions, pg.WithForceDownMigrations())
+		conf.MySQLOptions = append(conf.MySQLOptions, mysql.WithForceDownMigrations())
+	}
+
+	if conf.MigrationTable != "" {
+		conf.PgOptions = append(conf.PgOptions, pg.WithMigrationTable(conf.MigrationTable))
+		conf.MySQLOptions = append(conf.MySQLOptions, mysql.WithMigrationTable(conf.MigrationTable))
+		conf.SQLiteOptions = append(conf.SQLiteOptions, sqlite.WithMigrationTable(conf.MigrationTable))
+	}
+
+	if conf.Schema != "" {
+		conf.PgOptions = append(conf.PgOptions, pg.WithSchema(conf.Schema))
+	}
+
+	if conf.SQLOpenFunc != nil {
+		conf.MySQLOptions = append(conf.MySQLOptions, mysql.WithSQLOpenFunc(conf.SQLOpenFunc))
+		conf.SQLiteOptions = append(conf.SQLiteOptions, sqlite.WithSQLOpenFunc(conf.SQLOpenFunc))
+	}
+
+	if conf.MigrationHook != nil {
+		conf.PgOptions = append(conf.PgOptions, pg.WithMigrationHook(conf.MigrationHook))
+		conf.MySQLOptions = append(conf.MySQLOptions, mysql.WithMigrationHook(conf.MigrationHook))
+		conf.SQLiteOptions = append(conf.SQLiteOptions, sqlite.WithMigrationHook(conf.MigrationHook))
+	}
+
+	var db provider.Provider
+	var err error
+	switch conf.Dialect {
+	case "postgres":
+		db, err = pg.New(conf.DSN, conf.Migration, conf.PgOptions...)
+	case "cockroachdb":
+		pgOpts := append([]pg.Option{pg.WithSimpleProtocol(true)}, conf.PgOptions...)
+		db, err = pg.New(conf.DSN, conf.Migration, pgOpts...)
+	case "mysql":
+		db, err = mysql.New(conf.DSN, conf.Migration, conf.MySQLOptions...)
+	case "sqlite":
+		db, err = sqlite.New(conf.DSN, conf.Migration, conf.SQLiteOptions...)
+	default:
+		err = trail.NewErrorf("dialect %s is not supported", conf.Dialect)
+	}
+
+	if err != nil {
+		return nil, trail.Stacktrace(err)
+	}
+
+	s := NewStore(db)
+	s.conf = conf
+	if conf.Cache != nil {
+		s.cache = timedCache{Cache: conf.Cache}
+	}
+
+	if conf.HealthCheckInterval > 0 {
+		go s.monitor(conf.HealthCheckInterval)
+	}
+
+	for name, interval := range conf.AutoRefresh {
+		go s.refresh(name, interval)
+	}
+
+	return s, nil
+}
+
+// Txn A unit of work
+type Txn struct {
+	ctx      context.Context
+	uow      provider.UnitOfWork
+	store    *Store
+	root     bool
+	done     bool
+	depth    int
+	readOnly bool
+}
+
+// Context gets the context of the transaction
+func (tx Txn) Context() context.Context {
+	return tx.ctx
+}
+
+// WithTimeout creates a copy of the transaction whose operations respect the deadline, leaving the transaction unaffected (pg only)
+// each operation runs under a savepoint with statement_timeout set to the deadline, and is rolled back to it when exceeded,
+// so the transaction remains usable, and each savepoint is released when its operation ends (or its rows are closed for Scan)
+// as with context.WithTimeout, a cancel function is returned alongside the copy to release the deadline's timer,
+// and should be called when the copy is no longer used; operations of the copy fail once it is called
+func (tx Txn) WithTimeout(d time.Duration) (Txn, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(tx.ctx, d)
+	tx.uow = deadlineUnitOfWork{UnitOfWork: tx.uow, parent: tx.ctx}
+	tx.ctx = context.WithValue(ctx, contextKey{}, tx)
+	return tx, cancel
+}
+
+// TxnFromContext gets the transaction in context, if any
+// store methods called with the context join the transaction, and Do and Begin start one only if none is present
+func TxnFromContext(ctx context.Context) (Txn, bool) {
+	tx, ok := ctx.Value(contextKey{}).(Txn)
+	return tx, ok
+}
+
+// ContextWithTxn creates a context propagating the transaction (e.g., from a service layer to a repository layer)
+func ContextWithTxn(ctx context.Context, tx Txn) context.Context {
+	return context.WithValue(ctx, contextKey{}, tx)
+}
+
+// One retrieve the first value matching the spec
+func (tx Txn) One(spec provider.Spec, v interface{}, opts ...QueryOption) error {
+	return tx.store.One(tx.Context(), spec, v, opts...)
+}
+
+// All retrieves a listing of values
+func (tx Txn) All(spec provider.Spec, v interface{}, opts ...QueryOption) error {
+	return tx.store.All(tx.Context(), spec, v, opts...)
+}
+
+// AllWithLock retrieves a listing of values after waiting for an advisory lock on the key (pg only)
+// the lock is released when the transaction ends, so transactions listing (then editing) the same logical group of rows run serially
+func (tx Txn) AllWithLock(spec provider.Spec, v interface{}, key int64, opts ...QueryOption) error {
+	if err := tx.AdvisoryLock(key); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	return tx.All(spec, v, opts...)
+}
+
+// AllUnion retrieves the values matching the query in each of the tables within a transaction (pg only)
+func (tx Txn) AllUnion(tables []string, query *provider.Builder, v interface{}, opts ...QueryOption) error {
+	return tx.store.AllUnion(tx.Context(), tables, query, v, opts...)
+}
+
+// Refresh reloads the value from the collection by its primary key within a transaction
+func (tx Txn) Refresh(collection string, v interface{}, opts ...QueryOption) error {
+	return tx.store.Refresh(tx.Context(), collection, v, opts...)
+}
+
+// AllByIds retrieves the values with the ids within a transaction, in the order of the ids (pg only)
+func (tx Txn) AllByIds(collection, column string, ids interface{}, v interface{}, opts ...QueryOption) error {
+	return tx.store.AllByIds(tx.Context(), collection, column, ids, v, opts...)
+}
+
+// AllWhere retrieves the values where the column matches any of the values within a transaction (pg only)
+func (tx Txn) AllWhere(collection, column string, values interface{}, v interface{}, opts ...QueryOption) error {
+	return tx.store.AllWhere(tx.Context(), collection, column, values, v, opts...)
+}
+
+// AggregateScan retrieves the aggregates of the values matching the query within a transaction
+func (tx Txn) AggregateScan(query *provider.Builder, aggs []provider.Aggregate, v interface{}, opts ...QueryOption) error {
+	return tx.store.AggregateScan(tx.Context(), query, aggs, v, opts...)
+}
+
+// Scan iterates over the values matching the spec
+func (tx Txn) Scan(spec provider.Spec, opts ...QueryOption) (provider.Rows, error) {
+	return tx.store.Scan(tx.Context(), spec, opts...)
+}
+
+// Count gets the number of values matching the spec
+func (tx Txn) Count(spec provider.Spec, opts ...QueryOption) (int64, error) {
+	return tx.store.Count(tx.Context(), spec, opts...)
+}
+
+// Exists checks if any value matches the spec
+func (tx Txn) Exists(spec provider.Spec, opts ...QueryOption) (bool, error) {
+	return tx.store.Exists(tx.Context(), spec, opts...)
+}
+
+// Explain gets the json plan of the query within a transaction (pg only)
+func (tx Txn) Explain(spec provider.Spec) (string, error) {
+	return tx.store.Explain(tx.Context(), spec)
+}
+
+// ExplainBudget checks the planner's cost estimate for the query within a transaction (pg only)
+func (tx Txn) ExplainBudget(maxCost float64, spec provider.Spec) error {
+	return tx.store.ExplainBudget(tx.Context(), maxCost, spec)
+}
+
+// OneForUpdate retrieve the first value matching the query, locking it until the transaction ends
+// results are never cached, and locked rows are skipped if the query uses provider.Builder.SkipLocked
+func (tx Txn) OneForUpdate(query *provider.Builder, v interface{}, opts ...QueryOption) error {
+	if tx.readOnly {
+		return trail.NewErrorBadRequest("rows can not be locked in a read-only transaction")
+	}
+
+	conf := QueryConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	// the lock is taken on a copy, as builder methods modify the builder in place
+	locked := *query
+	err := tx.store.repository(tx.Context()).One(tx.Context(), tx.store.filter(locked.ForUpdate(), conf), v)
+	return trail.Stacktrace(err)
+}
+
+// Page retrieves a page of values using cursor pagination
+func (tx Txn) Page(query *provider.Builder, v interface{}, opts ...QueryOption) (provider.Page, error) {
+	return tx.store.Page(tx.Context(), query, v, opts...)
+}
+
+// AllPaginated retrieves a page (starting at 1) of values matching the query, with the total number of values
+func (tx Txn) AllPaginated(query *provider.Builder, page, pageSize int, v interface{}, opts ...QueryOption) (provider.PaginatedResult, error) {
+	return tx.store.AllPaginated(tx.Context(), query, page, pageSize, v, opts...)
+}
+
+// Add appends a value to the collection
+func (tx Txn) Add(collection string, v interface{}, opts ...QueryOption) error {
+	return tx.store.Add(tx.Context(), collection, v, opts...)
+}
+
+// AddBatch appends the values to the collection with multi-row inserts of up to 1000 rows each (pg only)
+func (tx Txn) AddBatch(collection string, values []interface{}) error {
+	return tx.store.AddBatch(tx.Context(), collection, values)
+}
+
+// OneOrAdd retrieves the value matching the values, adding it to the collection if none exists
+// a value added concurrently by another transaction is retrieved instead, reporting whether this transaction added it
+func (tx Txn) OneOrAdd(collection string, v interface{}, values map[string]interface{}, opts ...QueryOption) (bool, error) {
+	span := trail.StartSpan(tx.Context(), "Txn.OneOrAdd")
+	defer span.Finish()
+
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+
+	sort.Strings(columns)
+	query := provider.NewBuilder().Select("*").From(collection)
+	for _, column := range columns {
+		query = query.Where(fmt.Sprintf("%s = ?", column), values[column])
+	}
+
+	err := tx.One(query, v, opts...)
+	if !trail.IsNotFound(err) {
+		return false, trail.Stacktrace(err)
+	}
+
+	created := true
+	err = tx.Savepoint("one_or_add", func(tx Txn) error {
+		return tx.Add(collection, values)
+	})
+
+	if trail.IsConflict(err) {
+		created = false
+	} else if err != nil {
+		return false, trail.Stacktrace(err)
+	}
+
+	if err := tx.One(query, v, opts...); err != nil {
+		return false, trail.Stacktrace(err)
+	}
+
+	return created, nil
+}
+
+// Edit updates value(s) in the collection
+func (tx Txn) Edit(collection string, spec provider.Spec, v interface{}, opts ...QueryOption) error {
+	return tx.store.Edit(tx.Context(), collection, spec, v, opts...)
+}
+
+// Merge adds a value to the collection or updates the columns of the row matching it within a transaction (pg only)
+func (tx Txn) Merge(collection string, v interface{}, match []string, insertDefaults map[string]interface{}, update []string) error {
+	return tx.store.Merge(tx.Context(), collection, v, match, insertDefaults, update)
+}
+
+// Upsert adds a value to the collection or updates it on conflict
+func (tx Txn) Upsert(collection string, v interface{}, conflict []string) error {
+	return tx.store.Upsert(tx.Context(), collection, v, conflict)
+}
+
+// Remove deletes values(s) in the collection
+func (tx Txn) Remove(collection string, spec provider.Spec, opts ...QueryOption) error {
+	return tx.store.Remove(tx.Context(), collection, spec, opts...)
 }
 
 // BatchQuery performs a batch query op within a transaction
@@ -248,6 +1603,87 @@ func (tx Txn) BatchQuery(query provider.BatchQuery, opts ...QueryOption) error {
 	return tx.store.BatchQuery(tx.Context(), query, opts...)
 }
 
+// BatchExec performs a batch exec op within a transaction
+func (tx Txn) BatchExec(exec provider.BatchExec) error {
+	return tx.store.BatchExec(tx.Context(), exec)
+}
+
+// Truncate removes all values from the tables within a transaction (pg and mysql)
+func (tx Txn) Truncate(tables ...string) error {
+	return tx.store.Truncate(tx.Context(), tables...)
+}
+
+// ExecRaw executes the statement as is within a transaction, see Store.ExecRaw
+func (tx Txn) ExecRaw(stmt string, args ...interface{}) error {
+	return tx.store.ExecRaw(tx.Context(), stmt, args...)
+}
+
+// CopyFrom adds rows of values for the columns within a transaction
+func (tx Txn) CopyFrom(collection string, columns []string, rows [][]interface{}) (int64, error) {
+	return tx.store.CopyFrom(tx.Context(), collection, columns, rows)
+}
+
+// AdvisoryLock waits for an advisory lock on the key, released when the transaction ends (pg only)
+func (tx Txn) AdvisoryLock(key int64) error {
+	var exec provider.BatchExec
+	exec.Exec(provider.NewSpec(nil, squirrel.Expr("SELECT pg_advisory_xact_lock(?)", key)))
+	return tx.BatchExec(exec)
+}
+
+// AdvisoryTryLock attempts to take an advisory lock on the key without waiting (pg only)
+// the lock is released when the transaction ends
+func (tx Txn) AdvisoryTryLock(key int64) (bool, error) {
+	var locked bool
+	spec := provider.NewSpec(nil, squirrel.Expr("SELECT pg_try_advisory_xact_lock(?)", key))
+	err := tx.store.repository(tx.Context()).One(tx.Context(), spec, &locked)
+	return locked, trail.Stacktrace(err)
+}
+
+// Invalidate removes cached query results for the keys (spec ids or names given with WithCacheKey)
+func (tx Txn) Invalidate(keys ...interface{}) {
+	tx.store.Invalidate(keys...)
+}
+
+// InvalidateAll removes all cached query results
+// the cache is shared by the store, so results cached outside the transaction are removed as well
+func (tx Txn) InvalidateAll() {
+	tx.store.InvalidateAll()
+}
+
+// Savepoint execute callback in a nested transaction
+// changes made by the callback are rolled back on error while the parent transaction remains open
+func (tx Txn) Savepoint(name string, fn func(tx Txn) error) error {
+	span := trail.StartSpan(tx.Context(), "Txn.Savepoint")
+	defer span.Finish()
+	span.Tags.Set("Txn.Depth", fmt.Sprintf("%d", tx.depth+1))
+
+	uow, err := tx.uow.Savepoint(tx.Context(), name)
+	if err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	child := Txn{
+		uow:      uow,
+		store:    tx.store,
+		root:     true,
+		depth:    tx.depth + 1,
+		readOnly: tx.readOnly,
+	}
+
+	child.ctx = context.WithValue(tx.Context(), contextKey{}, child)
+	defer child.rollback()
+	if err := fn(child); err != nil {
+		return trail.Stacktrace(err)
+	}
+
+	return child.commit()
+}
+
+// Depth gets the savepoint nesting depth of the transaction
+func (tx Txn) Depth() int {
+	return tx.depth
+}
+
 // commit submit a unit of work
 func (tx *Txn) commit() error {
 	if tx.done || !tx.root {
@@ -268,9 +1704,85 @@ func (tx *Txn) rollback() {
 
 // Config a configuration for the store
 type Config struct {
-	DSN       string
-	Migration fs.ReadDirFS
-	PgOptions []pg.Option
+	Dialect             string
+	DSN                 string
+	Migration           fs.ReadDirFS
+	PgOptions           []pg.Option
+	MySQLOptions        []mysql.Option
+	SQLiteOptions       []sqlite.Option
+	SQLOpenFunc         func(driverName, dsn string) (*sql.DB, error)
+	RetryAttempts       int
+	RetryBackoff        time.Duration
+	DeadlockRetries     int
+	SoftDeleteColumn    string
+	PrimaryKeyTag       string
+	MigrationDryRun     bool
+	MigrationHook       provider.MigrationHook
+	MigrationTable      string
+	Schema              string
+	AuditTable          string
+	FeatureFlagTable    string
+	SeedDir             string
+	QueryTimeout        time.Duration
+	ForceDownMigrations bool
+	HealthCheckInterval time.Duration
+	MaxReplicationLag   time.Duration
+	AutoRefresh         map[string]time.Duration
+	Cache               Cache
+	ForceDelete         bool
+}
+
+// ConfigError an invalid store configuration field (e.g., trail.AsError(err, &ConfigError{}))
+type ConfigError struct {
+	Field  string
+	Reason string
+}
+
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("store: invalid %s: %s", e.Field, e.Reason)
+}
+
+// Validate checks the configuration for consistency before connecting, returning a ConfigError for the first invalid field
+func (c Config) Validate() error {
+	invalid := func(field, reason string) error {
+		return trail.ErrorBadRequest(ConfigError{Field: field, Reason: reason})
+	}
+
+	postgres := c.Dialect == "postgres" || c.Dialect == "cockroachdb"
+	switch {
+	case !postgres && c.Dialect != "mysql" && c.Dialect != "sqlite":
+		return invalid("Dialect", fmt.Sprintf("dialect %s is not supported", c.Dialect))
+	case c.RetryAttempts < 0:
+		return invalid("RetryAttempts", "must not be negative")
+	case c.RetryBackoff < 0:
+		return invalid("RetryBackoff", "must not be negative")
+	case c.DeadlockRetries < 0:
+		return invalid("DeadlockRetries", "must not be negative")
+	case c.QueryTimeout < 0:
+		return invalid("QueryTimeout", "must not be negative")
+	case c.HealthCheckInterval < 0:
+		return invalid("HealthCheckInterval", "must not be negative")
+	case c.MaxReplicationLag < 0:
+		return invalid("MaxReplicationLag", "must not be negative")
+	case c.Schema != "" && !postgres:
+		return invalid("Schema", "schemas are only supported by postgres")
+	case c.AuditTable != "" && !postgres:
+		return invalid("AuditTable", "audit logs are only supported by postgres")
+	case len(c.AutoRefresh) > 0 && !postgres:
+		return invalid("AutoRefresh", "materialized views are only supported by postgres")
+	}
+
+	for name, interval := range c.AutoRefresh {
+		if interval <= 0 {
+			return invalid("AutoRefresh", fmt.Sprintf("interval of %s must be positive", name))
+		}
+	}
+
+	if c.Migration == nil && (c.MigrationDryRun || c.ForceDownMigrations || c.MigrationHook != nil || c.MigrationTable != "") {
+		trail.Warnf("store: migration options have no effect without WithMigration")
+	}
+
+	return nil
 }
 
 // Option A store configuration option
@@ -283,6 +1795,92 @@ func WithMigration(fs fs.ReadDirFS) Option {
 	}
 }
 
+// WithMigrationDryRun Do not apply migrations, see Store.PendingMigrations
+func WithMigrationDryRun() Option {
+	return func(conf *Config) {
+		conf.MigrationDryRun = true
+	}
+}
+
+// WithMigrationTable Record applied migrations in the table instead of the default (goose_db_version)
+func WithMigrationTable(name string) Option {
+	return func(conf *Config) {
+		conf.MigrationTable = name
+	}
+}
+
+// SetDefaultMigrationTable sets the migration table of stores without WithMigrationTable
+func SetDefaultMigrationTable(name string) {
+	migration.SetDefaultTable(name)
+}
+
+// RegisterEnum maps the values of T (e.g., iota constants) to the labels of a postgres enum type, in order
+// stores created afterwards add and edit T as its label and retrieve labels as T
+func RegisterEnum[T ~int](typeName string, labels []string) {
+	pg.RegisterEnum[T](typeName, labels)
+}
+
+// RegisterComposite maps the fields of the struct T to the attributes of a postgres composite type (e.g., for function arguments)
+// stores created afterwards pass T as the composite type and retrieve the composite type as T
+func RegisterComposite[T any](typeName string) {
+	pg.RegisterComposite[T](typeName)
+}
+
+// WithMigrationHook Call the hook around each migration (e.g., for distributed locks or audit logging)
+func WithMigrationHook(hook provider.MigrationHook) Option {
+	return func(conf *Config) {
+		conf.MigrationHook = hook
+	}
+}
+
+// WithForceDownMigrations Allow migrating down for non-local databases, see Store.MigrateTo
+func WithForceDownMigrations() Option {
+	return func(conf *Config) {
+		conf.ForceDownMigrations = true
+	}
+}
+
+// WithForceDelete Allow truncating tables of non-local databases, see Store.Truncate
+func WithForceDelete() Option {
+	return func(conf *Config) {
+		conf.ForceDelete = true
+	}
+}
+
+// WithHealthCheckInterval Run health checks periodically, see Store.IsHealthy
+func WithHealthCheckInterval(d time.Duration) Option {
+	return func(conf *Config) {
+		conf.HealthCheckInterval = d
+	}
+}
+
+// WithMaxReplicationLag Fail health checks when the lag of any replication slot exceeds d
+func WithMaxReplicationLag(d time.Duration) Option {
+	return func(conf *Config) {
+		conf.MaxReplicationLag = d
+	}
+}
+
+// WithAutoRefresh Refresh the materialized view concurrently in the background at the interval (pg only)
+// the view requires a unique index, failed refreshes are logged and retried at the next interval
+func WithAutoRefresh(name string, interval time.Duration) Option {
+	return func(conf *Config) {
+		if conf.AutoRefresh == nil {
+			conf.AutoRefresh = make(map[string]time.Duration)
+		}
+
+		conf.AutoRefresh[name] = interval
+	}
+}
+
+// WithExternalCache Cache query results in an external store (e.g., redis) instead of in-process
+// values expire after their query ttl even if the cache ignores ttls, as the in-process cache does
+func WithExternalCache(c Cache) Option {
+	return func(conf *Config) {
+		conf.Cache = c
+	}
+}
+
 // WithPg Use custom pg options
 func WithPg(opts ...pg.Option) Option {
 	return func(conf *Config) {
@@ -290,6 +1888,35 @@ func WithPg(opts ...pg.Option) Option {
 	}
 }
 
+// WithMySQL Use custom mysql options
+func WithMySQL(opts ...mysql.Option) Option {
+	return func(conf *Config) {
+		conf.MySQLOptions = opts
+	}
+}
+
+// WithSQLite Use custom sqlite options
+func WithSQLite(opts ...sqlite.Option) Option {
+	return func(conf *Config) {
+		conf.SQLiteOptions = opts
+	}
+}
+
+// WithInMemory Use an in-memory sqlite database
+func WithInMemory() Option {
+	return func(conf *Config) {
+		conf.Dialect = "sqlite"
+		conf.DSN = "file::memory:?cache=shared"
+	}
+}
+
+// WithDialect Use a database dialect (e.g., postgres, cockroachdb, mysql, sqlite)
+func WithDialect(dialect string) Option {
+	return func(conf *Config) {
+		conf.Dialect = dialect
+	}
+}
+
 // WithDSN Use dsn
 func WithDSN(dsn string) Option {
 	return func(conf *Config) {
@@ -297,9 +1924,127 @@ func WithDSN(dsn string) Option {
 	}
 }
 
+// WithSchema Scope the queries and migrations of the store to the postgres schema (e.g., for multi-tenant setups)
+func WithSchema(name string) Option {
+	return func(conf *Config) {
+		conf.Schema = name
+	}
+}
+
+// WithAuditLog Record each add, edit and remove in the audit table (pg only)
+// the table has the columns operation, table_name, old_data jsonb, new_data jsonb, changed_at and changed_by
+// writes outside of transactions are audited within one
+func WithAuditLog(table string) Option {
+	return func(conf *Config) {
+		conf.AuditTable = table
+	}
+}
+
+// WithConfig Use the configuration (e.g., from ConfigFromEnv), replacing that of previous options
+func WithConfig(c Config) Option {
+	return func(conf *Config) {
+		*conf = c
+	}
+}
+
+// WithSeedDir Load the fixtures of the directory with Seed
+func WithSeedDir(dir string) Option {
+	return func(conf *Config) {
+		conf.SeedDir = dir
+	}
+}
+
+// WithFeatureFlagTable Read and write feature flags in the table instead of the default (feature_flags)
+func WithFeatureFlagTable(name string) Option {
+	return func(conf *Config) {
+		conf.FeatureFlagTable = name
+	}
+}
+
+// WithQueryTimeout Limit the duration of each query, unless its context has a sooner deadline
+// queries exceeding it fail with context.DeadlineExceeded, which aborts the transaction, if any
+func WithQueryTimeout(d time.Duration) Option {
+	return func(conf *Config) {
+		conf.QueryTimeout = d
+	}
+}
+
+// WithSQLOpenFunc Open mysql and sqlite databases with a custom sql.Open (e.g., a tracing wrapper such as otelsql.Open)
+// postgres connects through a pgx pool rather than database/sql, see pg.WithQueryLogger and pg.WithMetrics for tracing
+func WithSQLOpenFunc(fn func(driverName, dsn string) (*sql.DB, error)) Option {
+	return func(conf *Config) {
+		conf.SQLOpenFunc = fn
+	}
+}
+
+// WithRetry Retry transactions failing with retryable errors (e.g., serialization failures)
+func WithRetry(maxAttempts int, backoff time.Duration) Option {
+	return func(conf *Config) {
+		conf.RetryAttempts = maxAttempts
+		conf.RetryBackoff = backoff
+	}
+}
+
+// WithDeadlockRetries Replay transactions aborted by a deadlock (pg.ErrDeadlock) up to n times, before any retries
+// the callback is run again from the start in a new transaction, after the backoff of WithRetry if configured
+// serialization failures (pg.ErrRetryable) are not replayed, only retried
+func WithDeadlockRetries(n int) Option {
+	return func(conf *Config) {
+		conf.DeadlockRetries = n
+	}
+}
+
+// WithSoftDelete Remove values by setting the timestamp column instead of deleting them
+// builder queries exclude rows where the column is set
+func WithSoftDelete(deletedAtColumn string) Option {
+	return func(conf *Config) {
+		conf.SoftDeleteColumn = deletedAtColumn
+	}
+}
+
+// WithPrimaryKeyTag Identify the primary key of values by the struct tag option instead of the default (pk)
+// e.g., WithPrimaryKeyTag("key") for fields tagged db:"id,key"
+func WithPrimaryKeyTag(option string) Option {
+	return func(conf *Config) {
+		conf.PrimaryKeyTag = option
+	}
+}
+
 // QueryConfig configuration for store queries
 type QueryConfig struct {
-	QueryTTL time.Duration
+	QueryTTL              time.Duration
+	IncludeDeleted        bool
+	VersionColumn         string
+	UnsafeFullTableDelete bool
+	Columns               []string
+	ExcludedColumns       []string
+	Returning             []string
+	ReturningDest         interface{}
+	CacheKey              string
+	Filters               []squirrel.Sqlizer
+}
+
+// cacheKey gets the key query results are cached under
+func (c QueryConfig) cacheKey(spec provider.Spec) interface{} {
+	if c.CacheKey != "" {
+		return c.CacheKey
+	}
+
+	return spec.Id()
+}
+
+// columns gets the write options limiting the columns written, if any
+func (c QueryConfig) columns() []provider.WriteOption {
+	var opts []provider.WriteOption
+	if len(c.Columns) > 0 {
+		opts = append(opts, provider.WithColumns(c.Columns...))
+	}
+
+	if len(c.ExcludedColumns) > 0 {
+		opts = append(opts, provider.WithExcludeColumns(c.ExcludedColumns...))
+	}
+
+	return opts
 }
 
 // QueryOption for customizing store queries
@@ -312,6 +2057,79 @@ func QueryTTL(duration time.Duration) QueryOption {
 	}
 }
 
+// WithCacheKey cache query results under the key (e.g., to invalidate them by name after writes)
+func WithCacheKey(key string) QueryOption {
+	return func(conf *QueryConfig) {
+		conf.CacheKey = key
+	}
+}
+
+// WithIncludeDeleted include soft deleted values in query results
+func WithIncludeDeleted() QueryOption {
+	return func(conf *QueryConfig) {
+		conf.IncludeDeleted = true
+	}
+}
+
+// WithUnsafeFullTableDelete allow deleting all values in the collection
+func WithUnsafeFullTableDelete() QueryOption {
+	return func(conf *QueryConfig) {
+		conf.UnsafeFullTableDelete = true
+	}
+}
+
+// WithColumns only add or edit the columns, even if other fields are set
+func WithColumns(cols ...string) QueryOption {
+	return func(conf *QueryConfig) {
+		conf.Columns = cols
+	}
+}
+
+// WithExcludeColumns never add or edit the columns, even if their fields are set
+func WithExcludeColumns(cols ...string) QueryOption {
+	return func(conf *QueryConfig) {
+		conf.ExcludedColumns = cols
+	}
+}
+
+// WithReturning decode the columns of added, edited or removed values into v (e.g., a generated id)
+// v may be a slice for edits and removals matching many values, not supported by mysql
+func WithReturning(v interface{}, cols ...string) QueryOption {
+	return func(conf *QueryConfig) {
+		conf.ReturningDest = v
+		conf.Returning = cols
+	}
+}
+
+// WithILike filter queries to values whose column matches the pattern, ignoring case (pg only)
+func WithILike(column, pattern string) QueryOption {
+	return func(conf *QueryConfig) {
+		conf.Filters = append(conf.Filters, squirrel.ILike{column: pattern})
+	}
+}
+
+// WithNotILike filter queries to values whose column does not match the pattern, ignoring case (pg only)
+func WithNotILike(column, pattern string) QueryOption {
+	return func(conf *QueryConfig) {
+		conf.Filters = append(conf.Filters, squirrel.NotILike{column: pattern})
+	}
+}
+
+// WithSimilarTo filter queries to values whose column matches the SQL regular expression (pg only)
+func WithSimilarTo(column, regex string) QueryOption {
+	return func(conf *QueryConfig) {
+		conf.Filters = append(conf.Filters, squirrel.Expr(fmt.Sprintf("%s SIMILAR TO ?", column), regex))
+	}
+}
+
+// WithOptimisticLock edit values only if the version column matches, incrementing it
+// provider.ErrVersionConflict is returned if no values match
+func WithOptimisticLock(versionColumn string) QueryOption {
+	return func(conf *QueryConfig) {
+		conf.VersionColumn = versionColumn
+	}
+}
+
 // begin create instance of a read/write database transaction
 func begin(ctx context.Context, store *Store, opts ...provider.TxOption) (Txn, error) {
 	if tx, ok := ctx.Value(contextKey{}).(Txn); ok {
@@ -325,16 +2143,37 @@ func begin(ctx context.Context, store *Store, opts ...provider.TxOption) (Txn, e
 		return Txn{}, trail.Stacktrace(err)
 	}
 
+	if err := setRLSUser(ctx, uow); err != nil {
+		uow.Rollback(ctx)
+		return Txn{}, trail.Stacktrace(err)
+	}
+
+	conf := provider.TxConfig{}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
 	tx := Txn{
-		uow:   uow,
-		store: store,
-		root:  true,
+		uow:      uow,
+		store:    store,
+		root:     true,
+		readOnly: conf.ReadOnly,
 	}
 
 	tx.ctx = context.WithValue(ctx, contextKey{}, tx)
 	return tx, nil
 }
 
+// backoff gets the exponential backoff with jitter for the attempt
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := base << (attempt - 1)
+	if d <= 0 {
+		return 0
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
 // hydrate Copies src value to destination
 func hydrate(dst, src interface{}) error {
 	dv := reflect.Indirect(reflect.ValueOf(dst))
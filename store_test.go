@@ -2,16 +2,27 @@ package store
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"testing/fstest"
 	"time"
 
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
 	"github.com/pghq/go-tea/trail"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/pghq/go-store/provider"
+	"github.com/pghq/go-store/provider/mysql"
+	"github.com/pghq/go-store/provider/pg"
 	"github.com/pghq/go-store/provider/pg/pgtest"
+	"github.com/pghq/go-store/provider/sqlite"
 )
 
 var (
@@ -30,7 +41,19 @@ func TestMain(m *testing.M) {
 
 	store, err = New(WithDSN(dsn), WithMigration(fstest.MapFS{
 		"migrations/00001_test.sql": &fstest.MapFile{
-			Data: []byte("-- +goose Up\nCREATE TABLE tests (id text primary key, name text, num int); \n create index idx_tests_name ON tests (name);"),
+			Data: []byte("-- +goose Up\nCREATE TABLE tests (id text primary key, name text, num int, deleted_at timestamptz, data jsonb); \n create index idx_tests_name ON tests (name);"),
+		},
+		"migrations/00002_books.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nCREATE TABLE authors (id text primary key, name text);\nCREATE TABLE books (id text primary key, author_id text references authors, title text);"),
+		},
+		"migrations/00003_orders.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nCREATE TABLE orders (id serial primary key, name text);\nCREATE TABLE order_items (id serial primary key, order_id int references orders, name text);"),
+		},
+		"migrations/00004_tokens.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nCREATE EXTENSION IF NOT EXISTS pgcrypto;\nCREATE TABLE tokens (id uuid primary key default gen_random_uuid(), name text);"),
+		},
+		"migrations/00005_audit_log.sql": &fstest.MapFile{
+			Data: []byte("-- +goose Up\nCREATE TABLE audit_log (id bigserial primary key, operation text, table_name text, old_data jsonb, new_data jsonb, changed_at timestamptz, changed_by text);"),
 		},
 	}))
 	if err != nil {
@@ -55,164 +78,2425 @@ func TestNew(t *testing.T) {
 		assert.NotNil(t, err)
 	})
 
+	t.Run("unsupported dialect", func(t *testing.T) {
+		_, err := New(WithDialect("oracle"))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("bad mysql dsn", func(t *testing.T) {
+		_, err := New(WithDialect("mysql"), WithDSN(dsn), WithMySQL(mysql.WithConnectTimeout(time.Second)))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("cockroachdb", func(t *testing.T) {
+		store, err := New(WithDialect("cockroachdb"), WithDSN(dsn))
+		assert.Nil(t, err)
+		assert.NotNil(t, store)
+	})
+
+	t.Run("in memory", func(t *testing.T) {
+		store, err := New(WithInMemory(), WithSQLite(sqlite.WithMaxConns(1)))
+		assert.Nil(t, err)
+		assert.NotNil(t, store)
+	})
+
+	t.Run("sql open func", func(t *testing.T) {
+		var opened []string
+		store, err := New(WithInMemory(), WithSQLOpenFunc(func(driverName, dsn string) (*sql.DB, error) {
+			opened = append(opened, driverName)
+			return sql.Open(driverName, dsn)
+		}))
+		assert.Nil(t, err)
+		assert.NotNil(t, store)
+		assert.Equal(t, []string{"sqlite"}, opened)
+	})
+
+	t.Run("bad schema", func(t *testing.T) {
+		_, err := New(WithDSN(dsn), WithSchema("tenant; DROP TABLE tests"))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("schema", func(t *testing.T) {
+		assert.Nil(t, store.ExecRaw(context.TODO(), "CREATE SCHEMA IF NOT EXISTS tenant_a; CREATE SCHEMA IF NOT EXISTS tenant_b"))
+		migrations := fstest.MapFS{
+			"migrations/00001_accounts.sql": &fstest.MapFile{
+				Data: []byte("-- +goose Up\nCREATE TABLE accounts (id text primary key, name text);"),
+			},
+		}
+
+		tenants := map[string]*Store{}
+		for _, schema := range []string{"tenant_a", "tenant_b"} {
+			s, err := New(WithDSN(dsn), WithSchema(schema), WithMigration(migrations))
+			assert.Nil(t, err)
+			assert.Nil(t, s.Add(context.TODO(), "accounts", map[string]interface{}{"id": "1", "name": schema}))
+			tenants[schema] = s
+		}
+
+		for schema, s := range tenants {
+			var names []string
+			assert.Nil(t, s.All(context.TODO(), spec("SELECT name FROM accounts"), &names))
+			assert.Equal(t, []string{schema}, names)
+		}
+
+		var exists bool
+		assert.Nil(t, store.One(context.TODO(), spec("SELECT to_regclass('accounts') IS NOT NULL"), &exists))
+		assert.False(t, exists)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		store, _ := New(WithDSN(dsn), WithMigration(nil), WithPg())
+		assert.NotNil(t, store)
+	})
+}
+
+func TestConfig_Validate(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	for field, conf := range map[string]Config{
+		"Dialect":             {Dialect: "oracle"},
+		"RetryAttempts":       {Dialect: "postgres", RetryAttempts: -1},
+		"RetryBackoff":        {Dialect: "postgres", RetryBackoff: -time.Second},
+		"DeadlockRetries":     {Dialect: "postgres", DeadlockRetries: -1},
+		"QueryTimeout":        {Dialect: "postgres", QueryTimeout: -time.Second},
+		"HealthCheckInterval": {Dialect: "postgres", HealthCheckInterval: -time.Second},
+		"MaxReplicationLag":   {Dialect: "postgres", MaxReplicationLag: -time.Second},
+		"Schema":              {Dialect: "mysql", Schema: "tenant"},
+		"AuditTable":          {Dialect: "sqlite", AuditTable: "audit_log"},
+		"AutoRefresh":         {Dialect: "postgres", AutoRefresh: map[string]time.Duration{"names": 0}},
+	} {
+		field, conf := field, conf
+		t.Run(field, func(t *testing.T) {
+			err := conf.Validate()
+			assert.True(t, trail.IsBadRequest(err))
+
+			var ce ConfigError
+			assert.True(t, trail.AsError(err, &ce))
+			assert.Equal(t, field, ce.Field)
+			assert.Contains(t, err.Error(), field)
+		})
+	}
+
+	t.Run("migration options without migrations", func(t *testing.T) {
+		assert.Nil(t, Config{Dialect: "postgres", MigrationDryRun: true, MigrationTable: "versions"}.Validate())
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		for _, conf := range []Config{
+			{Dialect: "postgres", Schema: "tenant", AuditTable: "audit_log", RetryAttempts: 3, QueryTimeout: time.Second},
+			{Dialect: "cockroachdb", Schema: "tenant"},
+			{Dialect: "postgres", AutoRefresh: map[string]time.Duration{"names": time.Minute}},
+			{Dialect: "mysql"},
+			{Dialect: "sqlite"},
+		} {
+			assert.Nil(t, conf.Validate())
+		}
+	})
+
+	t.Run("before connecting", func(t *testing.T) {
+		_, err := New(WithDSN("postgres://localhost:1/db"), WithQueryTimeout(-time.Second))
+		var ce ConfigError
+		assert.True(t, trail.AsError(err, &ce))
+		assert.Equal(t, "QueryTimeout", ce.Field)
+	})
+}
+
+func TestStore_PendingMigrations(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		s, err := New(WithDSN(dsn), WithMigrationDryRun(), WithMigration(fstest.MapFS{
+			"migrations/00001_test.sql": &fstest.MapFile{
+				Data: []byte("-- +goose Up\nCREATE TABLE tests (id text primary key);"),
+			},
+			"migrations/00002_pending.sql": &fstest.MapFile{
+				Data: []byte("-- +goose Up\nCREATE TABLE pending (id text primary key);\n-- +goose Down\nDROP TABLE pending;"),
+			},
+		}))
+		assert.Nil(t, err)
+
+		pending, err := s.PendingMigrations(context.TODO())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"CREATE TABLE pending (id text primary key);"}, pending)
+	})
+}
+
+func TestStore_SchemaDiff(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		s, err := New(WithDSN(dsn), WithMigrationDryRun(), WithMigrationTable("schema_diff_version"), WithMigration(fstest.MapFS{
+			"migrations/00001_test.sql": &fstest.MapFile{
+				Data: []byte("-- +goose Up\nCREATE TABLE tests (id text primary key);"),
+			},
+			"migrations/00002_diff.sql": &fstest.MapFile{
+				Data: []byte("-- +goose Up\nCREATE TABLE schema_diff (id text primary key);\n-- +goose Down\nDROP TABLE schema_diff;"),
+			},
+		}))
+		assert.Nil(t, err)
+
+		diff, err := s.SchemaDiff(context.TODO())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"CREATE TABLE schema_diff (id text primary key);"}, diff)
+	})
+}
+
+func TestStore_MigrationStatus(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		status, err := store.MigrationStatus(context.TODO())
+		assert.Nil(t, err)
+		assert.Len(t, status, 1)
+		assert.True(t, status[0].Applied)
+	})
+}
+
+func TestStore_InspectTable(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := store.InspectTable(context.TODO(), "public", "missing")
+		assert.True(t, errors.Is(err, ErrTableNotFound))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		info, err := store.InspectTable(context.TODO(), "public", "tests")
+		assert.Nil(t, err)
+		assert.Equal(t, "tests", info.Name)
+		assert.Len(t, info.Columns, 5)
+
+		id, _ := info.Column("id")
+		assert.Equal(t, "text", id.Type)
+		assert.False(t, id.Nullable)
+
+		num, _ := info.Column("num")
+		assert.Equal(t, "integer", num.Type)
+		assert.True(t, num.Nullable)
+		assert.Nil(t, num.Default)
+
+		_, present := info.Column("missing")
+		assert.False(t, present)
+		assert.Empty(t, info.References)
+	})
+
+	t.Run("references", func(t *testing.T) {
+		info, err := store.InspectTable(context.TODO(), "public", "books")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"authors"}, info.References)
+	})
+}
+
+func TestStore_InspectTables(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	tables, err := store.InspectTables(context.TODO(), "public")
+	assert.Nil(t, err)
+	assert.Contains(t, tables, "tests")
+}
+
+func TestStore_Do(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("bad context", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.TODO(), 1)
+		defer cancel()
+		assert.NotNil(t, store.Do(ctx, nil))
+	})
+
+	t.Run("bad callback response", func(t *testing.T) {
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return trail.NewError("")
+		}))
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		var v struct{ Id string }
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			_ = tx.Add("tests", map[string]interface{}{"id": "do.rollback:1234"})
+			if err := tx.One(spec("SELECT id FROM tests WHERE id = 'do.rollback:1234'"), &v); err != nil {
+				return err
+			}
+
+			return trail.NewError("rollback")
+		}))
+		assert.Equal(t, "do.rollback:1234", v.Id)
+		assert.NotNil(t, store.One(context.TODO(), spec("SELECT id FROM tests WHERE id = 'do.rollback:1234'"), &v))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return store.Do(tx.Context(), func(tx Txn) error {
+				return nil
+			})
+		}))
+	})
+}
+
+func TestContextWithTxn(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("no transaction", func(t *testing.T) {
+		_, ok := TxnFromContext(context.TODO())
+		assert.False(t, ok)
+	})
+
+	t.Run("unit of work", func(t *testing.T) {
+		add := func(ctx context.Context, id string) error {
+			return store.Do(ctx, func(tx Txn) error {
+				return tx.Add("tests", map[string]interface{}{"id": id})
+			})
+		}
+
+		count := func(ctx context.Context) (int, error) {
+			var v struct{ Count int }
+			err := store.Do(ctx, func(tx Txn) error {
+				return tx.One(spec("SELECT count(*) AS count FROM tests WHERE id LIKE 'uow:%'"), &v)
+			})
+			return v.Count, err
+		}
+
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			ctx := ContextWithTxn(context.TODO(), tx)
+			joined, ok := TxnFromContext(ctx)
+			assert.True(t, ok)
+			assert.Equal(t, tx.uow, joined.uow)
+
+			assert.Nil(t, add(ctx, "uow:1"))
+			assert.Nil(t, add(ctx, "uow:2"))
+			n, err := count(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, 2, n)
+
+			n, err = count(context.TODO())
+			assert.Nil(t, err)
+			assert.Equal(t, 0, n)
+			return trail.NewError("rollback")
+		}))
+
+		n, err := count(context.TODO())
+		assert.Nil(t, err)
+		assert.Equal(t, 0, n)
+	})
+}
+
+func TestStore_Retry(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("retries retryable errors", func(t *testing.T) {
+		db := &retryProvider{}
+		s := NewStore(db)
+		WithRetry(3, time.Millisecond)(&s.conf)
+		attempts := 0
+		assert.Nil(t, s.Do(context.TODO(), func(tx Txn) error {
+			attempts += 1
+			if attempts < 3 {
+				return pg.ErrRetryable
+			}
+
+			return nil
+		}))
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, 3, db.begins)
+		assert.Equal(t, 1, db.commits)
+	})
+
+	t.Run("max attempts", func(t *testing.T) {
+		db := &retryProvider{}
+		s := NewStore(db)
+		WithRetry(2, time.Millisecond)(&s.conf)
+		err := s.Do(context.TODO(), func(tx Txn) error {
+			return pg.ErrRetryable
+		})
+		assert.True(t, errors.Is(err, pg.ErrRetryable))
+		assert.Equal(t, 2, db.begins)
+	})
+
+	t.Run("non retryable", func(t *testing.T) {
+		db := &retryProvider{}
+		s := NewStore(db)
+		WithRetry(3, time.Millisecond)(&s.conf)
+		assert.NotNil(t, s.Do(context.TODO(), func(tx Txn) error {
+			return trail.NewError("an error has occurred")
+		}))
+		assert.Equal(t, 1, db.begins)
+	})
+
+	t.Run("bad context", func(t *testing.T) {
+		db := &retryProvider{}
+		s := NewStore(db)
+		WithRetry(3, time.Hour)(&s.conf)
+		ctx, cancel := context.WithTimeout(context.TODO(), time.Millisecond)
+		defer cancel()
+		assert.NotNil(t, s.Do(ctx, func(tx Txn) error {
+			return pg.ErrRetryable
+		}))
+		assert.Equal(t, 1, db.begins)
+	})
+
+	t.Run("replays deadlocks", func(t *testing.T) {
+		db := &retryProvider{}
+		s := NewStore(db)
+		WithRetry(2, time.Millisecond)(&s.conf)
+		WithDeadlockRetries(2)(&s.conf)
+		attempts := 0
+		assert.Nil(t, s.Do(context.TODO(), func(tx Txn) error {
+			attempts += 1
+			if attempts < 3 {
+				return pg.ErrDeadlock
+			}
+
+			return nil
+		}))
+		assert.Equal(t, 3, db.begins)
+		assert.Equal(t, 1, db.commits)
+	})
+
+	t.Run("max replays", func(t *testing.T) {
+		db := &retryProvider{}
+		s := NewStore(db)
+		WithDeadlockRetries(2)(&s.conf)
+		err := s.Do(context.TODO(), func(tx Txn) error {
+			return pg.ErrDeadlock
+		})
+		assert.True(t, errors.Is(err, pg.ErrDeadlock))
+		assert.Equal(t, 3, db.begins)
+	})
+
+	t.Run("does not replay serialization failures", func(t *testing.T) {
+		db := &retryProvider{}
+		s := NewStore(db)
+		WithDeadlockRetries(2)(&s.conf)
+		err := s.Do(context.TODO(), func(tx Txn) error {
+			return pg.ErrRetryable
+		})
+		assert.True(t, errors.Is(err, pg.ErrRetryable))
+		assert.Equal(t, 1, db.begins)
+	})
+
+	t.Run("replays with backoff", func(t *testing.T) {
+		db := &retryProvider{}
+		s := NewStore(db)
+		WithRetry(1, time.Hour)(&s.conf)
+		WithDeadlockRetries(2)(&s.conf)
+		ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+		defer cancel()
+		assert.NotNil(t, s.Do(ctx, func(tx Txn) error {
+			return pg.ErrDeadlock
+		}))
+		assert.Equal(t, 1, db.begins)
+	})
+
+	t.Run("retries deadlocks", func(t *testing.T) {
+		db := &retryProvider{}
+		s := NewStore(db)
+		WithRetry(2, time.Millisecond)(&s.conf)
+		err := s.Do(context.TODO(), func(tx Txn) error {
+			return pg.ErrDeadlock
+		})
+		assert.True(t, errors.Is(err, pg.ErrDeadlock))
+		assert.Equal(t, 2, db.begins)
+	})
+
+	t.Run("deadlock", func(t *testing.T) {
+		s := NewStore(store.db)
+		WithDeadlockRetries(1)(&s.conf)
+		_ = s.Add(context.TODO(), "tests", map[string]interface{}{"id": "deadlock:a"})
+		_ = s.Add(context.TODO(), "tests", map[string]interface{}{"id": "deadlock:b"})
+
+		var ready sync.WaitGroup
+		ready.Add(2)
+		edit := func(first, second string) error {
+			attempts := 0
+			return s.Do(context.TODO(), func(tx Txn) error {
+				attempts += 1
+				if err := tx.Edit("tests", spec(fmt.Sprintf("id = '%s'", first)), map[string]interface{}{"name": first}); err != nil {
+					return err
+				}
+
+				if attempts == 1 {
+					// each transaction waits for the other to lock its first row
+					ready.Done()
+					ready.Wait()
+				}
+
+				return tx.Edit("tests", spec(fmt.Sprintf("id = '%s'", second)), map[string]interface{}{"name": first})
+			})
+		}
+
+		errs := make(chan error, 2)
+		go func() { errs <- edit("deadlock:a", "deadlock:b") }()
+		go func() { errs <- edit("deadlock:b", "deadlock:a") }()
+		assert.Nil(t, <-errs)
+		assert.Nil(t, <-errs)
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		assert.Equal(t, time.Duration(0), backoff(0, 1))
+		for attempt := 1; attempt < 4; attempt++ {
+			d := backoff(time.Second, attempt)
+			assert.True(t, d >= time.Second<<(attempt-1)/2 && d <= time.Second<<(attempt-1))
+		}
+	})
+}
+
+// retryProvider a provider counting transactions for tests
+type retryProvider struct {
+	provider.Provider
+	provider.UnitOfWork
+	begins  int
+	commits int
+}
+
+func (p *retryProvider) Begin(_ context.Context, _ ...provider.TxOption) (provider.UnitOfWork, error) {
+	p.begins += 1
+	return p, nil
+}
+
+func (p *retryProvider) Commit(_ context.Context) error {
+	p.commits += 1
+	return nil
+}
+
+func (p *retryProvider) Rollback(_ context.Context) {}
+
+func (p *retryProvider) Repository() provider.Repository {
+	return nil
+}
+
+func TestStore_HealthCheck(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("healthy", func(t *testing.T) {
+		s := NewStore(store.db)
+		assert.Nil(t, s.HealthCheck(context.TODO()))
+		assert.True(t, s.IsHealthy())
+	})
+
+	t.Run("reconnects", func(t *testing.T) {
+		db := &healthProvider{failures: 1}
+		s := NewStore(db)
+		assert.Nil(t, s.HealthCheck(context.TODO()))
+		assert.True(t, s.IsHealthy())
+		assert.Equal(t, int32(2), db.begins)
+	})
+
+	t.Run("unhealthy", func(t *testing.T) {
+		db := &healthProvider{failures: 2}
+		s := NewStore(db)
+		assert.NotNil(t, s.HealthCheck(context.TODO()))
+		assert.False(t, s.IsHealthy())
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		db := &healthProvider{failures: 10}
+		s := NewStore(db)
+		go s.monitor(time.Millisecond)
+		assert.Eventually(t, func() bool { return !s.IsHealthy() }, time.Second, time.Millisecond)
+		assert.Eventually(t, s.IsHealthy, time.Second, time.Millisecond)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		s := NewStore(&healthProvider{})
+		stopped := make(chan struct{})
+		go func() {
+			s.monitor(time.Millisecond)
+			close(stopped)
+		}()
+
+		s.Close()
+		s.Close()
+		assert.Eventually(t, func() bool {
+			select {
+			case <-stopped:
+				return true
+			default:
+				return false
+			}
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("replication lag", func(t *testing.T) {
+		db := &lagProvider{lag: map[string]time.Duration{"replica_a": time.Second, "replica_b": time.Minute}}
+		s := NewStore(db)
+		s.conf.MaxReplicationLag = 2 * time.Minute
+		assert.Nil(t, s.HealthCheck(context.TODO()))
+		assert.True(t, s.IsHealthy())
+
+		s.conf.MaxReplicationLag = 30 * time.Second
+		assert.True(t, errors.Is(s.HealthCheck(context.TODO()), ErrReplicationLag))
+		assert.False(t, s.IsHealthy())
+	})
+
+	t.Run("replication lag unavailable", func(t *testing.T) {
+		s := NewStore(&lagProvider{err: trail.NewError("permission denied")})
+		s.conf.MaxReplicationLag = time.Second
+		assert.NotNil(t, s.HealthCheck(context.TODO()))
+		assert.False(t, s.IsHealthy())
+	})
+}
+
+func TestStore_ReplicationLag(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("not supported", func(t *testing.T) {
+		_, err := NewStore(&healthProvider{}).ReplicationLag(context.TODO())
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		want := map[string]time.Duration{"replica_a": time.Second, "replica_b": 0}
+		lag, err := NewStore(&lagProvider{lag: want}).ReplicationLag(context.TODO())
+		assert.Nil(t, err)
+		assert.Equal(t, want, lag)
+	})
+
+	t.Run("pg", func(t *testing.T) {
+		lag, err := store.ReplicationLag(context.TODO())
+		assert.Nil(t, err)
+		assert.NotNil(t, lag)
+	})
+}
+
+// lagProvider a healthy provider with replication slots for tests
+type lagProvider struct {
+	healthProvider
+	lag map[string]time.Duration
+	err error
+}
+
+func (p *lagProvider) ReplicationLag(_ context.Context) (map[string]time.Duration, error) {
+	return p.lag, p.err
+}
+
+// healthProvider a provider failing to begin transactions for tests
+type healthProvider struct {
+	provider.Provider
+	failures int32
+	begins   int32
+}
+
+func (p *healthProvider) Begin(_ context.Context, _ ...provider.TxOption) (provider.UnitOfWork, error) {
+	if atomic.AddInt32(&p.begins, 1) <= p.failures {
+		return nil, trail.NewError("connection refused")
+	}
+
+	return healthUnitOfWork{}, nil
+}
+
+// healthUnitOfWork a unit of work with a repository for health checks
+type healthUnitOfWork struct {
+	provider.UnitOfWork
+}
+
+func (uow healthUnitOfWork) Rollback(_ context.Context) {}
+
+func (uow healthUnitOfWork) Repository() provider.Repository {
+	return healthRepository{}
+}
+
+// healthRepository a repository returning a single row
+type healthRepository struct {
+	provider.Repository
+}
+
+func (r healthRepository) One(_ context.Context, _ provider.Spec, _ interface{}) error {
+	return nil
+}
+
+func TestTxn_Savepoint(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("closed transaction", func(t *testing.T) {
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			_ = tx.uow.Commit(tx.Context())
+			return tx.Savepoint("sp1", func(tx Txn) error { return nil })
+		}))
+	})
+
+	t.Run("partial rollback", func(t *testing.T) {
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			_ = tx.Add("tests", map[string]interface{}{"id": "savepoint:1"})
+			return tx.Savepoint("sp1", func(tx Txn) error {
+				assert.Equal(t, 1, tx.Depth())
+				_ = tx.Add("tests", map[string]interface{}{"id": "savepoint:2"})
+				err := tx.Savepoint("sp2", func(tx Txn) error {
+					_ = tx.Add("tests", map[string]interface{}{"id": "savepoint:3"})
+					err := tx.Savepoint("sp3", func(tx Txn) error {
+						assert.Equal(t, 3, tx.Depth())
+						_ = tx.Add("tests", map[string]interface{}{"id": "savepoint:4"})
+						return nil
+					})
+					assert.Nil(t, err)
+					return trail.NewError("rollback")
+				})
+				assert.NotNil(t, err)
+				return nil
+			})
+		}))
+
+		var ids []string
+		assert.Nil(t, store.All(context.TODO(), spec("SELECT id FROM tests WHERE id LIKE 'savepoint:%' ORDER BY id"), &ids))
+		assert.Equal(t, []string{"savepoint:1", "savepoint:2"}, ids)
+	})
+}
+
+func TestTxn_OneOrAdd(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("bad query", func(t *testing.T) {
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			_, err := tx.OneOrAdd("missing", &map[string]interface{}{}, map[string]interface{}{"id": "one-or-add:bad"})
+			return err
+		}))
+	})
+
+	t.Run("existing", func(t *testing.T) {
+		_ = store.Add(context.TODO(), "tests", map[string]interface{}{"id": "one-or-add:existing", "num": 1})
+
+		var v map[string]interface{}
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			created, err := tx.OneOrAdd("tests", &v, map[string]interface{}{"id": "one-or-add:existing"})
+			assert.False(t, created)
+			return err
+		}))
+		assert.Equal(t, int32(1), v["num"])
+	})
+
+	t.Run("created", func(t *testing.T) {
+		var v map[string]interface{}
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			created, err := tx.OneOrAdd("tests", &v, map[string]interface{}{"id": "one-or-add:created", "num": 2})
+			assert.True(t, created)
+			return err
+		}))
+		assert.Equal(t, "one-or-add:created", v["id"])
+		assert.Equal(t, int32(2), v["num"])
+	})
+
+	t.Run("added concurrently", func(t *testing.T) {
+		values := map[string]interface{}{"id": "one-or-add:concurrent"}
+		tx, err := store.Begin(context.TODO())
+		assert.Nil(t, err)
+
+		created, err := tx.OneOrAdd("tests", &map[string]interface{}{}, values)
+		assert.Nil(t, err)
+		assert.True(t, created)
+
+		done := make(chan error)
+		go func() {
+			done <- store.Do(context.TODO(), func(tx Txn) error {
+				// blocks adding the value until the first transaction commits
+				created, err := tx.OneOrAdd("tests", &map[string]interface{}{}, values)
+				assert.False(t, created)
+				return err
+			})
+		}()
+
+		time.Sleep(100 * time.Millisecond)
+		assert.Nil(t, tx.commit())
+		assert.Nil(t, <-done)
+	})
+}
+
+func TestTxn_Add(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.Add("tests", map[string]interface{}{"id": "1234"})
+		}))
+	})
+
+	t.Run("returning", func(t *testing.T) {
+		var id string
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.Add("tests", map[string]interface{}{"id": "add:1234"}, WithReturning(&id, "id"))
+		}))
+		assert.Equal(t, "add:1234", id)
+	})
+
+	t.Run("returning generated", func(t *testing.T) {
+		var id string
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.Add("tokens", map[string]interface{}{"name": "add"}, WithReturning(&id, "id"))
+		}))
+		assert.NotEmpty(t, id)
+	})
+
+	t.Run("only columns", func(t *testing.T) {
+		logger := queryRecorder{}
+		s, err := New(WithDSN(dsn), WithPg(pg.WithQueryLogger(&logger, 0)))
+		assert.Nil(t, err)
+
+		v := columnsValue{Id: "add:columns", Name: "foo", Num: 1, DeletedAt: time.Now(), Data: `{"foo": 1}`}
+		assert.Nil(t, s.Add(context.TODO(), "tests", v, WithColumns("id", "name")))
+		assert.Equal(t, []string{"INSERT INTO tests (id,name) VALUES ($1,$2)"}, logger.queries())
+
+		var values []map[string]interface{}
+		assert.Nil(t, store.All(context.TODO(), spec("SELECT id, name, num, deleted_at, data FROM tests WHERE id = 'add:columns'"), &values))
+		assert.Equal(t, []map[string]interface{}{{"id": "add:columns", "name": "foo", "num": nil, "deleted_at": nil, "data": nil}}, values)
+	})
+
+	t.Run("excluded columns", func(t *testing.T) {
+		logger := queryRecorder{}
+		s, err := New(WithDSN(dsn), WithPg(pg.WithQueryLogger(&logger, 0)))
+		assert.Nil(t, err)
+
+		v := columnsValue{Id: "add:excluded", Name: "foo", Num: 1, DeletedAt: time.Now(), Data: `{"foo": 1}`}
+		assert.Nil(t, s.Add(context.TODO(), "tests", v, WithExcludeColumns("deleted_at", "data")))
+		assert.Equal(t, []string{"INSERT INTO tests (id,name,num) VALUES ($1,$2,$3)"}, logger.queries())
+	})
+}
+
+// columnsValue a value setting every column of the tests table
+type columnsValue struct {
+	Id        string    `db:"id"`
+	Name      string    `db:"name"`
+	Num       int       `db:"num"`
+	DeletedAt time.Time `db:"deleted_at"`
+	Data      string    `db:"data"`
+}
+
+// queryRecorder a query logger recording the sql of queries for tests
+type queryRecorder struct {
+	mu    sync.Mutex
+	stmts []string
+}
+
+func (r *queryRecorder) LogQuery(_ context.Context, sql string, _ []interface{}, _ time.Duration, _ error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stmts = append(r.stmts, sql)
+}
+
+func (r *queryRecorder) queries() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.stmts...)
+}
+
+func TestTxn_Edit(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	_ = store.Do(context.TODO(), func(tx Txn) error {
+		return tx.Add("tests", map[string]interface{}{"id": "edit:1234"})
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.Edit("tests", spec("id = 'edit:1234'"), map[string]interface{}{"id": "edit:1234"})
+		}))
+	})
+
+	t.Run("full table", func(t *testing.T) {
+		err := store.Edit(context.TODO(), "tests", spec(""), map[string]interface{}{"name": "foo"})
+		assert.True(t, errors.Is(err, ErrFullTableUpdate))
+	})
+
+	t.Run("builder condition", func(t *testing.T) {
+		query := provider.NewBuilder().Select("id").From("tests").Where("id = ?", "edit:1234")
+		err := store.Edit(context.TODO(), "tests", query, map[string]interface{}{"name": "foo"})
+		assert.True(t, errors.Is(err, ErrBuilderCondition))
+	})
+
+	t.Run("returning", func(t *testing.T) {
+		var ids []string
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			if err := tx.Add("tokens", map[string]interface{}{"name": "edit"}); err != nil {
+				return err
+			}
+
+			return tx.Edit("tokens", spec("name = 'edit'"), map[string]interface{}{"name": "edited"}, WithReturning(&ids, "id"))
+		}))
+		assert.Len(t, ids, 1)
+		assert.NotEmpty(t, ids[0])
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		err := store.Edit(context.TODO(), "tests", spec("id = 'edit:missing'"), map[string]interface{}{"name": "foo"})
+		assert.True(t, trail.IsNotFound(err))
+	})
+
+	t.Run("columns", func(t *testing.T) {
+		type value struct {
+			Id   string `db:"id,readonly"`
+			Name string `db:"name"`
+			Num  int    `db:"num"`
+		}
+
+		_ = store.Add(context.TODO(), "tests", map[string]interface{}{"id": "edit:columns", "num": 1})
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.Edit("tests", spec("id = 'edit:columns'"), value{Id: "edit:other", Name: "foo", Num: 2}, WithColumns("name"))
+		}))
+
+		var v value
+		assert.Nil(t, store.One(context.TODO(), spec("SELECT id, name, num FROM tests WHERE id = 'edit:columns'"), &v))
+		assert.Equal(t, value{Id: "edit:columns", Name: "foo", Num: 1}, v)
+	})
+
+	t.Run("excluded columns", func(t *testing.T) {
+		logger := queryRecorder{}
+		s, err := New(WithDSN(dsn), WithPg(pg.WithQueryLogger(&logger, 0)))
+		assert.Nil(t, err)
+
+		_ = store.Add(context.TODO(), "tests", map[string]interface{}{"id": "edit:excluded", "num": 1})
+		v := columnsValue{Id: "edit:excluded", Name: "foo", Num: 2, DeletedAt: time.Now(), Data: `{"foo": 1}`}
+		assert.Nil(t, s.Edit(context.TODO(), "tests", spec("id = 'edit:excluded'"), v, WithExcludeColumns("id", "num", "deleted_at", "data")))
+		assert.Equal(t, []string{"UPDATE tests SET name = $1 WHERE id = ?"}, logger.queries())
+	})
+
+	t.Run("concurrent version conflict", func(t *testing.T) {
+		_ = store.Add(context.TODO(), "tests", map[string]interface{}{"id": "edit:version", "num": 1})
+		errs := make(chan error, 2)
+		for _, name := range []string{"first", "second"} {
+			go func(name string) {
+				errs <- store.Do(context.TODO(), func(tx Txn) error {
+					return tx.Edit("tests", spec("id = 'edit:version'"), map[string]interface{}{"name": name, "num": 1}, WithOptimisticLock("num"))
+				})
+			}(name)
+		}
+
+		var conflicts int
+		for i := 0; i < 2; i++ {
+			if err := <-errs; errors.Is(err, provider.ErrVersionConflict) {
+				conflicts += 1
+			}
+		}
+
+		assert.Equal(t, 1, conflicts)
+	})
+}
+
+func TestTxn_Upsert(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			type value struct {
+				Id   string `db:"id"`
+				Name string `db:"name,omitempty"`
+				Num  int    `db:"num"`
+			}
+
+			if err := tx.Upsert("tests", value{Id: "upsert:1234", Name: "foo"}, []string{"id"}); err != nil {
+				return err
+			}
+
+			return tx.Upsert("tests", value{Id: "upsert:1234", Num: 1}, []string{"id"})
+		}))
+	})
+}
+
+func TestTxn_Merge(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("not supported", func(t *testing.T) {
+		s, err := New(WithInMemory())
+		assert.Nil(t, err)
+		assert.NotNil(t, s.Merge(context.TODO(), "tests", map[string]interface{}{"id": "merge:1"}, []string{"id"}, nil, nil))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		type value struct {
+			Id   string `db:"id"`
+			Name string `db:"name"`
+		}
+
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			if err := tx.Merge("tests", value{Id: "merge:1", Name: "foo"}, []string{"id"}, map[string]interface{}{"num": 42}, nil); err != nil {
+				return err
+			}
+
+			return tx.Merge("tests", value{Id: "merge:1", Name: "bar"}, []string{"id"}, map[string]interface{}{"num": 1}, nil)
+		}))
+
+		var v struct {
+			Name string `db:"name"`
+			Num  int    `db:"num"`
+		}
+		assert.Nil(t, store.One(context.TODO(), spec("SELECT name, num FROM tests WHERE id = 'merge:1'"), &v))
+		assert.Equal(t, "bar", v.Name)
+		assert.Equal(t, 42, v.Num)
+	})
+}
+
+func TestTxn_AddBatch(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("not supported", func(t *testing.T) {
+		s, err := New(WithInMemory())
+		assert.Nil(t, err)
+		assert.NotNil(t, s.AddBatch(context.TODO(), "tests", []interface{}{map[string]interface{}{"id": "batch:1"}}))
+	})
+
+	t.Run("bad values", func(t *testing.T) {
+		assert.NotNil(t, store.AddBatch(context.TODO(), "tests", []interface{}{map[string]interface{}{"id": "batch:1"}, "batch:2"}))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		type value struct {
+			Id   string `db:"id"`
+			Name string `db:"name"`
+		}
+
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.AddBatch("tests", []interface{}{value{Id: "batch:1", Name: "foo"}, value{Id: "batch:2", Name: "bar"}})
+		}))
+
+		var n int
+		assert.Nil(t, store.One(context.TODO(), spec("SELECT count(*) FROM tests WHERE id LIKE 'batch:%'"), &n))
+		assert.Equal(t, 2, n)
+	})
+}
+
+func TestTxn_Remove(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	_ = store.Do(context.TODO(), func(tx Txn) error {
+		return tx.Add("tests", map[string]interface{}{"id": "remove:1234"})
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.Remove("tests", spec("id = 'remove:1234'"))
+		}))
+	})
+
+	t.Run("returning", func(t *testing.T) {
+		var name string
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			var id string
+			if err := tx.Add("tokens", map[string]interface{}{"name": "remove"}, WithReturning(&id, "id")); err != nil {
+				return err
+			}
+
+			return tx.Remove("tokens", spec(fmt.Sprintf("id = '%s'", id)), WithReturning(&name, "name"))
+		}))
+		assert.Equal(t, "remove", name)
+	})
+
+	t.Run("full table", func(t *testing.T) {
+		assert.True(t, errors.Is(store.Remove(context.TODO(), "tests", nil), ErrFullTableDelete))
+		assert.True(t, errors.Is(store.Remove(context.TODO(), "tests", spec("")), ErrFullTableDelete))
+		assert.True(t, errors.Is(store.Remove(context.TODO(), "tests", provider.NewSpec("all", squirrel.Eq{})), ErrFullTableDelete))
+	})
+
+	t.Run("builder condition", func(t *testing.T) {
+		query := provider.NewBuilder().Select("id").From("tests").Where("id = ?", "remove:1234")
+		assert.True(t, errors.Is(store.Remove(context.TODO(), "tests", query), ErrBuilderCondition))
+	})
+
+	t.Run("unsafe full table", func(t *testing.T) {
+		db := &removeProvider{}
+		s := NewStore(db)
+		assert.Nil(t, s.Remove(context.TODO(), "tests", nil, WithUnsafeFullTableDelete()))
+		assert.Equal(t, "1=1", db.repo.where)
+	})
+
+	t.Run("soft delete", func(t *testing.T) {
+		db := &removeProvider{}
+		s := NewStore(db)
+		WithSoftDelete("deleted_at")(&s.conf)
+		assert.NotNil(t, s.Remove(context.TODO(), "tests", nil))
+		assert.Nil(t, s.Remove(context.TODO(), "tests", spec("id = 'remove:1234'")))
+		assert.Equal(t, "id = 'remove:1234'", db.repo.where)
+		assert.True(t, db.repo.edited)
+	})
+}
+
+// removeProvider a provider recording deletes for tests
+type removeProvider struct {
+	provider.Provider
+	repo removeRepository
+}
+
+func (p *removeProvider) Repository() provider.Repository {
+	return &p.repo
+}
+
+// removeRepository a repository recording deletes for tests
+type removeRepository struct {
+	provider.Repository
+	where  string
+	edited bool
+}
+
+func (r *removeRepository) Edit(_ context.Context, _ string, spec provider.Spec, _ interface{}, _ ...provider.WriteOption) error {
+	r.edited = true
+	r.where, _, _ = spec.ToSql()
+	return nil
+}
+
+func (r *removeRepository) Remove(_ context.Context, _ string, spec provider.Spec, _ ...provider.WriteOption) error {
+	r.where, _, _ = spec.ToSql()
+	return nil
+}
+
+func TestStore_SoftDelete(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	s := NewStore(store.db)
+	WithSoftDelete("deleted_at")(&s.conf)
+	query := provider.NewBuilder().Select("id").From("tests").Where("id = ?", "soft:1234")
+
+	t.Run("soft delete", func(t *testing.T) {
+		assert.Nil(t, s.Add(context.TODO(), "tests", map[string]interface{}{"id": "soft:1234"}))
+		assert.Nil(t, s.Remove(context.TODO(), "tests", spec("id = 'soft:1234'")))
+
+		var ids []string
+		assert.Nil(t, s.All(context.TODO(), query, &ids))
+		assert.Empty(t, ids)
+
+		assert.Nil(t, s.All(context.TODO(), query, &ids, WithIncludeDeleted()))
+		assert.Equal(t, []string{"soft:1234"}, ids)
+	})
+
+	t.Run("hard delete", func(t *testing.T) {
+		assert.Nil(t, store.Add(context.TODO(), "tests", map[string]interface{}{"id": "hard:1234"}))
+		assert.Nil(t, store.Remove(context.TODO(), "tests", spec("id = 'hard:1234'")))
+
+		var ids []string
+		assert.Nil(t, s.All(context.TODO(), provider.NewBuilder().Select("id").From("tests").Where("id = ?", "hard:1234"), &ids, WithIncludeDeleted()))
+		assert.Empty(t, ids)
+	})
+}
+
+func TestStore_ILike(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	assert.Nil(t, store.ExecRaw(context.TODO(), "CREATE TABLE emails (email text primary key)"))
+	for _, email := range []string{"foo@example.com", "BAR@EXAMPLE.COM", "baz@example.org"} {
+		assert.Nil(t, store.Add(context.TODO(), "emails", map[string]interface{}{"email": email}))
+	}
+
+	query := func() *provider.Builder {
+		return provider.NewBuilder().Select("email").From("emails").OrderBy("email", false)
+	}
+
+	t.Run("ilike", func(t *testing.T) {
+		var emails []string
+		assert.Nil(t, store.All(context.TODO(), query(), &emails, WithILike("email", "%@example.com")))
+		assert.Equal(t, []string{"BAR@EXAMPLE.COM", "foo@example.com"}, emails)
+	})
+
+	t.Run("not ilike", func(t *testing.T) {
+		var emails []string
+		assert.Nil(t, store.All(context.TODO(), query(), &emails, WithNotILike("email", "%@EXAMPLE.com")))
+		assert.Equal(t, []string{"baz@example.org"}, emails)
+	})
+
+	t.Run("similar to", func(t *testing.T) {
+		var emails []string
+		assert.Nil(t, store.All(context.TODO(), query(), &emails, WithSimilarTo("email", "(foo|baz)@%")))
+		assert.Equal(t, []string{"baz@example.org", "foo@example.com"}, emails)
+	})
+}
+
+func TestStore_Errors(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("not found", func(t *testing.T) {
+		var v struct{ Id string }
+		err := store.One(context.TODO(), spec("SELECT id FROM tests WHERE id = 'errors:missing'"), &v)
+		assert.True(t, trail.IsNotFound(err))
+		assert.True(t, trail.IsError(err, ErrNotFound))
+
+		var nf *NotFoundError
+		assert.True(t, trail.AsError(err, &nf))
+		assert.Equal(t, "tests", nf.Table)
+		assert.Equal(t, "SELECT id FROM tests WHERE id = ?", nf.Query)
+		assert.False(t, nf.Timestamp.IsZero())
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		assert.Nil(t, store.Add(context.TODO(), "tests", map[string]interface{}{"id": "errors:conflict"}))
+		err := store.Add(context.TODO(), "tests", map[string]interface{}{"id": "errors:conflict"})
+		assert.True(t, trail.IsConflict(err))
+		assert.True(t, trail.IsError(err, ErrConflict))
+
+		var c *ConflictError
+		assert.True(t, trail.AsError(err, &c))
+		assert.Equal(t, "tests", c.Table)
+		assert.Equal(t, "INSERT INTO tests (id) VALUES ($1)", c.Query)
+	})
+
+	t.Run("no results", func(t *testing.T) {
+		var v []struct{ Id string }
+		err := store.AllByIds(context.TODO(), "tests", "id", []string{}, &v)
+		assert.True(t, trail.IsError(err, ErrNoResults))
+
+		var nr *NoResultsError
+		assert.True(t, trail.AsError(err, &nr))
+		assert.Equal(t, "tests", nr.Table)
+	})
+}
+
+func TestTxn_One(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	_ = store.Do(context.TODO(), func(tx Txn) error {
+		return tx.Add("tests", map[string]interface{}{"id": "one:1234"})
+	})
+
+	t.Run("bad query", func(t *testing.T) {
+		var v map[string]interface{}
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.One(spec("= '1234'"), &v)
+		}))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		var v struct{ Id string }
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.One(spec("SELECT id FROM tests WHERE id = 'one:1234'"), &v)
+		}))
+		assert.Equal(t, "one:1234", v.Id)
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		t.Run("bad cache value", func(t *testing.T) {
+			var v struct{ Id string }
+			assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+				_ = tx.One(spec("SELECT id FROM tests WHERE id = 'one:1234'"), &v, QueryTTL(time.Minute))
+				wait(tx.store)
+				return tx.One(spec("SELECT id FROM tests WHERE id = 'one:1234'"), func() {}, QueryTTL(time.Minute))
+			}))
+		})
+
+		t.Run("ok", func(t *testing.T) {
+			var v struct{ Id string }
+			assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+				_ = tx.One(spec("SELECT id FROM tests WHERE id = 'one:1234'"), &v, QueryTTL(time.Minute))
+				wait(tx.store)
+				return tx.One(spec("SELECT id FROM tests WHERE id = 'one:1234'"), &v, QueryTTL(time.Minute))
+			}))
+			assert.Equal(t, "one:1234", v.Id)
+		})
+	})
+}
+
+func TestTxn_All(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	_ = store.Do(context.TODO(), func(tx Txn) error {
+		return tx.Add("tests", map[string]interface{}{"id": "all:1234"})
+	})
+
+	t.Run("bad query", func(t *testing.T) {
+		var v []map[string]interface{}
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.All(spec("= '1234'"), &v)
+		}))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		var v []struct{ Id string }
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.All(spec("SELECT id FROM tests WHERE id = 'all:1234'"), &v)
+		}))
+		assert.Equal(t, "all:1234", v[0].Id)
+	})
+
+	t.Run("builder", func(t *testing.T) {
+		var v []struct{ Id string }
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			query := provider.NewBuilder().Select("id").From("tests").Where("id = ?", "all:1234")
+			return tx.All(query, &v)
+		}))
+		assert.Equal(t, "all:1234", v[0].Id)
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		t.Run("bad cache value", func(t *testing.T) {
+			var v []struct{ Id string }
+			assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+				_ = tx.All(spec("SELECT id FROM tests WHERE id = 'all:1234'"), &v, QueryTTL(time.Minute))
+				wait(tx.store)
+				return tx.All(spec("SELECT id FROM tests WHERE id = 'all:1234'"), func() {}, QueryTTL(time.Minute))
+			}))
+		})
+
+		t.Run("ok", func(t *testing.T) {
+			var v []struct{ Id string }
+			assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+				_ = tx.All(spec("SELECT id FROM tests WHERE id = 'all:1234'"), &v, QueryTTL(time.Minute))
+				return tx.All(spec("SELECT id FROM tests WHERE id = 'all:1234'"), &v, QueryTTL(time.Minute))
+			}))
+			assert.Equal(t, "all:1234", v[0].Id)
+		})
+	})
+}
+
+func TestTxn_Invalidate(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	s := NewStore(store.db)
+	query := spec("SELECT id FROM tests WHERE id LIKE 'invalidate:%' ORDER BY id")
+	list := func(tx Txn) []string {
+		var ids []string
+		_ = tx.All(query, &ids, QueryTTL(time.Minute), WithCacheKey("invalidate"))
+		wait(tx.store)
+		return ids
+	}
+
+	t.Run("by key", func(t *testing.T) {
+		assert.Nil(t, s.Do(context.TODO(), func(tx Txn) error {
+			assert.Empty(t, list(tx))
+			assert.Nil(t, tx.Add("tests", map[string]interface{}{"id": "invalidate:1"}))
+			assert.Empty(t, list(tx))
+
+			tx.Invalidate("invalidate")
+			assert.Equal(t, []string{"invalidate:1"}, list(tx))
+			return nil
+		}))
+	})
+
+	t.Run("all", func(t *testing.T) {
+		assert.Nil(t, s.Do(context.TODO(), func(tx Txn) error {
+			assert.Nil(t, tx.Add("tests", map[string]interface{}{"id": "invalidate:2"}))
+			assert.Equal(t, []string{"invalidate:1"}, list(tx))
+
+			tx.InvalidateAll()
+			assert.Equal(t, []string{"invalidate:1", "invalidate:2"}, list(tx))
+			return nil
+		}))
+	})
+}
+
+func TestStore_ExternalCache(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	cache := &mapCache{values: map[interface{}]interface{}{}}
+	s := NewStore(store.db)
+	WithExternalCache(cache)(&s.conf)
+	s.cache = timedCache{Cache: s.conf.Cache}
+
+	_ = s.Add(context.TODO(), "tests", map[string]interface{}{"id": "cache:1234"})
+	query := spec("SELECT id FROM tests WHERE id = 'cache:1234'")
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, s.Do(context.TODO(), func(tx Txn) error {
+			var ids []string
+			if err := tx.All(query, &ids, QueryTTL(time.Minute)); err != nil {
+				return err
+			}
+
+			assert.Equal(t, []string{"cache:1234"}, ids)
+			return nil
+		}))
+	}
+
+	assert.Equal(t, 1, cache.hits)
+	assert.Equal(t, 1, cache.sets)
+
+	s.Invalidate(query.Id())
+	assert.Empty(t, cache.values)
+}
+
+func TestStore_CacheTTL(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	query := spec("SELECT id FROM tests WHERE id LIKE 'ttl:%' ORDER BY id")
+	list := func(s *Store) []string {
+		var ids []string
+		_ = s.All(context.TODO(), query, &ids, QueryTTL(50*time.Millisecond))
+		wait(s)
+		return ids
+	}
+
+	t.Run("memory", func(t *testing.T) {
+		s := NewStore(store.db)
+		assert.Empty(t, list(s))
+		_ = s.Add(context.TODO(), "tests", map[string]interface{}{"id": "ttl:1"})
+		assert.Empty(t, list(s))
+
+		time.Sleep(60 * time.Millisecond)
+		assert.Equal(t, []string{"ttl:1"}, list(s))
+	})
+
+	t.Run("external", func(t *testing.T) {
+		cache := &mapCache{values: map[interface{}]interface{}{}}
+		s := NewStore(store.db)
+		s.cache = timedCache{Cache: cache}
+		assert.Equal(t, []string{"ttl:1"}, list(s))
+		_ = s.Add(context.TODO(), "tests", map[string]interface{}{"id": "ttl:2"})
+		assert.Equal(t, []string{"ttl:1"}, list(s))
+		assert.Equal(t, 1, cache.hits)
+
+		time.Sleep(60 * time.Millisecond)
+		assert.Equal(t, []string{"ttl:1", "ttl:2"}, list(s))
+		assert.Equal(t, 2, cache.sets)
+	})
+}
+
+// mapCache an external cache for tests
+type mapCache struct {
+	values map[interface{}]interface{}
+	hits   int
+	sets   int
+}
+
+func (c *mapCache) Get(key interface{}) (interface{}, bool) {
+	v, present := c.values[key]
+	if present {
+		c.hits += 1
+	}
+
+	return v, present
+}
+
+func (c *mapCache) Set(key, v interface{}, _ time.Duration) {
+	c.sets += 1
+	c.values[key] = v
+}
+
+func (c *mapCache) Delete(keys ...interface{}) {
+	for _, key := range keys {
+		delete(c.values, key)
+	}
+}
+
+func (c *mapCache) Clear() {
+	c.values = map[interface{}]interface{}{}
+}
+
+// wait for in-process cache writes to be applied
+func wait(s *Store) {
+	if c, ok := s.cache.(memoryCache); ok {
+		c.Wait()
+	}
+}
+
+func TestStore_Subscribe(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("not supported", func(t *testing.T) {
+		s := NewStore(&retryProvider{})
+		_, err := s.Subscribe(context.TODO(), "tests", func(string) {})
+		assert.NotNil(t, err)
+		assert.NotNil(t, s.Notify(context.TODO(), "tests", "foo"))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		payloads := make(chan string, 1)
+		cancel, err := store.Subscribe(context.TODO(), "tests", func(payload string) {
+			payloads <- payload
+		})
+		assert.Nil(t, err)
+		defer cancel()
+
+		assert.Nil(t, store.Notify(context.TODO(), "tests", "foo"))
+		select {
+		case payload := <-payloads:
+			assert.Equal(t, "foo", payload)
+		case <-time.After(100 * time.Millisecond):
+			t.Fatal("notification was not delivered")
+		}
+	})
+}
+
+func TestStore_CreateIndexConcurrently(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("not supported", func(t *testing.T) {
+		assert.NotNil(t, NewStore(&healthProvider{}).CreateIndexConcurrently(context.TODO(), "", "tests", "idx_tests_num", "num", nil))
+	})
+
+	t.Run("within a transaction", func(t *testing.T) {
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return store.CreateIndexConcurrently(tx.Context(), "", "tests", "idx_tests_num", "num", nil)
+		}))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, store.CreateIndexConcurrently(context.TODO(), "", "tests", "idx_tests_num", "num", nil))
+
+		var n int
+		assert.Nil(t, store.One(context.TODO(), spec("SELECT count(*) FROM pg_indexes WHERE tablename = 'tests' AND indexname = 'idx_tests_num'"), &n))
+		assert.Equal(t, 1, n)
+	})
+}
+
+func TestStore_RefreshMaterializedView(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	assert.Nil(t, store.ExecRaw(context.TODO(), "CREATE TABLE refreshed (id text primary key, name text)"))
+	assert.Nil(t, store.ExecRaw(context.TODO(), "CREATE MATERIALIZED VIEW refreshed_names AS SELECT id, name FROM refreshed"))
+	assert.Nil(t, store.ExecRaw(context.TODO(), "CREATE UNIQUE INDEX idx_refreshed_names_id ON refreshed_names (id)"))
+
+	count := func(s *Store) int {
+		var n int
+		assert.Nil(t, s.One(context.TODO(), spec("SELECT count(*) FROM refreshed_names"), &n))
+		return n
+	}
+
+	t.Run("not supported", func(t *testing.T) {
+		assert.NotNil(t, NewStore(&healthProvider{}).RefreshMaterializedView(context.TODO(), "refreshed_names", false))
+	})
+
+	t.Run("within a transaction", func(t *testing.T) {
+		err := store.Do(context.TODO(), func(tx Txn) error {
+			return store.RefreshMaterializedView(tx.Context(), "refreshed_names", false)
+		})
+		assert.True(t, trail.IsBadRequest(err))
+	})
+
+	t.Run("non-postgres auto refresh", func(t *testing.T) {
+		_, err := New(WithInMemory(), WithAutoRefresh("refreshed_names", time.Second))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, store.Add(context.TODO(), "refreshed", map[string]interface{}{"id": "refresh:1", "name": "foo"}))
+		assert.Equal(t, 0, count(store))
+
+		assert.Nil(t, store.RefreshMaterializedView(context.TODO(), "refreshed_names", false))
+		assert.Equal(t, 1, count(store))
+	})
+
+	t.Run("auto refresh", func(t *testing.T) {
+		s, err := New(WithDSN(dsn), WithAutoRefresh("refreshed_names", 10*time.Millisecond))
+		assert.Nil(t, err)
+
+		assert.Nil(t, s.Add(context.TODO(), "refreshed", map[string]interface{}{"id": "refresh:2", "name": "bar"}))
+		assert.Eventually(t, func() bool { return count(s) == 2 }, time.Second, 10*time.Millisecond)
+
+		s.Close()
+		time.Sleep(50 * time.Millisecond)
+		assert.Nil(t, s.Add(context.TODO(), "refreshed", map[string]interface{}{"id": "refresh:3", "name": "baz"}))
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, 2, count(s))
+	})
+}
+
+func TestTxn_AllWithLock(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	assert.Nil(t, store.Add(context.TODO(), "tests", map[string]interface{}{"id": "lock:1", "num": 0}))
+
+	t.Run("serializes", func(t *testing.T) {
+		var active, overlaps int32
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				errs <- store.Do(context.TODO(), func(tx Txn) error {
+					var values []struct {
+						Id  string `db:"id"`
+						Num int    `db:"num"`
+					}
+
+					if err := tx.AllWithLock(spec("SELECT id, num FROM tests WHERE id = 'lock:1'"), &values, 4345); err != nil {
+						return err
+					}
+
+					if atomic.AddInt32(&active, 1) > 1 {
+						atomic.AddInt32(&overlaps, 1)
+					}
+
+					time.Sleep(50 * time.Millisecond)
+					atomic.AddInt32(&active, -1)
+					return tx.Edit("tests", spec("id = 'lock:1'"), map[string]interface{}{"num": values[0].Num + 1})
+				})
+			}()
+		}
+
+		assert.Nil(t, <-errs)
+		assert.Nil(t, <-errs)
+		assert.Equal(t, int32(0), overlaps)
+
+		var num int
+		assert.Nil(t, store.One(context.TODO(), spec("SELECT num FROM tests WHERE id = 'lock:1'"), &num))
+		assert.Equal(t, 2, num)
+	})
+}
+
+func TestTxn_AdvisoryLock(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("serializes", func(t *testing.T) {
+		var active, overlaps int32
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				errs <- store.Do(context.TODO(), func(tx Txn) error {
+					if err := tx.AdvisoryLock(4343); err != nil {
+						return err
+					}
+
+					if atomic.AddInt32(&active, 1) > 1 {
+						atomic.AddInt32(&overlaps, 1)
+					}
+
+					time.Sleep(50 * time.Millisecond)
+					atomic.AddInt32(&active, -1)
+					return nil
+				})
+			}()
+		}
+
+		assert.Nil(t, <-errs)
+		assert.Nil(t, <-errs)
+		assert.Equal(t, int32(0), overlaps)
+	})
+
+	t.Run("try lock", func(t *testing.T) {
+		locked := make(chan struct{})
+		release := make(chan struct{})
+		go func() {
+			_ = store.Do(context.TODO(), func(tx Txn) error {
+				ok, err := tx.AdvisoryTryLock(4344)
+				assert.True(t, ok)
+				close(locked)
+				<-release
+				return err
+			})
+		}()
+
+		<-locked
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			ok, err := tx.AdvisoryTryLock(4344)
+			assert.False(t, ok)
+			return err
+		}))
+		close(release)
+	})
+
+	t.Run("session", func(t *testing.T) {
+		assert.NotNil(t, NewStore(&retryProvider{}).SessionAdvisoryLock(context.TODO(), 4345))
+		assert.NotNil(t, NewStore(&retryProvider{}).SessionAdvisoryUnlock(context.TODO(), 4345))
+		assert.Nil(t, store.SessionAdvisoryLock(context.TODO(), 4345))
+		assert.Nil(t, store.SessionAdvisoryUnlock(context.TODO(), 4345))
+	})
+}
+
+func TestTxn_OneForUpdate(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	_ = store.Add(context.TODO(), "tests", map[string]interface{}{"id": "lock:1", "num": 0})
+	_ = store.Add(context.TODO(), "tests", map[string]interface{}{"id": "skip:1"})
+	_ = store.Add(context.TODO(), "tests", map[string]interface{}{"id": "skip:2"})
+
+	t.Run("read only", func(t *testing.T) {
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			var id string
+			return tx.OneForUpdate(provider.NewBuilder().Select("id").From("tests").Where("id = ?", "lock:1"), &id)
+		}, provider.WithReadOnly(true)))
+	})
+
+	t.Run("query unchanged", func(t *testing.T) {
+		query := provider.NewBuilder().Select("num").From("tests").Where("id = ?", "lock:1")
+		before, _, _ := query.ToSql()
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			var num int
+			return tx.OneForUpdate(query, &num)
+		}))
+
+		after, _, _ := query.ToSql()
+		assert.Equal(t, before, after)
+		assert.NotContains(t, after, "FOR UPDATE")
+	})
+
+	t.Run("serializes", func(t *testing.T) {
+		query := func() *provider.Builder {
+			return provider.NewBuilder().Select("num").From("tests").Where("id = ?", "lock:1")
+		}
+
+		locked := make(chan struct{})
+		errs := make(chan error, 1)
+		go func() {
+			errs <- store.Do(context.TODO(), func(tx Txn) error {
+				var num int
+				if err := tx.OneForUpdate(query(), &num); err != nil {
+					return err
+				}
+
+				close(locked)
+				time.Sleep(50 * time.Millisecond)
+				return tx.Edit("tests", spec("id = 'lock:1'"), map[string]interface{}{"num": num + 1})
+			})
+		}()
+
+		<-locked
+		var num int
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.OneForUpdate(query(), &num)
+		}))
+		assert.Nil(t, <-errs)
+		assert.Equal(t, 1, num)
+	})
+
+	t.Run("skip locked", func(t *testing.T) {
+		query := func() *provider.Builder {
+			return provider.NewBuilder().Select("id").From("tests").Where("id LIKE ?", "skip:%").OrderBy("id", false).Limit(1).SkipLocked()
+		}
+
+		locked := make(chan struct{})
+		release := make(chan struct{})
+		go func() {
+			_ = store.Do(context.TODO(), func(tx Txn) error {
+				var id string
+				err := tx.OneForUpdate(query(), &id)
+				assert.Equal(t, "skip:1", id)
+				close(locked)
+				<-release
+				return err
+			})
+		}()
+
+		<-locked
+		var id string
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.OneForUpdate(query(), &id)
+		}))
+		close(release)
+		assert.Equal(t, "skip:2", id)
+	})
+
+	t.Run("share lock", func(t *testing.T) {
+		_ = store.Add(context.TODO(), "tests", map[string]interface{}{"id": "share:1", "num": 0})
+		query := func() *provider.Builder {
+			return provider.NewBuilder().Select("num").From("tests").Where("id = ?", "share:1").ForShare()
+		}
+
+		// both readers hold the shared lock at once
+		var locked sync.WaitGroup
+		locked.Add(2)
+		release := make(chan struct{})
+		readers := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				readers <- store.Do(context.TODO(), func(tx Txn) error {
+					var num int
+					if err := tx.One(query(), &num); err != nil {
+						return err
+					}
+
+					locked.Done()
+					<-release
+					return nil
+				})
+			}()
+		}
+
+		locked.Wait()
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			var num int
+			return tx.One(provider.NewBuilder().Select("num").From("tests").Where("id = ?", "share:1").NoWait(), &num)
+		}))
+
+		written := make(chan error, 1)
+		go func() {
+			written <- store.Edit(context.TODO(), "tests", spec("id = 'share:1'"), map[string]interface{}{"num": 1})
+		}()
+
+		select {
+		case <-written:
+			t.Fatal("the writer was not blocked by the shared locks")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		close(release)
+		assert.Nil(t, <-readers)
+		assert.Nil(t, <-readers)
+		assert.Nil(t, <-written)
+	})
+}
+
+func TestTxn_Explain(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	query := provider.NewBuilder().Select("id").From("tests").Where("name = ?", "explain")
+	t.Run("bad query", func(t *testing.T) {
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			_, err := tx.Explain(spec("SELECT bad FROM"))
+			return err
+		}))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			plan, err := tx.Explain(query)
+			var v []map[string]interface{}
+			assert.Nil(t, json.Unmarshal([]byte(plan), &v))
+			assert.NotEmpty(t, v)
+			return err
+		}))
+	})
+
+	t.Run("budget", func(t *testing.T) {
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.ExplainBudget(1e6, query)
+		}))
+
+		err := store.Do(context.TODO(), func(tx Txn) error {
+			return tx.ExplainBudget(0, query)
+		})
+		assert.True(t, errors.Is(err, ErrQueryPlanExceeded))
+	})
+}
+
+func TestTxn_AllJSON(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	_ = store.Add(context.TODO(), "tests", map[string]interface{}{"id": "json:1234", "data": `{"metadata": {"user": {"id": "foo", "age": 30}}}`})
+	_ = store.Add(context.TODO(), "tests", map[string]interface{}{"id": "json:12345", "data": `{"metadata": {"user": {"id": "bar", "age": 20}}}`})
+
+	t.Run("ok", func(t *testing.T) {
+		var ids []string
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			query := provider.NewBuilder().Select("id").From("tests").
+				WhereJSON("data", "metadata.user.id", "->>", "foo").
+				WhereJSON("data", "metadata.user.age", ">", 25).
+				WhereJSON("data", "metadata", "?", "user").
+				WhereJSON("data", "", "@>", map[string]interface{}{"metadata": map[string]interface{}{"user": map[string]interface{}{"id": "foo"}}})
+			return tx.All(query, &ids)
+		}))
+		assert.Equal(t, []string{"json:1234"}, ids)
+	})
+}
+
+func TestTxn_AllFullText(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	_ = store.Add(context.TODO(), "tests", map[string]interface{}{"id": "fulltext:1", "name": "a quick brown fox"})
+	_ = store.Add(context.TODO(), "tests", map[string]interface{}{"id": "fulltext:2", "name": "the fox jumped over the fox den near a fox"})
+	_ = store.Add(context.TODO(), "tests", map[string]interface{}{"id": "fulltext:3", "name": "a lazy dog"})
+
+	t.Run("ok", func(t *testing.T) {
+		var ids []string
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			query := provider.NewBuilder().Select("id").From("tests").
+				Where("id LIKE ?", "fulltext:%").
+				WhereFullText("name", "foxes", "").
+				WithTSRank()
+			return tx.All(query, &ids)
+		}))
+		assert.Equal(t, []string{"fulltext:2", "fulltext:1"}, ids)
+	})
+}
+
+func TestTxn_AllByIds(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	var ids []interface{}
+	var rows [][]interface{}
+	for i := 0; i < 500; i++ {
+		id := fmt.Sprintf("ids:%03d", i)
+		ids = append([]interface{}{id}, ids...)
+		rows = append(rows, []interface{}{id, "foo"})
+	}
+
+	_, err := store.CopyFrom(context.TODO(), "tests", []string{"id", "name"}, rows)
+	assert.Nil(t, err)
+
+	t.Run("no ids", func(t *testing.T) {
+		var values []map[string]interface{}
+		err := store.Do(context.TODO(), func(tx Txn) error {
+			return tx.AllByIds("tests", "id", []string{}, &values)
+		})
+		assert.True(t, trail.IsError(err, ErrNoResults))
+	})
+
+	t.Run("bad ids", func(t *testing.T) {
+		var values []map[string]interface{}
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.AllByIds("tests", "id", "ids:000", &values)
+		}))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		logger := queryCounter{}
+		s, err := New(WithDSN(dsn), WithPg(pg.WithQueryLogger(&logger, 0)))
+		assert.Nil(t, err)
+
+		var values []map[string]interface{}
+		assert.Nil(t, s.AllByIds(context.TODO(), "tests", "id", ids, &values))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&logger.n))
+		assert.Len(t, values, 500)
+		assert.Equal(t, "ids:499", values[0]["id"])
+		assert.Equal(t, "ids:000", values[499]["id"])
+	})
+}
+
+func TestTxn_Refresh(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	assert.Nil(t, store.ExecRaw(context.TODO(), "CREATE TABLE refreshes (id text primary key, name text, num int DEFAULT 42, created_at timestamptz DEFAULT now())"))
+
+	type value struct {
+		Id        string    `db:"id,pk"`
+		Name      string    `db:"name"`
+		Num       int       `db:"num,omitempty"`
+		CreatedAt time.Time `db:"created_at,omitempty"`
+	}
+
+	t.Run("no primary key", func(t *testing.T) {
+		v := struct {
+			Id string `db:"id"`
+		}{Id: "refresh:1"}
+
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.Refresh("refreshes", &v)
+		}))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		v := value{Id: "refresh:missing"}
+		err := store.Do(context.TODO(), func(tx Txn) error {
+			return tx.Refresh("refreshes", &v)
+		})
+		assert.True(t, trail.IsError(err, ErrNoResults))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		v := value{Id: "refresh:1", Name: "foo"}
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			if err := tx.Add("refreshes", v); err != nil {
+				return err
+			}
+
+			return tx.Refresh("refreshes", &v)
+		}))
+		assert.Equal(t, "foo", v.Name)
+		assert.Equal(t, 42, v.Num)
+		assert.False(t, v.CreatedAt.IsZero())
+	})
+
+	t.Run("custom tag", func(t *testing.T) {
+		s, err := New(WithDSN(dsn), WithPrimaryKeyTag("key"))
+		assert.Nil(t, err)
+
+		v := struct {
+			Id        string    `db:"id,key"`
+			Name      string    `db:"name"`
+			Num       int       `db:"num"`
+			CreatedAt time.Time `db:"created_at"`
+		}{Id: "refresh:1"}
+		assert.Nil(t, s.Refresh(context.TODO(), "refreshes", &v))
+		assert.Equal(t, 42, v.Num)
+	})
+}
+
+func TestTxn_AllUnion(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	tables := []string{"union_1", "union_2", "public.union_3"}
+	for i, table := range tables {
+		assert.Nil(t, store.ExecRaw(context.TODO(), fmt.Sprintf("CREATE TABLE %s (id text primary key, name text)", table)))
+		for j := 0; j <= i+1; j++ {
+			assert.Nil(t, store.Add(context.TODO(), table, map[string]interface{}{"id": fmt.Sprintf("%s:%d", table, j), "name": fmt.Sprintf("name:%d", j)}))
+		}
+	}
+
+	assert.Nil(t, store.ExecRaw(context.TODO(), "CREATE TABLE union_other (id text primary key, num int)"))
+
+	type value struct {
+		Id   string `db:"id"`
+		Name string `db:"name"`
+	}
+
+	t.Run("missing table", func(t *testing.T) {
+		var values []value
+		err := store.Do(context.TODO(), func(tx Txn) error {
+			return tx.AllUnion([]string{"union_1", "union_missing"}, provider.NewBuilder().Select("*"), &values)
+		})
+		assert.True(t, errors.Is(err, ErrTableNotFound))
+	})
+
+	t.Run("different columns", func(t *testing.T) {
+		var values []value
+		err := store.Do(context.TODO(), func(tx Txn) error {
+			return tx.AllUnion([]string{"union_1", "union_other"}, provider.NewBuilder().Select("*"), &values)
+		})
+		assert.True(t, trail.IsBadRequest(err))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		var values []value
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.AllUnion(tables, provider.NewBuilder().Select("*"), &values)
+		}))
+		assert.Len(t, values, 2+3+4)
+
+		values = nil
+		assert.Nil(t, store.AllUnion(context.TODO(), tables, provider.NewBuilder().Select("*").Where("name = ?", "name:0"), &values))
+		assert.Len(t, values, 3)
+	})
+}
+
+func TestTxn_AggregateScan(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	assert.Nil(t, store.ExecRaw(context.TODO(), "CREATE TABLE amounts (id int primary key, kind text, amount int)"))
+	_, err := store.CopyFrom(context.TODO(), "amounts", []string{"id", "kind", "amount"}, [][]interface{}{
+		{1, "a", 10}, {2, "a", 20}, {3, "b", 20}, {4, "b", 30}, {5, "c", 70},
+	})
+	assert.Nil(t, err)
+
+	aggs := []provider.Aggregate{
+		{Func: "SUM", Column: "amount", Alias: "total"},
+		{Func: "AVG", Column: "amount", Alias: "average"},
+		{Func: "MIN", Column: "amount", Alias: "least"},
+		{Func: "MAX", Column: "amount", Alias: "greatest"},
+		{Func: "COUNT", Column: "amount", Alias: "amounts", Distinct: true},
+	}
+
+	type value struct {
+		Total    int64   `db:"total"`
+		Average  float64 `db:"average"`
+		Least    int     `db:"least"`
+		Greatest int     `db:"greatest"`
+		Amounts  int64   `db:"amounts"`
+	}
+
+	t.Run("no aggregates", func(t *testing.T) {
+		var v value
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.AggregateScan(provider.NewBuilder().From("amounts"), nil, &v)
+		}))
+	})
+
+	t.Run("bad aggregate", func(t *testing.T) {
+		var v value
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.AggregateScan(provider.NewBuilder().From("amounts"), []provider.Aggregate{{Func: "pg_sleep", Column: "amount", Alias: "total"}}, &v)
+		}))
+	})
+
 	t.Run("ok", func(t *testing.T) {
-		store, _ := New(WithDSN(dsn), WithMigration(nil), WithPg())
-		assert.NotNil(t, store)
+		var v value
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.AggregateScan(provider.NewBuilder().From("amounts"), aggs, &v)
+		}))
+		assert.Equal(t, value{Total: 150, Average: 30, Least: 10, Greatest: 70, Amounts: 4}, v)
+
+		v = value{}
+		assert.Nil(t, store.AggregateScan(context.TODO(), provider.NewBuilder().From("amounts").Where("kind = ?", "b"), aggs, &v))
+		assert.Equal(t, value{Total: 50, Average: 25, Least: 20, Greatest: 30, Amounts: 2}, v)
+	})
+
+	t.Run("grouped", func(t *testing.T) {
+		var values []struct {
+			Kind  string `db:"kind"`
+			Total int64  `db:"total"`
+		}
+
+		query := provider.NewBuilder().Select("kind").From("amounts").GroupBy("kind").OrderBy("kind", false)
+		assert.Nil(t, store.All(context.TODO(), query.Aggregate(aggs[0]), &values))
+		assert.Len(t, values, 3)
+		assert.Equal(t, int64(30), values[0].Total)
 	})
 }
 
-func TestStore_Do(t *testing.T) {
+func TestTxn_AllWhere(t *testing.T) {
 	trail.Testing()
 	t.Parallel()
 
-	t.Run("bad context", func(t *testing.T) {
-		ctx, cancel := context.WithTimeout(context.TODO(), 1)
-		defer cancel()
-		assert.NotNil(t, store.Do(ctx, nil))
+	var ids []uuid.UUID
+	for i := 0; i < 3; i++ {
+		var id uuid.UUID
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.Add("tokens", map[string]interface{}{"name": "where"}, WithReturning(&id, "id"))
+		}))
+		ids = append(ids, id)
+	}
+
+	t.Run("no values", func(t *testing.T) {
+		var values []map[string]interface{}
+		err := store.Do(context.TODO(), func(tx Txn) error {
+			return tx.AllWhere("tokens", "id", []uuid.UUID{}, &values)
+		})
+		assert.True(t, trail.IsError(err, ErrNoResults))
 	})
 
-	t.Run("bad callback response", func(t *testing.T) {
+	t.Run("bad values", func(t *testing.T) {
+		var values []map[string]interface{}
 		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
-			return trail.NewError("")
+			return tx.AllWhere("tokens", "id", ids[0], &values)
 		}))
 	})
 
-	t.Run("ok", func(t *testing.T) {
+	t.Run("uuid", func(t *testing.T) {
+		var values []struct {
+			Id   uuid.UUID `db:"id"`
+			Name string    `db:"name"`
+		}
+
 		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
-			return store.Do(tx.Context(), func(tx Txn) error {
-				return nil
-			})
+			return tx.AllWhere("tokens", "id", ids[:2], &values)
 		}))
+		assert.Len(t, values, 2)
+		assert.ElementsMatch(t, ids[:2], []uuid.UUID{values[0].Id, values[1].Id})
+		assert.Equal(t, "where", values[0].Name)
+	})
+
+	t.Run("text", func(t *testing.T) {
+		assert.Nil(t, store.Add(context.TODO(), "tests", map[string]interface{}{"id": "where:1", "name": "where"}))
+		assert.Nil(t, store.Add(context.TODO(), "tests", map[string]interface{}{"id": "where:2", "name": "where"}))
+
+		var values []map[string]interface{}
+		assert.Nil(t, store.AllWhere(context.TODO(), "tests", "id", []interface{}{"where:1", "where:2", "where:3"}, &values))
+		assert.Len(t, values, 2)
+
+		values = nil
+		assert.Nil(t, store.AllWhere(context.TODO(), "tests", "id", []string{"where:2"}, &values))
+		assert.Len(t, values, 1)
+		assert.Equal(t, "where:2", values[0]["id"])
+	})
+
+	t.Run("int", func(t *testing.T) {
+		assert.Nil(t, store.Add(context.TODO(), "tests", map[string]interface{}{"id": "where:int", "num": 4321}))
+
+		var values []map[string]interface{}
+		assert.Nil(t, store.AllWhere(context.TODO(), "tests", "num", []int64{4321, 4322}, &values))
+		assert.Len(t, values, 1)
+		assert.Equal(t, "where:int", values[0]["id"])
 	})
 }
 
-func TestTxn_Add(t *testing.T) {
+func TestTypedSlice(t *testing.T) {
 	trail.Testing()
 	t.Parallel()
 
+	t.Run("not a slice", func(t *testing.T) {
+		_, err := typedSlice(1)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("nil value", func(t *testing.T) {
+		_, err := typedSlice([]interface{}{nil})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("mixed types", func(t *testing.T) {
+		_, err := typedSlice([]interface{}{1, "2"})
+		assert.NotNil(t, err)
+	})
+
 	t.Run("ok", func(t *testing.T) {
-		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
-			return tx.Add("tests", map[string]interface{}{"id": "1234"})
-		}))
+		v, err := typedSlice([]interface{}{1, 2})
+		assert.Nil(t, err)
+		assert.Equal(t, []int{1, 2}, v)
+
+		v, err = typedSlice([]string{"1"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1"}, v)
+
+		v, err = typedSlice([]interface{}{})
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{}, v)
 	})
 }
 
-func TestTxn_Edit(t *testing.T) {
+// queryCounter counts the queries logged
+type queryCounter struct {
+	n int32
+}
+
+func (c *queryCounter) LogQuery(_ context.Context, _ string, _ []interface{}, _ time.Duration, _ error) {
+	atomic.AddInt32(&c.n, 1)
+}
+
+func TestTxn_Scan(t *testing.T) {
 	trail.Testing()
 	t.Parallel()
 
-	_ = store.Do(context.TODO(), func(tx Txn) error {
-		return tx.Add("tests", map[string]interface{}{"id": "edit:1234"})
+	t.Run("bad query", func(t *testing.T) {
+		_, err := store.Scan(context.TODO(), provider.NewBuilder())
+		assert.NotNil(t, err)
 	})
 
 	t.Run("ok", func(t *testing.T) {
+		var ids []string
 		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
-			return tx.Edit("tests", spec("id = 'edit:1234'"), map[string]interface{}{"id": "edit:1234"})
+			_ = tx.Add("tests", map[string]interface{}{"id": "scan:1234"})
+			rows, err := tx.Scan(provider.NewBuilder().Select("id").From("tests").Where("id = ?", "scan:1234"))
+			if err != nil {
+				return err
+			}
+
+			defer rows.Close()
+			for rows.Next() {
+				var v struct{ Id string }
+				if err := rows.Decode(&v); err != nil {
+					return err
+				}
+
+				ids = append(ids, v.Id)
+			}
+
+			return rows.Close()
 		}))
+		assert.Equal(t, []string{"scan:1234"}, ids)
 	})
 }
 
-func TestTxn_Remove(t *testing.T) {
+func TestTxn_Count(t *testing.T) {
 	trail.Testing()
 	t.Parallel()
 
 	_ = store.Do(context.TODO(), func(tx Txn) error {
-		return tx.Add("tests", map[string]interface{}{"id": "remove:1234"})
+		batch := provider.BatchExec{}
+		batch.Exec(spec("INSERT INTO tests (id, num) SELECT 'count:' || n, n % 2 FROM generate_series(1, 10000) n"))
+		return tx.BatchExec(batch)
 	})
 
-	t.Run("ok", func(t *testing.T) {
+	t.Run("bad query", func(t *testing.T) {
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			_, err := tx.Count(provider.NewBuilder())
+			return err
+		}))
+	})
+
+	t.Run("zero rows", func(t *testing.T) {
 		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
-			return tx.Remove("tests", spec("id = 'remove:1234'"))
+			n, err := tx.Count(provider.NewBuilder().Select("id").From("tests").Where("id = ?", "count:missing"))
+			assert.Equal(t, int64(0), n)
+			return err
+		}))
+	})
+
+	t.Run("large count", func(t *testing.T) {
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			n, err := tx.Count(spec("SELECT id FROM tests WHERE id LIKE 'count:%'"))
+			assert.Equal(t, int64(10000), n)
+			return err
+		}))
+	})
+
+	t.Run("builder", func(t *testing.T) {
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			n, err := tx.Count(provider.NewBuilder().Select("id").From("tests").Where("id LIKE ?", "count:%").Where("num = ?", 1))
+			assert.Equal(t, int64(5000), n)
+			return err
 		}))
 	})
+
+	t.Run("cached", func(t *testing.T) {
+		query := provider.NewBuilder().Select("id").From("tests").Where("id = ?", "count:1")
+		n, err := store.Count(context.TODO(), query, QueryTTL(time.Second))
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), n)
+		wait(store)
+
+		n, err = store.Count(context.TODO(), query, QueryTTL(time.Second))
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), n)
+	})
 }
 
-func TestTxn_One(t *testing.T) {
+func TestTxn_Exists(t *testing.T) {
 	trail.Testing()
 	t.Parallel()
 
-	_ = store.Do(context.TODO(), func(tx Txn) error {
-		return tx.Add("tests", map[string]interface{}{"id": "one:1234"})
-	})
+	_ = store.Add(context.TODO(), "tests", map[string]interface{}{"id": "exists:1234"})
 
 	t.Run("bad query", func(t *testing.T) {
-		var v map[string]interface{}
 		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
-			return tx.One(spec("= '1234'"), &v)
+			_, err := tx.Exists(provider.NewBuilder())
+			return err
+		}))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			ok, err := tx.Exists(provider.NewBuilder().Select("id").From("tests").Where("id = ?", "exists:missing"))
+			assert.False(t, ok)
+			return err
 		}))
 	})
 
 	t.Run("ok", func(t *testing.T) {
-		var v struct{ Id string }
 		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
-			return tx.One(spec("SELECT id FROM tests WHERE id = 'one:1234'"), &v)
+			ok, err := tx.Exists(spec("SELECT id FROM tests WHERE id = 'exists:1234'"))
+			assert.True(t, ok)
+			return err
 		}))
-		assert.Equal(t, "one:1234", v.Id)
 	})
 
-	t.Run("cached", func(t *testing.T) {
-		t.Run("bad cache value", func(t *testing.T) {
-			var v struct{ Id string }
-			assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
-				_ = tx.One(spec("SELECT id FROM tests WHERE id = 'one:1234'"), &v, QueryTTL(time.Minute))
-				tx.store.cache.Wait()
-				return tx.One(spec("SELECT id FROM tests WHERE id = 'one:1234'"), func() {}, QueryTTL(time.Minute))
-			}))
-		})
+	t.Run("soft deleted", func(t *testing.T) {
+		s := NewStore(store.db)
+		WithSoftDelete("deleted_at")(&s.conf)
+		query := provider.NewBuilder().Select("id").From("tests").Where("id = ?", "exists:soft")
+		_ = s.Add(context.TODO(), "tests", map[string]interface{}{"id": "exists:soft"})
+		_ = s.Remove(context.TODO(), "tests", spec("id = 'exists:soft'"))
 
-		t.Run("ok", func(t *testing.T) {
-			var v struct{ Id string }
-			assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
-				_ = tx.One(spec("SELECT id FROM tests WHERE id = 'one:1234'"), &v, QueryTTL(time.Minute))
-				tx.store.cache.Wait()
-				return tx.One(spec("SELECT id FROM tests WHERE id = 'one:1234'"), &v, QueryTTL(time.Minute))
-			}))
-			assert.Equal(t, "one:1234", v.Id)
-		})
+		ok, err := s.Exists(context.TODO(), query)
+		assert.Nil(t, err)
+		assert.False(t, ok)
+
+		ok, err = s.Exists(context.TODO(), query, WithIncludeDeleted())
+		assert.Nil(t, err)
+		assert.True(t, ok)
 	})
 }
 
-func TestTxn_All(t *testing.T) {
+func TestTxn_Page(t *testing.T) {
 	trail.Testing()
 	t.Parallel()
 
 	_ = store.Do(context.TODO(), func(tx Txn) error {
-		return tx.Add("tests", map[string]interface{}{"id": "all:1234"})
+		for _, id := range []string{"page:1", "page:2", "page:3"} {
+			if err := tx.Add("tests", map[string]interface{}{"id": id}); err != nil {
+				return err
+			}
+		}
+
+		return nil
 	})
 
 	t.Run("bad query", func(t *testing.T) {
-		var v []map[string]interface{}
+		var v []struct{ Id string }
 		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
-			return tx.All(spec("= '1234'"), &v)
+			_, err := tx.Page(provider.NewBuilder(), &v)
+			return err
 		}))
 	})
 
-	t.Run("ok", func(t *testing.T) {
+	t.Run("missing cursor column", func(t *testing.T) {
 		var v []struct{ Id string }
-		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
-			return tx.All(spec("SELECT id FROM tests WHERE id = 'all:1234'"), &v)
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			_, err := tx.Page(provider.NewBuilder().Select("id").From("tests"), &v)
+			return err
 		}))
-		assert.Equal(t, "all:1234", v[0].Id)
 	})
 
-	t.Run("cached", func(t *testing.T) {
-		t.Run("bad cache value", func(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		var ids []string
+		cursor := ""
+		for {
 			var v []struct{ Id string }
-			assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
-				_ = tx.All(spec("SELECT id FROM tests WHERE id = 'all:1234'"), &v, QueryTTL(time.Minute))
-				tx.store.cache.Wait()
-				return tx.All(spec("SELECT id FROM tests WHERE id = 'all:1234'"), func() {}, QueryTTL(time.Minute))
-			}))
-		})
+			query := provider.NewBuilder().
+				Select("id").
+				From("tests").
+				Where("id LIKE ?", "page:%").
+				CursorColumn("id", "asc").
+				Cursor(cursor).
+				Limit(2)
 
-		t.Run("ok", func(t *testing.T) {
-			var v []struct{ Id string }
-			assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
-				_ = tx.All(spec("SELECT id FROM tests WHERE id = 'all:1234'"), &v, QueryTTL(time.Minute))
-				return tx.All(spec("SELECT id FROM tests WHERE id = 'all:1234'"), &v, QueryTTL(time.Minute))
-			}))
-			assert.Equal(t, "all:1234", v[0].Id)
-		})
+			page, err := store.Page(context.TODO(), query, &v)
+			assert.Nil(t, err)
+			for _, item := range v {
+				ids = append(ids, item.Id)
+			}
+
+			if cursor = page.NextCursor; cursor == "" {
+				break
+			}
+		}
+
+		assert.Equal(t, []string{"page:1", "page:2", "page:3"}, ids)
+	})
+}
+
+func TestTxn_AllPaginated(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	_ = store.Do(context.TODO(), func(tx Txn) error {
+		for i := 1; i <= 5; i++ {
+			if err := tx.Add("tests", map[string]interface{}{"id": fmt.Sprintf("paginated:%d", i), "name": "paginated"}); err != nil {
+				return err
+			}
+		}
+
+		return tx.Add("tests", map[string]interface{}{"id": "paginated:other", "name": "other"})
+	})
+
+	query := func() *provider.Builder {
+		return provider.NewBuilder().
+			Select("id").
+			From("tests").
+			Where("name = ?", "paginated").
+			OrderBy("id", false)
+	}
+
+	t.Run("bad page", func(t *testing.T) {
+		var v []string
+		_, err := store.AllPaginated(context.TODO(), query(), 0, 2, &v)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("bad query", func(t *testing.T) {
+		var v []string
+		_, err := store.AllPaginated(context.TODO(), provider.NewBuilder(), 1, 2, &v)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		var ids []string
+		for page := 1; page <= 3; page++ {
+			var v []string
+			res, err := store.AllPaginated(context.TODO(), query(), page, 2, &v)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(5), res.Total)
+			assert.Equal(t, 3, res.TotalPages)
+			assert.Equal(t, page, res.Page)
+			assert.Equal(t, 2, res.PageSize)
+			assert.Equal(t, &v, res.Items)
+			ids = append(ids, v...)
+		}
+
+		assert.Equal(t, []string{"paginated:1", "paginated:2", "paginated:3", "paginated:4", "paginated:5"}, ids)
+	})
+
+	t.Run("cache key", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			var v []string
+			res, err := store.AllPaginated(context.TODO(), query(), 1, 2, &v, QueryTTL(time.Minute), WithCacheKey("paginated"))
+			assert.Nil(t, err)
+			assert.Equal(t, int64(5), res.Total)
+			assert.Equal(t, []string{"paginated:1", "paginated:2"}, v)
+		}
+	})
+
+	t.Run("past the last page", func(t *testing.T) {
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			var v []string
+			res, err := tx.AllPaginated(query(), 4, 2, &v)
+			assert.Empty(t, v)
+			assert.Equal(t, int64(5), res.Total)
+			return err
+		}))
 	})
 }
 
@@ -250,7 +2534,7 @@ func TestTxn_BatchQuery(t *testing.T) {
 				batch := provider.BatchQuery{}
 				batch.All(spec("SELECT id FROM tests WHERE id = 'batch.query:1234'"), &v)
 				_ = tx.BatchQuery(batch, QueryTTL(time.Minute))
-				tx.store.cache.Wait()
+				wait(tx.store)
 
 				batch = provider.BatchQuery{}
 				batch.All(spec("SELECT id FROM tests WHERE id = 'batch.query:1234'"), func() {})
@@ -264,7 +2548,7 @@ func TestTxn_BatchQuery(t *testing.T) {
 				batch := provider.BatchQuery{}
 				batch.All(spec("SELECT id FROM tests WHERE id = 'batch.query:1234'"), &v)
 				_ = tx.BatchQuery(batch, QueryTTL(time.Minute))
-				tx.store.cache.Wait()
+				wait(tx.store)
 				return tx.BatchQuery(batch, QueryTTL(time.Minute))
 			}))
 			assert.Equal(t, "batch.query:1234", v[0].Id)
@@ -272,6 +2556,148 @@ func TestTxn_BatchQuery(t *testing.T) {
 	})
 }
 
+func TestTxn_Truncate(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	seed := func(tx Txn) error {
+		var id int
+		if err := tx.Add("orders", map[string]interface{}{"name": "foo"}, WithReturning(&id, "id")); err != nil {
+			return err
+		}
+
+		return tx.Add("order_items", map[string]interface{}{"order_id": id, "name": "bar"})
+	}
+
+	t.Run("no tables", func(t *testing.T) {
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.Truncate()
+		}))
+	})
+
+	t.Run("not permitted", func(t *testing.T) {
+		s := NewStore(remoteProvider{Provider: store.db})
+		err := s.Do(context.TODO(), func(tx Txn) error {
+			return tx.Truncate("orders")
+		})
+		assert.True(t, errors.Is(err, ErrNotPermitted))
+	})
+
+	t.Run("bad table", func(t *testing.T) {
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.Truncate("missing")
+		}))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, store.Do(context.TODO(), seed))
+		assert.Nil(t, store.Do(context.TODO(), seed))
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.Truncate("orders", "public.order_items")
+		}))
+
+		for _, table := range []string{"orders", "order_items"} {
+			count, err := store.Count(context.TODO(), provider.NewBuilder().Select("id").From(table))
+			assert.Nil(t, err)
+			assert.Equal(t, int64(0), count)
+		}
+
+		assert.Nil(t, store.Do(context.TODO(), seed))
+		var id int
+		assert.Nil(t, store.One(context.TODO(), provider.NewBuilder().Select("id").From("orders"), &id))
+		assert.Equal(t, 1, id)
+	})
+
+	t.Run("force delete", func(t *testing.T) {
+		s := NewStore(remoteProvider{Provider: store.db})
+		WithForceDelete()(&s.conf)
+		assert.Nil(t, s.Do(context.TODO(), func(tx Txn) error {
+			return tx.Truncate("order_items")
+		}))
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		s, err := New(WithInMemory())
+		assert.Nil(t, err)
+		assert.NotNil(t, s.Truncate(context.TODO(), "tests"))
+	})
+
+	t.Run("mysql", func(t *testing.T) {
+		stmts, err := truncateStmts("mysql", []string{"orders", "db.order`items"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"TRUNCATE TABLE `orders`", "TRUNCATE TABLE `db`.`order``items`"}, stmts)
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		stmts, err := truncateStmts("postgres", []string{"orders", "public.order_items"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{`TRUNCATE "orders", "public"."order_items" RESTART IDENTITY CASCADE`}, stmts)
+	})
+}
+
+// remoteProvider a provider for a database which is not local
+type remoteProvider struct {
+	provider.Provider
+}
+
+func (p remoteProvider) IsLocal() bool {
+	return false
+}
+
+func TestTxn_ExecRaw(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+		return tx.ExecRaw("INSERT INTO tests (id, name) VALUES ($1, $2 || ' $1 ?')", "raw:1234", "foo")
+	}))
+
+	var name string
+	assert.Nil(t, store.One(context.TODO(), spec("SELECT name FROM tests WHERE id = 'raw:1234'"), &name))
+	assert.Equal(t, "foo $1 ?", name)
+}
+
+func TestTxn_CopyFrom(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			n, err := tx.CopyFrom("tests", []string{"id", "name"}, [][]interface{}{{"copy:1", "foo"}, {"copy:2", "bar"}})
+			assert.Equal(t, int64(2), n)
+			return err
+		}))
+	})
+}
+
+func TestTxn_BatchExec(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("bad query", func(t *testing.T) {
+		assert.NotNil(t, store.Do(context.TODO(), func(tx Txn) error {
+			batch := provider.BatchExec{}
+			batch.Exec(spec("= '1234'"))
+			return tx.BatchExec(batch)
+		}))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		var v struct{ Id string }
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			batch := provider.BatchExec{}
+			batch.Exec(spec("INSERT INTO tests (id) VALUES ('batch.exec:1234')"))
+			batch.Exec(spec("UPDATE tests SET num = 1 WHERE id = 'batch.exec:1234'"))
+			if err := tx.BatchExec(batch); err != nil {
+				return err
+			}
+
+			return tx.One(spec("SELECT id FROM tests WHERE id = 'batch.exec:1234'"), &v)
+		}))
+		assert.Equal(t, "batch.exec:1234", v.Id)
+	})
+}
+
 type spec string
 
 func (s spec) Id() interface{} {
@@ -281,3 +2707,30 @@ func (s spec) Id() interface{} {
 func (s spec) ToSql() (string, []interface{}, error) {
 	return string(s), nil, nil
 }
+
+func TestStore_CreatePartition(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	assert.Nil(t, store.ExecRaw(context.TODO(), "CREATE TABLE measurements (id text, taken_at date not null) PARTITION BY RANGE (taken_at)"))
+
+	t.Run("not supported", func(t *testing.T) {
+		assert.NotNil(t, NewStore(&healthProvider{}).CreatePartition(context.TODO(), "measurements", "measurements_2022", "DEFAULT"))
+	})
+
+	t.Run("within a transaction", func(t *testing.T) {
+		err := store.Do(context.TODO(), func(tx Txn) error {
+			return store.CreatePartition(tx.Context(), "measurements", "measurements_2022", "DEFAULT")
+		})
+		assert.True(t, trail.IsBadRequest(err))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		assert.Nil(t, store.CreatePartition(context.TODO(), "measurements", "measurements_2022", "FOR VALUES FROM ('2022-01-01') TO ('2023-01-01')"))
+		assert.Nil(t, store.Add(context.TODO(), "measurements", map[string]interface{}{"id": "measurement:1", "taken_at": "2022-06-01"}))
+
+		var n int
+		assert.Nil(t, store.One(context.TODO(), spec("SELECT count(*) FROM measurements_2022"), &n))
+		assert.Equal(t, 1, n)
+	})
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pghq/go-store/provider"
+)
+
+// TestTxn begins a transaction for a test that is always rolled back, never committed
+// the rollback is registered with t.Cleanup, and can be run earlier by calling the returned func
+func TestTxn(t testing.TB, s *Store) (Txn, func()) {
+	t.Helper()
+
+	tx, err := s.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("failed to begin test transaction: %+v", err)
+	}
+
+	rollback := func() { tx.rollback() }
+	t.Cleanup(rollback)
+	return tx, rollback
+}
+
+// SnapshotQuery retrieves the rows matching the spec and compares them to a snapshot of a previous run
+// the snapshot is written on the first run (or when updating), otherwise differences are reported with t.Errorf
+func SnapshotQuery(t testing.TB, s *Store, spec provider.Spec, opts ...SnapshotOption) []map[string]interface{} {
+	t.Helper()
+
+	conf := SnapshotConfig{
+		Path: filepath.Join("testdata", strings.ReplaceAll(t.Name(), "/", "_")+".snapshot"),
+	}
+
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	var rows []map[string]interface{}
+	if err := s.All(context.Background(), spec, &rows); err != nil {
+		t.Fatalf("failed to query snapshot rows: %+v", err)
+	}
+
+	actual, err := json.MarshalIndent(rows, "", "  ")
+	if err != nil {
+		t.Fatalf("failed to encode snapshot rows: %+v", err)
+	}
+
+	expected, err := os.ReadFile(conf.Path)
+	if conf.Update || os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(conf.Path), 0755); err != nil {
+			t.Fatalf("failed to create snapshot dir: %+v", err)
+		}
+
+		if err := os.WriteFile(conf.Path, actual, 0644); err != nil {
+			t.Fatalf("failed to write snapshot: %+v", err)
+		}
+
+		return rows
+	}
+
+	if err != nil {
+		t.Fatalf("failed to read snapshot: %+v", err)
+	}
+
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("query rows do not match snapshot %s\nexpected: %s\nactual: %s", conf.Path, expected, actual)
+	}
+
+	return rows
+}
+
+// SnapshotConfig a configuration for query snapshots
+type SnapshotConfig struct {
+	Path   string
+	Update bool
+}
+
+// SnapshotOption a query snapshot option
+type SnapshotOption func(conf *SnapshotConfig)
+
+// WithUpdateSnapshot overwrites the snapshot with the current rows
+func WithUpdateSnapshot() SnapshotOption {
+	return func(conf *SnapshotConfig) {
+		conf.Update = true
+	}
+}
+
+// WithSnapshotPath custom path for the snapshot (defaults to testdata/<test name>.snapshot)
+func WithSnapshotPath(path string) SnapshotOption {
+	return func(conf *SnapshotConfig) {
+		conf.Path = path
+	}
+}
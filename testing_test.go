@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pghq/go-store/provider"
+)
+
+func TestTestTxn(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	query := provider.NewBuilder().Select("id").From("tests").Where("id = ?", "testtxn:1234")
+
+	t.Run("cleanup", func(t *testing.T) {
+		t.Run("test", func(t *testing.T) {
+			tx, _ := TestTxn(t, store)
+			assert.Nil(t, tx.Add("tests", map[string]interface{}{"id": "testtxn:1234"}))
+
+			var id string
+			assert.Nil(t, tx.One(query, &id))
+			assert.Equal(t, "testtxn:1234", id)
+		})
+
+		var id string
+		assert.NotNil(t, store.One(context.TODO(), query, &id))
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		tx, rollback := TestTxn(t, store)
+		assert.Nil(t, tx.Add("tests", map[string]interface{}{"id": "testtxn:5678"}))
+		rollback()
+		rollback()
+
+		var id string
+		err := store.One(context.TODO(), provider.NewBuilder().Select("id").From("tests").Where("id = ?", "testtxn:5678"), &id)
+		assert.True(t, trail.IsNotFound(err))
+	})
+}
+
+func TestSnapshotQuery(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+		return tx.Add("tests", map[string]interface{}{"id": "snapshot:1234", "name": "foo", "num": 1})
+	}))
+
+	path := filepath.Join(t.TempDir(), "snapshots", "tests.snapshot")
+	query := provider.NewBuilder().Select("id", "name", "num").From("tests").Where("id = ?", "snapshot:1234")
+
+	t.Run("write", func(t *testing.T) {
+		tb := &snapshotTB{TB: t}
+		rows := SnapshotQuery(tb, store, query, WithSnapshotPath(path))
+		assert.Empty(t, tb.errors)
+		assert.Len(t, rows, 1)
+		assert.Equal(t, "foo", rows[0]["name"])
+
+		data, err := os.ReadFile(path)
+		assert.Nil(t, err)
+		assert.Contains(t, string(data), "snapshot:1234")
+	})
+
+	t.Run("match", func(t *testing.T) {
+		tb := &snapshotTB{TB: t}
+		SnapshotQuery(tb, store, query, WithSnapshotPath(path))
+		assert.Empty(t, tb.errors)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			return tx.Edit("tests", spec("id = 'snapshot:1234'"), map[string]interface{}{"name": "bar"})
+		}))
+
+		tb := &snapshotTB{TB: t}
+		SnapshotQuery(tb, store, query, WithSnapshotPath(path))
+		assert.Len(t, tb.errors, 1)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		tb := &snapshotTB{TB: t}
+		SnapshotQuery(tb, store, query, WithSnapshotPath(path), WithUpdateSnapshot())
+		SnapshotQuery(tb, store, query, WithSnapshotPath(path))
+		assert.Empty(t, tb.errors)
+	})
+}
+
+// snapshotTB records errors reported by snapshot comparisons instead of failing the test
+type snapshotTB struct {
+	testing.TB
+	errors []string
+}
+
+func (tb *snapshotTB) Errorf(format string, args ...interface{}) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/pghq/go-tea/trail"
+
+	"github.com/pghq/go-store/provider"
+)
+
+// timeoutRepository a repository limiting the duration of each query
+type timeoutRepository struct {
+	provider.Repository
+	timeout time.Duration
+}
+
+// context limits the context to the timeout, unless its deadline is sooner
+func (r timeoutRepository) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= r.timeout {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, r.timeout)
+}
+
+func (r timeoutRepository) One(ctx context.Context, spec provider.Spec, v interface{}) error {
+	ctx, cancel := r.context(ctx)
+	defer cancel()
+	return r.Repository.One(ctx, spec, v)
+}
+
+func (r timeoutRepository) All(ctx context.Context, spec provider.Spec, v interface{}) error {
+	ctx, cancel := r.context(ctx)
+	defer cancel()
+	return r.Repository.All(ctx, spec, v)
+}
+
+// Scan the timeout includes iterating over the rows, which are released when closed
+func (r timeoutRepository) Scan(ctx context.Context, spec provider.Spec) (provider.Rows, error) {
+	ctx, cancel := r.context(ctx)
+	rows, err := r.Repository.Scan(ctx, spec)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return timeoutRows{Rows: rows, cancel: cancel}, nil
+}
+
+func (r timeoutRepository) Add(ctx context.Context, collection string, v interface{}, opts ...provider.WriteOption) error {
+	ctx, cancel := r.context(ctx)
+	defer cancel()
+	return r.Repository.Add(ctx, collection, v, opts...)
+}
+
+func (r timeoutRepository) Edit(ctx context.Context, collection string, spec provider.Spec, v interface{}, opts ...provider.WriteOption) error {
+	ctx, cancel := r.context(ctx)
+	defer cancel()
+	return r.Repository.Edit(ctx, collection, spec, v, opts...)
+}
+
+func (r timeoutRepository) Upsert(ctx context.Context, collection string, v interface{}, conflict []string) error {
+	ctx, cancel := r.context(ctx)
+	defer cancel()
+	return r.Repository.Upsert(ctx, collection, v, conflict)
+}
+
+func (r timeoutRepository) Merge(ctx context.Context, collection string, v interface{}, match []string, insertDefaults map[string]interface{}, update []string) error {
+	m, ok := r.Repository.(provider.Merger)
+	if !ok {
+		return trail.NewErrorf("repository %T does not support merge", r.Repository)
+	}
+
+	ctx, cancel := r.context(ctx)
+	defer cancel()
+	return m.Merge(ctx, collection, v, match, insertDefaults, update)
+}
+
+func (r timeoutRepository) AddBatch(ctx context.Context, collection string, values []interface{}) error {
+	b, ok := r.Repository.(provider.BatchAdder)
+	if !ok {
+		return trail.NewErrorf("repository %T does not support batch adds", r.Repository)
+	}
+
+	ctx, cancel := r.context(ctx)
+	defer cancel()
+	return b.AddBatch(ctx, collection, values)
+}
+
+func (r timeoutRepository) Remove(ctx context.Context, collection string, spec provider.Spec, opts ...provider.WriteOption) error {
+	ctx, cancel := r.context(ctx)
+	defer cancel()
+	return r.Repository.Remove(ctx, collection, spec, opts...)
+}
+
+func (r timeoutRepository) BatchQuery(ctx context.Context, query provider.BatchQuery) error {
+	ctx, cancel := r.context(ctx)
+	defer cancel()
+	return r.Repository.BatchQuery(ctx, query)
+}
+
+func (r timeoutRepository) BatchExec(ctx context.Context, exec provider.BatchExec) error {
+	ctx, cancel := r.context(ctx)
+	defer cancel()
+	return r.Repository.BatchExec(ctx, exec)
+}
+
+func (r timeoutRepository) CopyFrom(ctx context.Context, collection string, columns []string, rows [][]interface{}) (int64, error) {
+	ctx, cancel := r.context(ctx)
+	defer cancel()
+	return r.Repository.CopyFrom(ctx, collection, columns, rows)
+}
+
+func (r timeoutRepository) ExecRaw(ctx context.Context, stmt string, args ...interface{}) error {
+	ctx, cancel := r.context(ctx)
+	defer cancel()
+	return r.Repository.ExecRaw(ctx, stmt, args...)
+}
+
+// timeoutRows rows releasing the query context when closed
+type timeoutRows struct {
+	provider.Rows
+	cancel context.CancelFunc
+}
+
+func (r timeoutRows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pghq/go-tea/trail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithQueryTimeout(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	s, err := New(WithDSN(dsn), WithQueryTimeout(10*time.Millisecond))
+	assert.Nil(t, err)
+
+	t.Run("exceeded", func(t *testing.T) {
+		err := s.ExecRaw(context.TODO(), "SELECT pg_sleep(1)")
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+		var v []string
+		err = s.All(context.TODO(), spec("SELECT pg_sleep(1)::text"), &v)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("exceeded in transaction", func(t *testing.T) {
+		err := s.Do(context.TODO(), func(tx Txn) error {
+			return tx.ExecRaw("SELECT pg_sleep(1)")
+		})
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("sooner deadline", func(t *testing.T) {
+		s, err := New(WithDSN(dsn), WithQueryTimeout(time.Minute))
+		assert.Nil(t, err)
+
+		ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+		defer cancel()
+
+		err = s.ExecRaw(ctx, "SELECT pg_sleep(1)")
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		s, err := New(WithDSN(dsn), WithQueryTimeout(time.Second))
+		assert.Nil(t, err)
+
+		var v []string
+		assert.Nil(t, s.All(context.TODO(), spec("SELECT 'ok'"), &v))
+		assert.Equal(t, []string{"ok"}, v)
+
+		rows, err := s.Scan(context.TODO(), spec("SELECT 'ok' AS v"))
+		assert.Nil(t, err)
+		defer rows.Close()
+
+		assert.True(t, rows.Next())
+		var row struct {
+			V string `db:"v"`
+		}
+		assert.Nil(t, rows.Decode(&row))
+		assert.Equal(t, "ok", row.V)
+	})
+}
+
+func TestTxn_WithTimeout(t *testing.T) {
+	trail.Testing()
+	t.Parallel()
+
+	t.Run("exceeded", func(t *testing.T) {
+		err := store.Do(context.TODO(), func(tx Txn) error {
+			timed, cancel := tx.WithTimeout(10 * time.Millisecond)
+			defer cancel()
+			return timed.ExecRaw("SELECT pg_sleep(1)")
+		})
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("transaction usable after a statement exceeds the deadline", func(t *testing.T) {
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			assert.Nil(t, tx.ExecRaw("CREATE TEMP TABLE timeout_items (id text) ON COMMIT DROP"))
+			assert.Nil(t, tx.ExecRaw("INSERT INTO timeout_items VALUES ('before')"))
+
+			timed, cancel := tx.WithTimeout(10 * time.Millisecond)
+			defer cancel()
+			_, ok := tx.Context().Deadline()
+			assert.False(t, ok)
+
+			assert.Nil(t, timed.ExecRaw("INSERT INTO timeout_items VALUES ('timed')"))
+			err := timed.ExecRaw("INSERT INTO timeout_items SELECT 'slow' FROM pg_sleep(1)")
+			assert.True(t, errors.Is(err, context.DeadlineExceeded))
+			assert.True(t, errors.Is(timed.ExecRaw("SELECT 1"), context.DeadlineExceeded))
+
+			var timeout string
+			assert.Nil(t, tx.One(spec("SELECT current_setting('statement_timeout')"), &timeout))
+			assert.Equal(t, "0", timeout)
+
+			assert.Nil(t, tx.ExecRaw("INSERT INTO timeout_items VALUES ('after')"))
+			var ids []string
+			assert.Nil(t, tx.All(spec("SELECT id FROM timeout_items ORDER BY id"), &ids))
+			assert.Equal(t, []string{"after", "before", "timed"}, ids)
+			return nil
+		}))
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		assert.Nil(t, store.Do(context.TODO(), func(tx Txn) error {
+			assert.Nil(t, tx.ExecRaw("CREATE TEMP TABLE cancel_items (id text) ON COMMIT DROP"))
+
+			timed, cancel := tx.WithTimeout(time.Minute)
+			assert.Nil(t, timed.ExecRaw("INSERT INTO cancel_items VALUES ('timed')"))
+			rows, err := timed.Scan(spec("SELECT id FROM cancel_items"))
+			assert.Nil(t, err)
+			assert.Nil(t, rows.Close())
+
+			cancel()
+			assert.True(t, errors.Is(timed.ExecRaw("SELECT 1"), context.Canceled))
+
+			// no savepoint of the copy remains open, and its statement timeout was restored
+			assert.NotNil(t, tx.Savepoint("check", func(tx Txn) error {
+				return tx.ExecRaw("RELEASE SAVEPOINT deadline")
+			}))
+
+			var timeout string
+			assert.Nil(t, tx.One(spec("SELECT current_setting('statement_timeout')"), &timeout))
+			assert.Equal(t, "0", timeout)
+
+			var ids []string
+			assert.Nil(t, tx.All(spec("SELECT id FROM cancel_items"), &ids))
+			assert.Equal(t, []string{"timed"}, ids)
+			return nil
+		}))
+	})
+}